@@ -0,0 +1,1132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/config"
+	"github.com/mbacalan/paper-mc-tui/internal/control"
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/java"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
+	"github.com/mbacalan/paper-mc-tui/internal/nagios"
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/rcon"
+	"github.com/mbacalan/paper-mc-tui/internal/report"
+	"github.com/mbacalan/paper-mc-tui/internal/safeupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/selfupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
+	"github.com/mbacalan/paper-mc-tui/internal/startscript"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+	"github.com/mbacalan/paper-mc-tui/internal/systemd"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/views"
+	"github.com/mbacalan/paper-mc-tui/internal/watch"
+)
+
+// Exit codes for the non-interactive commands. These are part of the CLI's contract
+// with scripts (cron, Ansible); do not renumber them.
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitUsage           = 2
+	exitUpdateAvailable = 10
+	exitVerifyFailed    = 11
+)
+
+// cli is what every command runs against.
+type cli struct {
+	svc      *paper.Service
+	cfg      *config.Config
+	log      *slog.Logger // the activity log
+	logFile  *logging.File
+	metrics  *metrics.Metrics
+	notifier *notify.Notifier
+	updater  *selfupdate.Updater
+	safe     *safeupdate.Updater
+	unit     *systemd.Unit
+	server   views.Server // unit when systemd manages the server, else the supervisor
+	dir      string
+	json     bool // --output json: print one report.Document instead of text
+}
+
+// command is a non-interactive subcommand. run receives the arguments after its name
+// and returns the process exit code.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, c *cli, args []string) int
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because runHelp refers back to commands.
+	commands = []command{
+		{"check", "check for a newer build (exit 10 if one is available)", runCheck},
+		{"check-nagios", "Nagios/Icinga plugin: builds behind, days since update and verification", runCheckNagios},
+		{"status", "show the installed build", runStatus},
+		{"versions", "list Paper versions with their support status and Java requirement (--all: with end-of-life ones)", runVersions},
+		{"install", "install the latest build, or --version/--build", runInstall},
+		{"backup", "move paper.jar aside to the backup name", runBackup},
+		{"rollback", "restore the backup jar", runRollback},
+		{"safe-update", "warn players, stop the server, install the latest build and start it (rolled back if it fails)", runSafeUpdate},
+		{"verify", "check paper.jar against the recorded checksum (exit 11 on mismatch)", runVerify},
+		{"start-script", "write start.sh with a heap sized to this machine and recommended JVM flags (shows a diff first)", runStartScript},
+		{"systemd-unit", "write a hardened systemd unit for the server directory (shows a diff first)", runSystemdUnit},
+		{"eula", "show whether the Minecraft EULA is accepted (--accept: accept it and write eula.txt)", runEULA},
+		{"java", "list the Java runtimes found and check the configured one against the latest build (or --version)", runJava},
+		{"rcon", "run a server console command over RCON, e.g. rcon list", runRCON},
+		{"watch", "keep running, checking for new builds (and installing them with auto_install)", runWatch},
+		{"serve", "run the local HTTP control API (needs control_token)", runServe},
+		{"config", "config show: print effective settings and where each came from", runConfig},
+		{"self-update", "replace this binary with the latest release (--check: exit 10 if one is available)", runSelfUpdate},
+		{"help", "show this help", runHelp},
+	}
+}
+
+// runCommand dispatches args[0] to its command, cancelling its context on SIGINT or
+// SIGTERM so an in-flight download stops cleanly.
+func runCommand(c *cli, args []string) int {
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return cmd.run(ctx, c, args[1:])
+		}
+	}
+	err := fmt.Errorf("unknown command %q", args[0])
+	if c.json {
+		return c.usageError(report.New(args[0]), err)
+	}
+	fmt.Fprintf(os.Stderr, "error: %v\n\n", err)
+	usage()
+	return exitUsage
+}
+
+// parse parses a command's flags, reporting a usage error in the chosen output format.
+func (c *cli) parse(fs *flag.FlagSet, doc *report.Document, args []string) (code int, ok bool) {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return c.usageError(doc, err), false
+	}
+	return 0, true
+}
+
+// printf writes human output; it is silent with --output json.
+func (c *cli) printf(format string, args ...any) {
+	if !c.json {
+		fmt.Printf(format, args...)
+	}
+}
+
+// done finishes a command with code, printing doc with --output json.
+func (c *cli) done(doc *report.Document, code int) int {
+	doc.ExitCode = code
+	doc.OK = code == exitOK || code == exitUpdateAvailable
+	if c.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			fmt.Fprintln(os.Stderr, "error: write output:", err)
+			return exitError
+		}
+	}
+	return code
+}
+
+// fail finishes a command with err, classified for --output json.
+func (c *cli) fail(doc *report.Document, err error) int {
+	doc.Error = report.FromError(err)
+	if !c.json {
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+	return c.done(doc, exitError)
+}
+
+func (c *cli) usageError(doc *report.Document, err error) int {
+	doc.Error = &report.Error{Kind: report.KindUsage, Message: err.Error()}
+	if !c.json && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "error:", err)
+	}
+	return c.done(doc, exitUsage)
+}
+
+// recoverFirst finishes or undoes an interrupted operation before a command runs, as
+// the TUI does at startup.
+func (c *cli) recoverFirst(doc *report.Document) error {
+	r, err := c.svc.Recover()
+	if err != nil {
+		return err
+	}
+	doc.Recovery = report.FromRecovery(r)
+	if r.Action != paper.RecoveryNone && !c.json {
+		fmt.Fprintln(os.Stderr, r)
+	}
+	return nil
+}
+
+// printPlan finishes a --dry-run command by showing what it would have done.
+func (c *cli) printPlan(doc *report.Document, plan paper.Plan) int {
+	doc.Plan = report.FromPlan(plan)
+	c.printf("Dry run: %s would\n", plan.Op)
+	for _, line := range plan.Lines() {
+		c.printf("  %s\n", line)
+	}
+	c.printf("Nothing was changed.\n")
+	return c.done(doc, exitOK)
+}
+
+// runHelp prints the usage text, or with --output json the list of commands.
+func runHelp(_ context.Context, c *cli, _ []string) int {
+	doc := report.New("help")
+	if !c.json {
+		usage()
+	}
+	for _, cmd := range commands {
+		doc.Commands = append(doc.Commands, report.Command{Name: cmd.name, Summary: cmd.summary})
+	}
+	return c.done(doc, exitOK)
+}
+
+func runCheck(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("check")
+	if code, ok := c.parse(flag.NewFlagSet("check", flag.ContinueOnError), doc, args); !ok {
+		return code
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+	defer cancel()
+
+	info, err := c.svc.CheckLatest(ctx)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	installed, err := c.svc.Installed()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Latest = report.FromLatest(info)
+	doc.Installed = report.FromState(installed)
+	doc.Installed.JarPresent = c.svc.JarExists()
+
+	switch {
+	case info.UpToDate:
+		c.printf("Up to date: %s build %d (%s)\n", info.Version, info.Build, info.Channel)
+		return c.done(doc, exitOK)
+	case installed.Build == 0:
+		c.printf("Update available: %s build %d (%s); nothing installed yet\n", info.Version, info.Build, info.Channel)
+	default:
+		c.printf("Update available: %s build %d (%s); installed is %s build %d\n",
+			info.Version, info.Build, info.Channel, installed.Version, installed.Build)
+	}
+	return c.done(doc, exitUpdateAvailable)
+}
+
+func runStatus(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("status")
+	if code, ok := c.parse(flag.NewFlagSet("status", flag.ContinueOnError), doc, args); !ok {
+		return code
+	}
+	st, err := c.svc.Installed()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Installed = report.FromState(st)
+	doc.Installed.JarPresent = c.svc.JarExists()
+
+	if st.Build == 0 && st.SHA256 == "" {
+		c.printf("Installed:  nothing recorded by this tool\n")
+	} else {
+		c.printf("Installed:  %s build %d (%s)\n", st.Version, st.Build, st.JarName)
+		c.printf("At:         %s\n", st.InstalledAt.Local().Format("2006-01-02 15:04:05"))
+		c.printf("SHA256:     %s\n", st.SHA256)
+	}
+	if doc.Installed.JarPresent {
+		c.printf("paper.jar:  present\n")
+	} else {
+		c.printf("paper.jar:  missing\n")
+	}
+	if err := c.logFile.Check(); err != nil {
+		doc.Installed.LogError = err.Error()
+		c.printf("Log:        not writable, events are being lost (%v)\n", err)
+	}
+	if h, err := lock.Read(c.dir); err == nil {
+		doc.Installed.LockedBy = report.FromHolder(h)
+		c.printf("Locked by:  %s\n", h)
+	}
+	if st.Version != "" {
+		// Only a note: status works offline, and says nothing when the API cannot be reached.
+		ctx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+		defer cancel()
+		if sup, err := c.svc.InstalledSupport(ctx); err == nil && sup.Status != "" {
+			doc.Installed.Support, doc.Installed.EndOfLife = string(sup.Status), sup.EndOfLife()
+			label := sup.Status.Note()
+			if label == "" {
+				label = "supported"
+			}
+			c.printf("Support:    %s\n", label)
+			if note := sup.String(); note != "" {
+				c.printf("\n%s\n", note)
+			}
+		}
+	}
+	return c.done(doc, exitOK)
+}
+
+// runVersions lists the versions Paper publishes, newest first, with their support
+// status and the Java release each needs.
+func runVersions(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("versions")
+	fs := flag.NewFlagSet("versions", flag.ContinueOnError)
+	all := fs.Bool("all", false, "include end-of-life versions")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	st, err := c.svc.Installed()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+	defer cancel()
+	versions, err := c.svc.Versions(ctx)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	if !*all {
+		versions = slices.DeleteFunc(versions, func(v papermc.VersionInfo) bool { return v.EndOfLife() && v.ID != st.Version })
+	}
+	doc.Versions = report.FromVersions(versions, st.Version)
+	for _, v := range versions {
+		mark := " "
+		if v.ID == st.Version {
+			mark = "*"
+		}
+		java := "Java ?"
+		if n := v.Java.Version.Minimum; n > 0 {
+			java = fmt.Sprintf("Java %d", n)
+		}
+		c.printf("%s\n", strings.TrimRight(fmt.Sprintf("%s %-12s %-8s %s", mark, v.ID, java, v.Support.Status.Note()), " "))
+	}
+	if st.Version != "" {
+		c.printf("(* installed)\n")
+	}
+	return c.done(doc, exitOK)
+}
+
+func runInstall(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("install")
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	version := fs.String("version", "", "install this Paper version instead of the latest")
+	build := fs.Int("build", 0, "install this build of --version (default: its latest)")
+	backup := fs.Bool("backup", c.svc.BackupPolicy() == paper.BackupAlways, "move the existing jar aside first (default from the backup policy)")
+	backupName := fs.String("backup-name", c.svc.BackupName(), "backup file name")
+	force := fs.Bool("force", false, "reinstall even if already up to date")
+	dryRun := fs.Bool("dry-run", false, "print what would be downloaded, renamed and recorded, and change nothing")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	if *build != 0 && *version == "" {
+		return c.usageError(doc, errors.New("--build requires --version"))
+	}
+	// A dry run must not touch disk, so it does not recover; planning then fails with
+	// journal.ErrPending, reported as pending_recovery, until a real command has.
+	if !*dryRun {
+		if err := c.recoverFirst(doc); err != nil {
+			return c.fail(doc, err)
+		}
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+	var (
+		info paper.LatestInfo
+		err  error
+	)
+	if *version != "" {
+		info, err = c.svc.Select(checkCtx, *version, *build)
+	} else {
+		info, err = c.svc.CheckLatest(checkCtx)
+	}
+	cancel()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Latest = report.FromLatest(info)
+	doc.Install = &report.Install{
+		Version: info.Version,
+		Build:   info.Build,
+		JarName: info.JarName,
+		SHA256:  info.Download.Checksums.SHA256,
+	}
+	if info.UpToDate && !*force {
+		c.printf("Already installed: %s build %d\n", info.Version, info.Build)
+		return c.done(doc, exitOK)
+	}
+	if (*backup || c.svc.SmokeTest()) && c.svc.JarExists() {
+		doc.Install.Backup = *backupName
+	}
+	if *dryRun {
+		plan, err := c.svc.PlanInstall(ctx, paper.InstallOptions{Backup: *backup, BackupName: *backupName})
+		if err != nil {
+			return c.fail(doc, err)
+		}
+		return c.printPlan(doc, plan)
+	}
+
+	c.printf("Installing %s build %d (%s, %.1f MB)\n", info.Version, info.Build, info.JarName, float64(info.Download.Size)/(1<<20))
+	if c.svc.SmokeTest() {
+		c.printf("The new build is then booted in a sandbox; if it does not start cleanly, the previous jar is put back.\n")
+	}
+	ctx, cancel = context.WithTimeout(ctx, c.svc.Timeouts().Download)
+	defer cancel()
+	lastTenth := int64(-1)
+	err = c.svc.Install(ctx, paper.InstallOptions{
+		Backup:     *backup,
+		BackupName: *backupName,
+		OnProgress: func(done, total int64) {
+			if total <= 0 || c.json {
+				return
+			}
+			if tenth := done * 10 / total; tenth != lastTenth {
+				lastTenth = tenth
+				fmt.Fprintf(os.Stderr, "  %3d%%\n", tenth*10)
+			}
+		},
+		OnSmokeTest: func(r smoketest.Result) {
+			doc.Install.SmokeTest = report.FromSmokeTest(r)
+		},
+	})
+	var serr *paper.SmokeTestError
+	if errors.As(err, &serr) {
+		doc.Install.SmokeTest = report.FromSmokeTestError(serr)
+		if !c.json {
+			for _, line := range serr.Result.Excerpt {
+				fmt.Fprintln(os.Stderr, "  | "+line)
+			}
+		}
+	}
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Install.Performed = true
+	c.printf("Installed and verified %s\n", info.JarName)
+	if st := doc.Install.SmokeTest; st != nil {
+		c.printf("Smoke test %s\n", smokeTestSummary(st))
+	}
+	return c.done(doc, exitOK)
+}
+
+// smokeTestSummary describes a smoke test that did not stop the install.
+func smokeTestSummary(st *report.SmokeTest) string {
+	if st.Skipped {
+		return "skipped: " + st.Reason
+	}
+	return fmt.Sprintf("passed: the new build was ready in %.1fs", st.ReadySeconds)
+}
+
+// runSafeUpdate installs the latest build under the running server: countdown, save,
+// stop, install, start, and a rollback if the new build does not come up.
+func runSafeUpdate(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("safe-update")
+	if code, ok := c.parse(flag.NewFlagSet("safe-update", flag.ContinueOnError), doc, args); !ok {
+		return code
+	}
+	if err := c.recoverFirst(doc); err != nil {
+		return c.fail(doc, err)
+	}
+	if u, ok := c.server.(*systemd.Unit); ok {
+		c.printf("The server runs under systemd; stopping and starting %s with systemctl.\n", u.Name())
+	}
+	res, err := c.safe.Run(ctx, func(_ safeupdate.Step, detail string) {
+		c.printf("%s: %s\n", time.Now().Format(time.TimeOnly), detail)
+	})
+	if res.Latest.Version != "" {
+		doc.Safe = report.FromSafeUpdate(res)
+	}
+	switch {
+	case res.RolledBack:
+		doc.Error = report.FromError(err)
+		if !c.json {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		c.printf("Rolled back: %s build %d is running again\n", res.Restored.Restored.Version, res.Restored.Restored.Build)
+		return c.done(doc, exitError)
+	case err != nil:
+		return c.fail(doc, err)
+	case res.UpToDate:
+		c.printf("Already up to date (%s build %d); the server was left running\n", res.Latest.Version, res.Latest.Build)
+	default:
+		c.printf("Updated to %s build %d; the server was ready in %s\n", res.Latest.Version, res.Latest.Build, res.Ready.Round(time.Second))
+	}
+	return c.done(doc, exitOK)
+}
+
+func runBackup(_ context.Context, c *cli, args []string) int {
+	doc := report.New("backup")
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	backupName := fs.String("backup-name", c.svc.BackupName(), "file to move the jar to")
+	dryRun := fs.Bool("dry-run", false, "print the rename, and change nothing")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	if *dryRun {
+		plan, err := c.svc.PlanBackup(*backupName)
+		if err != nil {
+			return c.fail(doc, err)
+		}
+		return c.printPlan(doc, plan)
+	}
+	if err := c.recoverFirst(doc); err != nil {
+		return c.fail(doc, err)
+	}
+
+	dest, err := c.svc.Backup(*backupName)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Backup = &report.Backup{Path: dest}
+	c.printf("Moved %s to %s\n", c.svc.JarName(), dest)
+	return c.done(doc, exitOK)
+}
+
+func runRollback(_ context.Context, c *cli, args []string) int {
+	doc := report.New("rollback")
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	backupName := fs.String("backup-name", c.svc.BackupName(), "backup file to restore")
+	dryRun := fs.Bool("dry-run", false, "print what would be renamed and recorded, and change nothing")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	if *dryRun {
+		plan, err := c.svc.PlanRollback(*backupName)
+		if err != nil {
+			return c.fail(doc, err)
+		}
+		return c.printPlan(doc, plan)
+	}
+	if err := c.recoverFirst(doc); err != nil {
+		return c.fail(doc, err)
+	}
+
+	res, err := c.svc.Rollback(*backupName)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Rollback = report.FromRollback(res)
+	if res.Known {
+		c.printf("Rolled back to %s build %d\n", res.Restored.Version, res.Restored.Build)
+	} else {
+		c.printf("Rolled back to %s (not a build this tool recorded installing)\n", res.Restored.JarName)
+	}
+	if res.MovedTo != "" {
+		c.printf("The replaced jar was kept as %s\n", res.MovedTo)
+	}
+	return c.done(doc, exitOK)
+}
+
+func runVerify(_ context.Context, c *cli, args []string) int {
+	doc := report.New("verify")
+	if code, ok := c.parse(flag.NewFlagSet("verify", flag.ContinueOnError), doc, args); !ok {
+		return code
+	}
+	res, err := c.svc.Verify()
+	switch {
+	case errors.Is(err, paper.ErrJarModified):
+		doc.Verify = report.FromVerify(res)
+		doc.Error = report.FromError(err)
+		c.printf("MISMATCH: paper.jar sha256 %s, recorded %s\n", res.Actual, res.Expected)
+		return c.done(doc, exitVerifyFailed)
+	case err != nil:
+		return c.fail(doc, err)
+	}
+	doc.Verify = report.FromVerify(res)
+	c.printf("OK: paper.jar matches the recorded sha256 %s\n", res.Expected)
+	return c.done(doc, exitOK)
+}
+
+// runRCON sends its arguments as one console command to the server over RCON, using
+// the port and password in server.properties, and prints the response. It works on any
+// server in the directory, whoever started it.
+func runRCON(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("rcon")
+	fs := flag.NewFlagSet("rcon", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", rcon.DefaultTimeout, "give up on the server after this long")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		return c.usageError(doc, errors.New(`usage: rcon [--timeout D] COMMAND [ARGS...], e.g. rcon say "Restarting in 5 minutes"`))
+	}
+	command := strings.Join(fs.Args(), " ")
+	cfg, err := rcon.FromDir(c.dir)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	out, err := rcon.Run(ctx, cfg, command)
+	log := c.log.With(logging.KeyEvent, logging.EventRCON, "addr", cfg.Addr, "command", command)
+	if err != nil {
+		log.Error("rcon command failed", logging.Err(err))
+		return c.fail(doc, err)
+	}
+	log.Info("rcon command")
+	doc.RCON = &report.RCON{Addr: cfg.Addr, Command: command, Response: out}
+	if out != "" {
+		c.printf("%s\n", strings.TrimRight(out, "\n"))
+	}
+	return c.done(doc, exitOK)
+}
+
+// runStartScript writes start.sh for running the server outside the TUI. The heap is
+// sized from /proc/meminfo unless --memory says otherwise, and the flags are the ones
+// Paper recommends for the installed version, or Aikar's when the API has none. If
+// there is a start.sh already, the diff is printed before it is replaced.
+func runStartScript(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("start-script")
+	fs := flag.NewFlagSet("start-script", flag.ContinueOnError)
+	memory := fs.String("memory", "", "heap size, e.g. 6G or 1536M (default: sized from the machine's memory)")
+	restart := fs.Bool("restart", false, "start the server again whenever it crashes")
+	restartDelay := fs.Duration("restart-delay", startscript.DefaultRestartDelay, "with --restart, how long to wait before starting it again")
+	dryRun := fs.Bool("dry-run", false, "print the diff and write nothing")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+
+	opts := paper.StartScriptOptions{Restart: *restart, RestartDelay: *restartDelay}
+	if *memory != "" {
+		heap, err := startscript.ParseSize(*memory)
+		if err != nil {
+			return c.usageError(doc, err)
+		}
+		opts.HeapMB = heap
+	} else if _, err := startscript.ReadMemory(startscript.MemInfoPath); err != nil {
+		return c.usageError(doc, fmt.Errorf("%w; pass --memory", err))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+	defer cancel()
+	plan, err := c.svc.PlanStartScript(ctx, opts)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	if plan.FlagsErr != nil && !c.json {
+		fmt.Fprintln(os.Stderr, "warning: no recommended flags from the API, using Aikar's:", plan.FlagsErr)
+	}
+	change := plan.Change
+	doc.Start = report.FromStartScript(plan.Script, change)
+	c.printf("Heap %s", startscript.FormatSize(plan.HeapMB))
+	if mem := plan.Memory; mem.TotalMB > 0 {
+		c.printf(" of %.1f GB memory", float64(mem.UsableMB())/1024)
+		if mem.UsableMB() < mem.TotalMB {
+			c.printf(" (the cgroup's limit)")
+		}
+		c.printf(", %.1f GB available now", float64(mem.AvailableMB)/1024)
+	}
+	c.printf("; %s.\n", plan.FlagsFrom)
+	if !change.Changed() {
+		c.printf("%s is already up to date.\n", change.Path)
+		return c.done(doc, exitOK)
+	}
+	c.printf("\n%s\n", change.Diff())
+	if *dryRun {
+		c.printf("Dry run: %s was not written.\n", change.Path)
+		return c.done(doc, exitOK)
+	}
+	if err := c.svc.WriteStartScript(plan); err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Start.Written = true
+	c.printf("Wrote %s.\n", change.Path)
+	return c.done(doc, exitOK)
+}
+
+// runSystemdUnit writes a hardened systemd unit running the server from its directory,
+// showing the diff against the unit file already there, and has systemd reload it.
+func runSystemdUnit(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("systemd-unit")
+	fs := flag.NewFlagSet("systemd-unit", flag.ContinueOnError)
+	usr := fs.String("user", "", "user to run the server as (default: the owner of the server directory)")
+	path := fs.String("path", "", "where to write the unit (default: "+systemd.UnitDir+"/<systemd_unit>)")
+	restart := fs.String("restart", systemd.DefaultRestart, "restart policy: "+strings.Join(systemd.RestartPolicies, "|"))
+	dryRun := fs.Bool("dry-run", false, "print the diff and write nothing")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	if !slices.Contains(systemd.RestartPolicies, *restart) {
+		return c.usageError(doc, fmt.Errorf("invalid --restart %q (want %s)", *restart, strings.Join(systemd.RestartPolicies, ", ")))
+	}
+	dir, err := filepath.Abs(c.dir)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+
+	spec := systemd.Spec{Name: c.unit.Name(), Dir: dir, User: *usr, Restart: *restart, StopTimeout: c.cfg.Duration(config.KeyStopTimeout)}
+	if spec.User == "" {
+		if spec.User, spec.Group, err = systemd.Owner(dir); err != nil {
+			return c.usageError(doc, fmt.Errorf("%w; pass --user", err))
+		}
+	}
+	if spec.User == "root" {
+		return c.usageError(doc, errors.New("refusing to run the server as root; pass --user, or give the server directory to the user it should run as"))
+	}
+	javaPath := c.svc.Java()
+	if !strings.ContainsRune(javaPath, '/') {
+		if p, err := exec.LookPath(javaPath); err == nil {
+			javaPath = p
+		} else if !c.json {
+			fmt.Fprintf(os.Stderr, "warning: %s is not in PATH here; systemd will look for it in its own\n", javaPath)
+		}
+	}
+	// Load validated jvm_args already.
+	jvmArgs, _ := supervisor.SplitArgs(c.cfg.String(config.KeyJVMArgs))
+	spec.ExecStart = append(append([]string{javaPath}, jvmArgs...), "-jar", c.svc.JarName(), "nogui")
+	if tool, err := os.Executable(); err == nil {
+		spec.Tool = tool
+	}
+	if *path == "" {
+		*path = filepath.Join(systemd.UnitDir, spec.Name)
+	}
+
+	change, err := systemd.Prepare(*path, spec.Render())
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Unit = report.FromUnit(spec, change)
+	props, err := c.unit.Show(ctx)
+	doc.Unit.Managed = err == nil && props.Manages(dir)
+	c.printf("%s runs the server as %s from %s.\n", spec.Name, spec.User, dir)
+	if !change.Changed() {
+		c.printf("%s is already up to date.\n", change.Path)
+		return c.done(doc, exitOK)
+	}
+	c.printf("\n%s\n", change.Diff())
+	if *dryRun {
+		c.printf("Dry run: %s was not written.\n", change.Path)
+		return c.done(doc, exitOK)
+	}
+	if err := change.Write(); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			err = fmt.Errorf("%w (run it as root, or pass --path)", err)
+		}
+		return c.fail(doc, err)
+	}
+	doc.Unit.Written = true
+	c.log.Info("wrote systemd unit", logging.KeyEvent, logging.EventSystemd, "unit", spec.Name, "path", change.Path, "user", spec.User)
+	c.printf("Wrote %s.\n", change.Path)
+
+	if filepath.Dir(change.Path) != systemd.UnitDir {
+		c.printf("Copy it to %s, then run: systemctl daemon-reload && systemctl enable --now %s\n", systemd.UnitDir, spec.Name)
+		return c.done(doc, exitOK)
+	}
+	if err := c.unit.DaemonReload(ctx); err != nil {
+		if !c.json {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		}
+		c.printf("Run: systemctl daemon-reload\n")
+	} else {
+		doc.Unit.Reloaded = true
+	}
+	if doc.Unit.Managed {
+		c.printf("Restart the server for the changes to apply: systemctl restart %s\n", spec.Name)
+	} else {
+		c.printf("Start it now and at boot with: systemctl enable --now %s\n", spec.Name)
+	}
+	return c.done(doc, exitOK)
+}
+
+// runEULA shows whether the Minecraft EULA is accepted for the server. With --accept,
+// which stands for the user's agreement to it, it writes eula.txt as the server would
+// and records who accepted it in the activity log.
+func runEULA(_ context.Context, c *cli, args []string) int {
+	doc := report.New("eula")
+	fs := flag.NewFlagSet("eula", flag.ContinueOnError)
+	accept := fs.Bool("accept", false, "accept the Minecraft EULA ("+eula.URL+") and write eula.txt")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	st, err := c.svc.EULA()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.EULA = report.FromEULA(st)
+	switch {
+	case st == eula.Accepted:
+		c.printf("The Minecraft EULA is accepted in %s.\n", eula.FileName)
+		return c.done(doc, exitOK)
+	case !*accept:
+		reason := "there is no " + eula.FileName
+		if st == eula.Declined {
+			reason = eula.FileName + " does not say eula=true"
+		}
+		c.printf("The Minecraft EULA has not been accepted (%s), so the server will not start.\n", reason)
+		c.printf("Read it at %s; run \"eula --accept\" to accept it.\n", eula.URL)
+		return c.done(doc, exitOK)
+	}
+	a, err := c.svc.AcceptEULA()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.EULA = report.FromEULA(eula.Accepted)
+	doc.EULA.AcceptedBy, doc.EULA.Host, doc.EULA.AcceptedAt = a.User, a.Host, &a.At
+	c.printf("%s accepted the Minecraft EULA (%s); wrote %s.\n", a.User, eula.URL, eula.FileName)
+	return c.done(doc, exitOK)
+}
+
+// runJava lists the Java runtimes on the machine and checks the configured one against
+// the Java release the latest build (or --version) needs. It exits 1 if that runtime is
+// too old, whatever java_check says.
+func runJava(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("java")
+	fs := flag.NewFlagSet("java", flag.ContinueOnError)
+	version := fs.String("version", "", "check against this Minecraft version instead of the latest build's")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+	defer cancel()
+	if *version == "" {
+		info, err := c.svc.CheckLatest(ctx)
+		if err != nil {
+			return c.fail(doc, err)
+		}
+		*version = info.Version
+	}
+	check := c.svc.CheckJava(ctx, *version)
+	doc.Java = report.FromJavaCheck(check)
+	runtimes := java.NewFinder(java.WithLogger(c.log)).Discover(ctx)
+	for _, rt := range runtimes {
+		doc.Java.Runtimes = append(doc.Java.Runtimes, *report.FromJavaRuntime(rt))
+	}
+
+	from := "the Fill API"
+	if check.Source == paper.JavaFromBuiltin {
+		from = "the built-in table, as the API did not say"
+	}
+	c.printf("%s\n(requirement from %s; java_check = %s)\n\n", check, from, c.svc.JavaPolicy())
+	if len(runtimes) == 0 {
+		c.printf("No Java runtimes found in PATH, JAVA_HOME or the usual install directories.\n")
+	} else {
+		c.printf("Runtimes found:\n")
+	}
+	for _, rt := range runtimes {
+		mark := "  "
+		if check.Required > 0 && java.Check(rt, check.Version, check.Required) == nil {
+			mark = "ok"
+		}
+		c.printf("  %s Java %-3d %-12s %s  [%s]\n", mark, rt.Major, rt.Version, rt.Path, rt.Source)
+	}
+	if err := check.TooOld(); err != nil {
+		return c.fail(doc, err)
+	}
+	return c.done(doc, exitOK)
+}
+
+// verifyStatuses maps check-nagios --verify to the status a missing or modified jar
+// raises; "ignore" skips hashing the jar altogether.
+var verifyStatuses = map[string]nagios.Status{"critical": nagios.Critical, "warning": nagios.Warning, "ignore": nagios.OK}
+
+// runCheckNagios is a monitoring plugin: it compares the installed build with the latest
+// one, looks at how long ago it was installed and re-hashes paper.jar, and exits with
+// the plugin status (0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN) instead of the usual exit
+// codes. Every failure, bad flags included, is reported as UNKNOWN so the monitoring
+// system shows why.
+func runCheckNagios(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("check-nagios")
+	finish := func(r nagios.Result) int {
+		r.Service = "PAPER"
+		doc.Nagios = report.FromNagios(r)
+		c.printf("%s", r)
+		return c.done(doc, int(r.Status))
+	}
+	unknown := func(err error, kind report.ErrorKind) int {
+		doc.Error = report.FromError(err)
+		if kind != "" {
+			doc.Error.Kind = kind
+		}
+		return finish(nagios.Result{Status: nagios.Unknown, Summary: err.Error()})
+	}
+
+	fs := flag.NewFlagSet("check-nagios", flag.ContinueOnError)
+	warnBehind := fs.String("warning-behind", "5", "builds behind the latest that raise WARNING (plugin range; empty: never)")
+	critBehind := fs.String("critical-behind", "20", "builds behind the latest that raise CRITICAL (plugin range; empty: never)")
+	warnAge := fs.String("warning-age", "", "days since the last install that raise WARNING (plugin range; empty: never)")
+	critAge := fs.String("critical-age", "", "days since the last install that raise CRITICAL (plugin range; empty: never)")
+	onVerify := fs.String("verify", "critical", "status when paper.jar is missing or modified: critical, warning or ignore")
+	timeout := fs.Duration("timeout", c.svc.Timeouts().Check, "give up on the API after this long")
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return unknown(err, report.KindUsage)
+	}
+	behind, err := nagios.ParseThresholds(*warnBehind, *critBehind)
+	if err != nil {
+		return unknown(err, report.KindUsage)
+	}
+	age, err := nagios.ParseThresholds(*warnAge, *critAge)
+	if err != nil {
+		return unknown(err, report.KindUsage)
+	}
+	verifyStatus, ok := verifyStatuses[*onVerify]
+	if !ok {
+		return unknown(fmt.Errorf("invalid --verify %q (want critical, warning or ignore)", *onVerify), report.KindUsage)
+	}
+
+	installed, err := c.svc.Installed()
+	if err != nil {
+		return unknown(err, "")
+	}
+	doc.Installed = report.FromState(installed)
+	doc.Installed.JarPresent = c.svc.JarExists()
+	if installed.Build == 0 && installed.SHA256 == "" {
+		return unknown(fmt.Errorf("no install recorded in %s", c.dir), report.KindNotInstalled)
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	info, err := c.svc.CheckLatest(ctx)
+	if err != nil {
+		return unknown(err, "")
+	}
+	doc.Latest = report.FromLatest(info)
+
+	var res nagios.Result
+	var statuses []nagios.Status
+	res.Details = append(res.Details,
+		fmt.Sprintf("installed: %s build %d (%s)", installed.Version, installed.Build, installed.JarName),
+		fmt.Sprintf("latest: %s build %d (%s)", info.Version, info.Build, info.Channel))
+
+	var notes []string
+	if info.UpToDate {
+		res.Summary = fmt.Sprintf("%s build %d is the latest", info.Version, info.Build)
+	} else {
+		res.Summary = fmt.Sprintf("%s build %d installed, %d builds behind %s build %d",
+			installed.Version, installed.Build, info.Behind, info.Version, info.Build)
+	}
+	statuses = append(statuses, behind.Status(float64(info.Behind)))
+	res.Perf = append(res.Perf, nagios.Perf{Label: "builds_behind", Value: float64(info.Behind), Thresholds: behind, Min: new(0.0)})
+
+	if at := installed.InstalledAt; !at.IsZero() {
+		days := math.Round(time.Since(at).Hours()/24*10) / 10
+		st := age.Status(days)
+		statuses = append(statuses, st)
+		if st != nagios.OK {
+			notes = append(notes, fmt.Sprintf("last updated %.1f days ago", days))
+		}
+		res.Details = append(res.Details, "installed at: "+at.Local().Format("2006-01-02 15:04:05"))
+		res.Perf = append(res.Perf, nagios.Perf{Label: "days_since_update", Value: days, Thresholds: age, Min: new(0.0)})
+	}
+
+	if *onVerify != "ignore" {
+		verified := 0.0
+		if !doc.Installed.JarPresent {
+			notes = append(notes, c.svc.JarName()+" is missing")
+		} else {
+			vr, err := c.svc.Verify()
+			switch {
+			case errors.Is(err, paper.ErrJarModified):
+				notes = append(notes, c.svc.JarName()+" does not match the recorded sha256")
+				res.Details = append(res.Details, fmt.Sprintf("sha256 mismatch: %s on disk, %s recorded", vr.Actual, vr.Expected))
+			case err != nil:
+				return unknown(err, "")
+			default:
+				verified = 1
+				res.Details = append(res.Details, "verified: sha256 "+vr.Expected)
+			}
+			doc.Verify = report.FromVerify(vr)
+		}
+		if verified == 0 {
+			statuses = append(statuses, verifyStatus)
+		}
+		res.Perf = append(res.Perf, nagios.Perf{Label: "jar_verified", Value: verified, Min: new(0.0), Max: new(1.0)})
+	}
+
+	if len(notes) > 0 {
+		res.Summary += "; " + strings.Join(notes, "; ")
+	}
+	res.Status = nagios.Worst(statuses...)
+	return finish(res)
+}
+
+func runConfig(_ context.Context, c *cli, args []string) int {
+	doc := report.New("config")
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "show" {
+		return c.usageError(doc, errors.New(`usage: config show`))
+	}
+	doc.Config = report.FromConfig(c.cfg)
+	if !c.json {
+		user, err := config.UserFile()
+		if err != nil {
+			user = "(unavailable: " + err.Error() + ")"
+		}
+		c.printf("Config files: %s, %s\n\n", user, filepath.Join(c.dir, config.DirFileName))
+		width := 0
+		for _, s := range doc.Config {
+			width = max(width, len(s.Key))
+		}
+		for _, s := range doc.Config {
+			c.printf("%-*s %-28s %s\n", width, s.Key, s.Value, s.Source)
+		}
+	}
+	return c.done(doc, exitOK)
+}
+
+// runSelfUpdate replaces the running binary with the latest release. With --check it
+// only reports, exiting 10 when a newer release exists, like check does for Paper.
+func runSelfUpdate(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("self-update")
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	checkOnly := fs.Bool("check", false, "only report whether a newer release exists")
+	force := fs.Bool("force", false, "install the latest release even if it is not newer (e.g. over a dev build)")
+	if code, ok := c.parse(fs, doc, args); !ok {
+		return code
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, c.svc.Timeouts().Check)
+	st, err := c.updater.Check(checkCtx)
+	cancel()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Self = report.FromSelfUpdate(st)
+	switch {
+	case !st.Newer && !*force:
+		c.printf("paper-mc-tui %s is current (latest release %s)\n", st.Current, st.Latest.Tag)
+		return c.done(doc, exitOK)
+	case *checkOnly:
+		c.printf("paper-mc-tui %s is available (running %s): %s\n", st.Latest.Tag, st.Current, st.Latest.URL)
+		if !st.Newer {
+			return c.done(doc, exitOK)
+		}
+		return c.done(doc, exitUpdateAvailable)
+	}
+
+	c.printf("Updating paper-mc-tui %s to %s (%.1f MB)\n", st.Current, st.Latest.Tag, float64(st.Asset.Size)/(1<<20))
+	ctx, cancel = context.WithTimeout(ctx, c.svc.Timeouts().Download)
+	defer cancel()
+	path, err := c.updater.Apply(ctx, st, nil)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	doc.Self.Updated, doc.Self.Path = true, path
+	c.printf("Updated and verified %s; the new version is used from the next run\n", path)
+	return c.done(doc, exitOK)
+}
+
+// runWatch runs until SIGINT/SIGTERM, which cancels any install in progress. Records go
+// to the activity log and, as they happen, to stderr (as JSON lines with --output json).
+func runWatch(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("watch")
+	if code, ok := c.parse(flag.NewFlagSet("watch", flag.ContinueOnError), doc, args); !ok {
+		return code
+	}
+	if err := c.recoverFirst(doc); err != nil {
+		return c.fail(doc, err)
+	}
+
+	// Load validated these already.
+	level, _ := logging.ParseLevel(c.cfg.String(config.KeyLogLevel))
+	window, _ := watch.ParseWindow(c.cfg.String(config.KeyMaintenanceWindow))
+	format := logging.FormatText
+	if c.json {
+		format = logging.FormatJSON
+	}
+	console := logging.New(os.Stderr, logging.Options{Format: format, Level: level})
+
+	opts := []watch.Option{
+		watch.WithInterval(c.cfg.Duration(config.KeyWatchInterval)),
+		watch.WithJitter(c.cfg.Duration(config.KeyWatchJitter)),
+		watch.WithLogger(slog.New(slog.NewMultiHandler(c.log.Handler(), console.Handler()))),
+		watch.WithNotifier(c.notifier),
+	}
+	if c.cfg.Bool(config.KeyAutoInstall) {
+		opts = append(opts, watch.WithAutoInstall(window))
+	}
+	stop, err := c.serveMetrics()
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	defer stop()
+	if err := watch.New(c.svc, opts...).Run(ctx); err != nil {
+		return c.fail(doc, err)
+	}
+	return c.done(doc, exitOK)
+}
+
+// runServe serves the control API until SIGINT/SIGTERM.
+func runServe(ctx context.Context, c *cli, args []string) int {
+	doc := report.New("serve")
+	if code, ok := c.parse(flag.NewFlagSet("serve", flag.ContinueOnError), doc, args); !ok {
+		return code
+	}
+	srv, err := control.New(c.svc, c.dir, c.cfg.String(config.KeyControlToken), control.WithLogger(c.log))
+	if err != nil {
+		return c.usageError(doc, fmt.Errorf("%w (set control_token or PAPERMC_CONTROL_TOKEN)", err))
+	}
+	addr := c.cfg.String(config.KeyControlListen)
+	l, err := control.Listen(addr)
+	if err != nil {
+		return c.fail(doc, err)
+	}
+	if !c.json {
+		fmt.Fprintf(os.Stderr, "Control API listening on %s\n", addr)
+	}
+	stop, err := c.serveMetrics()
+	if err != nil {
+		l.Close()
+		return c.fail(doc, err)
+	}
+	defer stop()
+	if err := srv.Serve(ctx, l); err != nil {
+		return c.fail(doc, err)
+	}
+	return c.done(doc, exitOK)
+}
+
+// serveMetrics serves /metrics on metrics_listen, if set, until stop is called. It
+// publishes the installed build first so the gauges are populated before any check.
+func (c *cli) serveMetrics() (stop func(), err error) {
+	addr := c.cfg.String(config.KeyMetricsListen)
+	if addr == "" {
+		return func() {}, nil
+	}
+	if _, err := c.svc.Installed(); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", c.metrics.Registry().Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			c.log.Error("metrics server stopped", logging.KeyEvent, logging.EventControl, logging.Err(err))
+		}
+	}()
+	c.log.Info("metrics listening", logging.KeyEvent, logging.EventControl, "addr", l.Addr().String())
+	if !c.json {
+		fmt.Fprintf(os.Stderr, "Metrics on http://%s/metrics\n", l.Addr())
+	}
+	return func() { srv.Close() }, nil
+}
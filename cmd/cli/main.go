@@ -1,51 +1,290 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mbacalan/paper-mc-tui/internal/buildinfo"
+	"github.com/mbacalan/paper-mc-tui/internal/config"
 	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
 	"github.com/mbacalan/paper-mc-tui/internal/paper"
 	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/report"
+	"github.com/mbacalan/paper-mc-tui/internal/safeupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/selfupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
 	"github.com/mbacalan/paper-mc-tui/internal/state"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+	"github.com/mbacalan/paper-mc-tui/internal/systemd"
 	"github.com/mbacalan/paper-mc-tui/internal/ui/views"
+	"github.com/mbacalan/paper-mc-tui/internal/watch"
 )
 
 func main() {
 	showVersion := flag.Bool("version", false, "print version and exit")
 	dir := flag.String("dir", envOr("PAPERMC_DIR", "."), "directory for paper.jar, backups, state and log")
-	channel := flag.String("channel", envOr("PAPERMC_CHANNEL", "stable"), "release channel: stable|experimental")
+	config.Bind(flag.CommandLine)
+	flag.Usage = usage
 	flag.Parse()
+	// Locks name the command, never the flags, which can hold tokens and webhook URLs.
+	lock.SetCommand(strings.TrimSpace(filepath.Base(os.Args[0]) + " " + flag.Arg(0)))
 
 	if *showVersion {
 		fmt.Printf("paper-mc-tui %s (commit %s, built %s)\n", buildinfo.Version, buildinfo.Commit, buildinfo.Date)
 		return
 	}
 
-	channels, err := channelsFor(*channel)
+	cfg, err := config.Load(*dir, flag.CommandLine, configChecks()...)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(2)
+		exitStartup(nil, err, exitUsage)
 	}
+	channels, err := channelsFor(cfg.String(config.KeyChannel))
+	if err != nil {
+		exitStartup(cfg, err, exitUsage)
+	}
+	// Load validated these already.
+	format, _ := logging.ParseFormat(cfg.String(config.KeyLogFormat))
+	level, _ := logging.ParseLevel(cfg.String(config.KeyLogLevel))
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		exitStartup(cfg, err, exitError)
+	}
+	logFile := logging.NewFile(filepath.Join(*dir, state.LogFileName), int64(cfg.Int(config.KeyLogMaxSize))<<20, cfg.Int(config.KeyLogKeep))
+	logger := logging.New(logFile, logging.Options{Format: format, Level: level})
 
 	userAgent := fmt.Sprintf("paper-mc-tui/%s (+https://github.com/mbacalan/paper-mc-tui)", buildinfo.Version)
 
-	store, err := state.NewStore(*dir)
+	store, err := state.NewStore(*dir, state.WithLogger(logger))
+	if err != nil {
+		exitStartup(cfg, err, exitError)
+	}
+	if m, err := store.Migrate(); err != nil {
+		if !errors.Is(err, state.ErrLegacyFormat) {
+			exitStartup(cfg, err, exitError)
+		}
+		fmt.Fprintln(os.Stderr, "warning: ignoring legacy version file:", err)
+	} else if m.To != 0 {
+		fmt.Fprintln(os.Stderr, "state:", m)
+	}
+	m := metrics.New()
+	notifier, err := newNotifier(cfg, logger)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(1)
+		exitStartup(cfg, err, exitUsage)
+	}
+	client := papermc.NewClient(
+		papermc.WithBaseURL(cfg.String(config.KeyAPIURL)),
+		papermc.WithUserAgent(userAgent),
+		papermc.WithLogger(logger),
+		papermc.WithMetrics(m),
+	)
+	downloader := download.NewDownloader(download.WithUserAgent(userAgent), download.WithLogger(logger), download.WithMetrics(m))
+	// Load validated jvm_args already.
+	jvmArgs, _ := supervisor.SplitArgs(cfg.String(config.KeyJVMArgs))
+	var smoke *smoketest.Tester
+	if cfg.Bool(config.KeySmokeTest) {
+		smoke = smoketest.New(
+			smoketest.WithJava(cfg.String(config.KeyJava)),
+			smoketest.WithJVMArgs(jvmArgs...),
+			smoketest.WithTimeout(cfg.Duration(config.KeySmokeTestTimeout)),
+			smoketest.WithLogger(logger),
+		)
+	}
+	svc := paper.NewService(*dir, client, downloader, store,
+		paper.WithChannels(channels...),
+		paper.WithConstraint(papermc.Constraint(cfg.String(config.KeyVersion))),
+		paper.WithJarName(cfg.String(config.KeyJarName)),
+		paper.WithBackup(paper.BackupPolicy(cfg.String(config.KeyBackup)), cfg.String(config.KeyBackupName)),
+		paper.WithMetrics(m),
+		paper.WithNotifier(notifier),
+		paper.WithJava(cfg.String(config.KeyJava), paper.JavaPolicy(cfg.String(config.KeyJavaCheck))),
+		paper.WithSmokeTest(smoke),
+		paper.WithTimeouts(paper.Timeouts{
+			Check:    cfg.Duration(config.KeyCheckTimeout),
+			Download: cfg.Duration(config.KeyDownloadTimeout),
+		}),
+	)
+	// Self-updates download with their own downloader so jar metrics count only jars.
+	updater := selfupdate.New(
+		selfupdate.WithBaseURL(cfg.String(config.KeySelfUpdateURL)),
+		selfupdate.WithUserAgent(userAgent),
+		selfupdate.WithLogger(logger),
+		selfupdate.WithDownloader(download.NewDownloader(download.WithUserAgent(userAgent), download.WithLogger(logger))),
+	)
+
+	sup := supervisor.New(*dir,
+		supervisor.WithJava(cfg.String(config.KeyJava)),
+		supervisor.WithJVMArgs(jvmArgs...),
+		supervisor.WithJarName(cfg.String(config.KeyJarName)),
+		supervisor.WithStopTimeout(cfg.Duration(config.KeyStopTimeout)),
+		supervisor.WithLogger(logger),
+	)
+	// A server that a systemd unit runs from this directory is started and stopped with
+	// systemctl; starting a second copy beside it would only fight over the port.
+	unit := systemd.New(cfg.String(config.KeySystemdUnit), *dir,
+		systemd.WithStopTimeout(cfg.Duration(config.KeyStopTimeout)),
+		systemd.WithLogger(logger),
+	)
+	// Asking systemd costs a systemctl call, so only runs that control the server do.
+	var server views.Server = sup
+	if (flag.NArg() == 0 || serverCommands[flag.Arg(0)]) && cfg.String(config.KeySystemd) == "auto" && managed(unit) {
+		server = unit
+	}
+	safe := safeupdate.New(svc, server,
+		safeupdate.WithCountdown(cfg.Duration(config.KeyUpdateCountdown)),
+		safeupdate.WithReadyTimeout(cfg.Duration(config.KeyReadyTimeout)),
+		safeupdate.WithLogger(logger),
+	)
+
+	if flag.NArg() > 0 {
+		code := runCommand(&cli{svc: svc, cfg: cfg, log: logger, logFile: logFile, metrics: m, notifier: notifier, updater: updater,
+			safe: safe, unit: unit, server: server, dir: *dir, json: cfg.String(config.KeyOutput) == "json"}, flag.Args())
+		notifier.Wait(notifyGrace)
+		os.Exit(code)
 	}
-	client := papermc.NewClient(papermc.WithUserAgent(userAgent))
-	downloader := download.NewDownloader(download.WithUserAgent(userAgent))
-	svc := paper.NewService(*dir, client, downloader, store, channels...)
 
-	if _, err := tea.NewProgram(views.NewManager(svc)).Run(); err != nil {
+	mopts := []views.ManagerOption{views.WithServer(server), views.WithSafeUpdate(safe), views.WithLogFile(logFile)}
+	if cfg.Bool(config.KeySelfUpdateCheck) {
+		mopts = append(mopts, views.WithSelfUpdate(updater))
+	}
+	_, err = tea.NewProgram(views.NewManager(svc, mopts...)).Run()
+	notifier.Wait(notifyGrace)
+	if err != nil {
 		fmt.Printf("Uh oh, there was an error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
+	}
+}
+
+// notifyGrace is how long to wait on exit for webhooks still being delivered.
+const notifyGrace = 15 * time.Second
+
+// webhookTemplates maps each notification kind to the key overriding its template.
+var webhookTemplates = map[notify.Kind]config.Key{
+	notify.KindNewBuild:         config.KeyWebhookTemplateNewBuild,
+	notify.KindInstallSucceeded: config.KeyWebhookTemplateInstalled,
+	notify.KindInstallFailed:    config.KeyWebhookTemplateFailed,
+	notify.KindRollback:         config.KeyWebhookTemplateRollback,
+}
+
+// configChecks validates the settings whose formats belong to the packages they
+// configure, so that Load reports a bad value with the layer it came from.
+func configChecks() []config.Option {
+	opts := []config.Option{
+		config.WithCheck(config.KeyMaintenanceWindow, func(v string) error { _, err := watch.ParseWindow(v); return err }),
+		config.WithCheck(config.KeyWebhooks, func(v string) error { _, err := notify.ParseWebhooks(v); return err }),
+		config.WithCheck(config.KeyWebhookEvents, func(v string) error { _, err := notify.ParseKinds(v); return err }),
+		config.WithCheck(config.KeyJVMArgs, func(v string) error { _, err := supervisor.SplitArgs(v); return err }),
+		config.WithCheck(config.KeySystemdUnit, func(v string) error {
+			if v == "" {
+				return nil
+			}
+			return systemd.ValidName(v)
+		}),
+	}
+	for kind, key := range webhookTemplates {
+		opts = append(opts, config.WithCheck(key, func(v string) error {
+			if v == "" {
+				return nil
+			}
+			_, err := notify.ParseTemplate(kind, v)
+			return err
+		}))
+	}
+	return opts
+}
+
+// newNotifier builds the webhook notifier from the webhook_* settings. Load has already
+// validated them, so errors here are unexpected.
+func newNotifier(cfg *config.Config, logger *slog.Logger) (*notify.Notifier, error) {
+	hooks, err := notify.ParseWebhooks(cfg.String(config.KeyWebhooks))
+	if err != nil {
+		return nil, err
+	}
+	kinds, err := notify.ParseKinds(cfg.String(config.KeyWebhookEvents))
+	if err != nil {
+		return nil, err
+	}
+	opts := []notify.Option{notify.WithKinds(kinds...), notify.WithLogger(logger)}
+	for kind, key := range webhookTemplates {
+		opts = append(opts, notify.WithTemplate(kind, cfg.String(key)))
+	}
+	return notify.New(hooks, opts...)
+}
+
+// managedTimeout bounds asking systemd whether it runs the server at startup, so a
+// stalled systemd cannot hang the tool.
+const managedTimeout = 2 * time.Second
+
+// serverCommands are the commands that start or stop the server, and so need to know
+// whether systemd runs it. The TUI does too.
+var serverCommands = map[string]bool{"safe-update": true}
+
+// managed reports whether unit runs the server, giving up after managedTimeout.
+func managed(unit *systemd.Unit) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), managedTimeout)
+	defer cancel()
+	return unit.Managed(ctx)
+}
+
+// exitStartup reports err, which stopped the tool before any command ran, and exits
+// with code. With --output json it prints the command's report.Document, as the
+// command itself would have. cfg is nil if the configuration did not load.
+func exitStartup(cfg *config.Config, err error, code int) {
+	c := &cli{json: startupJSON(cfg)}
+	doc := report.New(flag.Arg(0))
+	if code == exitUsage {
+		c.usageError(doc, err)
+	} else {
+		c.fail(doc, err)
+	}
+	os.Exit(code)
+}
+
+// startupJSON reports whether --output json was asked for. Without a loaded cfg only
+// the flag and PAPERMC_OUTPUT can say; a config file's setting is not known.
+func startupJSON(cfg *config.Config) bool {
+	if cfg != nil {
+		return cfg.String(config.KeyOutput) == "json"
+	}
+	output := os.Getenv("PAPERMC_OUTPUT")
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == string(config.KeyOutput) {
+			output = f.Value.String()
+		}
+	})
+	return output == "json"
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command [command flags]]\n\n", filepath.Base(os.Args[0]))
+	fmt.Fprintln(out, "With no command, starts the interactive TUI. Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintln(out, "\nExit codes:")
+	fmt.Fprintln(out, "  0   success (check: up to date)")
+	fmt.Fprintln(out, "  1   error")
+	fmt.Fprintln(out, "  2   invalid flags or arguments")
+	fmt.Fprintln(out, "  10  check: an update is available")
+	fmt.Fprintln(out, "  11  verify: paper.jar does not match the recorded checksum")
+	fmt.Fprintln(out, "check-nagios instead exits 0 OK, 1 WARNING, 2 CRITICAL or 3 UNKNOWN, as plugins do.")
+	fmt.Fprintf(out, "\nWith --output json, each command prints one JSON document (schema %d),\n", report.SchemaVersion)
+	fmt.Fprintln(out, "including an error.kind such as no_build, http_status or checksum_mismatch on failure.")
+	fmt.Fprintln(out, "\nSettings are layered: defaults < user config file < <dir>/"+config.DirFileName+" <")
+	fmt.Fprintln(out, "PAPERMC_* environment variables < flags. \"config show\" prints the effective values.")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
 }
 
 // channelsFor maps the --channel flag to the set of acceptable release channels.
@@ -0,0 +1,37 @@
+// Package atomicfile replaces small files so that a reader, or the next run after a
+// crash, sees either the old content or the new, never a partial file.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// TempPattern is the os.CreateTemp pattern WriteFile uses for a file named name. A
+// crash can leave such a temp file behind.
+func TempPattern(name string) string { return "." + name + "-*.tmp" }
+
+// WriteFile writes data to a temp file next to path, gives it perm and renames it over
+// path. The temp file is removed if anything fails. Errors are returned as the os
+// package reports them, for the caller to prefix.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), TempPattern(filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, path)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}
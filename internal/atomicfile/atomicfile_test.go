@@ -0,0 +1,39 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "paper.pid")
+	if err := os.WriteFile(path, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("new\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "new\n" {
+		t.Errorf("content = %q", got)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Errorf("mode = %v, %v", fi.Mode(), err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, TempPattern("paper.pid"))); len(matches) > 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+
+	// A failed rename leaves neither a temp file nor a changed target.
+	blocked := filepath.Join(dir, "sub")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(blocked, []byte("x"), 0o644); err == nil {
+		t.Error("WriteFile over a non-empty directory succeeded")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, TempPattern("sub"))); len(matches) > 0 {
+		t.Errorf("temp files left behind after a failure: %v", matches)
+	}
+}
@@ -0,0 +1,423 @@
+// Package config resolves the tool's settings from layered sources. From lowest to
+// highest precedence: built-in defaults, the user file (<user config dir>/paper-mc-tui/
+// config), the server directory's paper-mc.conf, PAPERMC_* environment variables, and
+// command-line flags. Each effective value remembers which layer it came from, so
+// "config show" can explain it. The server directory is often writable by the server
+// itself, so its paper-mc.conf may not set the UserOnly settings.
+//
+// Config files are "key = value" lines; blank lines and lines starting with # are
+// ignored. Keys are the Key constants below.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+// Key names a setting. The same name is used in config files; the environment variable
+// is PAPERMC_ followed by the upper-cased key, and the flag replaces _ with -.
+type Key string
+
+const (
+	KeyChannel         Key = "channel"
+	KeyVersion         Key = "version"
+	KeyAPIURL          Key = "api_url"
+	KeyCheckTimeout    Key = "check_timeout"
+	KeyDownloadTimeout Key = "download_timeout"
+	KeyBackup          Key = "backup"
+	KeyBackupName      Key = "backup_name"
+	KeyJarName         Key = "jar_name"
+	KeyLogFormat       Key = "log_format"
+	KeyLogLevel        Key = "log_level"
+	KeyLogMaxSize      Key = "log_max_size"
+	KeyLogKeep         Key = "log_keep"
+	KeyOutput          Key = "output"
+
+	KeyWatchInterval     Key = "watch_interval"
+	KeyWatchJitter       Key = "watch_jitter"
+	KeyAutoInstall       Key = "auto_install"
+	KeyMaintenanceWindow Key = "maintenance_window"
+
+	KeyControlListen Key = "control_listen"
+	KeyControlToken  Key = "control_token"
+
+	KeyMetricsListen Key = "metrics_listen"
+
+	KeySelfUpdateURL   Key = "self_update_url"
+	KeySelfUpdateCheck Key = "self_update_check"
+
+	KeyWebhooks                 Key = "webhooks"
+	KeyWebhookEvents            Key = "webhook_events"
+	KeyWebhookTemplateNewBuild  Key = "webhook_template_new_build"
+	KeyWebhookTemplateInstalled Key = "webhook_template_install_succeeded"
+	KeyWebhookTemplateFailed    Key = "webhook_template_install_failed"
+	KeyWebhookTemplateRollback  Key = "webhook_template_rollback"
+
+	KeyJava        Key = "java"
+	KeyJVMArgs     Key = "jvm_args"
+	KeyStopTimeout Key = "stop_timeout"
+	KeyJavaCheck   Key = "java_check"
+	KeySystemd     Key = "systemd"
+	KeySystemdUnit Key = "systemd_unit"
+
+	KeyUpdateCountdown Key = "update_countdown"
+	KeyReadyTimeout    Key = "ready_timeout"
+
+	KeySmokeTest        Key = "smoke_test"
+	KeySmokeTestTimeout Key = "smoke_test_timeout"
+)
+
+// DirFileName is the per-directory config file, read from the server directory.
+const DirFileName = "paper-mc.conf"
+
+var (
+	// ErrUnknownKey means a config file names a key this version does not know.
+	ErrUnknownKey = errors.New("config: unknown key")
+	// ErrNotInDirFile means the server directory's paper-mc.conf sets a key that only
+	// the user file, the environment or a flag may set.
+	ErrNotInDirFile = errors.New("config: key not allowed in " + DirFileName)
+)
+
+// Setting describes one key: its default, where it can be overridden and how its
+// values are checked.
+type Setting struct {
+	Key     Key
+	Default string
+	Flag    string // flag name, without dashes
+	Usage   string
+	check   func(string) error
+}
+
+// Env returns the environment variable that overrides the setting.
+func (s Setting) Env() string { return "PAPERMC_" + strings.ToUpper(string(s.Key)) }
+
+// Settings lists every key in display order.
+var Settings = []Setting{
+	{KeyChannel, "stable", "channel", "release channel: stable|experimental", oneOf("stable", "experimental")},
+	// --version already prints the tool's version.
+	{KeyVersion, "", "paper-version", `only consider Minecraft versions matching this prefix, e.g. "1.21"`, anyValue},
+	{KeyAPIURL, "https://fill.papermc.io/v3", "api-url", "Fill v3 API base URL", httpURL},
+	{KeyCheckTimeout, "30s", "check-timeout", "timeout for checking the latest build", duration},
+	{KeyDownloadTimeout, "15m", "download-timeout", "timeout for downloading a jar", duration},
+	{KeyBackup, "ask", "backup-policy", "back up the existing jar before installing: ask|always|never", oneOf("ask", "always", "never")},
+	{KeyBackupName, "paper.backup.jar", "backup-name", "backup file name", fileName},
+	{KeyJarName, "paper.jar", "jar-name", "server jar file name", fileName},
+	{KeyLogFormat, "text", "log-format", "activity log format: text|json", func(v string) error { _, err := logging.ParseFormat(v); return err }},
+	{KeyLogLevel, "info", "log-level", "activity log level: debug|info|warn|error", func(v string) error { _, err := logging.ParseLevel(v); return err }},
+	{KeyLogMaxSize, "5", "log-max-size", "rotate the activity log after this many MB", positiveInt},
+	{KeyLogKeep, "3", "log-keep", "number of rotated activity logs to keep", positiveInt},
+	{KeyOutput, "text", "output", "command output: text|json", oneOf("text", "json")},
+	{KeyWatchInterval, "1h", "watch-interval", "watch: time between checks", duration},
+	{KeyWatchJitter, "5m", "watch-jitter", "watch: up to this much random delay added to each interval", durationOrZero},
+	{KeyAutoInstall, "false", "auto-install", "watch: install new builds automatically", boolean},
+	{KeyMaintenanceWindow, "", "maintenance-window", `watch: only auto-install between these local times, e.g. "03:00-05:00"`, anyValue},
+	{KeyControlListen, "127.0.0.1:8765", "control-listen", `serve: loopback host:port or "unix:/path/to.sock"`, anyValue},
+	{KeyControlToken, "", "control-token", "serve: bearer token clients must send (required)", anyValue},
+	{KeyMetricsListen, "", "metrics-listen", `watch, serve: serve Prometheus /metrics on this host:port, e.g. ":9310" (off if empty)`, anyValue},
+	{KeySelfUpdateURL, "https://api.github.com/repos/mbacalan/paper-mc-tui", "self-update-url", "GitHub API URL of the repository self-update reads releases from", httpURL},
+	{KeySelfUpdateCheck, "true", "self-update-check", "TUI: mention it when a newer paper-mc-tui release exists", boolean},
+	{KeyWebhooks, "", "webhooks", `webhooks to notify, as "format=url" entries (format: discord|slack|generic)`, anyValue},
+	{KeyWebhookEvents, "", "webhook-events", "comma-separated events to send: new_build,install_succeeded,install_failed,rollback (all if empty)", anyValue},
+	{KeyWebhookTemplateNewBuild, "", "webhook-template-new-build", "message template for new_build (default built in)", anyValue},
+	{KeyWebhookTemplateInstalled, "", "webhook-template-install-succeeded", "message template for install_succeeded", anyValue},
+	{KeyWebhookTemplateFailed, "", "webhook-template-install-failed", "message template for install_failed", anyValue},
+	{KeyWebhookTemplateRollback, "", "webhook-template-rollback", "message template for rollback", anyValue},
+	{KeyJava, "java", "java", "server: java launcher, a path or a name in PATH", nonEmpty},
+	{KeyJVMArgs, "-Xms2G -Xmx2G", "jvm-args", `server: arguments for java before -jar; quote ones with spaces`, anyValue},
+	{KeyStopTimeout, "60s", "stop-timeout", `server: how long to wait after "stop" before signalling and then killing it`, duration},
+	{KeyJavaCheck, "block", "java-check", "install: when java is too old for the build: block|warn|off", oneOf("block", "warn", "off")},
+	{KeySystemd, "auto", "systemd", "server: start and stop it with systemctl when systemd_unit runs it from this directory: auto|off", oneOf("auto", "off")},
+	{KeySystemdUnit, "", "systemd-unit", `server: systemd unit name (default "paper-<directory name>.service")`, anyValue},
+	{KeyUpdateCountdown, "60s", "update-countdown", "safe-update: how long players are warned before the server stops (0 for no warning)", durationOrZero},
+	{KeyReadyTimeout, "5m", "ready-timeout", `safe-update: how long the new build has to log "Done" before it is rolled back`, duration},
+	{KeySmokeTest, "false", "smoke-test", "install: boot each new build against a sandboxed copy of the server, rolling back if it fails", boolean},
+	{KeySmokeTestTimeout, "3m", "smoke-test-timeout", `install: how long the smoke test waits for "Done"`, duration},
+}
+
+// secretKeys are never displayed, only whether they are set. Discord and Slack webhook
+// URLs embed their credentials.
+var secretKeys = map[Key]bool{KeyControlToken: true, KeyWebhooks: true}
+
+// Secret reports whether the setting's value must not be displayed.
+func (s Setting) Secret() bool { return secretKeys[s.Key] }
+
+// userOnlyKeys may not be set in the server directory's paper-mc.conf. The server and
+// its plugins can usually write there, and these choose what the tool runs, where it
+// downloads from and where it sends webhook credentials.
+var userOnlyKeys = map[Key]bool{KeyJava: true, KeyJVMArgs: true, KeySelfUpdateURL: true, KeyAPIURL: true, KeyWebhooks: true}
+
+// UserOnly reports whether the setting is refused in the server directory's
+// paper-mc.conf.
+func (s Setting) UserOnly() bool { return userOnlyKeys[s.Key] }
+
+func lookup(key Key) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Value is an effective setting and the layer it came from, e.g. "default",
+// "env PAPERMC_CHANNEL", "flag --channel" or "/srv/mc/paper-mc.conf:3".
+type Value struct {
+	Value  string
+	Source string
+}
+
+// Config holds the effective value of every setting.
+type Config struct {
+	values map[Key]Value
+	checks map[Key][]func(string) error // from WithCheck
+}
+
+// Option configures Load.
+type Option func(*Config)
+
+// WithCheck adds a check for key's values, run after the built-in one. Settings whose
+// format belongs to another package, such as webhooks to notify, are checked this way,
+// so config need not import the packages it configures.
+func WithCheck(key Key, check func(string) error) Option {
+	return func(c *Config) {
+		if c.checks == nil {
+			c.checks = make(map[Key][]func(string) error)
+		}
+		c.checks[key] = append(c.checks[key], check)
+	}
+}
+
+// UserFile returns the user-level config file path. It honors XDG_CONFIG_HOME on Unix.
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("config: %w", err)
+	}
+	return filepath.Join(dir, "paper-mc-tui", "config"), nil
+}
+
+// Bind registers a flag for every setting on fs, with the built-in default shown in
+// its usage. Boolean settings get boolean flags (--auto-install). Load only applies
+// flags that were actually set.
+func Bind(fs *flag.FlagSet) {
+	for _, s := range Settings {
+		v := &flagValue{value: s.Default, isBool: s.Default == "true" || s.Default == "false"}
+		fs.Var(v, s.Flag, s.Usage)
+	}
+}
+
+// flagValue is a string flag that can also act as a boolean one.
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.value }
+func (v *flagValue) Set(s string) error { v.value = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+// Load resolves every setting for the server directory dir. fs, if non-nil, must have
+// been passed to Bind and parsed. A missing config file is not an error; an unreadable
+// one, an unknown key, a UserOnly key in the directory's file or an invalid value in
+// any layer is.
+func Load(dir string, fs *flag.FlagSet, opts ...Option) (*Config, error) {
+	c := &Config{values: make(map[Key]Value, len(Settings))}
+	for _, opt := range opts {
+		opt(c)
+	}
+	for _, s := range Settings {
+		c.values[s.Key] = Value{Value: s.Default, Source: "default"}
+	}
+
+	user, err := UserFile()
+	if err == nil {
+		if err := c.loadFile(user, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.loadFile(filepath.Join(dir, DirFileName), true); err != nil {
+		return nil, err
+	}
+
+	for _, s := range Settings {
+		if v, ok := os.LookupEnv(s.Env()); ok && v != "" {
+			if err := c.set(s, v, "env "+s.Env()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if fs != nil {
+		var ferr error
+		fs.Visit(func(f *flag.Flag) {
+			for _, s := range Settings {
+				if s.Flag == f.Name && ferr == nil {
+					ferr = c.set(s, f.Value.String(), "flag --"+f.Name)
+				}
+			}
+		})
+		if ferr != nil {
+			return nil, ferr
+		}
+	}
+	return c, nil
+}
+
+// loadFile applies a config file's settings. A missing file is skipped. dirFile is
+// whether it is the server directory's, where UserOnly settings are refused.
+func (c *Config) loadFile(path string, dirFile bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", path, n)
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("config: %s: expected key = value", where)
+		}
+		key := Key(strings.TrimSpace(k))
+		s, ok := lookup(key)
+		if !ok {
+			return fmt.Errorf("%w %q at %s", ErrUnknownKey, key, where)
+		}
+		if dirFile && s.UserOnly() {
+			return fmt.Errorf("%w: %q at %s; set it in the user file, the environment or a flag", ErrNotInDirFile, key, where)
+		}
+		if err := c.set(s, unquote(strings.TrimSpace(v)), where); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) set(s Setting, v, source string) error {
+	for _, check := range append([]func(string) error{s.check}, c.checks[s.Key]...) {
+		if err := check(v); err != nil {
+			return fmt.Errorf("config: %s (%s): %w", s.Key, source, err)
+		}
+	}
+	c.values[s.Key] = Value{Value: v, Source: source}
+	return nil
+}
+
+// Get returns the effective value of key and where it came from.
+func (c *Config) Get(key Key) Value { return c.values[key] }
+
+// String returns the effective value of key.
+func (c *Config) String(key Key) string { return c.values[key].Value }
+
+// Duration returns a duration-valued key. Values were checked by Load.
+func (c *Config) Duration(key Key) time.Duration {
+	d, _ := time.ParseDuration(c.values[key].Value)
+	return d
+}
+
+// Bool returns a boolean-valued key. Values were checked by Load.
+func (c *Config) Bool(key Key) bool {
+	b, _ := strconv.ParseBool(c.values[key].Value)
+	return b
+}
+
+// Int returns an integer-valued key. Values were checked by Load.
+func (c *Config) Int(key Key) int {
+	n, _ := strconv.Atoi(c.values[key].Value)
+	return n
+}
+
+// unquote strips one pair of matching double quotes, so `version = "1.21"` works.
+func unquote(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+func anyValue(string) error { return nil }
+
+func nonEmpty(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return errors.New("must not be empty")
+	}
+	return nil
+}
+
+func oneOf(allowed ...string) func(string) error {
+	return func(v string) error {
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value %q (want %s)", v, strings.Join(allowed, ", "))
+	}
+}
+
+func duration(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid duration %q (e.g. 30s, 15m)", v)
+	}
+	return nil
+}
+
+// durationOrZero is duration, but also takes zero, e.g. "0" or "0s" for no jitter.
+func durationOrZero(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid duration %q (e.g. 0, 30s, 15m)", v)
+	}
+	return nil
+}
+
+func boolean(v string) error {
+	if _, err := strconv.ParseBool(v); err != nil {
+		return fmt.Errorf("invalid value %q (want true or false)", v)
+	}
+	return nil
+}
+
+func positiveInt(v string) error {
+	if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+		return fmt.Errorf("invalid value %q (want a positive integer)", v)
+	}
+	return nil
+}
+
+func fileName(v string) error {
+	if v == "" || v != filepath.Base(v) || strings.ContainsAny(v, `/\`) {
+		return fmt.Errorf("invalid file name %q (want a plain name within the directory)", v)
+	}
+	return nil
+}
+
+func httpURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q (want http or https)", v)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolate points the user config dir at a temp dir and clears PAPERMC_* overrides.
+func isolate(t *testing.T) (userFile string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home)
+	for _, s := range Settings {
+		t.Setenv(s.Env(), "")
+	}
+	return filepath.Join(home, "paper-mc-tui", "config")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	isolate(t)
+	c, err := Load(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range Settings {
+		if got := c.Get(s.Key); got.Value != s.Default || got.Source != "default" {
+			t.Errorf("%s = %+v, want default %q", s.Key, got, s.Default)
+		}
+	}
+	if got := c.Duration(KeyDownloadTimeout); got != 15*time.Minute {
+		t.Errorf("download_timeout = %v", got)
+	}
+	if got := c.Int(KeyLogKeep); got != 3 {
+		t.Errorf("log_keep = %d", got)
+	}
+}
+
+func TestLayering(t *testing.T) {
+	user := isolate(t)
+	dir := t.TempDir()
+	writeFile(t, user, "channel = experimental\nversion = 1.20\njar_name = server.jar\n")
+	writeFile(t, filepath.Join(dir, DirFileName), "# per-server\nversion = \"1.21\"\n\ncheck_timeout = 10s\n")
+	t.Setenv("PAPERMC_CHECK_TIMEOUT", "20s")
+	t.Setenv("PAPERMC_BACKUP", "always")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	Bind(fs)
+	if err := fs.Parse([]string{"--backup-policy", "never"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(dir, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    Key
+		value  string
+		source string
+	}{
+		{KeyChannel, "experimental", user + ":1"},
+		{KeyJarName, "server.jar", user + ":3"},
+		{KeyVersion, "1.21", filepath.Join(dir, DirFileName) + ":2"},
+		{KeyCheckTimeout, "20s", "env PAPERMC_CHECK_TIMEOUT"},
+		{KeyBackup, "never", "flag --backup-policy"},
+		{KeyOutput, "text", "default"}, // bound but not set on the command line
+	}
+	for _, tt := range tests {
+		if got := c.Get(tt.key); got.Value != tt.value || got.Source != tt.source {
+			t.Errorf("%s = %+v, want %q from %q", tt.key, got, tt.value, tt.source)
+		}
+	}
+}
+
+func TestInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  [2]string
+		want string
+	}{
+		{"unknown key", "chanel = stable\n", [2]string{}, "paper-mc.conf:1"},
+		{"missing equals", "channel stable\n", [2]string{}, "expected key = value"},
+		{"bad duration", "check_timeout = soon\n", [2]string{}, "invalid duration"},
+		{"negative jitter", "watch_jitter = -1s\n", [2]string{}, "invalid duration"},
+		{"path as jar name", "jar_name = ../paper.jar\n", [2]string{}, "invalid file name"},
+		{"bad url", "", [2]string{"PAPERMC_API_URL", "fill.papermc.io"}, "invalid URL"},
+		{"failed added check", "", [2]string{"PAPERMC_JVM_ARGS", `-Xmx4G -Dmotd="My Server`}, "unterminated quote"},
+		{"bad env", "", [2]string{"PAPERMC_OUTPUT", "yaml"}, "env PAPERMC_OUTPUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			dir := t.TempDir()
+			if tt.file != "" {
+				writeFile(t, filepath.Join(dir, DirFileName), tt.file)
+			}
+			if tt.env[0] != "" {
+				t.Setenv(tt.env[0], tt.env[1])
+			}
+			_, err := Load(dir, nil, WithCheck(KeyJVMArgs, func(v string) error {
+				if strings.Count(v, `"`)%2 != 0 {
+					return errors.New("unterminated quote")
+				}
+				return nil
+			}))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationOrZero(t *testing.T) {
+	for v, ok := range map[string]bool{"0": true, "0s": true, "0m": true, "90s": true, "-1s": false, "soon": false, "": false} {
+		if err := durationOrZero(v); (err == nil) != ok {
+			t.Errorf("durationOrZero(%q) = %v", v, err)
+		}
+	}
+}
+
+func TestUnknownKeyIsSentinel(t *testing.T) {
+	isolate(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, DirFileName), "colour = blue\n")
+	if _, err := Load(dir, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestDirFileRefusesUserOnlyKeys(t *testing.T) {
+	for _, key := range []Key{KeyJava, KeyJVMArgs, KeySelfUpdateURL, KeyAPIURL, KeyWebhooks} {
+		t.Run(string(key), func(t *testing.T) {
+			isolate(t)
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, DirFileName), string(key)+" = https://evil.example/x\n")
+			if _, err := Load(dir, nil); !errors.Is(err, ErrNotInDirFile) {
+				t.Fatalf("err = %v, want ErrNotInDirFile", err)
+			}
+		})
+	}
+
+	user := isolate(t)
+	writeFile(t, user, "java = /opt/jdk21/bin/java\n")
+	c, err := Load(t.TempDir(), nil)
+	if err != nil || c.String(KeyJava) != "/opt/jdk21/bin/java" {
+		t.Errorf("java from the user file = %v, %v", c, err)
+	}
+}
+
+func TestBadFlag(t *testing.T) {
+	isolate(t)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	Bind(fs)
+	if err := fs.Parse([]string{"--log-keep", "0"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(t.TempDir(), fs); err == nil || !strings.Contains(err.Error(), "flag --log-keep") {
+		t.Fatalf("err = %v", err)
+	}
+}
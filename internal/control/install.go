@@ -0,0 +1,191 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/report"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
+)
+
+// installRequest is the optional POST /v1/install body. It mirrors the install
+// command's flags; Backup defaults to the configured backup policy.
+type installRequest struct {
+	Version    string `json:"version"`
+	Build      int    `json:"build"`
+	Backup     *bool  `json:"backup"`
+	BackupName string `json:"backup_name"`
+	Force      bool   `json:"force"`
+	DryRun     bool   `json:"dry_run"` // answer with the plan and change nothing
+}
+
+// Progress is the data of a "progress" event.
+type Progress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// SSE event names sent by POST /v1/install with "Accept: text/event-stream".
+const (
+	EventRelease  = "release"  // report.Latest: what is about to be installed
+	EventProgress = "progress" // Progress, at most once per percent
+	EventResult   = "result"   // report.Document: the final outcome, then the stream ends
+)
+
+// handleInstall installs the latest build, or the requested version/build. With
+// "Accept: text/event-stream" it streams release, progress and result events;
+// otherwise it answers with the final document once the install finishes. Closing the
+// connection cancels the install.
+func (s *Server) handleInstall(w http.ResponseWriter, r *http.Request) {
+	doc := report.New("install")
+	var req installRequest
+	if err := decodeBody(r, &req); err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	if req.Build != 0 && req.Version == "" {
+		s.fail(w, doc, usageError{errors.New("build requires version")})
+		return
+	}
+	if !s.busy.TryLock() {
+		s.rejectBusy(w, doc)
+		return
+	}
+	defer s.busy.Unlock()
+
+	var events *eventStream
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		events = newEventStream(w)
+	}
+	finish := func(err error) {
+		if err != nil {
+			doc.Error = report.FromError(err)
+			doc.ExitCode = 1
+			s.log.Warn("control install failed", logging.KeyEvent, logging.EventControl, logging.Err(err))
+		} else {
+			doc.OK = true
+		}
+		if events != nil {
+			events.send(EventResult, doc)
+			return
+		}
+		writeDoc(w, doc)
+	}
+
+	// A dry run must not touch disk, so a pending recovery fails it instead of running.
+	if !req.DryRun {
+		if err := s.recoverFirst(doc); err != nil {
+			finish(err)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.svc.Timeouts().Check)
+	var (
+		info paper.LatestInfo
+		err  error
+	)
+	if req.Version != "" {
+		info, err = s.svc.Select(ctx, req.Version, req.Build)
+	} else {
+		info, err = s.svc.CheckLatest(ctx)
+	}
+	cancel()
+	if err != nil {
+		finish(err)
+		return
+	}
+	doc.Latest = report.FromLatest(info)
+	doc.Install = &report.Install{
+		Version: info.Version,
+		Build:   info.Build,
+		JarName: info.JarName,
+		SHA256:  info.Download.Checksums.SHA256,
+	}
+	if info.UpToDate && !req.Force {
+		finish(nil)
+		return
+	}
+
+	backup := s.svc.BackupPolicy() == paper.BackupAlways
+	if req.Backup != nil {
+		backup = *req.Backup
+	}
+	// A smoke test backs the jar up whatever was asked, to roll back to.
+	if (backup || s.svc.SmokeTest()) && s.svc.JarExists() {
+		doc.Install.Backup = req.BackupName
+		if doc.Install.Backup == "" {
+			doc.Install.Backup = s.svc.BackupName()
+		}
+	}
+	if req.DryRun {
+		ctx, cancel := context.WithTimeout(r.Context(), s.svc.Timeouts().Check)
+		plan, err := s.svc.PlanInstall(ctx, paper.InstallOptions{Backup: backup, BackupName: req.BackupName})
+		cancel()
+		if err == nil {
+			doc.Plan = report.FromPlan(plan)
+		}
+		finish(err)
+		return
+	}
+	if events != nil {
+		events.send(EventRelease, doc.Latest)
+	}
+
+	ctx, cancel = context.WithTimeout(r.Context(), s.svc.Timeouts().Download)
+	defer cancel()
+	lastPercent := int64(-1)
+	err = s.svc.Install(ctx, paper.InstallOptions{
+		Backup:     backup,
+		BackupName: req.BackupName,
+		OnProgress: func(done, total int64) {
+			if events == nil || total <= 0 {
+				return
+			}
+			if pct := done * 100 / total; pct != lastPercent {
+				lastPercent = pct
+				events.send(EventProgress, Progress{Done: done, Total: total})
+			}
+		},
+		OnSmokeTest: func(r smoketest.Result) {
+			doc.Install.SmokeTest = report.FromSmokeTest(r)
+		},
+	})
+	var serr *paper.SmokeTestError
+	if errors.As(err, &serr) {
+		doc.Install.SmokeTest = report.FromSmokeTestError(serr)
+	}
+	if err == nil {
+		doc.Install.Performed = true
+	}
+	finish(err)
+}
+
+// eventStream writes Server-Sent Events, flushing after each one.
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newEventStream(w http.ResponseWriter) *eventStream {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	return &eventStream{w: w, rc: http.NewResponseController(w)}
+}
+
+// send writes one event with v as its JSON data. Write errors mean the client went
+// away, which also cancels the request context, so they are ignored here.
+func (e *eventStream) send(event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, data)
+	_ = e.rc.Flush()
+}
@@ -0,0 +1,132 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrNotLocal means a listen address would expose the API beyond this machine.
+	ErrNotLocal = errors.New("control: listen address must be loopback or a unix socket")
+	// ErrInUse means another process is listening on the unix socket's path.
+	ErrInUse = errors.New("control: control socket in use")
+)
+
+// dialTimeout bounds the dial that tells a live socket from a stale one.
+const dialTimeout = time.Second
+
+// Listen opens addr, which is either "unix:/path/to.sock" or a host:port whose host is
+// loopback (localhost, 127.0.0.1, ::1). A stale socket file, one nothing accepts
+// connections on, is replaced, but a live socket or anything else at its path is not,
+// and a new one is only ever accessible to the current user.
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("control: empty unix socket path")
+		}
+		return listenUnix(path)
+	}
+	if err := CheckAddr(addr); err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	return l, nil
+}
+
+// listenUnix listens on a unix socket at path. The socket is bound in a new directory
+// only the current user can enter and made private there, then renamed into place, so
+// it is never open to others, not even between binding and chmod.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("control: %s exists and is not a socket", path)
+		}
+		conn, err := net.DialTimeout("unix", path, dialTimeout)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrInUse, path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("control: check %s: %w", path, err)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("control: remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+
+	private, err := os.MkdirTemp(filepath.Dir(path), ".paper-control-")
+	if err != nil {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	defer os.RemoveAll(private)
+	bound := filepath.Join(private, "sock")
+	l, err := net.Listen("unix", bound)
+	if err != nil {
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	ul := l.(*net.UnixListener)
+	// Closing it would unlink the bound path, long gone; unixListener removes the real one.
+	ul.SetUnlinkOnClose(false)
+	if err := os.Chmod(bound, 0o600); err == nil {
+		err = os.Rename(bound, path)
+	}
+	var fi os.FileInfo
+	if err == nil {
+		fi, err = os.Stat(path)
+	}
+	if err != nil {
+		ul.Close()
+		return nil, fmt.Errorf("control: %w", err)
+	}
+	return &unixListener{UnixListener: ul, path: path, file: fi}, nil
+}
+
+// unixListener is a socket renamed to path after binding. It reports and, on Close,
+// removes path, unless path is no longer our socket.
+type unixListener struct {
+	*net.UnixListener
+	path string
+	file os.FileInfo // path just after binding
+}
+
+func (l *unixListener) Addr() net.Addr { return &net.UnixAddr{Name: l.path, Net: "unix"} }
+
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	if fi, statErr := os.Stat(l.path); statErr == nil && os.SameFile(fi, l.file) {
+		os.Remove(l.path)
+	}
+	return err
+}
+
+// CheckAddr validates a listen address without opening it.
+func CheckAddr(addr string) error {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return fmt.Errorf("control: empty unix socket path")
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("control: listen address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrNotLocal, addr)
+}
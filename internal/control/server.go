@@ -0,0 +1,345 @@
+// Package control is an opt-in HTTP API over paper.Service for local dashboards and
+// tooling. It listens only on loopback or a unix socket, requires a bearer token, and
+// answers with the same report.Document JSON the CLI prints with --output json. Installs
+// go through Service.Install, the same journaled, locked path the TUI uses, and can
+// stream their progress as Server-Sent Events.
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/report"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+// ErrNoToken means the server was configured without a token. The API never runs
+// unauthenticated.
+var ErrNoToken = errors.New("control: a token is required")
+
+// shutdownTimeout bounds how long Serve waits for requests to finish after its context
+// is done. Installs in progress are cancelled, so this is only their cleanup.
+const shutdownTimeout = 10 * time.Second
+
+// Service is the part of paper.Service the API exposes.
+type Service interface {
+	Installed() (state.State, error)
+	JarExists() bool
+	CheckLatest(ctx context.Context) (paper.LatestInfo, error)
+	Select(ctx context.Context, version string, build int) (paper.LatestInfo, error)
+	Install(ctx context.Context, opts paper.InstallOptions) error
+	PlanInstall(ctx context.Context, opts paper.InstallOptions) (paper.Plan, error)
+	Rollback(name string) (paper.RollbackResult, error)
+	PlanRollback(name string) (paper.Plan, error)
+	Verify() (paper.VerifyResult, error)
+	Recover() (paper.Recovery, error)
+	Timeouts() paper.Timeouts
+	BackupPolicy() paper.BackupPolicy
+	BackupName() string
+	SmokeTest() bool
+}
+
+// Server routes API requests to a Service. Build one with New.
+type Server struct {
+	svc   Service
+	dir   string
+	token string
+	log   *slog.Logger
+	mux   *http.ServeMux
+
+	// busy serializes the handlers that resolve or install a release: the service
+	// caches the release between CheckLatest/Select and Install, so two of them
+	// interleaving could install something nobody asked for.
+	busy sync.Mutex
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithLogger sets where the server logs requests.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) {
+		if l != nil {
+			s.log = l
+		}
+	}
+}
+
+// New returns a Server for svc, whose target directory is dir. Requests must carry
+// "Authorization: Bearer <token>".
+func New(svc Service, dir, token string, opts ...Option) (*Server, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	s := &Server{svc: svc, dir: dir, token: token, log: logging.Discard(), mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("GET /v1/status", s.handleStatus)
+	s.mux.HandleFunc("GET /v1/latest", s.handleLatest)
+	s.mux.HandleFunc("GET /v1/history", s.handleHistory)
+	s.mux.HandleFunc("GET /v1/verify", s.handleVerify)
+	s.mux.HandleFunc("POST /v1/install", s.handleInstall)
+	s.mux.HandleFunc("POST /v1/rollback", s.handleRollback)
+	return s, nil
+}
+
+// ServeHTTP authenticates the request and dispatches it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.log.Debug("control request", logging.KeyEvent, logging.EventControl, "method", r.Method, "path", r.URL.Path)
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+		s.log.Warn("control request rejected", logging.KeyEvent, logging.EventControl,
+			"method", r.Method, "path", r.URL.Path, "reason", "bad or missing token")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="paper-mc-tui"`)
+		doc := report.New(commandFor(r))
+		doc.Error = &report.Error{Kind: report.KindUnauthorized, Message: "missing or invalid bearer token"}
+		writeDoc(w, doc)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve handles connections on l until ctx is done, then shuts down gracefully.
+// Request contexts derive from ctx, so an install in progress is cancelled too.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	s.log.Info("control API listening", logging.KeyEvent, logging.EventControl, "addr", l.Addr().String())
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+	select {
+	case err := <-errc:
+		return fmt.Errorf("control: %w", err)
+	case <-ctx.Done():
+	}
+
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return fmt.Errorf("control: shutdown: %w", err)
+	}
+	s.log.Info("control API stopped", logging.KeyEvent, logging.EventControl)
+	return nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	doc := report.New("status")
+	st, err := s.svc.Installed()
+	if err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	doc.Installed = report.FromState(st)
+	doc.Installed.JarPresent = s.svc.JarExists()
+	if h, err := lock.Read(s.dir); err == nil {
+		doc.Installed.LockedBy = report.FromHolder(h)
+	}
+	s.ok(w, doc)
+}
+
+func (s *Server) handleLatest(w http.ResponseWriter, r *http.Request) {
+	doc := report.New("latest")
+	if !s.busy.TryLock() {
+		s.rejectBusy(w, doc)
+		return
+	}
+	defer s.busy.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.svc.Timeouts().Check)
+	defer cancel()
+	info, err := s.svc.CheckLatest(ctx)
+	if err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	st, err := s.svc.Installed()
+	if err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	doc.Latest = report.FromLatest(info)
+	doc.Installed = report.FromState(st)
+	doc.Installed.JarPresent = s.svc.JarExists()
+	s.ok(w, doc)
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	doc := report.New("history")
+	st, err := s.svc.Installed()
+	if err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	doc.History = report.FromHistory(st.History)
+	s.ok(w, doc)
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	doc := report.New("verify")
+	res, err := s.svc.Verify()
+	if err == nil || errors.Is(err, paper.ErrJarModified) {
+		doc.Verify = report.FromVerify(res)
+	}
+	if err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	s.ok(w, doc)
+}
+
+// rollbackRequest is the optional POST /v1/rollback body.
+type rollbackRequest struct {
+	BackupName string `json:"backup_name"`
+	DryRun     bool   `json:"dry_run"` // answer with the plan and change nothing
+}
+
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	doc := report.New("rollback")
+	var req rollbackRequest
+	if err := decodeBody(r, &req); err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	if !s.busy.TryLock() {
+		s.rejectBusy(w, doc)
+		return
+	}
+	defer s.busy.Unlock()
+
+	name := req.BackupName
+	if name == "" {
+		name = s.svc.BackupName()
+	}
+	if req.DryRun {
+		plan, err := s.svc.PlanRollback(name)
+		if err != nil {
+			s.fail(w, doc, err)
+			return
+		}
+		doc.Plan = report.FromPlan(plan)
+		s.ok(w, doc)
+		return
+	}
+	if err := s.recoverFirst(doc); err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	res, err := s.svc.Rollback(name)
+	if err != nil {
+		s.fail(w, doc, err)
+		return
+	}
+	doc.Rollback = report.FromRollback(res)
+	s.ok(w, doc)
+}
+
+// recoverFirst finishes or undoes an interrupted operation, as the CLI commands do.
+func (s *Server) recoverFirst(doc *report.Document) error {
+	rec, err := s.svc.Recover()
+	if err != nil {
+		return err
+	}
+	doc.Recovery = report.FromRecovery(rec)
+	return nil
+}
+
+// ok writes a successful document.
+func (s *Server) ok(w http.ResponseWriter, doc *report.Document) {
+	doc.OK = true
+	writeDoc(w, doc)
+}
+
+// fail writes doc with err classified, and an HTTP status to match.
+func (s *Server) fail(w http.ResponseWriter, doc *report.Document, err error) {
+	var ue usageError
+	if errors.As(err, &ue) {
+		doc.Error = &report.Error{Kind: report.KindUsage, Message: err.Error()}
+	} else {
+		doc.Error = report.FromError(err)
+	}
+	writeDoc(w, doc)
+}
+
+func (s *Server) rejectBusy(w http.ResponseWriter, doc *report.Document) {
+	doc.Error = &report.Error{Kind: report.KindBusy, Message: "another install, rollback or check is in progress"}
+	writeDoc(w, doc)
+}
+
+// writeDoc writes doc as JSON with the status its error (if any) maps to.
+func writeDoc(w http.ResponseWriter, doc *report.Document) {
+	status := http.StatusOK
+	if doc.Error != nil {
+		status = statusFor(doc.Error.Kind)
+		doc.ExitCode = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(doc) // the client is gone if this fails
+}
+
+// statusFor maps an error kind to an HTTP status.
+func statusFor(kind report.ErrorKind) int {
+	switch kind {
+	case report.KindUsage:
+		return http.StatusBadRequest
+	case report.KindUnauthorized:
+		return http.StatusUnauthorized
+	case report.KindNotInstalled, report.KindNoBackup:
+		return http.StatusNotFound
+	case report.KindLocked, report.KindPendingRecovery, report.KindBusy, report.KindJarModified:
+		return http.StatusConflict
+	case report.KindNoBuild, report.KindNoServerDownload, report.KindHTTPStatus,
+		report.KindChecksumMismatch, report.KindSizeMismatch:
+		return http.StatusBadGateway
+	case report.KindTimeout:
+		return http.StatusGatewayTimeout
+	case report.KindCanceled:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// usageError marks a malformed request.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// decodeBody decodes an optional JSON body into v, rejecting unknown fields.
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return usageError{fmt.Errorf("invalid request body: %w", err)}
+	}
+	return nil
+}
+
+// commandFor names the document for a request that never reached its handler.
+func commandFor(r *http.Request) string {
+	if name, ok := strings.CutPrefix(r.URL.Path, "/v1/"); ok && name != "" {
+		return name
+	}
+	return "unknown"
+}
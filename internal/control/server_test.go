@@ -0,0 +1,364 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/report"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+const testToken = "s3cret"
+
+// fakeService is an in-memory Service: Install reports progress in quarters and then
+// records latest as installed.
+type fakeService struct {
+	st        state.State
+	latest    paper.LatestInfo
+	verifyErr error
+	smokeTest bool
+	installs  []paper.InstallOptions
+}
+
+func (f *fakeService) Installed() (state.State, error) { return f.st, nil }
+func (f *fakeService) JarExists() bool                 { return f.st.Build != 0 }
+func (f *fakeService) CheckLatest(context.Context) (paper.LatestInfo, error) {
+	return f.latest, nil
+}
+func (f *fakeService) Select(_ context.Context, version string, build int) (paper.LatestInfo, error) {
+	return paper.LatestInfo{Version: version, Build: build}, nil
+}
+func (f *fakeService) Install(_ context.Context, opts paper.InstallOptions) error {
+	f.installs = append(f.installs, opts)
+	for done := int64(25); done <= 100; done += 25 {
+		if opts.OnProgress != nil {
+			opts.OnProgress(done, 100)
+		}
+	}
+	f.st = state.WithInstall(f.st, state.State{Version: f.latest.Version, Build: f.latest.Build, InstalledAt: time.Now()})
+	return nil
+}
+func (f *fakeService) PlanInstall(_ context.Context, opts paper.InstallOptions) (paper.Plan, error) {
+	p := paper.Plan{Op: "install", Download: &f.latest.Download}
+	if opts.Backup {
+		p.Steps = append(p.Steps, journal.Step{Kind: journal.StepBackup, From: "paper.jar", To: "paper.backup.jar"})
+	}
+	p.Steps = append(p.Steps, journal.Step{Kind: journal.StepSwap, From: ".paper-staged.jar", To: "paper.jar"})
+	return p, nil
+}
+func (f *fakeService) PlanRollback(string) (paper.Plan, error) {
+	return paper.Plan{}, paper.ErrNoBackup
+}
+func (f *fakeService) Rollback(string) (paper.RollbackResult, error) {
+	return paper.RollbackResult{}, paper.ErrNoBackup
+}
+func (f *fakeService) Verify() (paper.VerifyResult, error) {
+	return paper.VerifyResult{Expected: "aa", Actual: "bb"}, f.verifyErr
+}
+func (f *fakeService) Recover() (paper.Recovery, error) { return paper.Recovery{}, nil }
+func (f *fakeService) Timeouts() paper.Timeouts {
+	return paper.Timeouts{Check: time.Second, Download: time.Minute}
+}
+func (f *fakeService) BackupPolicy() paper.BackupPolicy { return paper.BackupAlways }
+func (f *fakeService) BackupName() string               { return "paper.backup.jar" }
+func (f *fakeService) SmokeTest() bool                  { return f.smokeTest }
+
+func newTestServer(t *testing.T, svc *fakeService) (*Server, *httptest.Server) {
+	t.Helper()
+	s, err := New(svc, t.TempDir(), testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method, path, body string) (*http.Response, report.Document) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc report.Document
+	if resp.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp, doc
+}
+
+func TestNewRequiresToken(t *testing.T) {
+	if _, err := New(&fakeService{}, t.TempDir(), ""); !errors.Is(err, ErrNoToken) {
+		t.Fatalf("New = %v, want ErrNoToken", err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	_, ts := newTestServer(t, &fakeService{})
+	for _, auth := range []string{"", "Bearer wrong", testToken} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("auth %q: status %d, want 401", auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestStatusAndHistory(t *testing.T) {
+	svc := &fakeService{}
+	svc.st = state.WithInstall(svc.st, state.State{Version: "26.1.2", Build: 69})
+	svc.st = state.WithInstall(svc.st, state.State{Version: "26.1.2", Build: 70})
+	_, ts := newTestServer(t, svc)
+
+	resp, doc := do(t, ts, http.MethodGet, "/v1/status", "")
+	if resp.StatusCode != http.StatusOK || !doc.OK || doc.Installed.Build != 70 || !doc.Installed.JarPresent {
+		t.Errorf("status: %d %+v", resp.StatusCode, doc.Installed)
+	}
+	_, doc = do(t, ts, http.MethodGet, "/v1/history", "")
+	if len(doc.History) != 2 || doc.History[0].Build != 70 {
+		t.Errorf("history = %+v, want newest first", doc.History)
+	}
+}
+
+func TestInstallJSON(t *testing.T) {
+	svc := &fakeService{latest: paper.LatestInfo{Version: "26.1.2", Build: 71}}
+	_, ts := newTestServer(t, svc)
+
+	resp, doc := do(t, ts, http.MethodPost, "/v1/install", `{"backup": false}`)
+	if resp.StatusCode != http.StatusOK || !doc.OK || !doc.Install.Performed || doc.Install.Build != 71 {
+		t.Fatalf("install: %d %+v", resp.StatusCode, doc)
+	}
+	if len(svc.installs) != 1 || svc.installs[0].Backup {
+		t.Errorf("installs = %+v, want one without backup", svc.installs)
+	}
+
+	// Now up to date: nothing to do without force.
+	svc.latest.UpToDate = true
+	_, doc = do(t, ts, http.MethodPost, "/v1/install", "")
+	if !doc.OK || doc.Install.Performed || len(svc.installs) != 1 {
+		t.Errorf("second install: %+v", doc.Install)
+	}
+}
+
+func TestInstallReportsSmokeTestBackup(t *testing.T) {
+	svc := &fakeService{latest: paper.LatestInfo{Version: "26.1.2", Build: 71}, smokeTest: true}
+	svc.st = state.WithInstall(svc.st, state.State{Version: "26.1.2", Build: 70}) // so there is a jar to back up
+	_, ts := newTestServer(t, svc)
+
+	_, doc := do(t, ts, http.MethodPost, "/v1/install", `{"backup": false}`)
+	if !doc.OK || doc.Install.Backup != "paper.backup.jar" {
+		t.Errorf("install with a smoke test = %+v, want the backup it takes reported", doc.Install)
+	}
+}
+
+func TestInstallDryRun(t *testing.T) {
+	svc := &fakeService{latest: paper.LatestInfo{Version: "26.1.2", Build: 71}}
+	_, ts := newTestServer(t, svc)
+
+	resp, doc := do(t, ts, http.MethodPost, "/v1/install", `{"dry_run": true, "backup": true}`)
+	if resp.StatusCode != http.StatusOK || !doc.OK || doc.Plan == nil || len(doc.Plan.Steps) != 2 {
+		t.Fatalf("dry run: %d %+v", resp.StatusCode, doc.Plan)
+	}
+	if doc.Install.Performed || len(svc.installs) != 0 {
+		t.Error("dry run installed")
+	}
+	resp, doc = do(t, ts, http.MethodPost, "/v1/rollback", `{"dry_run": true}`)
+	if resp.StatusCode != http.StatusNotFound || doc.Error.Kind != report.KindNoBackup {
+		t.Errorf("rollback dry run: %d %+v", resp.StatusCode, doc.Error)
+	}
+}
+
+func TestInstallEvents(t *testing.T) {
+	svc := &fakeService{latest: paper.LatestInfo{Version: "26.1.2", Build: 71}}
+	svc.st = state.WithInstall(svc.st, state.State{Version: "26.1.2", Build: 70}) // so there is a jar to back up
+	_, ts := newTestServer(t, svc)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/install", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var events []string
+	var result report.Document
+	sc := bufio.NewScanner(resp.Body)
+	var event string
+	for sc.Scan() {
+		line := sc.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			events = append(events, name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && event == EventResult {
+			if err := json.Unmarshal([]byte(data), &result); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []string{EventRelease, EventProgress, EventProgress, EventProgress, EventProgress, EventResult}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if !result.OK || !result.Install.Performed || result.Install.Backup != "paper.backup.jar" {
+		t.Errorf("result = %+v", result.Install)
+	}
+}
+
+func TestErrorsMapToStatus(t *testing.T) {
+	svc := &fakeService{verifyErr: paper.ErrJarModified}
+	s, ts := newTestServer(t, svc)
+
+	resp, doc := do(t, ts, http.MethodGet, "/v1/verify", "")
+	if resp.StatusCode != http.StatusConflict || doc.Error.Kind != report.KindJarModified || doc.Verify == nil {
+		t.Errorf("verify: %d %+v", resp.StatusCode, doc)
+	}
+	resp, doc = do(t, ts, http.MethodPost, "/v1/rollback", "")
+	if resp.StatusCode != http.StatusNotFound || doc.Error.Kind != report.KindNoBackup {
+		t.Errorf("rollback: %d %+v", resp.StatusCode, doc.Error)
+	}
+	resp, doc = do(t, ts, http.MethodPost, "/v1/install", `{"build": 70}`)
+	if resp.StatusCode != http.StatusBadRequest || doc.Error.Kind != report.KindUsage {
+		t.Errorf("build without version: %d %+v", resp.StatusCode, doc.Error)
+	}
+	resp, doc = do(t, ts, http.MethodPost, "/v1/install", `{"bogus": 1}`)
+	if resp.StatusCode != http.StatusBadRequest || doc.Error.Kind != report.KindUsage {
+		t.Errorf("unknown field: %d %+v", resp.StatusCode, doc.Error)
+	}
+
+	s.busy.Lock()
+	resp, doc = do(t, ts, http.MethodPost, "/v1/install", "")
+	s.busy.Unlock()
+	if resp.StatusCode != http.StatusConflict || doc.Error.Kind != report.KindBusy {
+		t.Errorf("busy: %d %+v", resp.StatusCode, doc.Error)
+	}
+}
+
+func TestListen(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:8765", "example.com:80", ":8765"} {
+		if _, err := Listen(addr); !errors.Is(err, ErrNotLocal) {
+			t.Errorf("Listen(%q) = %v, want ErrNotLocal", addr, err)
+		}
+	}
+
+	sock := filepath.Join(t.TempDir(), "c.sock")
+	l, err := Listen("unix:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(sock); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket = %v, %v; want mode 0600", fi, err)
+	}
+	if l.Addr().String() != sock {
+		t.Errorf("Addr = %s, want %s", l.Addr(), sock)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(sock)); len(entries) != 1 {
+		t.Errorf("listening left %d entries beside the socket", len(entries)-1)
+	}
+	if conn, err := net.Dial("unix", sock); err != nil {
+		t.Errorf("dial the renamed socket: %v", err)
+	} else {
+		conn.Close()
+	}
+	l.Close()
+	// A stale socket file, as a crash leaves one, is replaced.
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	l, err = Listen("unix:" + sock)
+	if err != nil {
+		t.Fatalf("relisten: %v", err)
+	}
+	l.Close()
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Error("Close left the socket behind")
+	}
+
+	// A live one is not, and its owner's Close leaves a socket that replaced it alone.
+	first, err := Listen("unix:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen("unix:" + sock); !errors.Is(err, ErrInUse) {
+		t.Errorf("Listen on a live socket = %v, want ErrInUse", err)
+	}
+	os.Remove(sock)
+	second, err := Listen("unix:" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+	if conn, err := net.Dial("unix", sock); err != nil {
+		t.Errorf("the first listener's Close removed the second's socket: %v", err)
+	} else {
+		conn.Close()
+	}
+	second.Close()
+
+	// Anything else at the path is not.
+	os.WriteFile(sock, []byte("precious"), 0o644)
+	if _, err := Listen("unix:" + sock); err == nil {
+		t.Error("Listen replaced a regular file")
+	}
+	if data, _ := os.ReadFile(sock); string(data) != "precious" {
+		t.Error("Listen removed a regular file")
+	}
+}
+
+func TestServeStopsOnCancel(t *testing.T) {
+	s, err := New(&fakeService{}, t.TempDir(), testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, l) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+}
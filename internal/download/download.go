@@ -11,11 +11,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
 	"github.com/mbacalan/paper-mc-tui/internal/papermc"
 )
 
@@ -32,6 +36,8 @@ var (
 type Downloader struct {
 	httpClient *http.Client
 	userAgent  string
+	log        *slog.Logger
+	metrics    *metrics.Metrics
 }
 
 // Option configures a Downloader.
@@ -55,11 +61,31 @@ func WithUserAgent(ua string) Option {
 	}
 }
 
+// WithLogger sets the logger that records each transfer's outcome, size and duration.
+func WithLogger(l *slog.Logger) Option {
+	return func(d *Downloader) {
+		if l != nil {
+			d.log = l
+		}
+	}
+}
+
+// WithMetrics sets where bytes, durations and checksum failures are recorded.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(d *Downloader) {
+		if m != nil {
+			d.metrics = m
+		}
+	}
+}
+
 // NewDownloader returns a Downloader with sensible defaults, overridden by opts.
 func NewDownloader(opts ...Option) *Downloader {
 	d := &Downloader{
 		httpClient: &http.Client{}, // no timeout; the caller's context bounds the transfer
 		userAgent:  papermc.DefaultUserAgent,
+		log:        logging.Discard(),
+		metrics:    metrics.Discard(),
 	}
 	for _, opt := range opts {
 		opt(d)
@@ -71,6 +97,19 @@ func NewDownloader(opts ...Option) *Downloader {
 // downloaded so far and the total expected (dl.Size, 0 if unknown); it fires on each 1%
 // change and once at completion.
 func (d *Downloader) Download(ctx context.Context, dl papermc.Download, destPath string, onProgress func(done, total int64)) (err error) {
+	start := time.Now()
+	var written int64
+	defer func() {
+		d.metrics.ObserveDownload(written, time.Since(start), err, errors.Is(err, ErrChecksumMismatch))
+		attrs := []any{logging.KeyEvent, logging.EventDownload, "name", dl.Name,
+			logging.KeyBytes, written, logging.KeyDuration, time.Since(start)}
+		if err != nil {
+			d.log.Error("download failed", append(attrs, logging.Err(err))...)
+			return
+		}
+		d.log.Info("download verified", attrs...)
+	}()
+
 	dir := filepath.Dir(destPath)
 	tmp, err := os.CreateTemp(dir, ".paper-*.jar.tmp")
 	if err != nil {
@@ -104,7 +143,7 @@ func (d *Downloader) Download(ctx context.Context, dl papermc.Download, destPath
 
 	hasher := sha256.New()
 	pr := &progressReader{r: resp.Body, total: dl.Size, onProgress: onProgress}
-	written, err := io.Copy(io.MultiWriter(tmp, hasher), pr)
+	written, err = io.Copy(io.MultiWriter(tmp, hasher), pr)
 	if err != nil {
 		return fmt.Errorf("download: copy body: %w", err)
 	}
@@ -160,3 +199,18 @@ func (pr *progressReader) Read(p []byte) (int, error) {
 	}
 	return n, err
 }
+
+// HashFile returns the hex SHA256 of the file at path, for checking an installed jar
+// against the checksum recorded when it was downloaded.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("download: open %s: %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("download: hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
 	"github.com/mbacalan/paper-mc-tui/internal/papermc"
 )
 
@@ -166,3 +168,49 @@ func TestDownloadProgress(t *testing.T) {
 		t.Errorf("final progress = %d/%d, want %d/%d", lastDone, lastTotal, len(body), len(body))
 	}
 }
+
+func TestHashFile(t *testing.T) {
+	body := []byte("installed jar")
+	path := filepath.Join(t.TempDir(), "paper.jar")
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := HashFile(path)
+	if err != nil {
+		t.Fatalf("HashFile: %v", err)
+	}
+	if got != sha256Hex(body) {
+		t.Errorf("HashFile = %s, want %s", got, sha256Hex(body))
+	}
+	if _, err := HashFile(path + ".missing"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestDownloadMetrics(t *testing.T) {
+	body := []byte("real content")
+	srv := payloadServer(t, body)
+	dir := t.TempDir()
+	m := metrics.New()
+	d := NewDownloader(WithMetrics(m))
+
+	if err := d.Download(context.Background(), dl(srv, body, sha256Hex(body)), filepath.Join(dir, "a.jar"), nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Download(context.Background(), dl(srv, body, sha256Hex([]byte("different"))), filepath.Join(dir, "b.jar"), nil)
+
+	var out strings.Builder
+	if _, err := m.Registry().WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		fmt.Sprintf("paper_mc_download_bytes_total %d\n", 2*len(body)),
+		"paper_mc_checksum_failures_total 1\n",
+		`paper_mc_download_duration_seconds_count{result="ok"} 1`,
+		`paper_mc_download_duration_seconds_count{result="error"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q in:\n%s", want, out.String())
+		}
+	}
+}
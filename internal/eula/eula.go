@@ -0,0 +1,75 @@
+// Package eula reads and writes eula.txt, where a Minecraft server records that its
+// owner accepted the Minecraft End User License Agreement. The server stops straight
+// after its first start, writing eula=false, until the file says eula=true.
+package eula
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/atomicfile"
+	"github.com/mbacalan/paper-mc-tui/internal/properties"
+)
+
+const (
+	// FileName is eula.txt's name in the server directory.
+	FileName = "eula.txt"
+	// URL is where the EULA can be read; the server links to it in eula.txt.
+	URL = "https://aka.ms/MinecraftEULA"
+	// dateLayout is Java's Date.toString, which the server stamps eula.txt with.
+	dateLayout = "Mon Jan 02 15:04:05 MST 2006"
+)
+
+// State is whether the EULA has been accepted.
+type State int
+
+const (
+	Missing  State = iota // there is no eula.txt
+	Declined              // eula.txt does not say eula=true
+	Accepted
+)
+
+func (s State) String() string {
+	switch s {
+	case Missing:
+		return "missing"
+	case Declined:
+		return "not accepted"
+	}
+	return "accepted"
+}
+
+// Read returns the state of the EULA for the server in dir. Like the server, it takes
+// "true" in any case as acceptance.
+func Read(dir string) (State, error) {
+	p, err := properties.Load(filepath.Join(dir, FileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return Missing, nil
+	case err != nil:
+		return Missing, fmt.Errorf("eula: %w", err)
+	case strings.EqualFold(strings.TrimSpace(p.Get("eula", "")), "true"):
+		return Accepted, nil
+	}
+	return Declined, nil
+}
+
+// Render returns an accepting eula.txt as the server itself writes it, stamped with at.
+func Render(at time.Time) []byte {
+	return []byte("#By changing the setting below to TRUE you are indicating your agreement to our EULA (" + URL + ").\n" +
+		"#" + at.Format(dateLayout) + "\n" +
+		"eula=true\n")
+}
+
+// Accept writes an accepting eula.txt into dir, atomically replacing any file there.
+func Accept(dir string, at time.Time) error {
+	path := filepath.Join(dir, FileName)
+	if err := atomicfile.WriteFile(path, Render(at), 0o644); err != nil {
+		return fmt.Errorf("eula: write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package eula
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	if st, err := Read(dir); err != nil || st != Missing {
+		t.Errorf("Read without eula.txt = %v, %v, want missing", st, err)
+	}
+	for data, want := range map[string]State{
+		"#By changing the setting below to TRUE you are indicating your agreement to our EULA (https://aka.ms/MinecraftEULA).\n#Sat Oct 17 12:00:00 UTC 2026\neula=false\n": Declined,
+		"eula=TRUE\n": Accepted,
+		"eula = true": Accepted,
+		"":            Declined,
+	} {
+		os.WriteFile(filepath.Join(dir, FileName), []byte(data), 0o644)
+		if st, err := Read(dir); err != nil || st != want {
+			t.Errorf("Read(%q) = %v, %v, want %v", data, st, err, want)
+		}
+	}
+}
+
+func TestAccept(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, FileName), []byte("eula=false\n"), 0o644)
+	at := time.Date(2026, 10, 19, 14, 5, 9, 0, time.UTC)
+	if err := Accept(dir, at); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(filepath.Join(dir, FileName))
+	want := "#By changing the setting below to TRUE you are indicating your agreement to our EULA (https://aka.ms/MinecraftEULA).\n" +
+		"#Mon Oct 19 14:05:09 UTC 2026\neula=true\n"
+	if string(got) != want {
+		t.Errorf("eula.txt =\n%s\nwant\n%s", got, want)
+	}
+	if st, err := Read(dir); err != nil || st != Accepted {
+		t.Errorf("Read after Accept = %v, %v", st, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "."+FileName+"-*")); len(matches) > 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
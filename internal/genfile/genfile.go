@@ -0,0 +1,63 @@
+// Package genfile compares a file the tool generates, such as start.sh or a systemd
+// unit, with the one on disk, shows what regenerating it would change, and writes it.
+// Errors are left for the generating package to prefix with its own name.
+package genfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mbacalan/paper-mc-tui/internal/atomicfile"
+	"github.com/mbacalan/paper-mc-tui/internal/textdiff"
+)
+
+// Change is a generated file as it is on disk and as it would be written.
+type Change struct {
+	Path string
+	Old  []byte // nil if there is no file yet
+	New  []byte
+	Mode os.FileMode // what Write gives the file
+}
+
+// Prepare compares data with the file at path, which Write would give mode.
+func Prepare(path string, data []byte, mode os.FileMode) (Change, error) {
+	c := Change{Path: path, New: data, Mode: mode}
+	old, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		c.Old = old
+	case !errors.Is(err, os.ErrNotExist):
+		return Change{}, err
+	}
+	return c, nil
+}
+
+// Exists reports whether there is a file already.
+func (c Change) Exists() bool { return c.Old != nil }
+
+// Changed reports whether writing would change anything.
+func (c Change) Changed() bool { return !c.Exists() || !bytes.Equal(c.Old, c.New) }
+
+// Diff is the change as a unified diff, or "" if there is none.
+func (c Change) Diff() string {
+	if !c.Changed() {
+		return ""
+	}
+	name := filepath.Base(c.Path)
+	from := name
+	if !c.Exists() {
+		from = "/dev/null"
+	}
+	return textdiff.Unified(from, name+" (new)", c.Old, c.New)
+}
+
+// Write replaces the file atomically.
+func (c Change) Write() error {
+	if err := atomicfile.WriteFile(c.Path, c.New, c.Mode); err != nil {
+		return fmt.Errorf("write %s: %w", c.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package genfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.sh")
+	c, err := Prepare(path, []byte("one\n"), 0o750)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Exists() || !c.Changed() || !strings.HasPrefix(c.Diff(), "--- /dev/null\n+++ run.sh (new)\n") {
+		t.Errorf("new file: exists %v, changed %v, diff:\n%s", c.Exists(), c.Changed(), c.Diff())
+	}
+	if err := c.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o750 {
+		t.Errorf("mode = %v, %v", fi.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+
+	if c, _ = Prepare(path, []byte("one\n"), 0o750); c.Changed() || c.Diff() != "" {
+		t.Error("identical content counts as changed")
+	}
+	c, _ = Prepare(path, []byte("two\n"), 0o750)
+	if d := c.Diff(); !strings.Contains(d, "--- run.sh\n+++ run.sh (new)\n") || !strings.Contains(d, "-one\n+two\n") {
+		t.Errorf("diff:\n%s", d)
+	}
+}
@@ -0,0 +1,304 @@
+// Package java finds the Java runtimes installed on the machine and tells which Paper
+// versions they can run. It asks each `java -version`, so a runtime is described by
+// what it reports rather than by its path or directory name.
+package java
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+// probeTimeout bounds one `java -version`; a cold JVM can take a few seconds.
+const probeTimeout = 10 * time.Second
+
+// ErrTooOld means a runtime is older than the Java release a Paper version needs.
+var ErrTooOld = errors.New("java: runtime too old")
+
+// TooOldError is ErrTooOld with the details.
+type TooOldError struct {
+	Runtime  Runtime
+	Required int
+	Version  string // the Paper version
+}
+
+func (e *TooOldError) Error() string {
+	return fmt.Sprintf("java: %s is Java %d, but Paper %s needs Java %d or newer", e.Runtime.Path, e.Runtime.Major, e.Version, e.Required)
+}
+
+func (e *TooOldError) Is(target error) bool { return target == ErrTooOld }
+
+// Runtime is one Java installation.
+type Runtime struct {
+	Path    string // the java launcher
+	Major   int    // feature release, e.g. 21 (8 for "1.8.0_392")
+	Version string // as reported, e.g. "21.0.4"
+	Name    string // the runtime line, e.g. "OpenJDK Runtime Environment Temurin-21.0.4+7 (build 21.0.4+7-LTS)"
+	Source  string // where it was found: "PATH", "JAVA_HOME" or the directory pattern
+}
+
+func (r Runtime) String() string {
+	return fmt.Sprintf("Java %d (%s) at %s", r.Major, r.Version, r.Path)
+}
+
+// versionRE matches the first line of `java -version`, e.g. `openjdk version "21.0.4"
+// 2024-07-16` or `java version "1.8.0_392"`.
+var versionRE = regexp.MustCompile(`version "([^"]+)"`)
+
+// Probe runs `path -version` and reports what it says.
+func Probe(ctx context.Context, path string) (Runtime, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "-version").CombinedOutput()
+	if err != nil {
+		return Runtime{}, fmt.Errorf("java: %s -version: %w", path, err)
+	}
+	rt, err := ParseVersion(out)
+	if err != nil {
+		return Runtime{}, fmt.Errorf("java: %s: %w", path, err)
+	}
+	rt.Path = path
+	return rt, nil
+}
+
+// ParseVersion reads the output of `java -version`.
+func ParseVersion(out []byte) (Runtime, error) {
+	lines := strings.Split(strings.TrimSpace(string(bytes.ReplaceAll(out, []byte("\r"), nil))), "\n")
+	for i, line := range lines {
+		m := versionRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		major, err := Major(m[1])
+		if err != nil {
+			return Runtime{}, err
+		}
+		rt := Runtime{Major: major, Version: m[1]}
+		if i+1 < len(lines) {
+			rt.Name = strings.TrimSpace(lines[i+1])
+		}
+		return rt, nil
+	}
+	return Runtime{}, fmt.Errorf("no version in %q", strings.TrimSpace(string(out)))
+}
+
+// Major returns the feature release of a Java version string: 21 for "21.0.4" or
+// "21-ea", 8 for "1.8.0_392".
+func Major(version string) (int, error) {
+	v := strings.TrimPrefix(version, "1.")
+	end := strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		v = v[:end]
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("unrecognized Java version %q", version)
+	}
+	return n, nil
+}
+
+// MinimumFor is the Java release a Paper version needs, for when the API does not say:
+// Java 21 from 1.20.5 (and for the year-based versions), 17 from 1.18, 16 for 1.17 and
+// 8 before that. It returns 0 for a version it cannot read.
+func MinimumFor(version string) int {
+	release, _, _ := strings.Cut(version, "-")
+	parts := strings.Split(release, ".")
+	nums := make([]int, 3)
+	for i := 0; i < len(parts) && i < 3; i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0
+		}
+		nums[i] = n
+	}
+	switch major, minor, patch := nums[0], nums[1], nums[2]; {
+	case major > 1:
+		return 21
+	case major < 1:
+		return 0
+	case minor > 20 || (minor == 20 && patch >= 5):
+		return 21
+	case minor >= 18:
+		return 17
+	case minor == 17:
+		return 16
+	}
+	return 8
+}
+
+// Check returns a *TooOldError if rt cannot run a Paper version that needs Java
+// required. A required of 0 (unknown) is always satisfied.
+func Check(rt Runtime, version string, required int) error {
+	if required > 0 && rt.Major < required {
+		return &TooOldError{Runtime: rt, Required: required, Version: version}
+	}
+	return nil
+}
+
+// DefaultPatterns are the directories searched for runtimes besides PATH and JAVA_HOME,
+// as globs matching a JDK or JRE home.
+var DefaultPatterns = defaultPatterns()
+
+func defaultPatterns() []string {
+	home, _ := os.UserHomeDir()
+	switch runtime.GOOS {
+	case "windows":
+		return []string{
+			`C:\Program Files\Java\*`,
+			`C:\Program Files\Eclipse Adoptium\*`,
+			`C:\Program Files\Microsoft\jdk-*`,
+			`C:\Program Files\Zulu\*`,
+			`C:\Program Files\Amazon Corretto\*`,
+		}
+	case "darwin":
+		return []string{
+			"/Library/Java/JavaVirtualMachines/*/Contents/Home",
+			filepath.Join(home, "Library/Java/JavaVirtualMachines/*/Contents/Home"),
+			"/opt/homebrew/opt/openjdk*",
+			filepath.Join(home, ".sdkman/candidates/java/*"),
+		}
+	}
+	return []string{
+		"/usr/lib/jvm/*",
+		"/usr/java/*",
+		"/opt/java/*",
+		"/opt/jdk*",
+		filepath.Join(home, ".sdkman/candidates/java/*"),
+		filepath.Join(home, ".jdks/*"),
+	}
+}
+
+// Finder discovers runtimes. Build one with NewFinder.
+type Finder struct {
+	getenv   func(string) string
+	patterns []string
+	log      *slog.Logger
+}
+
+// Option configures a Finder.
+type Option func(*Finder)
+
+// WithGetenv sets how PATH and JAVA_HOME are read (default os.Getenv).
+func WithGetenv(f func(string) string) Option {
+	return func(fd *Finder) {
+		if f != nil {
+			fd.getenv = f
+		}
+	}
+}
+
+// WithPatterns replaces DefaultPatterns.
+func WithPatterns(patterns ...string) Option {
+	return func(f *Finder) { f.patterns = patterns }
+}
+
+// WithLogger sets where runtimes that fail to probe are reported.
+func WithLogger(l *slog.Logger) Option {
+	return func(f *Finder) {
+		if l != nil {
+			f.log = l
+		}
+	}
+}
+
+// NewFinder returns a Finder.
+func NewFinder(opts ...Option) *Finder {
+	f := &Finder{getenv: os.Getenv, patterns: DefaultPatterns, log: logging.Discard()}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Find resolves name the way the server is started with it: a path is used as is, and
+// a bare name such as "java" is looked up in PATH.
+func (f *Finder) Find(ctx context.Context, name string) (Runtime, error) {
+	path := name
+	if !strings.ContainsRune(name, filepath.Separator) && !strings.ContainsRune(name, '/') {
+		path = f.lookPath(name)
+		if path == "" {
+			return Runtime{}, fmt.Errorf("java: %q not found in PATH", name)
+		}
+	}
+	rt, err := Probe(ctx, path)
+	if err != nil {
+		return Runtime{}, err
+	}
+	rt.Source = "configured"
+	return rt, nil
+}
+
+// Discover returns the runtimes found in PATH, JAVA_HOME and the directory patterns,
+// in that order, each once. Candidates that fail to run are skipped.
+func (f *Finder) Discover(ctx context.Context) []Runtime {
+	type candidate struct{ path, source string }
+	var candidates []candidate
+	if p := f.lookPath("java"); p != "" {
+		candidates = append(candidates, candidate{p, "PATH"})
+	}
+	if home := f.getenv("JAVA_HOME"); home != "" {
+		candidates = append(candidates, candidate{launcher(home), "JAVA_HOME"})
+	}
+	for _, pattern := range f.patterns {
+		homes, _ := filepath.Glob(pattern)
+		for _, home := range homes {
+			candidates = append(candidates, candidate{launcher(home), pattern})
+		}
+	}
+
+	var found []Runtime
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		real, err := filepath.EvalSymlinks(c.path)
+		if err != nil || seen[real] {
+			continue
+		}
+		seen[real] = true
+		rt, err := Probe(ctx, c.path)
+		if err != nil {
+			f.log.Debug("skipping java runtime", "path", c.path, logging.Err(err))
+			continue
+		}
+		rt.Source = c.source
+		found = append(found, rt)
+	}
+	return found
+}
+
+// lookPath is exec.LookPath against the Finder's PATH.
+func (f *Finder) lookPath(name string) string {
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		name += ".exe"
+	}
+	for _, dir := range filepath.SplitList(f.getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() && (runtime.GOOS == "windows" || fi.Mode()&0o111 != 0) {
+			return path
+		}
+	}
+	return ""
+}
+
+// launcher returns the java launcher in a JDK or JRE home.
+func launcher(home string) string {
+	name := "java"
+	if runtime.GOOS == "windows" {
+		name = "java.exe"
+	}
+	return filepath.Join(home, "bin", name)
+}
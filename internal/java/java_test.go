@@ -0,0 +1,137 @@
+package java
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		out   string
+		major int
+		name  string
+	}{
+		{
+			out: "openjdk version \"21.0.4\" 2024-07-16 LTS\r\nOpenJDK Runtime Environment Temurin-21.0.4+7 (build 21.0.4+7-LTS)\r\n" +
+				"OpenJDK 64-Bit Server VM Temurin-21.0.4+7 (build 21.0.4+7-LTS, mixed mode, sharing)\r\n",
+			major: 21,
+			name:  "OpenJDK Runtime Environment Temurin-21.0.4+7 (build 21.0.4+7-LTS)",
+		},
+		{
+			out:   "java version \"1.8.0_392\"\nJava(TM) SE Runtime Environment (build 1.8.0_392-b08)\n",
+			major: 8,
+			name:  "Java(TM) SE Runtime Environment (build 1.8.0_392-b08)",
+		},
+		{
+			out:   "Picked up JAVA_TOOL_OPTIONS: -Xmx1G\nopenjdk version \"25-ea\" 2025-09-16\n",
+			major: 25,
+		},
+		{out: "openjdk version \"17\" 2021-09-14\n", major: 17},
+	}
+	for _, tt := range tests {
+		rt, err := ParseVersion([]byte(tt.out))
+		if err != nil {
+			t.Errorf("ParseVersion(%q): %v", tt.out, err)
+			continue
+		}
+		if rt.Major != tt.major || rt.Name != tt.name {
+			t.Errorf("ParseVersion(%q) = %+v, want Java %d %q", tt.out, rt, tt.major, tt.name)
+		}
+	}
+	if _, err := ParseVersion([]byte("bash: java: command not found")); err == nil {
+		t.Error("ParseVersion accepted output without a version")
+	}
+}
+
+func TestMinimumFor(t *testing.T) {
+	for version, want := range map[string]int{
+		"1.16.5":    8,
+		"1.17.1":    16,
+		"1.18.2":    17,
+		"1.20.4":    17,
+		"1.20.5":    21,
+		"1.21.10":   21,
+		"26.1.2":    21,
+		"26.2-rc-2": 21,
+		"banana":    0,
+	} {
+		if got := MinimumFor(version); got != want {
+			t.Errorf("MinimumFor(%q) = %d, want %d", version, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rt := Runtime{Path: "/usr/bin/java", Major: 17, Version: "17.0.12"}
+	err := Check(rt, "1.21.10", 21)
+	if !errors.Is(err, ErrTooOld) {
+		t.Fatalf("Check = %v, want ErrTooOld", err)
+	}
+	if want := "/usr/bin/java is Java 17, but Paper 1.21.10 needs Java 21"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Check = %q, want it to say %q", err, want)
+	}
+	if err := Check(rt, "1.20.4", 17); err != nil {
+		t.Errorf("Check(17 for 17) = %v", err)
+	}
+	if err := Check(rt, "26.1.2", 0); err != nil {
+		t.Errorf("Check(unknown requirement) = %v", err)
+	}
+}
+
+// fakeJava writes a java launcher under home/bin that reports version.
+func fakeJava(t *testing.T, home, version string) string {
+	t.Helper()
+	bin := filepath.Join(home, "bin")
+	if err := os.MkdirAll(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(bin, "java")
+	script := "#!/bin/sh\necho 'openjdk version \"" + version + "\" 2026-01-20' >&2\necho 'OpenJDK Runtime Environment' >&2\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake java is a shell script")
+	}
+	root := t.TempDir()
+	onPath := fakeJava(t, filepath.Join(root, "path-jdk"), "21.0.4")
+	fakeJava(t, filepath.Join(root, "jvm", "jdk-17"), "17.0.12")
+	fakeJava(t, filepath.Join(root, "jvm", "jdk-25"), "25.0.1")
+	os.MkdirAll(filepath.Join(root, "jvm", "broken", "bin"), 0o755) // no launcher
+	env := map[string]string{
+		"PATH":      filepath.Dir(onPath),
+		"JAVA_HOME": filepath.Join(root, "jvm", "jdk-25"),
+	}
+	f := NewFinder(WithGetenv(func(k string) string { return env[k] }), WithPatterns(filepath.Join(root, "jvm", "*")))
+
+	found := f.Discover(context.Background())
+	var got []string
+	for _, rt := range found {
+		got = append(got, rt.Source+" "+rt.Version)
+	}
+	// jdk-25 is found as JAVA_HOME first and not again through the pattern.
+	want := []string{"PATH 21.0.4", "JAVA_HOME 25.0.1", filepath.Join(root, "jvm", "*") + " 17.0.12"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Discover =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	rt, err := f.Find(context.Background(), "java")
+	if err != nil || rt.Path != onPath || rt.Major != 21 {
+		t.Errorf("Find(java) = %+v, %v", rt, err)
+	}
+	if _, err := f.Find(context.Background(), "java11"); err == nil {
+		t.Error("Find found a launcher that is not in PATH")
+	}
+	if _, err := f.Find(context.Background(), filepath.Join(root, "jvm", "broken", "bin", "java")); err == nil {
+		t.Error("Find accepted a missing launcher")
+	}
+}
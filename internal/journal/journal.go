@@ -0,0 +1,152 @@
+// Package journal is a small write-ahead log for multi-step changes to the target
+// directory. An operation records its whole plan (backup, swap, state save, prune)
+// before touching anything and marks each step done as it goes, so a crash at any point
+// leaves enough on disk for the next run to finish the operation or undo it.
+//
+// The journal only persists the plan; deciding how to replay or roll back a step is the
+// caller's job, since only the caller knows what the steps mean.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+const fileName = "paper-mc.journal"
+
+// ErrPending means an earlier operation did not finish and must be recovered before a
+// new one can begin.
+var ErrPending = errors.New("journal: an unfinished operation is pending recovery")
+
+// StepKind identifies what a step does.
+type StepKind string
+
+const (
+	// StepBackup renames From (the live jar) to To.
+	StepBackup StepKind = "backup"
+	// StepSwap renames From (a verified, staged jar) to To (the live jar).
+	StepSwap StepKind = "swap"
+	// StepSaveState writes Entry.Next to state.json.
+	StepSaveState StepKind = "save_state"
+	// StepPrune removes temp files left behind by interrupted downloads and saves.
+	StepPrune StepKind = "prune"
+)
+
+// Step is one unit of work in an Entry.
+type Step struct {
+	Kind StepKind `json:"kind"`
+	From string   `json:"from,omitempty"`
+	To   string   `json:"to,omitempty"`
+	Done bool     `json:"done"`
+}
+
+// Entry is the plan for one operation, as written before it starts.
+type Entry struct {
+	Op        string      `json:"op"` // e.g. "install"
+	StartedAt time.Time   `json:"started_at"`
+	Prev      state.State `json:"prev"` // state.json before the operation
+	Next      state.State `json:"next"` // state.json once it completes
+	Steps     []Step      `json:"steps"`
+}
+
+// Step returns the first step of the given kind, if the entry has one.
+func (e *Entry) Step(kind StepKind) (*Step, bool) {
+	for i := range e.Steps {
+		if e.Steps[i].Kind == kind {
+			return &e.Steps[i], true
+		}
+	}
+	return nil, false
+}
+
+// Journal reads and writes the journal file in a directory.
+type Journal struct {
+	dir  string
+	path string
+}
+
+// New returns a Journal rooted in dir. It does not touch the disk.
+func New(dir string) *Journal {
+	return &Journal{dir: dir, path: filepath.Join(dir, fileName)}
+}
+
+// Path returns the journal file's location.
+func (j *Journal) Path() string { return j.path }
+
+// Pending returns the unfinished entry, if any. ok is false when there is nothing to
+// recover.
+func (j *Journal) Pending() (e Entry, ok bool, err error) {
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Entry{}, false, nil
+		}
+		return Entry{}, false, fmt.Errorf("journal: read %s: %w", j.path, err)
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Entry{}, false, fmt.Errorf("journal: parse %s: %w", j.path, err)
+	}
+	return e, true, nil
+}
+
+// Begin records e as the operation in progress. It refuses to overwrite an unfinished
+// entry, returning ErrPending.
+func (j *Journal) Begin(e *Entry) error {
+	if _, err := os.Stat(j.path); err == nil {
+		return ErrPending
+	}
+	return j.write(e)
+}
+
+// Mark records the i-th step of e as done.
+func (j *Journal) Mark(e *Entry, i int) error {
+	e.Steps[i].Done = true
+	return j.write(e)
+}
+
+// Commit removes the journal, marking the operation finished.
+func (j *Journal) Commit() error {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("journal: remove %s: %w", j.path, err)
+	}
+	return nil
+}
+
+// write replaces the journal atomically (temp file + fsync + rename), so a reader never
+// sees a torn entry.
+func (j *Journal) write(e *Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("journal: marshal: %w", err)
+	}
+	data = append(data, '\n')
+
+	tmp, err := os.CreateTemp(j.dir, ".journal-*.tmp")
+	if err != nil {
+		return fmt.Errorf("journal: create temp: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("journal: write temp: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("journal: sync temp: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("journal: close temp: %w", err)
+	}
+	if err := os.Rename(tmpName, j.path); err != nil {
+		return fmt.Errorf("journal: rename temp: %w", err)
+	}
+	return nil
+}
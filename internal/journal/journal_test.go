@@ -0,0 +1,74 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+func TestBeginMarkCommit(t *testing.T) {
+	dir := t.TempDir()
+	j := New(dir)
+
+	e := &Entry{
+		Op:        "install",
+		StartedAt: time.Date(2026, 5, 20, 10, 0, 0, 0, time.UTC),
+		Next:      state.State{Version: "26.1.2", Build: 70},
+		Steps:     []Step{{Kind: StepSwap, From: "a", To: "b"}, {Kind: StepSaveState}},
+	}
+	if err := j.Begin(e); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := j.Mark(e, 0); err != nil {
+		t.Fatalf("Mark: %v", err)
+	}
+
+	got, ok, err := j.Pending()
+	if err != nil || !ok {
+		t.Fatalf("Pending = %v, %v; want an entry", ok, err)
+	}
+	if got.Op != "install" || got.Next.Build != 70 || len(got.Steps) != 2 {
+		t.Errorf("unexpected entry: %+v", got)
+	}
+	if !got.Steps[0].Done || got.Steps[1].Done {
+		t.Errorf("done flags = %v/%v, want true/false", got.Steps[0].Done, got.Steps[1].Done)
+	}
+
+	if err := j.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if _, ok, _ := j.Pending(); ok {
+		t.Error("expected no pending entry after Commit")
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			t.Errorf("leftover temp file: %s", e.Name())
+		}
+	}
+}
+
+func TestBeginRefusesPending(t *testing.T) {
+	j := New(t.TempDir())
+	if err := j.Begin(&Entry{Op: "install"}); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if err := j.Begin(&Entry{Op: "install"}); !errors.Is(err, ErrPending) {
+		t.Errorf("second Begin err = %v, want ErrPending", err)
+	}
+}
+
+func TestEntryStep(t *testing.T) {
+	e := Entry{Steps: []Step{{Kind: StepBackup}, {Kind: StepSwap, From: "staged"}}}
+	s, ok := e.Step(StepSwap)
+	if !ok || s.From != "staged" {
+		t.Errorf("Step(StepSwap) = %+v, %v", s, ok)
+	}
+	if _, ok := e.Step(StepPrune); ok {
+		t.Error("expected Step(StepPrune) to report absent")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package lock
+
+import (
+	"errors"
+	"syscall"
+)
+
+// alive reports whether a process with the given PID exists. Signal 0 performs the
+// existence and permission checks without delivering anything; EPERM means the process
+// exists but belongs to another user.
+func alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
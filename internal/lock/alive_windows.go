@@ -0,0 +1,19 @@
+//go:build windows
+
+package lock
+
+import "os"
+
+// alive reports whether a process with the given PID exists. On Windows FindProcess
+// opens a handle to the process and fails if there is none.
+func alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
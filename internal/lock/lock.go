@@ -0,0 +1,198 @@
+// Package lock is an advisory lock on a target directory, so two copies of the tool (or
+// the TUI and a cron job) never mutate paper.jar and state.json at the same time.
+//
+// The lock is a file created with O_EXCL that records who holds it. A lock left behind
+// by a process that has since died on this host is detected as stale and taken over.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const fileName = "paper-mc.lock"
+
+// ErrLocked is matched by HeldError via errors.Is.
+var ErrLocked = errors.New("lock: directory is locked by another process")
+
+// command describes this process in the locks it takes. It is the program's name
+// unless SetCommand says more; the full command line may carry secrets passed as flags,
+// and the lock file is readable by anyone.
+var command = filepath.Base(os.Args[0])
+
+// SetCommand sets how the locks this process takes describe it, e.g. the program and
+// subcommand names. It must not include flag values.
+func SetCommand(c string) { command = c }
+
+// Holder identifies the process holding a lock.
+type Holder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Command   string    `json:"command"` // the program and subcommand, without flags
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("pid %d on %s (%s) since %s", h.PID, h.Host, h.Command, h.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+// HeldError is returned when another live process holds the lock. It names the holder
+// so the user can see who to wait for, and matches ErrLocked.
+type HeldError struct {
+	Path   string
+	Holder Holder
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("lock: %s is held by %s", e.Path, e.Holder)
+}
+
+func (e *HeldError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Lock is a held directory lock. Release it when the mutating operation is done.
+type Lock struct {
+	path   string
+	holder Holder
+}
+
+// Acquire takes the lock on dir for the current process. If another live process holds
+// it, it returns a *HeldError. A stale lock (its holder is on this host and no longer
+// running) is taken over and acquisition retried once.
+func Acquire(dir string) (*Lock, error) {
+	path := filepath.Join(dir, fileName)
+	host, _ := os.Hostname()
+	me := Holder{
+		PID:       os.Getpid(),
+		Host:      host,
+		Command:   command,
+		StartedAt: time.Now(),
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := create(path, me)
+		if err == nil {
+			return &Lock{path: path, holder: me}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		held, rerr := Read(dir)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if attempt > 0 || !stale(held, host) {
+			return nil, &HeldError{Path: path, Holder: held}
+		}
+		if err := takeOver(path, held); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// takeOver removes the stale lock at path, held by held. Two processes can both find
+// the same stale holder, so it is not simply deleted: the first would create its own
+// lock, and the second would delete that. Instead the file is renamed aside, which only
+// one of them can do, and what was moved is checked to be the stale lock. A live lock
+// moved by mistake is put back, unless a new one has already taken its place.
+func takeOver(path string, held Holder) error {
+	aside := fmt.Sprintf("%s.stale-%d", path, os.Getpid())
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // another process took it over first
+		}
+		return fmt.Errorf("lock: take over stale %s: %w", path, err)
+	}
+	defer os.Remove(aside)
+	moved, err := readFile(aside)
+	if err == nil && !sameHolder(moved, held) {
+		if err := os.Link(aside, path); err != nil && !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("lock: restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// sameHolder reports whether a and b describe the same acquisition.
+func sameHolder(a, b Holder) bool {
+	return a.PID == b.PID && a.Host == b.Host && a.StartedAt.Equal(b.StartedAt)
+}
+
+// Read returns the current holder of dir's lock. It returns an error wrapping
+// os.ErrNotExist if the directory is not locked.
+func Read(dir string) (Holder, error) {
+	return readFile(filepath.Join(dir, fileName))
+}
+
+func readFile(path string) (Holder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Holder{}, fmt.Errorf("lock: read %s: %w", path, err)
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return Holder{}, fmt.Errorf("lock: parse %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Held reports whether this process holds the lock on dir.
+func Held(dir string) bool {
+	h, err := Read(dir)
+	if err != nil {
+		return false
+	}
+	host, _ := os.Hostname()
+	return h.PID == os.Getpid() && h.Host == host
+}
+
+// Holder returns who holds this lock (the current process).
+func (l *Lock) Holder() Holder { return l.holder }
+
+// Release removes the lock file. It is safe to call on a nil Lock.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("lock: release %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// create writes the lock file exclusively, failing with os.ErrExist if it is present.
+func create(path string, h Holder) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return fmt.Errorf("lock: marshal: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return err
+		}
+		return fmt.Errorf("lock: create %s: %w", path, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("lock: write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("lock: close %s: %w", path, err)
+	}
+	return nil
+}
+
+// stale reports whether a holder is provably gone. We can only check processes on this
+// host; a lock from another host (e.g. a shared NFS directory) is never considered stale.
+func stale(h Holder, host string) bool {
+	return h.Host == host && !alive(h.PID)
+}
@@ -0,0 +1,125 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	h, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if h.PID != os.Getpid() || h.Host == "" || h.Command == "" || h.StartedAt.IsZero() {
+		t.Errorf("unexpected holder: %+v", h)
+	}
+	// go test passes -test.* flags; like any flag, they must not reach the lock file.
+	if strings.Contains(h.Command, "-test.") {
+		t.Errorf("holder command %q records flags", h.Command)
+	}
+	if !Held(dir) {
+		t.Error("Held = false while holding the lock")
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, fileName)); !os.IsNotExist(err) {
+		t.Error("lock file should be gone after Release")
+	}
+	if Held(dir) {
+		t.Error("Held = true after Release")
+	}
+	l2, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("re-Acquire after Release: %v", err)
+	}
+	l2.Release()
+}
+
+func TestAcquireHeld(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer l.Release()
+
+	_, err = Acquire(dir)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Acquire err = %v, want ErrLocked", err)
+	}
+	var held *HeldError
+	if !errors.As(err, &held) || held.Holder.PID != os.Getpid() {
+		t.Errorf("expected a HeldError naming this process, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "pid ") {
+		t.Errorf("error should name the holder: %v", err)
+	}
+}
+
+func writeHolder(t *testing.T, dir string, h Holder) {
+	t.Helper()
+	data, _ := json.Marshal(h)
+	if err := os.WriteFile(filepath.Join(dir, fileName), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireTakesOverStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	host, _ := os.Hostname()
+	// PIDs are bounded well below this on every supported OS, so it cannot be alive.
+	writeHolder(t, dir, Holder{PID: 1 << 30, Host: host, Command: "paper-mc-tui", StartedAt: time.Now()})
+
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire over stale lock: %v", err)
+	}
+	defer l.Release()
+	if h, _ := Read(dir); h.PID != os.Getpid() {
+		t.Errorf("holder pid = %d, want %d", h.PID, os.Getpid())
+	}
+}
+
+func TestAcquireRespectsOtherHost(t *testing.T) {
+	dir := t.TempDir()
+	writeHolder(t, dir, Holder{PID: 1 << 30, Host: "some-other-host.invalid", Command: "paper-mc-tui", StartedAt: time.Now()})
+
+	if _, err := Acquire(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("err = %v, want ErrLocked for a lock held on another host", err)
+	}
+}
+
+func TestTakeOverLeavesANewLock(t *testing.T) {
+	dir := t.TempDir()
+	host, _ := os.Hostname()
+	old := Holder{PID: 1 << 30, Host: host, Command: "paper-mc-tui", StartedAt: time.Now()}
+	writeHolder(t, dir, old)
+
+	// Another process saw the same stale lock and took it over first.
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	if err := takeOver(filepath.Join(dir, fileName), old); err != nil {
+		t.Fatalf("takeOver: %v", err)
+	}
+	if h, err := Read(dir); err != nil || !sameHolder(h, l.Holder()) {
+		t.Errorf("holder after a late takeover = %+v, %v; want the new lock kept", h, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, fileName+".stale-*")); len(matches) > 0 {
+		t.Errorf("left behind %v", matches)
+	}
+}
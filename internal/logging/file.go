@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultMaxBytes is the size at which the log rotates.
+	DefaultMaxBytes = 5 << 20
+	// DefaultMaxFiles is how many rotated files (path.1 … path.N) are kept.
+	DefaultMaxFiles = 3
+)
+
+// File is an io.Writer that appends to a log file and rotates it by size: once a write
+// would push it past maxBytes, path becomes path.1, path.1 becomes path.2 and so on,
+// and anything beyond maxFiles is deleted.
+//
+// The file is opened for each write rather than held open, so an external logrotate or
+// a user deleting the file never leaves us writing to an unlinked inode.
+type File struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	err      error // the last Write's, kept for Err
+}
+
+// NewFile returns a rotating log file at path. Non-positive limits take the defaults.
+func NewFile(path string, maxBytes int64, maxFiles int) *File {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	return &File{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+// Path returns the live log file's location.
+func (f *File) Path() string { return f.path }
+
+// Err returns the error the last Write hit, or nil if it succeeded. slog drops a
+// handler's write errors, so this is the only way to learn that records are being lost.
+func (f *File) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// Check opens the log for appending without writing to it, so a log that cannot be
+// written shows up in Err before a record is lost.
+func (f *File) Check() error {
+	_, err := f.Write(nil)
+	return err
+}
+
+// Write appends p, rotating first if needed. Each slog record arrives as one Write, so
+// a record is never split across files.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.write(p)
+	f.err = err
+	return n, err
+}
+
+func (f *File) write(p []byte) (int, error) {
+	if info, err := os.Stat(f.path); err == nil && info.Size() > 0 && info.Size()+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("logging: %w", err)
+	}
+	defer file.Close()
+	n, err := file.Write(p)
+	if err != nil {
+		return n, fmt.Errorf("logging: %w", err)
+	}
+	return n, nil
+}
+
+// rotate shifts path.(i) to path.(i+1), dropping the oldest, then moves path to path.1.
+func (f *File) rotate() error {
+	if err := os.Remove(f.rotated(f.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("logging: remove oldest log: %w", err)
+	}
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(f.rotated(i), f.rotated(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("logging: rotate: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, f.rotated(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("logging: rotate: %w", err)
+	}
+	return nil
+}
+
+func (f *File) rotated(i int) string { return fmt.Sprintf("%s.%d", f.path, i) }
@@ -0,0 +1,99 @@
+// Package logging builds the structured activity logger (log/slog) shared by the API
+// client, the downloader, the state store and the service. Records carry levels and
+// key/value fields, are written as logfmt-style text or JSON lines, and go to a file
+// that rotates by size (see File).
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Keys shared by records across packages, so the log can be filtered consistently.
+const (
+	KeyEvent    = "event"
+	KeyVersion  = "version"
+	KeyBuild    = "build"
+	KeyBytes    = "bytes"
+	KeyDuration = "duration"
+	KeyError    = "error"
+)
+
+// Event values for KeyEvent. Every record the tool writes carries one.
+const (
+	EventCheck       = "check"
+	EventDownload    = "download"
+	EventInstall     = "install"
+	EventBackup      = "backup"
+	EventRollback    = "rollback"
+	EventVerify      = "verify"
+	EventRecover     = "recover"
+	EventMigrate     = "migrate"
+	EventAPI         = "api"
+	EventWatch       = "watch"
+	EventControl     = "control"
+	EventNotify      = "notify"
+	EventSelfUpdate  = "self_update"
+	EventServer      = "server"
+	EventRCON        = "rcon"
+	EventSafeUpdate  = "safe_update"
+	EventStartScript = "start_script"
+	EventSystemd     = "systemd"
+	EventSmokeTest   = "smoke_test"
+	EventEULA        = "eula"
+)
+
+// Format selects how records are encoded.
+type Format string
+
+const (
+	FormatText Format = "text" // key=value pairs, one record per line
+	FormatJSON Format = "json" // one JSON object per line
+)
+
+// Options configures New.
+type Options struct {
+	Format Format
+	Level  slog.Level
+}
+
+// New returns a logger writing records to w.
+func New(w io.Writer, opts Options) *slog.Logger {
+	ho := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, ho))
+	}
+	return slog.New(slog.NewTextHandler(w, ho))
+}
+
+// Discard returns a logger that drops everything. Packages use it as the default so a
+// nil logger never needs checking.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// ParseFormat maps a flag value to a Format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid log format %q (want text or json)", s)
+	}
+}
+
+// ParseLevel maps a flag value (debug, info, warn, error) to a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (want debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+// Err returns an attribute for an error under KeyError.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
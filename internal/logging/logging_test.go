@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Options{Format: FormatJSON})
+	log.Info("installed", KeyEvent, EventInstall, KeyVersion, "26.1.2", KeyBuild, 70)
+	log.Error("download failed", KeyEvent, EventDownload, Err(errors.New("boom")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if rec["msg"] != "installed" || rec[KeyEvent] != EventInstall || rec[KeyBuild] != float64(70) {
+		t.Errorf("unexpected record: %v", rec)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil || rec[KeyError] != "boom" || rec["level"] != "ERROR" {
+		t.Errorf("unexpected error record: %v (%v)", rec, err)
+	}
+}
+
+func TestLevelFilters(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(&buf, Options{Level: slog.LevelWarn})
+	log.Info("quiet")
+	log.Warn("loud")
+	if strings.Contains(buf.String(), "quiet") || !strings.Contains(buf.String(), "loud") {
+		t.Errorf("level filtering wrong:\n%s", buf.String())
+	}
+}
+
+func TestParse(t *testing.T) {
+	if f, err := ParseFormat("JSON"); err != nil || f != FormatJSON {
+		t.Errorf("ParseFormat(JSON) = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if l, err := ParseLevel("debug"); err != nil || l != slog.LevelDebug {
+		t.Errorf("ParseLevel(debug) = %v, %v", l, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestFileRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "paper-mc.log")
+	f := NewFile(path, 100, 2)
+
+	line := []byte(strings.Repeat("x", 59) + "\n") // 60 bytes: two never fit in one file
+	for range 5 {
+		if _, err := f.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	for _, name := range []string{"paper-mc.log", "paper-mc.log.1", "paper-mc.log.2"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if len(data) != len(line) {
+			t.Errorf("%s has %d bytes, want %d", name, len(data), len(line))
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("retention exceeded: paper-mc.log.3 should not exist")
+	}
+	if err := f.Err(); err != nil {
+		t.Errorf("Err after good writes = %v", err)
+	}
+}
+
+func TestFileErrClears(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	f := NewFile(filepath.Join(dir, "paper-mc.log"), 0, 0)
+	if _, err := f.Write([]byte("lost\n")); err == nil {
+		t.Fatal("Write into a missing directory succeeded")
+	}
+	if err := f.Err(); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Err = %v, want the open error", err)
+	}
+	os.Mkdir(dir, 0o755)
+	if _, err := f.Write([]byte("kept\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Err(); err != nil {
+		t.Errorf("Err after the log recovered = %v, want nil", err)
+	}
+}
+
+func TestParseRecordBothFormats(t *testing.T) {
+	for _, format := range []Format{FormatText, FormatJSON} {
+		var buf bytes.Buffer
+		New(&buf, Options{Format: format}).Warn("install failed", KeyEvent, EventInstall,
+			KeyBuild, 70, KeyError, `rename "a": no such file`)
+
+		rec, ok := ParseRecord(buf.String())
+		if !ok {
+			t.Fatalf("%s: ParseRecord failed on %q", format, buf.String())
+		}
+		if rec.Msg != "install failed" || rec.Level != slog.LevelWarn || rec.Time.IsZero() {
+			t.Errorf("%s: unexpected record %+v", format, rec)
+		}
+		if rec.Event() != EventInstall || rec.Attrs[KeyBuild] != "70" || rec.Attrs[KeyError] != `rename "a": no such file` {
+			t.Errorf("%s: unexpected attrs %v", format, rec.Attrs)
+		}
+	}
+	if _, ok := ParseRecord("[2025-01-01 10:00:00] installed paper.jar"); ok {
+		t.Error("pre-slog lines should not parse")
+	}
+}
+
+func TestFollower(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paper-mc.log")
+	fol := NewFollower(path)
+	if recs, err := fol.Next(); err != nil || len(recs) != 0 {
+		t.Fatalf("missing file: got %v, %v", recs, err)
+	}
+
+	log := New(NewFile(path, 0, 0), Options{})
+	log.Info("one")
+	log.Info("two")
+	recs, err := fol.Next()
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("got %d records (%v), want 2", len(recs), err)
+	}
+
+	// A partial line is held back until it is complete.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`level=INFO msg=thr`)
+	if recs, _ := fol.Next(); len(recs) != 0 {
+		t.Errorf("partial line should not be returned yet: %v", recs)
+	}
+	f.WriteString("ee\n")
+	f.Close()
+	if recs, _ := fol.Next(); len(recs) != 1 || recs[0].Msg != "three" {
+		t.Errorf("got %+v, want the completed 'three' record", recs)
+	}
+
+	// After rotation the follower reads what it missed in path.1, then starts over on
+	// the new file.
+	log.Info("four")
+	os.Rename(path, path+".1")
+	log.Info("five")
+	if got := msgs(fol.Next()); !slices.Equal(got, []string{"four", "five"}) {
+		t.Errorf("got %v after rotation, want [four five]", got)
+	}
+
+	// A file truncated in place is read again from the top.
+	os.Truncate(path, 0)
+	log.Info("six")
+	if got := msgs(fol.Next()); !slices.Equal(got, []string{"six"}) {
+		t.Errorf("got %v after truncation, want [six]", got)
+	}
+
+	// A file moved anywhere else is given up on.
+	log.Info("gone")
+	os.Rename(path, path+".old")
+	log.Info("seven")
+	if got := msgs(fol.Next()); !slices.Equal(got, []string{"seven"}) {
+		t.Errorf("got %v after an external rotation, want [seven]", got)
+	}
+}
+
+func msgs(recs []Record, err error) []string {
+	var out []string
+	for _, r := range recs {
+		out = append(out, r.Msg)
+	}
+	if err != nil {
+		out = append(out, err.Error())
+	}
+	return out
+}
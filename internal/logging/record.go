@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one parsed line of the activity log, in either format.
+type Record struct {
+	Time  time.Time
+	Level slog.Level
+	Msg   string
+	Attrs map[string]string // every other field, values rendered as strings
+	Raw   string            // the line as written
+}
+
+// Event returns the record's event type (see the Event constants), if any.
+func (r Record) Event() string { return r.Attrs[KeyEvent] }
+
+// ParseRecord parses a line written by a text or JSON handler. ok is false for lines
+// that are neither (e.g. from the tool's pre-slog releases).
+func ParseRecord(line string) (rec Record, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	var fields map[string]string
+	if strings.HasPrefix(line, "{") {
+		fields, ok = parseJSON(line)
+	} else {
+		fields, ok = parseText(line)
+	}
+	if !ok {
+		return Record{}, false
+	}
+
+	rec = Record{Msg: fields[slog.MessageKey], Raw: line, Attrs: fields}
+	if t, err := time.Parse(time.RFC3339Nano, fields[slog.TimeKey]); err == nil {
+		rec.Time = t
+	}
+	if err := rec.Level.UnmarshalText([]byte(fields[slog.LevelKey])); err != nil {
+		return Record{}, false
+	}
+	delete(fields, slog.TimeKey)
+	delete(fields, slog.LevelKey)
+	delete(fields, slog.MessageKey)
+	return rec, true
+}
+
+func parseJSON(line string) (map[string]string, bool) {
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil, false
+	}
+	fields := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			fields[k] = v
+		default:
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	return fields, true
+}
+
+// parseText parses the key=value format of slog.TextHandler, where values needing it
+// are Go-quoted.
+func parseText(line string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	for rest := strings.TrimSpace(line); rest != ""; rest = strings.TrimLeft(rest, " ") {
+		key, after, found := strings.Cut(rest, "=")
+		if !found || key == "" || strings.Contains(key, " ") {
+			return nil, false
+		}
+		var value string
+		if strings.HasPrefix(after, `"`) {
+			quoted, err := strconv.QuotedPrefix(after)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = after[len(quoted):]
+		} else {
+			value, rest, _ = strings.Cut(after, " ")
+		}
+		fields[key] = value
+	}
+	_, hasMsg := fields[slog.MessageKey]
+	return fields, hasMsg
+}
+
+// Follower reads records appended to a log file since its last call, so a viewer can
+// tail the log. If the file was rotated since the last call, it first reads what was
+// left unread in path.1, where File moves it, then starts from the top of the new file;
+// if it shrank (it was truncated), it starts again from the top.
+type Follower struct {
+	path    string
+	offset  int64
+	partial string
+	last    os.FileInfo
+}
+
+// NewFollower returns a Follower positioned at the start of path.
+func NewFollower(path string) *Follower {
+	return &Follower{path: path}
+}
+
+// Next returns the records written since the previous call. A missing file yields no
+// records and no error: nothing has been logged yet.
+func (f *Follower) Next() ([]Record, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	var data []byte
+	if f.last != nil && !os.SameFile(f.last, info) {
+		data = f.drainRotated()
+		f.offset = 0
+	} else if info.Size() < f.offset {
+		f.offset, f.partial = 0, ""
+	}
+	f.last = info
+	if info.Size() > f.offset {
+		if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("logging: %w", err)
+		}
+		fresh, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("logging: %w", err)
+		}
+		f.offset += int64(len(fresh))
+		data = append(data, fresh...)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	// Hold back a trailing partial line until its newline arrives.
+	text := f.partial + string(data)
+	lines := strings.Split(text, "\n")
+	f.partial = lines[len(lines)-1]
+
+	var recs []Record
+	for _, line := range lines[:len(lines)-1] {
+		if rec, ok := ParseRecord(line); ok {
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
+
+// drainRotated returns what was appended to the file last read after the previous call,
+// if it is now path.1. Otherwise, say after an external logrotate or if it rotated
+// twice, that part is lost and the partial line held back is dropped.
+func (f *Follower) drainRotated() []byte {
+	data, ok := f.readRotated()
+	if !ok {
+		f.partial = ""
+		return nil
+	}
+	// Records never span files, so a partial line ends here.
+	if (len(data) > 0 || f.partial != "") && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	return data
+}
+
+func (f *Follower) readRotated() ([]byte, bool) {
+	file, err := os.Open(f.path + ".1")
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !os.SameFile(f.last, info) || info.Size() < f.offset {
+		return nil, false
+	}
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil, false
+	}
+	data, err := io.ReadAll(file)
+	return data, err == nil
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// latencyBuckets suit Fill API round-trips; downloadBuckets suit a ~50 MB jar.
+var (
+	latencyBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	downloadBuckets = []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 900}
+)
+
+// Metrics is the tool's instrumentation. The API client, downloader and service each
+// take one through a WithMetrics option and record into it; Handler serves it. Create
+// it with New; the packages default to Discard.
+type Metrics struct {
+	reg *Registry
+
+	installedInfo  *Gauge
+	installedBuild *Gauge
+	latestBuild    *Gauge
+	buildsBehind   *Gauge
+	lastCheck      *Gauge
+
+	apiRequests *Counter
+	apiDuration *Histogram
+
+	downloadBytes    *Counter
+	downloadDuration *Histogram
+	checksumFailures *Counter
+}
+
+// New returns a Metrics with every family registered.
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		reg: r,
+		installedInfo: r.Gauge("paper_mc_installed_info",
+			"The installed Paper version and build (always 1).", "version", "build"),
+		installedBuild: r.Gauge("paper_mc_installed_build",
+			"The installed Paper build number (0 if nothing is installed)."),
+		latestBuild: r.Gauge("paper_mc_latest_build",
+			"The newest build available on the configured channels, by version.", "version"),
+		buildsBehind: r.Gauge("paper_mc_builds_behind",
+			"How many builds the installed jar is behind the newest one."),
+		lastCheck: r.Gauge("paper_mc_last_check_success_timestamp_seconds",
+			"Unix time of the last successful check for new builds."),
+		apiRequests: r.Counter("paper_mc_api_requests_total",
+			"Fill API requests, by HTTP status (\"error\" if no response).", "status"),
+		apiDuration: r.Histogram("paper_mc_api_request_duration_seconds",
+			"Fill API request latency, by HTTP status.", latencyBuckets, "status"),
+		downloadBytes: r.Counter("paper_mc_download_bytes_total",
+			"Bytes of server jar downloaded."),
+		downloadDuration: r.Histogram("paper_mc_download_duration_seconds",
+			"Server jar download time, by result (ok or error).", downloadBuckets, "result"),
+		checksumFailures: r.Counter("paper_mc_checksum_failures_total",
+			"Downloads rejected because their SHA256 did not match."),
+	}
+}
+
+// Discard returns a Metrics that records nothing. Packages use it as the default so a
+// nil Metrics never needs checking.
+func Discard() *Metrics { return &Metrics{} }
+
+// discards reports whether m is Discard's, which has no families to record into.
+func (m *Metrics) discards() bool { return m.reg == nil }
+
+// Registry returns the registry behind m, e.g. to serve it; nil for Discard's.
+func (m *Metrics) Registry() *Registry { return m.reg }
+
+// ObserveAPI records one Fill API request. status is 0 when no response arrived.
+func (m *Metrics) ObserveAPI(status int, d time.Duration) {
+	if m.discards() {
+		return
+	}
+	label := "error"
+	if status != 0 {
+		label = strconv.Itoa(status)
+	}
+	m.apiRequests.Inc(label)
+	m.apiDuration.Observe(d.Seconds(), label)
+}
+
+// ObserveDownload records one jar download of n bytes.
+func (m *Metrics) ObserveDownload(n int64, d time.Duration, err error, checksumMismatch bool) {
+	if m.discards() {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.downloadBytes.Add(float64(n))
+	m.downloadDuration.Observe(d.Seconds(), result)
+	if checksumMismatch {
+		m.checksumFailures.Inc()
+	}
+}
+
+// SetInstalled publishes the installed version and build (build 0 means none).
+func (m *Metrics) SetInstalled(version string, build int) {
+	if m.discards() {
+		return
+	}
+	m.installedInfo.Reset()
+	if build != 0 {
+		m.installedInfo.Set(1, version, strconv.Itoa(build))
+	}
+	m.installedBuild.Set(float64(build))
+}
+
+// SetLatest publishes the newest available build and how far behind the installed one
+// is, and stamps the time of this successful check.
+func (m *Metrics) SetLatest(version string, build, behind int, at time.Time) {
+	if m.discards() {
+		return
+	}
+	m.latestBuild.Reset()
+	m.latestBuild.Set(float64(build), version)
+	m.buildsBehind.Set(float64(behind))
+	m.lastCheck.Set(float64(at.UnixMilli()) / 1000)
+}
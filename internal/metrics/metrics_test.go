@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestRegistryFormat(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("things_total", "Things seen.\nSecond line.", "kind")
+	g := r.Gauge("temperature", "Current temperature.")
+	h := r.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+
+	c.Inc(`a"b`)
+	c.Add(2, "plain")
+	c.Add(-5, "plain") // ignored: counters only go up
+	g.Set(21.5)
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(3)
+
+	want := `# HELP things_total Things seen.\nSecond line.
+# TYPE things_total counter
+things_total{kind="a\"b"} 1
+things_total{kind="plain"} 2
+# HELP temperature Current temperature.
+# TYPE temperature gauge
+temperature 21.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.15
+latency_seconds_count 3
+`
+	if got := render(t, r); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := New()
+	m.SetInstalled("26.1.2", 69)
+	m.SetInstalled("26.1.2", 70) // replaces the info series rather than adding one
+	m.SetLatest("26.1.2", 72, 2, time.Unix(1780000000, 0))
+	m.ObserveAPI(200, 120*time.Millisecond)
+	m.ObserveAPI(0, time.Second)
+
+	out := render(t, m.Registry())
+	for _, want := range []string{
+		`paper_mc_installed_info{version="26.1.2",build="70"} 1`,
+		"paper_mc_installed_build 70\n",
+		`paper_mc_latest_build{version="26.1.2"} 72`,
+		"paper_mc_builds_behind 2\n",
+		"paper_mc_last_check_success_timestamp_seconds 1.78e+09\n",
+		`paper_mc_api_requests_total{status="200"} 1`,
+		`paper_mc_api_requests_total{status="error"} 1`,
+		`paper_mc_api_request_duration_seconds_bucket{status="200",le="0.25"} 1`,
+		"paper_mc_checksum_failures_total 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `build="69"`) {
+		t.Errorf("stale installed_info series:\n%s", out)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	m := Discard()
+	m.SetInstalled("26.1.2", 70)
+	m.SetLatest("26.1.2", 72, 2, time.Now())
+	m.ObserveAPI(200, time.Second)
+	m.ObserveDownload(1<<20, time.Second, nil, false)
+	if m.Registry() != nil {
+		t.Error("Discard has a registry to serve")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	rec := httptest.NewRecorder()
+	m.Registry().Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "# TYPE paper_mc_builds_behind gauge") {
+		t.Errorf("body:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,239 @@
+// Package nagios formats check results the way Nagios, Icinga and compatible monitoring
+// systems expect from a plugin: one status line with optional performance data, more
+// lines of detail, and an exit code that is the status. Thresholds use the standard
+// plugin range syntax ("10", "10:", "~:10", "10:20", "@10:20").
+package nagios
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Status is a plugin's result; its value is the exit code.
+type Status int
+
+const (
+	OK       Status = 0
+	Warning  Status = 1
+	Critical Status = 2
+	Unknown  Status = 3
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// severity orders statuses for Worst: OK < WARNING < UNKNOWN < CRITICAL.
+func (s Status) severity() int {
+	switch s {
+	case OK:
+		return 0
+	case Warning:
+		return 1
+	case Critical:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// Worst returns the most severe of statuses, or OK if there are none. CRITICAL outranks
+// UNKNOWN, which outranks WARNING.
+func Worst(statuses ...Status) Status {
+	worst := OK
+	for _, s := range statuses {
+		if s.severity() > worst.severity() {
+			worst = s
+		}
+	}
+	return worst
+}
+
+// Range is a threshold range. A value outside it (or inside, for an "@" range) raises an
+// alert.
+type Range struct {
+	start, end float64
+	inside     bool
+	raw        string
+}
+
+// ParseRange parses the plugin range syntax:
+//
+//	10      alert if < 0 or > 10
+//	10:     alert if < 10
+//	~:10    alert if > 10
+//	10:20   alert if < 10 or > 20
+//	@10:20  alert if >= 10 and <= 20
+func ParseRange(s string) (Range, error) {
+	r := Range{raw: strings.TrimSpace(s), end: math.Inf(1)}
+	body, inside := strings.CutPrefix(r.raw, "@")
+	r.inside = inside
+	if body == "" {
+		return Range{}, fmt.Errorf("nagios: empty range %q", s)
+	}
+	lo, hi, hasColon := strings.Cut(body, ":")
+	if !hasColon {
+		lo, hi = "0", body
+	}
+	var err error
+	switch lo {
+	case "~":
+		r.start = math.Inf(-1)
+	case "":
+		return Range{}, fmt.Errorf("nagios: invalid range %q: missing start", s)
+	default:
+		if r.start, err = strconv.ParseFloat(lo, 64); err != nil {
+			return Range{}, fmt.Errorf("nagios: invalid range %q: %q is not a number", s, lo)
+		}
+	}
+	if hi != "" {
+		if r.end, err = strconv.ParseFloat(hi, 64); err != nil {
+			return Range{}, fmt.Errorf("nagios: invalid range %q: %q is not a number", s, hi)
+		}
+	}
+	if r.start > r.end {
+		return Range{}, fmt.Errorf("nagios: invalid range %q: start is greater than end", s)
+	}
+	return r, nil
+}
+
+// Alert reports whether v raises an alert against the range.
+func (r Range) Alert(v float64) bool {
+	in := v >= r.start && v <= r.end
+	return in == r.inside
+}
+
+// String returns the range as it was given, for performance data.
+func (r Range) String() string { return r.raw }
+
+// Thresholds are the warning and critical ranges for one value; either may be nil.
+type Thresholds struct {
+	Warning  *Range
+	Critical *Range
+}
+
+// ParseThresholds parses a warning and a critical range, where an empty string means
+// no threshold.
+func ParseThresholds(warning, critical string) (Thresholds, error) {
+	var t Thresholds
+	for _, p := range []struct {
+		s   string
+		dst **Range
+	}{{warning, &t.Warning}, {critical, &t.Critical}} {
+		if strings.TrimSpace(p.s) == "" {
+			continue
+		}
+		r, err := ParseRange(p.s)
+		if err != nil {
+			return Thresholds{}, err
+		}
+		*p.dst = &r
+	}
+	return t, nil
+}
+
+// Status evaluates v: CRITICAL if it alerts against the critical range, else WARNING
+// if it alerts against the warning range, else OK.
+func (t Thresholds) Status(v float64) Status {
+	switch {
+	case t.Critical != nil && t.Critical.Alert(v):
+		return Critical
+	case t.Warning != nil && t.Warning.Alert(v):
+		return Warning
+	default:
+		return OK
+	}
+}
+
+// Perf is one performance data item: 'label'=value[UOM];[warn];[crit];[min];[max].
+type Perf struct {
+	Label      string
+	Value      float64
+	UOM        string // "", "s", "%", "B", "c", ...
+	Thresholds Thresholds
+	Min, Max   *float64
+}
+
+func (p Perf) String() string {
+	label := p.Label
+	if strings.ContainsAny(label, " '=") {
+		label = "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	fields := []string{formatFloat(p.Value) + p.UOM, "", "", "", ""}
+	if w := p.Thresholds.Warning; w != nil {
+		fields[1] = w.String()
+	}
+	if c := p.Thresholds.Critical; c != nil {
+		fields[2] = c.String()
+	}
+	if p.Min != nil {
+		fields[3] = formatFloat(*p.Min)
+	}
+	if p.Max != nil {
+		fields[4] = formatFloat(*p.Max)
+	}
+	// Trailing empty fields may be dropped.
+	n := len(fields)
+	for n > 1 && fields[n-1] == "" {
+		n--
+	}
+	return label + "=" + strings.Join(fields[:n], ";")
+}
+
+func formatFloat(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// Result is a complete plugin result.
+type Result struct {
+	Service string // prefix of the status line, e.g. "PAPER"
+	Status  Status
+	Summary string
+	Details []string // long output, one line each
+	Perf    []Perf
+}
+
+// String renders the result as plugin output: "SERVICE STATUS - summary | perf",
+// followed by the details.
+func (r Result) String() string {
+	var b strings.Builder
+	if r.Service != "" {
+		b.WriteString(r.Service + " ")
+	}
+	b.WriteString(r.Status.String())
+	if r.Summary != "" {
+		b.WriteString(" - " + oneLine(r.Summary))
+	}
+	if perf := r.PerfData(); perf != "" {
+		b.WriteString(" | " + perf)
+	}
+	b.WriteString("\n")
+	for _, d := range r.Details {
+		b.WriteString(oneLine(d) + "\n")
+	}
+	return b.String()
+}
+
+// PerfData renders the performance data items, space separated.
+func (r Result) PerfData() string {
+	items := make([]string, len(r.Perf))
+	for i, p := range r.Perf {
+		items[i] = p.String()
+	}
+	return strings.Join(items, " ")
+}
+
+// oneLine keeps text from breaking the output format: newlines would start long output
+// early and "|" would start performance data.
+func oneLine(s string) string {
+	return strings.NewReplacer("\n", " ", "\r", " ", "|", "/").Replace(s)
+}
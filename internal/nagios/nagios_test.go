@@ -0,0 +1,103 @@
+package nagios
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		spec  string
+		alert []float64
+		quiet []float64
+	}{
+		{"10", []float64{-1, 10.5, 11}, []float64{0, 5, 10}},
+		{"10:", []float64{-1, 9.9}, []float64{10, 1e9}},
+		{"~:10", []float64{11}, []float64{-1e9, 0, 10}},
+		{"10:20", []float64{9, 21}, []float64{10, 15, 20}},
+		{"@10:20", []float64{10, 15, 20}, []float64{9, 21}},
+		{"0", []float64{1}, []float64{0}},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseRange(%q): %v", tt.spec, err)
+		}
+		for _, v := range tt.alert {
+			if !r.Alert(v) {
+				t.Errorf("%q: %v did not alert", tt.spec, v)
+			}
+		}
+		for _, v := range tt.quiet {
+			if r.Alert(v) {
+				t.Errorf("%q: %v alerted", tt.spec, v)
+			}
+		}
+	}
+
+	for _, bad := range []string{"", "@", ":10", "x", "10:y", "20:10"} {
+		if _, err := ParseRange(bad); err == nil {
+			t.Errorf("ParseRange(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	th, err := ParseThresholds("5", "20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for v, want := range map[float64]Status{0: OK, 5: OK, 6: Warning, 20: Warning, 21: Critical} {
+		if got := th.Status(v); got != want {
+			t.Errorf("Status(%v) = %v, want %v", v, got, want)
+		}
+	}
+	none, err := ParseThresholds("", " ")
+	if err != nil || none.Status(1e9) != OK {
+		t.Errorf("empty thresholds = %+v, %v", none, err)
+	}
+	if _, err := ParseThresholds("5", "x"); err == nil {
+		t.Error("ParseThresholds accepted a bad critical range")
+	}
+}
+
+func TestWorst(t *testing.T) {
+	tests := []struct {
+		in   []Status
+		want Status
+	}{
+		{nil, OK},
+		{[]Status{OK, Warning}, Warning},
+		{[]Status{Warning, Unknown}, Unknown},
+		{[]Status{Unknown, Critical, Warning}, Critical},
+	}
+	for _, tt := range tests {
+		if got := Worst(tt.in...); got != tt.want {
+			t.Errorf("Worst(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResultString(t *testing.T) {
+	th, _ := ParseThresholds("5", "20")
+	r := Result{
+		Service: "PAPER",
+		Status:  Warning,
+		Summary: "6 builds behind | oops",
+		Details: []string{"installed: 1.21.10 build 124", "latest:\n1.21.10 build 130"},
+		Perf: []Perf{
+			{Label: "builds_behind", Value: 6, Thresholds: th, Min: new(0.0)},
+			{Label: "days since update", Value: 12.5},
+			{Label: "jar_verified", Value: 1, Min: new(0.0), Max: new(1.0)},
+		},
+	}
+	want := "PAPER WARNING - 6 builds behind / oops | builds_behind=6;5;20;0 'days since update'=12.5 jar_verified=1;;;0;1\n" +
+		"installed: 1.21.10 build 124\n" +
+		"latest: 1.21.10 build 130\n"
+	if got := r.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+	if got := (Result{Status: Unknown}).String(); strings.TrimSpace(got) != "UNKNOWN" {
+		t.Errorf("bare result = %q", got)
+	}
+}
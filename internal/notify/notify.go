@@ -0,0 +1,399 @@
+// Package notify sends outgoing webhooks when something noteworthy happens: a new build
+// is available, an install succeeds or fails, or a jar is rolled back. Each webhook
+// speaks Discord's, Slack's or a generic JSON format; messages come from text/template
+// templates over Event. Delivery is asynchronous and retried with backoff, and final
+// failures go to the activity log rather than interrupting the operation that fired
+// them.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+)
+
+// Kind is what happened.
+type Kind string
+
+const (
+	KindNewBuild         Kind = "new_build"
+	KindInstallSucceeded Kind = "install_succeeded"
+	KindInstallFailed    Kind = "install_failed"
+	KindRollback         Kind = "rollback"
+)
+
+// Kinds lists every Kind.
+var Kinds = []Kind{KindNewBuild, KindInstallSucceeded, KindInstallFailed, KindRollback}
+
+// DefaultTemplates are the messages used unless overridden with WithTemplate.
+var DefaultTemplates = map[Kind]string{
+	KindNewBuild:         "New Paper build available: {{.Version}} build {{.Build}} ({{.Channel}}){{range .Commits}}\n• {{.Summary}}{{end}}",
+	KindInstallSucceeded: "Installed Paper {{.Version}} build {{.Build}} on {{.Host}}{{range .Commits}}\n• {{.Summary}}{{end}}",
+	KindInstallFailed:    "Failed to install Paper {{.Version}} build {{.Build}} on {{.Host}}: {{.Error}}",
+	KindRollback:         "Rolled back Paper on {{.Host}} to {{if .Build}}{{.Version}} build {{.Build}}{{else}}{{.JarName}}{{end}}",
+}
+
+// Commit is one change in a build, for templates.
+type Commit struct {
+	SHA     string `json:"sha"`
+	Summary string `json:"summary"` // first line of the commit message
+}
+
+// Commits converts a build's commits, keeping the first line of each message.
+func Commits(cs []papermc.Commit) []Commit {
+	out := make([]Commit, 0, len(cs))
+	for _, c := range cs {
+		summary, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		out = append(out, Commit{SHA: c.SHA, Summary: strings.TrimSpace(summary)})
+	}
+	return out
+}
+
+// Event is what templates are executed against and what the generic format sends.
+type Event struct {
+	Kind    Kind      `json:"event"`
+	Version string    `json:"version,omitempty"`
+	Build   int       `json:"build,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	JarName string    `json:"jar_name,omitempty"`
+	Commits []Commit  `json:"commits,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Host    string    `json:"host"`
+	Time    time.Time `json:"time"`
+}
+
+// Format is a webhook's payload shape.
+type Format string
+
+const (
+	FormatDiscord Format = "discord" // {"content": message}
+	FormatSlack   Format = "slack"   // {"text": message}
+	FormatGeneric Format = "generic" // the Event plus "message"
+)
+
+// discordLimit is Discord's maximum message length, in characters.
+const discordLimit = 2000
+
+// Webhook is one destination.
+type Webhook struct {
+	Format Format
+	URL    string
+}
+
+// ParseWebhooks parses a list of "format=url" entries separated by spaces or commas,
+// e.g. "discord=https://discord.com/api/webhooks/… generic=https://hooks.example/paper".
+// A bare URL uses the generic format.
+func ParseWebhooks(s string) ([]Webhook, error) {
+	var hooks []Webhook
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' }) {
+		h := Webhook{Format: FormatGeneric, URL: f}
+		if name, target, ok := strings.Cut(f, "="); ok && !strings.Contains(name, "/") {
+			h = Webhook{Format: Format(name), URL: target}
+		}
+		switch h.Format {
+		case FormatDiscord, FormatSlack, FormatGeneric:
+		default:
+			return nil, fmt.Errorf("invalid webhook format %q (want discord, slack or generic)", h.Format)
+		}
+		if !strings.HasPrefix(h.URL, "https://") && !strings.HasPrefix(h.URL, "http://") {
+			return nil, fmt.Errorf("invalid webhook URL %q (want http or https)", h.URL)
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// ParseKinds parses a comma-separated list of kinds. The empty string means all.
+func ParseKinds(s string) ([]Kind, error) {
+	var kinds []Kind
+	for _, f := range strings.Split(s, ",") {
+		k := Kind(strings.TrimSpace(f))
+		if k == "" {
+			continue
+		}
+		if !validKind(k) {
+			return nil, fmt.Errorf("invalid webhook event %q (want %s)", k, joinKinds())
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+// ParseTemplate checks a message template.
+func ParseTemplate(kind Kind, text string) (*template.Template, error) {
+	t, err := template.New(string(kind)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("webhook template for %s: %w", kind, err)
+	}
+	return t, nil
+}
+
+func validKind(k Kind) bool {
+	for _, known := range Kinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKinds() string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Notifier delivers events to webhooks. Build one with New; the zero-hook Notifier from
+// Discard does nothing.
+type Notifier struct {
+	hooks     []Webhook
+	kinds     map[Kind]bool // nil means every kind
+	templates map[Kind]string
+	parsed    map[Kind]*template.Template
+	client    *http.Client
+	log       *slog.Logger
+	attempts  int
+	backoff   time.Duration
+	host      string
+
+	wg sync.WaitGroup
+}
+
+// Option configures a Notifier.
+type Option func(*Notifier)
+
+// WithKinds limits delivery to the given kinds (default: all).
+func WithKinds(kinds ...Kind) Option {
+	return func(n *Notifier) {
+		if len(kinds) == 0 {
+			return
+		}
+		n.kinds = make(map[Kind]bool, len(kinds))
+		for _, k := range kinds {
+			n.kinds[k] = true
+		}
+	}
+}
+
+// WithTemplate overrides the message template for kind. An empty text keeps the default.
+func WithTemplate(kind Kind, text string) Option {
+	return func(n *Notifier) {
+		if text != "" {
+			n.templates[kind] = text
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for delivery (useful for tests).
+func WithHTTPClient(c *http.Client) Option {
+	return func(n *Notifier) {
+		if c != nil {
+			n.client = c
+		}
+	}
+}
+
+// WithLogger sets where delivery failures are logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(n *Notifier) {
+		if l != nil {
+			n.log = l
+		}
+	}
+}
+
+// WithRetry sets how many times a delivery is attempted and the delay before the first
+// retry, which doubles after each failure (default 4 attempts, 2s).
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(n *Notifier) {
+		if attempts > 0 {
+			n.attempts = attempts
+		}
+		if backoff >= 0 {
+			n.backoff = backoff
+		}
+	}
+}
+
+// New returns a Notifier for hooks. It fails if a template does not parse.
+func New(hooks []Webhook, opts ...Option) (*Notifier, error) {
+	host, _ := os.Hostname()
+	n := &Notifier{
+		hooks:     hooks,
+		templates: make(map[Kind]string, len(DefaultTemplates)),
+		parsed:    make(map[Kind]*template.Template, len(DefaultTemplates)),
+		client:    &http.Client{Timeout: 10 * time.Second},
+		log:       logging.Discard(),
+		attempts:  4,
+		backoff:   2 * time.Second,
+		host:      host,
+	}
+	for k, t := range DefaultTemplates {
+		n.templates[k] = t
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	for k, text := range n.templates {
+		t, err := ParseTemplate(k, text)
+		if err != nil {
+			return nil, fmt.Errorf("notify: %w", err)
+		}
+		n.parsed[k] = t
+	}
+	return n, nil
+}
+
+// Discard returns a Notifier with no webhooks. Packages use it as the default so a nil
+// Notifier never needs checking.
+func Discard() *Notifier {
+	n, _ := New(nil)
+	return n
+}
+
+// Message renders ev's message with its kind's template.
+func (n *Notifier) Message(ev Event) (string, error) {
+	t, ok := n.parsed[ev.Kind]
+	if !ok {
+		return "", fmt.Errorf("notify: no template for %q", ev.Kind)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, ev); err != nil {
+		return "", fmt.Errorf("notify: render %s: %w", ev.Kind, err)
+	}
+	return b.String(), nil
+}
+
+// Notify delivers ev to every webhook in the background. Host and Time are filled in if
+// unset. Call Wait before exiting so deliveries are not cut short.
+func (n *Notifier) Notify(ev Event) {
+	if len(n.hooks) == 0 || (n.kinds != nil && !n.kinds[ev.Kind]) {
+		return
+	}
+	if ev.Host == "" {
+		ev.Host = n.host
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	msg, err := n.Message(ev)
+	if err != nil {
+		n.log.Error("webhook not sent", logging.KeyEvent, logging.EventNotify, "kind", string(ev.Kind), logging.Err(err))
+		return
+	}
+	for _, h := range n.hooks {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.deliver(h, ev, msg)
+		}()
+	}
+}
+
+// Wait blocks until pending deliveries finish or timeout passes, and reports whether
+// they all finished.
+func (n *Notifier) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// errPermanent marks a response that retrying will not fix (a 4xx other than 429).
+var errPermanent = errors.New("permanent failure")
+
+func (n *Notifier) deliver(h Webhook, ev Event, msg string) {
+	body, err := payload(h.Format, ev, msg)
+	if err != nil {
+		n.log.Error("webhook not sent", logging.KeyEvent, logging.EventNotify, "kind", string(ev.Kind), logging.Err(err))
+		return
+	}
+	delay := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(h.URL, body)
+		if err == nil {
+			n.log.Debug("webhook delivered", logging.KeyEvent, logging.EventNotify,
+				"kind", string(ev.Kind), "format", string(h.Format), "attempt", attempt)
+			return
+		}
+		if attempt >= n.attempts || errors.Is(err, errPermanent) {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	// The URL is left out: for Discord and Slack it is the secret.
+	n.log.Error("webhook delivery failed", logging.KeyEvent, logging.EventNotify,
+		"kind", string(ev.Kind), "format", string(h.Format), logging.Err(err))
+}
+
+func (n *Notifier) post(target string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Scrub the URL from transport errors for the same reason.
+		if uerr, ok := errors.AsType[*url.Error](err); ok {
+			return uerr.Err
+		}
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: webhook returned %s", errPermanent, resp.Status)
+	}
+}
+
+// payload builds the request body for a format.
+func payload(f Format, ev Event, msg string) ([]byte, error) {
+	switch f {
+	case FormatDiscord:
+		if utf8.RuneCountInString(msg) > discordLimit {
+			msg = string([]rune(msg)[:discordLimit-1]) + "…"
+		}
+		return json.Marshal(struct {
+			Content  string `json:"content"`
+			Username string `json:"username"`
+		}{msg, "paper-mc-tui"})
+	case FormatSlack:
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{msg})
+	default:
+		return json.Marshal(struct {
+			Event
+			Message string `json:"message"`
+		}{ev, msg})
+	}
+}
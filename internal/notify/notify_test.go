@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+)
+
+// receiver records request bodies and answers with the queued statuses, then 204s.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []map[string]any
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	var v map[string]any
+	_ = json.Unmarshal(body, &v)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, v)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bodies
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return r, ts.URL
+}
+
+var testEvent = Event{
+	Kind:    KindNewBuild,
+	Version: "26.1.2",
+	Build:   71,
+	Channel: "STABLE",
+	Commits: Commits([]papermc.Commit{
+		{SHA: "abc123", Message: "Fix chunk loading\n\nLonger description."},
+		{SHA: "def456", Message: "Update upstream"},
+	}),
+}
+
+func TestFormats(t *testing.T) {
+	rd, discord := newReceiver(t)
+	rs, slack := newReceiver(t)
+	rg, generic := newReceiver(t)
+	hooks, err := ParseWebhooks("discord=" + discord + " slack=" + slack + "," + generic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := New(hooks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(testEvent)
+	if !n.Wait(5 * time.Second) {
+		t.Fatal("Wait timed out")
+	}
+
+	want := "New Paper build available: 26.1.2 build 71 (STABLE)\n• Fix chunk loading\n• Update upstream"
+	if got := rd.received(); len(got) != 1 || got[0]["content"] != want || got[0]["username"] == "" {
+		t.Errorf("discord = %v", got)
+	}
+	if got := rs.received(); len(got) != 1 || got[0]["text"] != want {
+		t.Errorf("slack = %v", got)
+	}
+	got := rg.received()
+	if len(got) != 1 || got[0]["message"] != want || got[0]["event"] != "new_build" || got[0]["build"] != float64(71) {
+		t.Fatalf("generic = %v", got)
+	}
+	if commits, _ := got[0]["commits"].([]any); len(commits) != 2 {
+		t.Errorf("generic commits = %v", got[0]["commits"])
+	}
+}
+
+func TestDiscordTruncatesByCharacter(t *testing.T) {
+	for _, msg := range []string{strings.Repeat("é", 1500), strings.Repeat("日本", 1500)} {
+		data, err := payload(FormatDiscord, testEvent, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct{ Content string }
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Fatal(err)
+		}
+		if n := utf8.RuneCountInString(body.Content); n > discordLimit || !utf8.ValidString(body.Content) {
+			t.Errorf("content of %d characters, valid UTF-8 %v", n, utf8.ValidString(body.Content))
+		}
+		if utf8.RuneCountInString(msg) <= discordLimit && body.Content != msg {
+			t.Errorf("a message of %d characters was truncated", utf8.RuneCountInString(msg))
+		}
+	}
+}
+
+func TestKindsAndTemplates(t *testing.T) {
+	r, url := newReceiver(t)
+	n, err := New([]Webhook{{Format: FormatSlack, URL: url}},
+		WithKinds(KindInstallFailed),
+		WithTemplate(KindInstallFailed, "{{.Host}}: {{.Error}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(testEvent) // filtered out
+	n.Notify(Event{Kind: KindInstallFailed, Host: "mc1", Error: "checksum mismatch"})
+	n.Wait(5 * time.Second)
+	if got := r.received(); len(got) != 1 || got[0]["text"] != "mc1: checksum mismatch" {
+		t.Errorf("received = %v", got)
+	}
+
+	if _, err := New(nil, WithTemplate(KindRollback, "{{.Nope")); err == nil {
+		t.Error("New accepted a broken template")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	r, url := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	n, _ := New([]Webhook{{Format: FormatGeneric, URL: url}}, WithRetry(3, time.Millisecond))
+	n.Notify(testEvent)
+	n.Wait(5 * time.Second)
+	if got := len(r.received()); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestFailureIsLogged(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// A 4xx other than 429 is not retried.
+	r, url := newReceiver(t, http.StatusNotFound)
+	n, _ := New([]Webhook{{Format: FormatDiscord, URL: url}}, WithRetry(3, time.Millisecond), WithLogger(log))
+	n.Notify(testEvent)
+	n.Wait(5 * time.Second)
+	if got := len(r.received()); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"event":"notify"`) || !strings.Contains(out, "404") {
+		t.Errorf("log = %s", out)
+	}
+	if strings.Contains(out, url) {
+		t.Errorf("log leaks the webhook URL: %s", out)
+	}
+}
+
+func TestParseWebhooks(t *testing.T) {
+	for _, bad := range []string{"teams=https://x.example", "discord=ftp://x.example", "not-a-url"} {
+		if _, err := ParseWebhooks(bad); err == nil {
+			t.Errorf("ParseWebhooks(%q) accepted", bad)
+		}
+	}
+	hooks, err := ParseWebhooks("https://x.example/a=b")
+	if err != nil || len(hooks) != 1 || hooks[0].Format != FormatGeneric || hooks[0].URL != "https://x.example/a=b" {
+		t.Errorf("bare URL with '=' = %+v, %v", hooks, err)
+	}
+	if _, err := ParseKinds("new_build, rollback"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseKinds("new_build,started"); err == nil {
+		t.Error("ParseKinds accepted an unknown kind")
+	}
+}
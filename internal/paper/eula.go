@@ -0,0 +1,49 @@
+package paper
+
+import (
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+// EULAAcceptance records who accepted the Minecraft EULA for the server, and when.
+type EULAAcceptance struct {
+	User string
+	Host string
+	At   time.Time
+}
+
+// EULA returns whether the Minecraft EULA has been accepted for the server. Until it
+// is, the server stops as soon as it is started.
+func (s *Service) EULA() (eula.State, error) { return eula.Read(s.dir) }
+
+// AcceptEULA writes an accepting eula.txt for the server, as the server itself would,
+// and records in the activity log which user accepted it, on which host and when.
+// Callers must have shown the user eula.URL and had them agree to it.
+func (s *Service) AcceptEULA() (EULAAcceptance, error) {
+	a := EULAAcceptance{User: currentUser(), At: time.Now()}
+	a.Host, _ = os.Hostname()
+	log := s.log.With(logging.KeyEvent, logging.EventEULA)
+	if err := eula.Accept(s.dir, a.At); err != nil {
+		log.Error("could not accept the Minecraft EULA", logging.Err(err))
+		return EULAAcceptance{}, err
+	}
+	log.Info("accepted the Minecraft EULA", "user", a.User, "host", a.Host,
+		"accepted_at", a.At.Format(time.RFC3339), "url", eula.URL)
+	return a, nil
+}
+
+// currentUser names the user running the tool, falling back to $USER when there is no
+// account database to ask.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,38 @@
+package paper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+)
+
+func TestAcceptEULA(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	if st, err := svc.EULA(); err != nil || st != eula.Missing {
+		t.Fatalf("EULA = %v, %v, want missing", st, err)
+	}
+	os.WriteFile(filepath.Join(dir, eula.FileName), []byte("eula=false\n"), 0o644)
+	if st, _ := svc.EULA(); st != eula.Declined {
+		t.Fatalf("EULA with eula=false = %v", st)
+	}
+
+	a, err := svc.AcceptEULA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := svc.EULA(); st != eula.Accepted {
+		t.Errorf("EULA after AcceptEULA = %v", st)
+	}
+	if a.User == "" || a.At.IsZero() {
+		t.Errorf("acceptance = %+v", a)
+	}
+	log, _ := os.ReadFile(svc.LogPath())
+	for _, want := range []string{"event=eula", "accepted the Minecraft EULA", "user=" + a.User, "accepted_at="} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("activity log lacks %s:\n%s", want, log)
+		}
+	}
+}
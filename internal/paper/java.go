@@ -0,0 +1,122 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mbacalan/paper-mc-tui/internal/java"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+// JavaPolicy says what Install does when the configured java is too old for the build.
+type JavaPolicy string
+
+const (
+	JavaBlock JavaPolicy = "block" // refuse the install
+	JavaWarn  JavaPolicy = "warn"  // install, logging a warning
+	JavaOff   JavaPolicy = "off"   // do not check
+)
+
+// Where JavaCheck.Required came from.
+const (
+	JavaFromAPI     = "api"     // the version's metadata in Fill v3
+	JavaFromBuiltin = "builtin" // java.MinimumFor, when the API does not say
+)
+
+// JavaCheck is whether the configured java can run a Paper version.
+type JavaCheck struct {
+	Version  string // the Paper version
+	Required int    // minimum Java release; 0 if unknown
+	Source   string // JavaFromAPI or JavaFromBuiltin
+	Runtime  *java.Runtime
+	// Err is why the configured java could not be run, if it could not. The check is
+	// then inconclusive rather than failed: the tool may not run where the server does.
+	Err error
+}
+
+// TooOld returns the *java.TooOldError if the configured java cannot run the version.
+func (c JavaCheck) TooOld() error {
+	if c.Runtime == nil {
+		return nil
+	}
+	return java.Check(*c.Runtime, c.Version, c.Required)
+}
+
+// String describes the check for people.
+func (c JavaCheck) String() string {
+	need := fmt.Sprintf("Paper %s needs Java %d", c.Version, c.Required)
+	if c.Required == 0 {
+		need = fmt.Sprintf("the Java release Paper %s needs is unknown", c.Version)
+	}
+	switch {
+	case c.Err != nil:
+		return fmt.Sprintf("%s; could not check the configured java: %v", need, c.Err)
+	case c.TooOld() != nil:
+		return fmt.Sprintf("%s, but %s is Java %d", need, c.Runtime.Path, c.Runtime.Major)
+	}
+	return fmt.Sprintf("%s; %s is Java %d", need, c.Runtime.Path, c.Runtime.Major)
+}
+
+// WithJava sets the java launcher the server runs with (a path, or a name looked up in
+// PATH) and what Install does when it is too old for the build. Without it Install
+// does not check.
+func WithJava(name string, policy JavaPolicy) Option {
+	return func(s *Service) {
+		if name != "" {
+			s.java = name
+		}
+		if policy != "" {
+			s.javaPolicy = policy
+		}
+	}
+}
+
+// Java returns the configured java launcher.
+func (s *Service) Java() string { return s.java }
+
+// JavaPolicy returns the configured java policy.
+func (s *Service) JavaPolicy() JavaPolicy { return s.javaPolicy }
+
+// CheckJava works out which Java release version needs, from the API or failing that
+// java.MinimumFor, and whether the configured java has it.
+func (s *Service) CheckJava(ctx context.Context, version string) JavaCheck {
+	c := JavaCheck{Version: version, Source: JavaFromAPI}
+	if info, err := s.client.Version(ctx, version); err == nil && info.Java.Version.Minimum > 0 {
+		c.Required = info.Java.Version.Minimum
+	} else {
+		if err != nil {
+			s.log.Debug("no java requirement from the API", logging.KeyVersion, version, logging.Err(err))
+		}
+		c.Required, c.Source = java.MinimumFor(version), JavaFromBuiltin
+	}
+	rt, err := java.NewFinder().Find(ctx, s.java)
+	if err != nil {
+		c.Err = err
+		return c
+	}
+	c.Runtime = &rt
+	return c
+}
+
+// checkJava applies the java policy before installing version.
+func (s *Service) checkJava(ctx context.Context, version string) error {
+	if s.javaPolicy == JavaOff {
+		return nil
+	}
+	c := s.CheckJava(ctx, version)
+	log := s.log.With(logging.KeyEvent, logging.EventInstall, logging.KeyVersion, version,
+		"java_required", c.Required, "java_required_from", c.Source)
+	tooOld := c.TooOld()
+	switch {
+	case c.Err != nil:
+		log.Warn("could not check the java version", logging.Err(c.Err))
+	case tooOld == nil:
+		log.Debug("java is new enough", "java", c.Runtime.Path, "java_major", c.Runtime.Major)
+	case s.javaPolicy == JavaBlock:
+		log.Error("install refused: java too old", "java", c.Runtime.Path, "java_major", c.Runtime.Major)
+		return fmt.Errorf("paper: install: %w (set java to a newer runtime, or java_check = warn)", tooOld)
+	default:
+		log.Warn("java too old for this build; installing anyway", "java", c.Runtime.Path, "java_major", c.Runtime.Major)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mbacalan/paper-mc-tui/internal/java"
+)
+
+// fakeJava writes a java launcher that reports Java major.
+func fakeJava(t *testing.T, major string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake java is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "java")
+	script := "#!/bin/sh\necho 'openjdk version \"" + major + ".0.1\" 2026-01-20' >&2\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInstallJavaCheck(t *testing.T) {
+	ctx := context.Background()
+	old := fakeJava(t, "21")
+
+	// The fixture's 26.1.2 needs Java 25 according to the API.
+	svc, dir, _ := newServiceFixture(t, WithJava(old, JavaBlock))
+	err := svc.Install(ctx, InstallOptions{})
+	var tooOld *java.TooOldError
+	if !errors.As(err, &tooOld) || tooOld.Required != 25 || tooOld.Runtime.Major != 21 {
+		t.Fatalf("Install with Java 21 = %v, want a TooOldError for Java 25", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "paper.jar")); !errors.Is(err, os.ErrNotExist) {
+		t.Error("a refused install still wrote paper.jar")
+	}
+
+	plan, err := svc.PlanInstall(ctx, InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Refused || plan.Java == nil || plan.Java.Source != JavaFromAPI {
+		t.Errorf("plan = %+v, want refused by the API's requirement", plan)
+	}
+	if lines := strings.Join(plan.Lines(), "\n"); !strings.Contains(lines, "Paper 26.1.2 needs Java 25, but "+old+" is Java 21") {
+		t.Errorf("plan lines do not explain the refusal:\n%s", lines)
+	}
+
+	svc, _, _ = newServiceFixture(t, WithJava(old, JavaWarn))
+	if err := svc.Install(ctx, InstallOptions{}); err != nil {
+		t.Errorf("Install with java_check = warn: %v", err)
+	}
+	svc, _, _ = newServiceFixture(t, WithJava(fakeJava(t, "25"), JavaBlock))
+	if err := svc.Install(ctx, InstallOptions{}); err != nil {
+		t.Errorf("Install with Java 25: %v", err)
+	}
+	// A java that cannot be run does not block: the tool may not run where the server does.
+	svc, _, _ = newServiceFixture(t, WithJava(filepath.Join(t.TempDir(), "missing"), JavaBlock))
+	if err := svc.Install(ctx, InstallOptions{}); err != nil {
+		t.Errorf("Install with a missing java: %v", err)
+	}
+}
+
+func TestCheckJavaBuiltin(t *testing.T) {
+	svc, _, _ := newServiceFixture(t, WithJava(fakeJava(t, "17"), JavaBlock))
+	// The fixture has no metadata for 1.21.10, so the built-in table applies.
+	c := svc.CheckJava(context.Background(), "1.21.10")
+	if c.Source != JavaFromBuiltin || c.Required != 21 || c.TooOld() == nil {
+		t.Errorf("CheckJava = %+v, want Java 21 from the built-in table, too old", c)
+	}
+}
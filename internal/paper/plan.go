@@ -0,0 +1,212 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mbacalan/paper-mc-tui/internal/atomicfile"
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/startscript"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+)
+
+// opBackup names a standalone Backup in a Plan; it is not journaled.
+const opBackup = "backup"
+
+// Plan is what Install, Backup or Rollback would do, worked out without changing
+// anything on disk, so it can be reviewed before the real operation (--dry-run).
+type Plan struct {
+	Op       string            // "install", "backup" or "rollback"
+	Download *papermc.Download // install only: the jar that would be fetched and verified
+	Steps    []journal.Step    // renames, state save and prune, in the order they would run
+	Prune    []string          // temp files the prune step would remove, as of now
+	State    *state.State      // what state.json would hold afterwards; nil if unchanged
+	LockedBy *lock.Holder      // another process holding the directory lock right now
+	Java     *JavaCheck        // install only, unless the java policy is JavaOff
+	Refused  bool              // the java check would refuse the install
+	// SmokeTest means the install would boot the new build in a sandbox, rolling back
+	// if it fails.
+	SmokeTest bool
+}
+
+// PlanInstall is Install without the download or any change to the directory. It
+// resolves the release the same way (honoring Select and CheckLatest) and fails, like
+// Install, if an interrupted operation is waiting for recovery.
+func (s *Service) PlanInstall(ctx context.Context, opts InstallOptions) (Plan, error) {
+	if err := s.planPending(opInstall); err != nil {
+		return Plan{}, err
+	}
+	rel, err := s.resolve(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+	prev, err := s.store.Load()
+	if err != nil {
+		return Plan{}, err
+	}
+	e := s.installEntry(rel, opts, prev)
+	dl := rel.Download
+	p, err := s.plan(e, &dl)
+	if err != nil {
+		return p, err
+	}
+	p.SmokeTest = s.smoke != nil
+	if s.javaPolicy == JavaOff {
+		return p, nil
+	}
+	jc := s.CheckJava(ctx, rel.Version)
+	p.Java, p.Refused = &jc, s.javaPolicy == JavaBlock && jc.TooOld() != nil
+	return p, nil
+}
+
+// PlanRollback is Rollback without the renames or the state save.
+func (s *Service) PlanRollback(name string) (Plan, error) {
+	if name == "" {
+		name = s.backupName
+	}
+	if err := s.planPending(opRollback); err != nil {
+		return Plan{}, err
+	}
+	e, _, err := s.rollbackEntry(name)
+	if err != nil {
+		return Plan{}, err
+	}
+	return s.plan(e, nil)
+}
+
+// PlanBackup is Backup without the rename.
+func (s *Service) PlanBackup(name string) (Plan, error) {
+	if name == "" {
+		name = s.backupName
+	}
+	if err := s.planPending(opBackup); err != nil {
+		return Plan{}, err
+	}
+	if !s.JarExists() {
+		return Plan{}, fmt.Errorf("paper: backup existing jar: %s: %w", s.jarName, os.ErrNotExist)
+	}
+	p := Plan{Op: opBackup, Steps: []journal.Step{{Kind: journal.StepBackup, From: s.jarPath(), To: filepath.Join(s.dir, name)}}}
+	p.LockedBy = s.lockHolder()
+	return p, nil
+}
+
+func (s *Service) planPending(op string) error {
+	if _, pending, err := s.journal.Pending(); err != nil {
+		return err
+	} else if pending {
+		return fmt.Errorf("paper: %s: %w", op, journal.ErrPending)
+	}
+	return nil
+}
+
+func (s *Service) plan(e *journal.Entry, dl *papermc.Download) (Plan, error) {
+	prune, err := s.pruneCandidates()
+	if err != nil {
+		return Plan{}, err
+	}
+	next := e.Next
+	return Plan{Op: e.Op, Download: dl, Steps: e.Steps, Prune: prune, State: &next, LockedBy: s.lockHolder()}, nil
+}
+
+// lockHolder returns who holds the directory lock, or nil if nobody does.
+func (s *Service) lockHolder() *lock.Holder {
+	h, err := lock.Read(s.dir)
+	if err != nil {
+		return nil
+	}
+	return &h
+}
+
+// Lines describes the plan for people, one action per line, with paths relative to the
+// server directory.
+func (p Plan) Lines() []string {
+	var lines []string
+	if h := p.LockedBy; h != nil {
+		lines = append(lines, fmt.Sprintf("note: the directory is locked by %s; the %s would fail while it holds it", h, p.Op))
+	}
+	if p.Java != nil {
+		lines = append(lines, "check java: "+p.Java.String())
+		if p.Refused {
+			lines = append(lines, fmt.Sprintf("  so the %s would be refused before downloading", p.Op))
+		}
+	}
+	if dl := p.Download; dl != nil {
+		lines = append(lines, fmt.Sprintf("download %s (%.1f MB) from %s", dl.Name, float64(dl.Size)/(1<<20), dl.URL))
+		lines = append(lines, "  and verify sha256 "+dl.Checksums.SHA256)
+	}
+	for _, st := range p.Steps {
+		switch st.Kind {
+		case journal.StepBackup, journal.StepSwap:
+			lines = append(lines, fmt.Sprintf("rename %s -> %s", filepath.Base(st.From), filepath.Base(st.To)))
+		case journal.StepSaveState:
+			if p.State != nil {
+				lines = append(lines, fmt.Sprintf("write state.json: %s (%d installs in history)",
+					describeState(*p.State), len(p.State.History)))
+			}
+		case journal.StepPrune:
+			if len(p.Prune) == 0 {
+				lines = append(lines, "remove leftover temp files (none now)")
+			} else {
+				lines = append(lines, "remove leftover temp files: "+strings.Join(p.Prune, ", "))
+			}
+		}
+	}
+	if p.SmokeTest {
+		i := slices.IndexFunc(p.Steps, func(st journal.Step) bool { return st.Kind == journal.StepBackup })
+		if i < 0 {
+			lines = append(lines, "boot the new build in a sandbox to smoke test it (there is no previous jar to roll back to)")
+		} else {
+			lines = append(lines, "boot the new build in a sandbox to smoke test it; put "+filepath.Base(p.Steps[i].To)+" back if it fails")
+		}
+	}
+	return lines
+}
+
+func describeState(st state.State) string {
+	if st.Build == 0 {
+		return st.JarName + " (unknown build)"
+	}
+	return fmt.Sprintf("%s build %d (%s)", st.Version, st.Build, st.JarName)
+}
+
+// tempPatterns match the temp files this tool writes in the server directory: downloads,
+// state and journal saves, pid files, eula.txt and start scripts. A crash can leave them
+// behind, along with a staged jar no journal was begun for.
+var tempPatterns = []string{
+	".paper-*.jar.tmp", ".state-*.json.tmp", ".journal-*.tmp",
+	atomicfile.TempPattern(supervisor.PIDFileName),
+	atomicfile.TempPattern(eula.FileName),
+	atomicfile.TempPattern(startscript.FileName),
+	stagedName,
+}
+
+// pruneCandidates lists the temp files prune would remove. Anything else is left alone,
+// even if it looks like a temp file: it is not ours.
+func (s *Service) pruneCandidates() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("paper: prune: %w", err)
+	}
+	var names []string
+	for _, ent := range entries {
+		if name := ent.Name(); slices.ContainsFunc(tempPatterns, func(pattern string) bool {
+			ok, _ := filepath.Match(pattern, name)
+			return ok
+		}) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
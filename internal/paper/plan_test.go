@@ -0,0 +1,123 @@
+package paper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+// snapshot records every file in dir with its content, to prove a plan changed nothing.
+func snapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		data, _ := os.ReadFile(filepath.Join(dir, e.Name()))
+		files[e.Name()] = string(data)
+	}
+	return files
+}
+
+func stepKinds(steps []journal.Step) []journal.StepKind {
+	kinds := make([]journal.StepKind, len(steps))
+	for i, s := range steps {
+		kinds[i] = s.Kind
+	}
+	return kinds
+}
+
+func TestPlanInstall(t *testing.T) {
+	svc, dir, payload := newServiceFixture(t)
+	old := []byte("old jar")
+	sum := sha256.Sum256(old)
+	prev := state.State{Version: "26.1.1", Build: 60, JarName: "paper-26.1.1-60.jar", SHA256: hex.EncodeToString(sum[:])}
+	if err := svc.store.Save(state.WithInstall(state.State{}, prev)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "paper.jar"), old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".paper-123.jar.tmp"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, dir)
+
+	p, err := svc.PlanInstall(context.Background(), InstallOptions{Backup: true, BackupName: "old.jar"})
+	if err != nil {
+		t.Fatalf("PlanInstall: %v", err)
+	}
+	want := []journal.StepKind{journal.StepBackup, journal.StepSwap, journal.StepSaveState, journal.StepPrune}
+	if got := stepKinds(p.Steps); !slices.Equal(got, want) {
+		t.Errorf("steps = %v, want %v", got, want)
+	}
+	if p.Download == nil || p.Download.Size != int64(len(payload)) || p.State.Build != 70 || len(p.State.History) != 2 {
+		t.Errorf("plan = %+v", p)
+	}
+	if !slices.Equal(p.Prune, []string{".paper-123.jar.tmp"}) {
+		t.Errorf("prune = %v", p.Prune)
+	}
+	text := strings.Join(p.Lines(), "\n")
+	for _, s := range []string{"download paper-26.1.2-70.jar", "rename paper.jar -> old.jar", "26.1.2 build 70", ".paper-123.jar.tmp"} {
+		if !strings.Contains(text, s) {
+			t.Errorf("plan text lacks %q:\n%s", s, text)
+		}
+	}
+	if after := snapshot(t, dir); !maps.Equal(before, after) {
+		t.Errorf("PlanInstall changed the directory:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func TestPlanRollbackAndBackup(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	if _, err := svc.PlanRollback(""); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("PlanRollback without a backup = %v, want ErrNoBackup", err)
+	}
+	if _, err := svc.PlanBackup(""); err == nil {
+		t.Error("PlanBackup without a jar succeeded")
+	}
+
+	for name, data := range map[string]string{"paper.jar": "new", "paper.backup.jar": "unknown"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := snapshot(t, dir)
+	p, err := svc.PlanRollback("")
+	if err != nil {
+		t.Fatalf("PlanRollback: %v", err)
+	}
+	if p.Op != opRollback || p.State.Build != 0 || p.Steps[0].To != filepath.Join(dir, rolledBackName) {
+		t.Errorf("plan = %+v", p)
+	}
+	b, err := svc.PlanBackup("keep.jar")
+	if err != nil || len(b.Steps) != 1 || b.State != nil || filepath.Base(b.Steps[0].To) != "keep.jar" {
+		t.Errorf("PlanBackup = %+v, %v", b, err)
+	}
+	if after := snapshot(t, dir); !maps.Equal(before, after) {
+		t.Errorf("plans changed the directory:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func TestPlanRefusesWhilePending(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	crashAt(t, svc, dir, false)
+	if _, err := svc.PlanInstall(context.Background(), InstallOptions{}); !errors.Is(err, journal.ErrPending) {
+		t.Errorf("PlanInstall = %v, want ErrPending", err)
+	}
+	if _, err := svc.PlanBackup(""); !errors.Is(err, journal.ErrPending) {
+		t.Errorf("PlanBackup = %v, want ErrPending", err)
+	}
+}
@@ -0,0 +1,199 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
+)
+
+// RecoveryAction says how an interrupted operation was resolved.
+type RecoveryAction string
+
+const (
+	// RecoveryNone means there was nothing to recover.
+	RecoveryNone RecoveryAction = ""
+	// RecoveryRolledForward means the new jar was already in place, so the remaining
+	// steps (state save, prune) were replayed.
+	RecoveryRolledForward RecoveryAction = "rolled forward"
+	// RecoveryRolledBack means the new jar never made it into place, so any backup was
+	// restored and the staged download discarded.
+	RecoveryRolledBack RecoveryAction = "rolled back"
+)
+
+// Recovery reports what Recover did. The zero value means nothing was pending.
+type Recovery struct {
+	Action RecoveryAction
+	Entry  journal.Entry
+}
+
+// String summarizes the recovery for display, e.g. "Recovered an interrupted install of
+// paper-26.1.2-70.jar: rolled forward."
+func (r Recovery) String() string {
+	if r.Action == RecoveryNone {
+		return ""
+	}
+	return fmt.Sprintf("Recovered an interrupted %s of %s: %s.", r.Entry.Op, r.Entry.Next.JarName, r.Action)
+}
+
+// Recover resolves an operation left unfinished by a crash or kill. If the staged jar
+// was already swapped into place it replays the remaining steps; otherwise it restores
+// any backup and discards the staged jar. Either way the journal is cleared. Smoke test
+// sandboxes left behind are removed too. Call it once at startup, before anything else
+// touches the directory.
+func (s *Service) Recover() (Recovery, error) {
+	_, pending, err := s.journal.Pending()
+	if err != nil {
+		return Recovery{}, err
+	}
+	sandboxes, err := smoketest.Sandboxes(s.dir)
+	if err != nil {
+		return Recovery{}, err
+	}
+	if !pending && len(sandboxes) == 0 {
+		return Recovery{}, nil
+	}
+	l, err := lock.Acquire(s.dir)
+	if err != nil {
+		// A live holder means the journal belongs to an operation still in progress,
+		// not a crashed one.
+		if errors.Is(err, lock.ErrLocked) {
+			return Recovery{}, nil
+		}
+		return Recovery{}, err
+	}
+	defer l.Release()
+	s.removeSandboxes(sandboxes)
+	return s.recover()
+}
+
+// removeSandboxes removes smoke test sandboxes a crash left behind. The caller holds
+// the lock, so no test is using them.
+func (s *Service) removeSandboxes(paths []string) {
+	for _, path := range paths {
+		log := s.log.With(logging.KeyEvent, logging.EventRecover, "path", path)
+		if err := os.RemoveAll(path); err != nil {
+			log.Warn("could not remove a leftover smoke test sandbox", logging.Err(err))
+			continue
+		}
+		log.Info("removed a leftover smoke test sandbox")
+	}
+}
+
+// recover is Recover for callers that already hold the directory lock.
+func (s *Service) recover() (Recovery, error) {
+	e, pending, err := s.journal.Pending()
+	if err != nil || !pending {
+		return Recovery{}, err
+	}
+
+	var action RecoveryAction
+	if swapped(&e) {
+		action = RecoveryRolledForward
+		for _, step := range e.Steps {
+			// The swap, and any backup before it, already took effect; replaying a
+			// rename now would move the new jar aside.
+			if step.Done || step.Kind == journal.StepBackup || step.Kind == journal.StepSwap {
+				continue
+			}
+			if err := s.runStep(&e, step); err != nil {
+				return Recovery{}, fmt.Errorf("paper: recover %s: replay %s: %w", e.Op, step.Kind, err)
+			}
+		}
+	} else {
+		action = RecoveryRolledBack
+		if err := s.rollBack(&e); err != nil {
+			return Recovery{}, fmt.Errorf("paper: recover %s: %w", e.Op, err)
+		}
+	}
+
+	if err := s.journal.Commit(); err != nil {
+		return Recovery{}, err
+	}
+	s.log.Warn("recovered interrupted operation", logging.KeyEvent, logging.EventRecover,
+		"op", e.Op, "action", string(action), "jar", e.Next.JarName,
+		logging.KeyVersion, e.Next.Version, logging.KeyBuild, e.Next.Build)
+	return Recovery{Action: action, Entry: e}, nil
+}
+
+// swapped reports whether the entry's swap step took effect. The journal may have been
+// written before the rename or after it but before the step was marked, so the staged
+// file's absence is the real signal: it always exists when the journal is begun.
+func swapped(e *journal.Entry) bool {
+	step, ok := e.Step(journal.StepSwap)
+	if !ok {
+		return false
+	}
+	return step.Done || !exists(step.From)
+}
+
+// rollBack undoes a backup step (if it happened) and, for an install, removes the staged
+// jar. A rollback's swap source is the user's backup jar, which is left alone.
+func (s *Service) rollBack(e *journal.Entry) error {
+	if step, ok := e.Step(journal.StepBackup); ok && !exists(step.From) && exists(step.To) {
+		if err := os.Rename(step.To, step.From); err != nil {
+			return fmt.Errorf("restore backup: %w", err)
+		}
+	}
+	if step, ok := e.Step(journal.StepSwap); ok && e.Op == opInstall {
+		if err := os.Remove(step.From); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove staged jar: %w", err)
+		}
+	}
+	return s.prune()
+}
+
+// runStep applies one journal step. Steps tolerate having already run (a rename whose
+// source is gone and destination present is a no-op), so replaying one is safe.
+func (s *Service) runStep(e *journal.Entry, step journal.Step) error {
+	switch step.Kind {
+	case journal.StepBackup, journal.StepSwap:
+		if !exists(step.From) && exists(step.To) {
+			return nil // already renamed
+		}
+		if err := os.Rename(step.From, step.To); err != nil {
+			return fmt.Errorf("paper: %s: %w", step.Kind, err)
+		}
+		if step.Kind == journal.StepBackup {
+			s.log.Info("backed up existing jar", logging.KeyEvent, logging.EventBackup, "to", filepath.Base(step.To))
+		}
+		return nil
+	case journal.StepSaveState:
+		if err := s.store.Save(e.Next); err != nil {
+			return fmt.Errorf("paper: save state: %w", err)
+		}
+		return nil
+	case journal.StepPrune:
+		return s.prune()
+	default:
+		return fmt.Errorf("paper: unknown journal step %q", step.Kind)
+	}
+}
+
+// prune removes temp files that interrupted downloads and state saves leave behind. It
+// only runs under the directory lock, so no other copy of the tool is writing them.
+func (s *Service) prune() error {
+	if !lock.Held(s.dir) {
+		return errors.New("paper: prune: the directory lock is not held")
+	}
+	names, err := s.pruneCandidates()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("paper: prune %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,125 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+// rolledBackName is where Rollback moves the jar it replaces, so a rollback can itself be
+// undone by hand.
+const rolledBackName = "paper.rolledback.jar"
+
+// ErrNoBackup means there is no backup jar to roll back to.
+var ErrNoBackup = errors.New("paper: no backup jar to roll back to")
+
+// RollbackResult describes a completed rollback.
+type RollbackResult struct {
+	Restored state.State // what is installed now
+	Known    bool        // whether the backup matched a build in the install history
+	MovedTo  string      // where the replaced jar was moved
+}
+
+// Rollback puts the backup jar (name, default "paper.backup.jar") back in place as
+// paper.jar, moving the current jar to paper.rolledback.jar. The backup is identified by
+// its SHA256 against the install history so state.json can name its build; an
+// unrecognized backup is still restored, recorded with build 0. Like Install, the swap
+// is journaled and runs under the directory lock.
+func (s *Service) Rollback(name string) (RollbackResult, error) {
+	if name == "" {
+		name = s.backupName
+	}
+
+	l, err := lock.Acquire(s.dir)
+	if err != nil {
+		return RollbackResult{}, err
+	}
+	defer l.Release()
+	return s.rollback(name)
+}
+
+// rollback restores backup file name. The caller holds the lock.
+func (s *Service) rollback(name string) (RollbackResult, error) {
+	if _, pending, err := s.journal.Pending(); err != nil {
+		return RollbackResult{}, err
+	} else if pending {
+		return RollbackResult{}, fmt.Errorf("paper: rollback: %w", journal.ErrPending)
+	}
+	e, result, err := s.rollbackEntry(name)
+	if err != nil {
+		return RollbackResult{}, err
+	}
+	restored := result.Restored
+
+	log := s.log.With(logging.KeyEvent, logging.EventRollback, logging.KeyVersion, restored.Version,
+		logging.KeyBuild, restored.Build, "jar", name)
+	if !result.Known {
+		log.Warn("backup does not match any recorded install; restoring it as an unknown build")
+	}
+	if err := s.journal.Begin(e); err != nil {
+		return RollbackResult{}, fmt.Errorf("paper: rollback: %w", err)
+	}
+	if err := s.apply(e, log); err != nil {
+		return RollbackResult{}, err
+	}
+	log.Info("rolled back")
+	s.notifier.Notify(notify.Event{
+		Kind:    notify.KindRollback,
+		Version: restored.Version,
+		Build:   restored.Build,
+		JarName: restored.JarName,
+	})
+	return result, nil
+}
+
+// rollbackEntry journals restoring backup file name over the live jar, identifying the
+// backup by its SHA256 against the install history. It only reads the directory.
+func (s *Service) rollbackEntry(name string) (*journal.Entry, RollbackResult, error) {
+	backup := filepath.Join(s.dir, name)
+	if !exists(backup) {
+		return nil, RollbackResult{}, fmt.Errorf("%w: %s not found", ErrNoBackup, name)
+	}
+	sum, err := download.HashFile(backup)
+	if err != nil {
+		return nil, RollbackResult{}, err
+	}
+	prev, err := s.store.Load()
+	if err != nil {
+		return nil, RollbackResult{}, err
+	}
+
+	restored := state.State{JarName: name, SHA256: sum}
+	i := slices.IndexFunc(prev.History, func(r state.Record) bool { return r.SHA256 == sum })
+	if i >= 0 {
+		r := prev.History[i]
+		restored = state.State{Version: r.Version, Build: r.Build, JarName: r.JarName, SHA256: r.SHA256}
+	}
+	restored.InstalledAt = time.Now()
+
+	e := &journal.Entry{
+		Op:        opRollback,
+		StartedAt: time.Now(),
+		Prev:      prev,
+		Next:      state.WithInstall(prev, restored),
+	}
+	result := RollbackResult{Restored: e.Next, Known: i >= 0}
+	if s.JarExists() {
+		result.MovedTo = filepath.Join(s.dir, rolledBackName)
+		e.Steps = append(e.Steps, journal.Step{Kind: journal.StepBackup, From: s.jarPath(), To: result.MovedTo})
+	}
+	e.Steps = append(e.Steps,
+		journal.Step{Kind: journal.StepSwap, From: backup, To: s.jarPath()},
+		journal.Step{Kind: journal.StepSaveState},
+		journal.Step{Kind: journal.StepPrune},
+	)
+	return e, result, nil
+}
@@ -5,19 +5,61 @@ package paper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
 	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
 	"github.com/mbacalan/paper-mc-tui/internal/state"
 )
 
 const (
-	jarName           = "paper.jar"
-	defaultBackupName = "paper.backup.jar"
+	// DefaultJarName is the server jar's name in the target directory.
+	DefaultJarName = "paper.jar"
+	// DefaultBackupName is where Backup and Install move an existing jar by default.
+	DefaultBackupName = "paper.backup.jar"
+	// stagedName is where a verified download waits before the journaled swap moves it
+	// over paper.jar.
+	stagedName = ".paper-staged.jar"
+)
+
+const (
+	// DefaultCheckTimeout bounds a "check latest" API round-trip.
+	DefaultCheckTimeout = 30 * time.Second
+	// DefaultDownloadTimeout bounds the jar transfer (separate from the short API timeout).
+	DefaultDownloadTimeout = 15 * time.Minute
+)
+
+// Timeouts bounds the service's network operations. Callers apply them to the contexts
+// they pass in.
+type Timeouts struct {
+	Check    time.Duration
+	Download time.Duration
+}
+
+// BackupPolicy says whether an existing jar is backed up before an install.
+type BackupPolicy string
+
+const (
+	BackupAsk    BackupPolicy = "ask"    // prompt in the TUI; the CLI does not back up unless told to
+	BackupAlways BackupPolicy = "always" // always move the existing jar aside
+	BackupNever  BackupPolicy = "never"  // overwrite the existing jar
+)
+
+// Journal operation names.
+const (
+	opInstall  = "install"
+	opRollback = "rollback"
 )
 
 // Service ties together the API client, downloader, and state store for one target
@@ -26,8 +68,20 @@ type Service struct {
 	client     *papermc.Client
 	downloader *download.Downloader
 	store      *state.Store
+	journal    *journal.Journal
+	log        *slog.Logger
 	dir        string
 	channels   []papermc.Channel
+	within     papermc.Constraint
+	jarName    string
+	backupName string
+	backup     BackupPolicy
+	timeouts   Timeouts
+	metrics    *metrics.Metrics
+	notifier   *notify.Notifier
+	java       string
+	javaPolicy JavaPolicy
+	smoke      *smoketest.Tester
 
 	// cached holds the most recent resolution so Install need not query the API again
 	// after CheckLatest. The UI drives these calls sequentially on one goroutine.
@@ -42,40 +96,166 @@ type LatestInfo struct {
 	Channel  papermc.Channel
 	Download papermc.Download
 	UpToDate bool // true if the installed jar already matches this release
+	Commits  []papermc.Commit
+	Behind   int // builds between the installed jar and this release
+}
+
+// Option configures a Service.
+type Option func(*Service)
+
+// WithChannels sets the release channels CheckLatest accepts (default STABLE only).
+func WithChannels(channels ...papermc.Channel) Option {
+	return func(s *Service) {
+		if len(channels) > 0 {
+			s.channels = channels
+		}
+	}
+}
+
+// WithConstraint restricts CheckLatest to versions the constraint allows, e.g. "1.21"
+// to stay on 1.21.x.
+func WithConstraint(c papermc.Constraint) Option {
+	return func(s *Service) { s.within = c }
+}
+
+// WithJarName sets the server jar's name in the target directory.
+func WithJarName(name string) Option {
+	return func(s *Service) {
+		if name != "" {
+			s.jarName = name
+		}
+	}
+}
+
+// WithBackup sets the backup policy and the default backup file name.
+func WithBackup(policy BackupPolicy, name string) Option {
+	return func(s *Service) {
+		if policy != "" {
+			s.backup = policy
+		}
+		if name != "" {
+			s.backupName = name
+		}
+	}
 }
 
-// NewService builds a Service. If no channels are given it defaults to STABLE.
-func NewService(dir string, client *papermc.Client, dl *download.Downloader, store *state.Store, channels ...papermc.Channel) *Service {
-	if len(channels) == 0 {
-		channels = []papermc.Channel{papermc.ChannelStable}
+// WithMetrics sets where the installed build, the latest build and the time of the last
+// successful check are published.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(s *Service) {
+		if m != nil {
+			s.metrics = m
+		}
 	}
-	return &Service{
+}
+
+// WithNotifier sets where install and rollback outcomes are announced.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(s *Service) {
+		if n != nil {
+			s.notifier = n
+		}
+	}
+}
+
+// WithTimeouts overrides the default timeouts; zero fields keep their default.
+func WithTimeouts(t Timeouts) Option {
+	return func(s *Service) {
+		if t.Check > 0 {
+			s.timeouts.Check = t.Check
+		}
+		if t.Download > 0 {
+			s.timeouts.Download = t.Download
+		}
+	}
+}
+
+// NewService builds a Service for dir with sensible defaults, overridden by opts. It
+// records activity to the store's logger.
+func NewService(dir string, client *papermc.Client, dl *download.Downloader, store *state.Store, opts ...Option) *Service {
+	s := &Service{
 		client:     client,
 		downloader: dl,
 		store:      store,
+		journal:    journal.New(dir),
+		log:        store.Logger(),
 		dir:        dir,
-		channels:   channels,
+		channels:   []papermc.Channel{papermc.ChannelStable},
+		jarName:    DefaultJarName,
+		backupName: DefaultBackupName,
+		backup:     BackupAsk,
+		timeouts:   Timeouts{Check: DefaultCheckTimeout, Download: DefaultDownloadTimeout},
+		metrics:    metrics.Discard(),
+		notifier:   notify.Discard(),
+		java:       "java",
+		javaPolicy: JavaOff,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-func (s *Service) jarPath() string { return filepath.Join(s.dir, jarName) }
+// Timeouts returns the configured timeouts.
+func (s *Service) Timeouts() Timeouts { return s.timeouts }
+
+// BackupPolicy returns the configured backup policy.
+func (s *Service) BackupPolicy() BackupPolicy { return s.backup }
+
+// BackupName returns the default backup file name.
+func (s *Service) BackupName() string { return s.backupName }
+
+// JarName returns the server jar's name in the target directory.
+func (s *Service) JarName() string { return s.jarName }
+
+// InstallOptions tunes a single Install.
+type InstallOptions struct {
+	// Backup moves an existing jar aside (to BackupName, default the configured backup
+	// name) as part of the install instead of overwriting it.
+	Backup     bool
+	BackupName string
+	// OnProgress, if non-nil, receives transfer progress.
+	OnProgress func(done, total int64)
+	// OnSmokeTest, if non-nil, receives the result of the smoke test, when one is
+	// configured with WithSmokeTest.
+	OnSmokeTest func(smoketest.Result)
+}
+
+func (s *Service) jarPath() string    { return filepath.Join(s.dir, s.jarName) }
+func (s *Service) stagedPath() string { return filepath.Join(s.dir, stagedName) }
 
 // CheckLatest resolves the newest available release and reports whether it is already
 // installed. It refreshes the cached release used by Install.
 func (s *Service) CheckLatest(ctx context.Context) (LatestInfo, error) {
-	rel, err := s.client.Resolve(ctx, s.channels...)
+	rel, err := s.client.ResolveWithin(ctx, s.within, s.channels...)
 	if err != nil {
 		return LatestInfo{}, err
 	}
 	s.cached = &rel
-	return s.infoFor(rel)
+	info, err := s.infoFor(rel)
+	if err != nil {
+		return LatestInfo{}, err
+	}
+	s.metrics.SetLatest(info.Version, info.Build, info.Behind, time.Now())
+	s.log.Info("checked latest", logging.KeyEvent, logging.EventCheck, logging.KeyVersion, info.Version,
+		logging.KeyBuild, info.Build, "up_to_date", info.UpToDate)
+	return info, nil
 }
 
-// Installed returns the build currently recorded as installed (zero State if none).
+// Installed returns the build currently recorded as installed (zero State if none),
+// publishing it to the metrics.
 func (s *Service) Installed() (state.State, error) {
-	return s.store.Load()
+	st, err := s.store.Load()
+	if err != nil {
+		return state.State{}, err
+	}
+	s.metrics.SetInstalled(st.Version, st.Build)
+	return st, nil
 }
 
+// LogPath returns the location of the activity log in the target directory.
+func (s *Service) LogPath() string { return filepath.Join(s.dir, state.LogFileName) }
+
 // JarExists reports whether a paper.jar is already present in the target directory.
 func (s *Service) JarExists() bool {
 	_, err := os.Stat(s.jarPath())
@@ -85,42 +265,191 @@ func (s *Service) JarExists() bool {
 // Backup renames the existing paper.jar to name (default "paper.backup.jar") within the
 // target directory and returns the path it was moved to.
 func (s *Service) Backup(name string) (string, error) {
+	l, err := lock.Acquire(s.dir)
+	if err != nil {
+		return "", err
+	}
+	defer l.Release()
+
 	if name == "" {
-		name = defaultBackupName
+		name = s.backupName
 	}
 	dest := filepath.Join(s.dir, name)
 	if err := os.Rename(s.jarPath(), dest); err != nil {
 		return "", fmt.Errorf("paper: backup existing jar: %w", err)
 	}
-	_ = s.store.Log("backed up existing %s to %s", jarName, name)
+	s.log.Info("backed up existing jar", logging.KeyEvent, logging.EventBackup, "to", name)
 	return dest, nil
 }
 
-// Install downloads and verifies the latest release into the target directory, then
-// records it in the state file. onProgress, if non-nil, receives transfer progress.
-func (s *Service) Install(ctx context.Context, onProgress func(done, total int64)) error {
+// Install downloads and verifies the latest release into a staging file, then swaps it
+// into place and records it in the state file. The swap is journaled (backup, swap,
+// state save, prune) so a crash part-way through is finished or undone by Recover on
+// the next run instead of leaving state.json describing the wrong jar.
+//
+// Unless the java policy is JavaOff, Install first checks that the configured java can
+// run the release; with JavaBlock a runtime that is too old fails the install with a
+// *java.TooOldError before anything is downloaded.
+//
+// With WithSmokeTest, the new build is then booted against a sandboxed copy of the
+// server. If it fails, the jar it replaced is restored and the error is a
+// *SmokeTestError; the existing jar is always backed up so there is one to restore.
+//
+// Install holds the directory lock throughout; if another process holds it, the error
+// is a *lock.HeldError naming that process. Once a release is resolved, success or
+// failure is announced to the notifier, unless ctx was canceled.
+func (s *Service) Install(ctx context.Context, opts InstallOptions) error {
+	l, err := lock.Acquire(s.dir)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	if _, pending, err := s.journal.Pending(); err != nil {
+		return err
+	} else if pending {
+		return fmt.Errorf("paper: install: %w", journal.ErrPending)
+	}
+
 	rel, err := s.resolve(ctx)
 	if err != nil {
 		return err
 	}
 
-	_ = s.store.Log("downloading %s (build %d, %s)", rel.Download.Name, rel.Build.ID, rel.Build.Channel)
-	if err := s.downloader.Download(ctx, rel.Download, s.jarPath(), onProgress); err != nil {
-		_ = s.store.Log("download of %s failed: %v", rel.Download.Name, err)
+	err = s.install(ctx, rel, opts)
+	ev := notify.Event{
+		Kind:    notify.KindInstallSucceeded,
+		Version: rel.Version,
+		Build:   rel.Build.ID,
+		Channel: string(rel.Build.Channel),
+		JarName: rel.Download.Name,
+		Commits: notify.Commits(rel.Build.Commits),
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The user called it off; nothing to announce.
+	case err != nil:
+		ev.Kind, ev.Error = notify.KindInstallFailed, err.Error()
+		s.notifier.Notify(ev)
+	default:
+		s.notifier.Notify(ev)
+	}
+	return err
+}
+
+// install downloads rel and swaps it in. The caller holds the lock.
+func (s *Service) install(ctx context.Context, rel papermc.Release, opts InstallOptions) error {
+	log := s.log.With(logging.KeyEvent, logging.EventInstall, logging.KeyVersion, rel.Version,
+		logging.KeyBuild, rel.Build.ID, "jar", rel.Download.Name)
+	if err := s.checkJava(ctx, rel.Version); err != nil {
+		return err
+	}
+	log.Info("installing", "channel", string(rel.Build.Channel), logging.KeyBytes, rel.Download.Size)
+	if err := s.downloader.Download(ctx, rel.Download, s.stagedPath(), opts.OnProgress); err != nil {
+		log.Error("install failed", "step", "download", logging.Err(err))
+		return err
+	}
+
+	prev, err := s.store.Load()
+	if err != nil {
+		os.Remove(s.stagedPath())
+		return err
+	}
+	e := s.installEntry(rel, opts, prev)
+
+	if err := s.journal.Begin(e); err != nil {
+		os.Remove(s.stagedPath())
+		return fmt.Errorf("paper: install: %w", err)
+	}
+	if err := s.apply(e, log); err != nil {
 		return err
 	}
+	log.Info("installed")
+	if s.smoke != nil {
+		return s.smokeTest(ctx, e, opts, log)
+	}
+	return nil
+}
+
+// installEntry journals installing rel over prev: a backup of the live jar if asked
+// for or a smoke test is configured, the swap of the staged download into place, the
+// state save and a prune.
+func (s *Service) installEntry(rel papermc.Release, opts InstallOptions, prev state.State) *journal.Entry {
+	e := &journal.Entry{
+		Op:        opInstall,
+		StartedAt: time.Now(),
+		Prev:      prev,
+		Next: state.WithInstall(prev, state.State{
+			Version:     rel.Version,
+			Build:       rel.Build.ID,
+			JarName:     rel.Download.Name,
+			SHA256:      rel.Download.Checksums.SHA256,
+			InstalledAt: time.Now(),
+		}),
+	}
+	if (opts.Backup || s.smoke != nil) && s.JarExists() {
+		e.Steps = append(e.Steps, journal.Step{Kind: journal.StepBackup, From: s.jarPath(), To: filepath.Join(s.dir, s.installBackupName(opts))})
+	}
+	e.Steps = append(e.Steps,
+		journal.Step{Kind: journal.StepSwap, From: s.stagedPath(), To: s.jarPath()},
+		journal.Step{Kind: journal.StepSaveState},
+		journal.Step{Kind: journal.StepPrune},
+	)
+	return e
+}
+
+// installBackupName is where an install with opts moves the existing jar.
+func (s *Service) installBackupName(opts InstallOptions) string {
+	if opts.BackupName != "" {
+		return opts.BackupName
+	}
+	return s.backupName
+}
 
-	st := state.State{
-		Version:     rel.Version,
-		Build:       rel.Build.ID,
-		JarName:     rel.Download.Name,
-		SHA256:      rel.Download.Checksums.SHA256,
-		InstalledAt: time.Now(),
+// Select pins the release Install will use to a specific version and build, bypassing
+// the channel filter: an explicit choice is honored whatever its channel. A build of 0
+// means the version's latest build.
+func (s *Service) Select(ctx context.Context, version string, build int) (LatestInfo, error) {
+	var (
+		b   papermc.Build
+		err error
+	)
+	if build == 0 {
+		b, err = s.client.LatestBuild(ctx, version)
+	} else {
+		b, err = s.client.Build(ctx, version, build)
 	}
-	if err := s.store.Save(st); err != nil {
-		return fmt.Errorf("paper: save state: %w", err)
+	if err != nil {
+		return LatestInfo{}, err
 	}
-	_ = s.store.Log("installed %s", rel.Download.Name)
+	dl, ok := b.ServerDefault()
+	if !ok {
+		return LatestInfo{}, fmt.Errorf("papermc: version %s build %d: %w", version, b.ID, papermc.ErrNoServerDownload)
+	}
+	rel := papermc.Release{Version: version, Build: b, Download: dl}
+	s.cached = &rel
+	return s.infoFor(rel)
+}
+
+// apply runs a begun journal entry's steps, marking each done, and commits it. If a
+// step fails it recovers immediately rather than waiting for the next start.
+func (s *Service) apply(e *journal.Entry, log *slog.Logger) error {
+	for i := range e.Steps {
+		if err := s.runStep(e, e.Steps[i]); err != nil {
+			log.Error(e.Op+" failed", "step", string(e.Steps[i].Kind), logging.Err(err))
+			if _, rerr := s.recover(); rerr != nil {
+				return fmt.Errorf("%w (recovery also failed: %v)", err, rerr)
+			}
+			return err
+		}
+		if err := s.journal.Mark(e, i); err != nil {
+			return err
+		}
+	}
+	if err := s.journal.Commit(); err != nil {
+		return err
+	}
+	s.metrics.SetInstalled(e.Next.Version, e.Next.Build)
 	return nil
 }
 
@@ -130,7 +459,7 @@ func (s *Service) resolve(ctx context.Context) (papermc.Release, error) {
 	if s.cached != nil {
 		return *s.cached, nil
 	}
-	rel, err := s.client.Resolve(ctx, s.channels...)
+	rel, err := s.client.ResolveWithin(ctx, s.within, s.channels...)
 	if err != nil {
 		return papermc.Release{}, err
 	}
@@ -140,7 +469,7 @@ func (s *Service) resolve(ctx context.Context) (papermc.Release, error) {
 
 // infoFor builds a LatestInfo and compares it against the installed state.
 func (s *Service) infoFor(rel papermc.Release) (LatestInfo, error) {
-	installed, err := s.store.Load()
+	installed, err := s.Installed()
 	if err != nil {
 		return LatestInfo{}, err
 	}
@@ -152,5 +481,21 @@ func (s *Service) infoFor(rel papermc.Release) (LatestInfo, error) {
 		Channel:  rel.Build.Channel,
 		Download: rel.Download,
 		UpToDate: upToDate,
+		Commits:  rel.Build.Commits,
+		Behind:   buildsBehind(installed, rel, upToDate),
 	}, nil
 }
+
+// buildsBehind counts the builds between the installed jar and rel. Build numbers are
+// per version, so on a different version (or with nothing installed) every build of
+// rel's version counts.
+func buildsBehind(installed state.State, rel papermc.Release, upToDate bool) int {
+	switch {
+	case upToDate:
+		return 0
+	case installed.Version == rel.Version:
+		return max(rel.Build.ID-installed.Build, 0)
+	default:
+		return rel.Build.ID
+	}
+}
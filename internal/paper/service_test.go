@@ -4,21 +4,30 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
 	"github.com/mbacalan/paper-mc-tui/internal/papermc"
 	"github.com/mbacalan/paper-mc-tui/internal/state"
 )
 
 // newServiceFixture spins up an httptest server that serves the Fill v3 endpoints and
 // the jar object, wired to a Service rooted in a temp dir.
-func newServiceFixture(t *testing.T) (*Service, string, []byte) {
+func newServiceFixture(t *testing.T, opts ...Option) (*Service, string, []byte) {
 	t.Helper()
 
 	payload := []byte("pretend this is a 55MB paper server jar")
@@ -29,6 +38,13 @@ func newServiceFixture(t *testing.T) (*Service, string, []byte) {
 	mux.HandleFunc("/projects/paper", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, `{"project":{"id":"paper","name":"Paper"},"versions":{"26.2":["26.2-rc-2"],"26.1":["26.1.2"]}}`)
 	})
+	mux.HandleFunc("/projects/paper/versions/26.1.2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":{"id":"26.1.2","support":{"status":"SUPPORTED"},
+			"java":{"version":{"minimum":25},"flags":{"recommended":["-XX:+UseG1GC","-XX:+AlwaysPreTouch"]}}},"builds":[70]}`)
+	})
+	mux.HandleFunc("/projects/paper/versions/1.20.4", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"version":{"id":"1.20.4","support":{"status":"UNSUPPORTED"},"java":{"version":{"minimum":17}}},"builds":[499]}`)
+	})
 	mux.HandleFunc("/jar", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write(payload)
 	})
@@ -55,7 +71,8 @@ func newServiceFixture(t *testing.T) (*Service, string, []byte) {
 	)
 	dl := download.NewDownloader(download.WithHTTPClient(srv.Client()))
 
-	return NewService(dir, client, dl, store, papermc.ChannelStable), dir, payload
+	opts = append([]Option{WithChannels(papermc.ChannelStable)}, opts...)
+	return NewService(dir, client, dl, store, opts...), dir, payload
 }
 
 func TestServiceInstallThenUpToDate(t *testing.T) {
@@ -73,7 +90,7 @@ func TestServiceInstallThenUpToDate(t *testing.T) {
 		t.Error("nothing installed yet; UpToDate should be false")
 	}
 
-	if err := svc.Install(ctx, nil); err != nil {
+	if err := svc.Install(ctx, InstallOptions{}); err != nil {
 		t.Fatalf("Install: %v", err)
 	}
 
@@ -102,6 +119,104 @@ func TestServiceInstallThenUpToDate(t *testing.T) {
 	}
 }
 
+func TestServiceOptions(t *testing.T) {
+	svc, dir, payload := newServiceFixture(t,
+		WithJarName("server.jar"),
+		WithBackup(BackupAlways, "server.old.jar"),
+		WithTimeouts(Timeouts{Check: time.Second}),
+	)
+	if got := svc.Timeouts(); got.Check != time.Second || got.Download != DefaultDownloadTimeout {
+		t.Fatalf("Timeouts = %+v", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "server.jar"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CheckLatest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// An empty BackupName falls back to the configured one.
+	if err := svc.Install(context.Background(), InstallOptions{Backup: true}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "server.jar")); string(got) != string(payload) {
+		t.Errorf("server.jar = %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "server.old.jar")); string(got) != "old" {
+		t.Errorf("server.old.jar = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DefaultJarName)); !os.IsNotExist(err) {
+		t.Errorf("%s should not exist: %v", DefaultJarName, err)
+	}
+}
+
+func TestServiceConstraint(t *testing.T) {
+	// 26.2 only has a release candidate, which the STABLE channel never accepts.
+	svc, _, _ := newServiceFixture(t, WithConstraint("26.2"))
+	if _, err := svc.CheckLatest(context.Background()); !errors.Is(err, papermc.ErrNoStableBuild) {
+		t.Fatalf("CheckLatest = %v, want ErrNoStableBuild", err)
+	}
+}
+
+func TestServiceMetrics(t *testing.T) {
+	m := metrics.New()
+	svc, _, _ := newServiceFixture(t, WithMetrics(m))
+	scrape := func() string {
+		var b strings.Builder
+		if _, err := m.Registry().WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	if _, err := svc.CheckLatest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	out := scrape()
+	for _, want := range []string{
+		"paper_mc_installed_build 0\n",
+		`paper_mc_latest_build{version="26.1.2"} 70`,
+		"paper_mc_builds_behind 70\n", // nothing installed: every build counts
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("before install: missing %q", want)
+		}
+	}
+
+	if err := svc.Install(context.Background(), InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CheckLatest(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	out = scrape()
+	for _, want := range []string{
+		`paper_mc_installed_info{version="26.1.2",build="70"} 1`,
+		"paper_mc_builds_behind 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("after install: missing %q", want)
+		}
+	}
+}
+
+func TestBuildsBehind(t *testing.T) {
+	rel := papermc.Release{Version: "26.1.2", Build: papermc.Build{ID: 70}}
+	tests := []struct {
+		installed state.State
+		want      int
+	}{
+		{state.State{Version: "26.1.2", Build: 66}, 4},
+		{state.State{Version: "26.1.2", Build: 72}, 0}, // pinned newer than latest
+		{state.State{Version: "1.21.10", Build: 130}, 70},
+		{state.State{}, 70},
+	}
+	for _, tt := range tests {
+		if got := buildsBehind(tt.installed, rel, false); got != tt.want {
+			t.Errorf("buildsBehind(%s #%d) = %d, want %d", tt.installed.Version, tt.installed.Build, got, tt.want)
+		}
+	}
+}
+
 func TestServiceBackup(t *testing.T) {
 	svc, dir, _ := newServiceFixture(t)
 	jar := filepath.Join(dir, "paper.jar")
@@ -124,3 +239,280 @@ func TestServiceBackup(t *testing.T) {
 		t.Errorf("backup content = %q, want 'old jar'", got)
 	}
 }
+
+func TestServiceInstallWithBackup(t *testing.T) {
+	svc, dir, payload := newServiceFixture(t)
+	if err := os.WriteFile(filepath.Join(dir, "paper.jar"), []byte("old jar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Install(context.Background(), InstallOptions{Backup: true, BackupName: "old.jar"}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "old.jar")); string(got) != "old jar" {
+		t.Errorf("backup content = %q, want 'old jar'", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(got) != string(payload) {
+		t.Error("paper.jar should hold the new build")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "paper-mc.journal")); !os.IsNotExist(err) {
+		t.Error("journal should be removed after a completed install")
+	}
+}
+
+// crashAt writes a journal as Install would have just before a crash: the new jar is
+// staged, the old one may have been backed up, and done marks the completed steps.
+func crashAt(t *testing.T, svc *Service, dir string, swappedIn bool) {
+	t.Helper()
+	jar := filepath.Join(dir, "paper.jar")
+	backup := filepath.Join(dir, "paper.backup.jar")
+	staged := filepath.Join(dir, stagedName)
+
+	// The backup step ran: the old jar is aside.
+	if err := os.WriteFile(backup, []byte("old jar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if swappedIn {
+		if err := os.WriteFile(jar, []byte("new jar"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		if err := os.WriteFile(staged, []byte("new jar"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := &journal.Entry{
+		Op:   opInstall,
+		Prev: state.State{Version: "26.1.1", Build: 60, JarName: "paper-26.1.1-60.jar"},
+		Next: state.State{Version: "26.1.2", Build: 70, JarName: "paper-26.1.2-70.jar"},
+		Steps: []journal.Step{
+			{Kind: journal.StepBackup, From: jar, To: backup, Done: true},
+			{Kind: journal.StepSwap, From: staged, To: jar}, // rename may or may not have happened
+			{Kind: journal.StepSaveState},
+			{Kind: journal.StepPrune},
+		},
+	}
+	if err := svc.journal.Begin(e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverRollsForward(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	crashAt(t, svc, dir, true)
+
+	r, err := svc.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if r.Action != RecoveryRolledForward {
+		t.Errorf("action = %q, want %q", r.Action, RecoveryRolledForward)
+	}
+	st, _ := svc.Installed()
+	if st.Build != 70 {
+		t.Errorf("state build = %d, want 70 after replaying the state save", st.Build)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(got) != "new jar" {
+		t.Errorf("paper.jar = %q, want 'new jar'", got)
+	}
+	if r2, _ := svc.Recover(); r2.Action != RecoveryNone {
+		t.Error("second Recover should find nothing pending")
+	}
+}
+
+func TestRecoverRollsBack(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	crashAt(t, svc, dir, false)
+
+	r, err := svc.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if r.Action != RecoveryRolledBack {
+		t.Errorf("action = %q, want %q", r.Action, RecoveryRolledBack)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(got) != "old jar" {
+		t.Errorf("paper.jar = %q, want the restored 'old jar'", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stagedName)); !os.IsNotExist(err) {
+		t.Error("staged jar should be discarded on rollback")
+	}
+	if st, _ := svc.Installed(); st.Build != 0 {
+		t.Errorf("state should be untouched on rollback, got build %d", st.Build)
+	}
+}
+
+func TestRecoverPrunesOnlyOurTempFiles(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	ours := []string{".paper-1.jar.tmp", ".state-2.json.tmp", ".journal-3.tmp", ".paper.pid-4.tmp", ".eula.txt-5.tmp", ".start.sh-6.tmp"}
+	theirs := []string{".editor-7.tmp", ".plugin-8.jar.tmp", "world.tmp"}
+	for _, name := range append(ours, theirs...) {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.prune(); err == nil {
+		t.Error("prune without the lock succeeded")
+	}
+	crashAt(t, svc, dir, false)
+	if _, err := svc.Recover(); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	for _, name := range ours {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not pruned", name)
+		}
+	}
+	for _, name := range theirs {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was pruned: %v", name, err)
+		}
+	}
+}
+
+func TestInstallRefusesWhilePending(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	crashAt(t, svc, dir, false)
+
+	err := svc.Install(context.Background(), InstallOptions{})
+	if !errors.Is(err, journal.ErrPending) {
+		t.Errorf("Install err = %v, want ErrPending", err)
+	}
+}
+
+func TestInstallRefusesWhileLocked(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	l, err := lock.Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer l.Release()
+
+	err = svc.Install(context.Background(), InstallOptions{})
+	var held *lock.HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("Install err = %v, want a *lock.HeldError", err)
+	}
+	if svc.JarExists() {
+		t.Error("nothing should be installed while another process holds the lock")
+	}
+}
+
+func TestServiceSelect(t *testing.T) {
+	svc, _, _ := newServiceFixture(t)
+	info, err := svc.Select(context.Background(), "26.1.2", 0)
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if info.Version != "26.1.2" || info.Build != 70 {
+		t.Errorf("got %s build %d, want 26.1.2 build 70", info.Version, info.Build)
+	}
+	if _, err := svc.Select(context.Background(), "0.0.0", 0); !errors.Is(err, papermc.ErrUnexpectedStatus) {
+		t.Errorf("unknown version err = %v, want ErrUnexpectedStatus", err)
+	}
+}
+
+func TestServiceRollback(t *testing.T) {
+	svc, dir, payload := newServiceFixture(t)
+	ctx := context.Background()
+
+	// An earlier install of build 60 is on disk and in the history.
+	old := []byte("old jar")
+	sum := sha256.Sum256(old)
+	prev := state.State{Version: "26.1.1", Build: 60, JarName: "paper-26.1.1-60.jar", SHA256: hex.EncodeToString(sum[:])}
+	if err := svc.store.Save(state.WithInstall(state.State{}, prev)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "paper.jar"), old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Install(ctx, InstallOptions{Backup: true}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	res, err := svc.Rollback("")
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if !res.Known || res.Restored.Build != 60 {
+		t.Errorf("restored %+v (known=%v), want build 60", res.Restored, res.Known)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(got) != string(old) {
+		t.Errorf("paper.jar = %q, want the old jar back", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, rolledBackName)); string(got) != string(payload) {
+		t.Error("the replaced jar should be kept as paper.rolledback.jar")
+	}
+	st, _ := svc.Installed()
+	if st.Build != 60 || len(st.History) != 3 {
+		t.Errorf("state = build %d with %d history entries, want build 60 with 3", st.Build, len(st.History))
+	}
+
+	if _, err := svc.Rollback(""); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("second Rollback err = %v, want ErrNoBackup", err)
+	}
+}
+
+func TestServiceVerify(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	if _, err := svc.Verify(); !errors.Is(err, ErrNothingInstalled) {
+		t.Errorf("Verify before install err = %v, want ErrNothingInstalled", err)
+	}
+
+	if err := svc.Install(context.Background(), InstallOptions{}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if res, err := svc.Verify(); err != nil || !res.OK {
+		t.Errorf("Verify after install = %+v, %v; want OK", res, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "paper.jar"), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := svc.Verify()
+	if !errors.Is(err, ErrJarModified) || res.OK || res.Actual == res.Expected {
+		t.Errorf("Verify after tampering = %+v, %v; want ErrJarModified", res, err)
+	}
+}
+
+func TestServiceNotifies(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev struct{ Event, Message string }
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		got = append(got, ev.Event+": "+ev.Message)
+		mu.Unlock()
+	}))
+	t.Cleanup(hook.Close)
+	n, err := notify.New([]notify.Webhook{{Format: notify.FormatGeneric, URL: hook.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, dir, _ := newServiceFixture(t, WithNotifier(n))
+	ctx := context.Background()
+
+	if err := svc.Install(ctx, InstallOptions{}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	n.Wait(5 * time.Second)
+	// A directory where the staged jar goes makes the download fail.
+	if err := os.Mkdir(filepath.Join(dir, stagedName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Install(ctx, InstallOptions{}); err == nil {
+		t.Fatal("Install succeeded with an unwritable staging path")
+	}
+	n.Wait(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || !strings.HasPrefix(got[0], "install_succeeded: Installed Paper 26.1.2 build 70") ||
+		!strings.HasPrefix(got[1], "install_failed: ") {
+		t.Errorf("notifications = %q", got)
+	}
+}
@@ -0,0 +1,105 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
+)
+
+// smokeTestSlack is how long a smoke test may take beyond the tester's ready timeout,
+// to set up the sandbox and shut the server down again.
+const smokeTestSlack = 2 * time.Minute
+
+// ErrSmokeTestFailed means a newly installed build failed its smoke test.
+var ErrSmokeTestFailed = errors.New("paper: the new build failed its smoke test")
+
+// SmokeTestError is a failed smoke test after an install, and what became of the jar
+// it replaced.
+type SmokeTestError struct {
+	Result smoketest.Result
+	// RolledBack means the previous jar is back in place; Restored describes it.
+	RolledBack bool
+	Restored   RollbackResult
+	// RollbackErr is why the previous jar could not be restored, if there was one.
+	RollbackErr error
+	// Err is why the test did not finish, e.g. context.Canceled, if it did not.
+	Err error
+}
+
+func (e *SmokeTestError) Error() string {
+	msg := "paper: the new build failed its smoke test: " + e.Result.Reason
+	switch {
+	case e.RolledBack:
+		return msg + "; rolled back to " + describeState(e.Restored.Restored)
+	case e.RollbackErr != nil:
+		return fmt.Sprintf("%s; the previous jar could not be restored: %v", msg, e.RollbackErr)
+	}
+	return msg + "; there was no previous jar to restore"
+}
+
+func (e *SmokeTestError) Is(target error) bool { return target == ErrSmokeTestFailed }
+
+func (e *SmokeTestError) Unwrap() error { return e.Err }
+
+// WithSmokeTest has Install boot each new build with t before calling it installed,
+// rolling back to the previous jar if it does not start cleanly.
+func WithSmokeTest(t *smoketest.Tester) Option {
+	return func(s *Service) { s.smoke = t }
+}
+
+// SmokeTest reports whether Install smoke tests new builds.
+func (s *Service) SmokeTest() bool { return s.smoke != nil }
+
+// smokeTest boots the build entry e just installed and, if it fails, restores the jar
+// it replaced. The test gets a deadline of its own rather than what is left of the
+// download's, but still ends when ctx is canceled. A test that was cut short fails:
+// the build it was testing is not kept untested. One that could not be set up at all
+// is only logged, as it says nothing about the build. The caller holds the lock.
+func (s *Service) smokeTest(ctx context.Context, e *journal.Entry, opts InstallOptions, log *slog.Logger) error {
+	tctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.smoke.Timeout()+smokeTestSlack)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			cancel()
+		}
+	})
+	defer stop()
+
+	res, err := s.smoke.Run(tctx, s.dir, s.jarPath())
+	var cut error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		cut = err
+		res = smoketest.Result{Reason: "the test did not finish: " + err.Error()}
+	case err != nil:
+		log.Warn("could not smoke test the new build; keeping it", logging.Err(err))
+		res = smoketest.Result{Skipped: true, Reason: "could not run: " + err.Error()}
+	}
+	if opts.OnSmokeTest != nil {
+		opts.OnSmokeTest(res)
+	}
+	if res.Passed || res.Skipped {
+		return nil
+	}
+
+	serr := &SmokeTestError{Result: res, Err: cut}
+	if !slices.ContainsFunc(e.Steps, func(st journal.Step) bool { return st.Kind == journal.StepBackup }) {
+		log.Error("the new build failed its smoke test and there is no previous jar to restore")
+		return serr
+	}
+	log.Error("the new build failed its smoke test; rolling back", "reason", res.Reason)
+	restored, err := s.rollback(s.installBackupName(opts))
+	if err != nil {
+		serr.RollbackErr = err
+		return serr
+	}
+	serr.RolledBack, serr.Restored = true, restored
+	return serr
+}
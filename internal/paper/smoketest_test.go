@@ -0,0 +1,215 @@
+package paper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+// fakeServer writes a java launcher that plays a server logging lines and then its
+// ready line.
+func fakeServer(t *testing.T, lines ...string) *smoketest.Tester {
+	t.Helper()
+	script := ""
+	for _, l := range append(lines, `[12:00:05 INFO]: Done (4.321s)! For help, type "help"`) {
+		script += "echo '" + l + "'\n"
+	}
+	return scriptedServer(t, script+"read cmd\n")
+}
+
+// scriptedServer writes a java launcher running script.
+func scriptedServer(t *testing.T, script string) *smoketest.Tester {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake java is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "java")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return smoketest.New(smoketest.WithJava(path))
+}
+
+// withOldBuild records build 60 as installed, with its jar in place and the EULA
+// accepted.
+func withOldBuild(t *testing.T, svc *Service, dir string) []byte {
+	t.Helper()
+	old := []byte("old jar")
+	sum := sha256.Sum256(old)
+	prev := state.State{Version: "26.1.1", Build: 60, JarName: "paper-26.1.1-60.jar", SHA256: hex.EncodeToString(sum[:])}
+	if err := svc.store.Save(state.WithInstall(state.State{}, prev)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "paper.jar"), old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "eula.txt"), []byte("eula=true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return old
+}
+
+func TestInstallSmokeTestPasses(t *testing.T) {
+	svc, dir, payload := newServiceFixture(t, WithBackup(BackupNever, ""), WithSmokeTest(fakeServer(t)))
+	withOldBuild(t, svc, dir)
+
+	plan, err := svc.PlanInstall(context.Background(), InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Join(plan.Lines(), "\n"); !strings.Contains(lines, "rename paper.jar -> paper.backup.jar") ||
+		!strings.Contains(lines, "smoke test it; put paper.backup.jar back if it fails") {
+		t.Errorf("plan with a smoke test:\n%s", lines)
+	}
+
+	var got smoketest.Result
+	if err := svc.Install(context.Background(), InstallOptions{OnSmokeTest: func(r smoketest.Result) { got = r }}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if !got.Passed {
+		t.Errorf("smoke test = %+v, want a pass", got)
+	}
+	if jar, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(jar) != string(payload) {
+		t.Error("paper.jar should hold the new build")
+	}
+	// The smoke test needs something to roll back to, whatever the backup policy.
+	if _, err := os.Stat(filepath.Join(dir, DefaultBackupName)); err != nil {
+		t.Errorf("no backup with a smoke test: %v", err)
+	}
+}
+
+func TestInstallSmokeTestRollsBack(t *testing.T) {
+	tester := fakeServer(t, "[12:00:03 ERROR]: Error occurred while enabling Essentials v2.21.0 (Is it up to date?)")
+	svc, dir, _ := newServiceFixture(t, WithSmokeTest(tester))
+	old := withOldBuild(t, svc, dir)
+
+	err := svc.Install(context.Background(), InstallOptions{})
+	var serr *SmokeTestError
+	if !errors.Is(err, ErrSmokeTestFailed) || !errors.As(err, &serr) {
+		t.Fatalf("Install = %v, want a SmokeTestError", err)
+	}
+	if !serr.RolledBack || serr.Restored.Restored.Build != 60 || len(serr.Result.PluginErrors) != 1 {
+		t.Errorf("SmokeTestError = %+v", serr)
+	}
+	if !strings.Contains(err.Error(), "1 plugin(s) failed to load or enable; rolled back to 26.1.1 build 60") {
+		t.Errorf("error = %q", err)
+	}
+	if jar, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(jar) != string(old) {
+		t.Errorf("paper.jar = %q, want the old jar back", jar)
+	}
+	if st, _ := svc.Installed(); st.Build != 60 {
+		t.Errorf("installed build = %d, want 60", st.Build)
+	}
+
+	// A first install has nothing to go back to.
+	svc, dir, _ = newServiceFixture(t, WithSmokeTest(tester))
+	os.WriteFile(filepath.Join(dir, "eula.txt"), []byte("eula=true\n"), 0o644)
+	err = svc.Install(context.Background(), InstallOptions{})
+	if !errors.As(err, &serr) || serr.RolledBack || !strings.Contains(err.Error(), "no previous jar to restore") {
+		t.Errorf("first Install = %v", err)
+	}
+}
+
+func TestInstallSmokeTestSkipped(t *testing.T) {
+	tester := fakeServer(t, "[12:00:03 ERROR]: Error occurred while enabling Essentials v2.21.0")
+	svc, dir, payload := newServiceFixture(t, WithSmokeTest(tester))
+	withOldBuild(t, svc, dir)
+	os.Remove(filepath.Join(dir, "eula.txt"))
+
+	var got smoketest.Result
+	if err := svc.Install(context.Background(), InstallOptions{OnSmokeTest: func(r smoketest.Result) { got = r }}); err != nil {
+		t.Fatalf("Install without an accepted EULA: %v", err)
+	}
+	if !got.Skipped {
+		t.Errorf("smoke test = %+v, want skipped", got)
+	}
+	if jar, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(jar) != string(payload) {
+		t.Error("a skipped smoke test should keep the new build")
+	}
+}
+
+func TestInstallSmokeTestOutlivesDownloadDeadline(t *testing.T) {
+	// Ready only after the install's context has expired.
+	slow := scriptedServer(t, "sleep 1\necho '[12:00:05 INFO]: Done (1.000s)! For help, type \"help\"'\nread cmd\n")
+	svc, dir, payload := newServiceFixture(t, WithSmokeTest(slow))
+	withOldBuild(t, svc, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	var got smoketest.Result
+	if err := svc.Install(ctx, InstallOptions{OnSmokeTest: func(r smoketest.Result) { got = r }}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if !got.Passed {
+		t.Errorf("smoke test = %+v, want a pass", got)
+	}
+	if jar, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(jar) != string(payload) {
+		t.Error("paper.jar should hold the new build")
+	}
+}
+
+func TestInstallSmokeTestCanceled(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t, WithSmokeTest(scriptedServer(t, "touch started\nexec sleep 30\n")))
+	old := withOldBuild(t, svc, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Cancel once the server is booting in its sandbox.
+		for range 500 {
+			if m, _ := filepath.Glob(filepath.Join(dir, ".paper-smoke-*", "started")); len(m) > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+	err := svc.Install(ctx, InstallOptions{})
+	var serr *SmokeTestError
+	if !errors.As(err, &serr) || !errors.Is(err, context.Canceled) || serr.Result.Skipped {
+		t.Fatalf("Install = %v, want a canceled SmokeTestError", err)
+	}
+	if !serr.RolledBack {
+		t.Errorf("a build whose test was cut short was kept: %+v", serr)
+	}
+	if jar, _ := os.ReadFile(filepath.Join(dir, "paper.jar")); string(jar) != string(old) {
+		t.Errorf("paper.jar = %q, want the old jar back", jar)
+	}
+}
+
+func TestRecoverRemovesLeftoverSandboxes(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	sandbox := filepath.Join(dir, ".paper-smoke-123")
+	os.MkdirAll(filepath.Join(sandbox, "plugins"), 0o755)
+	os.WriteFile(filepath.Join(sandbox, "paper.jar"), []byte("jar"), 0o644)
+
+	// One a running test may still be using stays.
+	l, err := lock.Acquire(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sandbox); err != nil {
+		t.Error("Recover removed a sandbox while the directory was locked")
+	}
+	l.Release()
+
+	if r, err := svc.Recover(); err != nil || r.Action != RecoveryNone {
+		t.Fatalf("Recover = %+v, %v", r, err)
+	}
+	if _, err := os.Stat(sandbox); !os.IsNotExist(err) {
+		t.Error("the leftover sandbox was kept")
+	}
+}
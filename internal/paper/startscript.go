@@ -0,0 +1,72 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/startscript"
+)
+
+// StartScriptOptions are the choices behind start.sh.
+type StartScriptOptions struct {
+	HeapMB       int // 0 sizes the heap from the machine's memory
+	Restart      bool
+	RestartDelay time.Duration // 0 means startscript.DefaultRestartDelay
+}
+
+// StartScript is start.sh as PlanStartScript would write it.
+type StartScript struct {
+	startscript.Script
+	Change startscript.Change
+	// Memory is the machine's memory as read while planning; zero if it could not be.
+	Memory startscript.Memory
+	// FlagsErr is why the API's recommended flags could not be had; Aikar's are used
+	// instead.
+	FlagsErr error
+}
+
+// PlanStartScript renders start.sh for the server directory, with the configured java
+// and jar and the flags Paper recommends for the installed version, and compares it
+// with the start.sh there now. It changes nothing.
+func (s *Service) PlanStartScript(ctx context.Context, opts StartScriptOptions) (StartScript, error) {
+	sc := startscript.Script{Java: s.java, JarName: s.jarName, HeapMB: opts.HeapMB, Restart: opts.Restart, RestartDelay: opts.RestartDelay}
+	mem, err := startscript.ReadMemory(startscript.MemInfoPath)
+	switch {
+	case err == nil:
+		sc.TotalMB = mem.UsableMB()
+		if sc.HeapMB == 0 {
+			sc.HeapMB = startscript.SuggestHeap(mem.UsableMB())
+		}
+	case sc.HeapMB == 0:
+		return StartScript{}, fmt.Errorf("paper: start script: %w; give the heap size instead", err)
+	}
+
+	version, recommended, flagsErr := s.RecommendedFlags(ctx)
+	if flagsErr != nil {
+		s.log.Warn("no recommended flags from the API; using Aikar's", logging.KeyEvent, logging.EventStartScript, logging.Err(flagsErr))
+	}
+	sc.Flags, sc.FlagsFrom = startscript.ChooseFlags(version, recommended, sc.HeapMB)
+
+	change, err := startscript.Prepare(s.dir, sc.Render())
+	if err != nil {
+		return StartScript{}, err
+	}
+	return StartScript{Script: sc, Change: change, Memory: mem, FlagsErr: flagsErr}, nil
+}
+
+// WriteStartScript writes the start.sh PlanStartScript prepared. An unchanged script
+// is left alone.
+func (s *Service) WriteStartScript(p StartScript) error {
+	if !p.Change.Changed() {
+		return nil
+	}
+	log := s.log.With(logging.KeyEvent, logging.EventStartScript, "path", p.Change.Path)
+	if err := p.Change.Write(); err != nil {
+		log.Error("could not write the start script", logging.Err(err))
+		return err
+	}
+	log.Info("wrote start script", "memory", startscript.FormatSize(p.HeapMB), "flags_from", p.FlagsFrom, "restart", p.Restart)
+	return nil
+}
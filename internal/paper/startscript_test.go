@@ -0,0 +1,52 @@
+package paper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mbacalan/paper-mc-tui/internal/startscript"
+)
+
+func TestStartScript(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t, WithJarName("server.jar"), WithJava("/opt/jdk-25/bin/java", JavaOff))
+	ctx := context.Background()
+
+	p, err := svc.PlanStartScript(ctx, StartScriptOptions{HeapMB: 4 << 10, Restart: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is installed, so the flags are the latest version's from the API.
+	if p.FlagsErr != nil || strings.Join(p.Flags, " ") != "-XX:+UseG1GC -XX:+AlwaysPreTouch" || !strings.Contains(p.FlagsFrom, "26.1.2") {
+		t.Errorf("flags = %q from %q (%v)", p.Flags, p.FlagsFrom, p.FlagsErr)
+	}
+	if p.Change.Exists() || !p.Change.Changed() {
+		t.Error("there is no start.sh yet")
+	}
+	if _, err := os.Stat(filepath.Join(dir, startscript.FileName)); !os.IsNotExist(err) {
+		t.Error("PlanStartScript wrote start.sh")
+	}
+	if err := svc.WriteStartScript(p); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, startscript.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"MEMORY=4G", "\t/opt/jdk-25/bin/java -Xms$MEMORY", "-jar server.jar nogui", "while :; do"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("start.sh missing %q:\n%s", want, got)
+		}
+	}
+
+	p, err = svc.PlanStartScript(ctx, StartScriptOptions{HeapMB: 4 << 10, Restart: true})
+	if err != nil || p.Change.Changed() {
+		t.Errorf("planning the same script again: changed %v, %v", p.Change.Changed(), err)
+	}
+	p, _ = svc.PlanStartScript(ctx, StartScriptOptions{HeapMB: 6 << 10, Restart: true})
+	if d := p.Change.Diff(); !strings.Contains(d, "-MEMORY=4G\n+MEMORY=6G\n") {
+		t.Errorf("diff after resizing:\n%s", d)
+	}
+}
@@ -0,0 +1,76 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+)
+
+// Support is what the API says about the installed version's support.
+type Support struct {
+	Version string                // the installed version; "" if nothing is installed
+	Status  papermc.SupportStatus // "" if the API does not say
+}
+
+// EndOfLife reports whether Paper no longer supports the installed version.
+func (s Support) EndOfLife() bool { return s.Status == papermc.SupportUnsupported }
+
+// String describes the status for people, or returns "" for a supported version.
+func (s Support) String() string {
+	switch s.Status {
+	case papermc.SupportUnsupported:
+		return fmt.Sprintf("Paper %s is end of life: it gets no more builds, fixes or security updates. Move to a supported version.", s.Version)
+	case papermc.SupportDeprecated:
+		return fmt.Sprintf("Paper %s is deprecated: it will stop getting builds soon.", s.Version)
+	}
+	return ""
+}
+
+// Versions returns every version the API lists, newest first, with its support status
+// and the Java release it needs.
+func (s *Service) Versions(ctx context.Context) ([]papermc.VersionInfo, error) {
+	return s.client.VersionMetadata(ctx)
+}
+
+// InstalledSupport asks the API how the installed version is supported, logging a
+// warning if it is end of life. With nothing installed it returns a zero Support.
+func (s *Service) InstalledSupport(ctx context.Context) (Support, error) {
+	st, err := s.Installed()
+	if err != nil || st.Version == "" {
+		return Support{}, err
+	}
+	info, err := s.client.Version(ctx, st.Version)
+	if err != nil {
+		return Support{Version: st.Version}, err
+	}
+	sup := Support{Version: st.Version, Status: info.Support.Status}
+	if sup.EndOfLife() {
+		s.log.Warn("installed version is end of life", logging.KeyEvent, logging.EventCheck, logging.KeyVersion, st.Version)
+	}
+	return sup, nil
+}
+
+// RecommendedFlags returns the JVM flags Paper recommends for the installed version, or
+// for the latest one if nothing is installed, together with that version. The flags
+// are nil if the API gives none.
+func (s *Service) RecommendedFlags(ctx context.Context) (version string, flags []string, err error) {
+	st, err := s.Installed()
+	if err != nil {
+		return "", nil, err
+	}
+	version = st.Version
+	if version == "" {
+		latest, err := s.CheckLatest(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		version = latest.Version
+	}
+	info, err := s.client.Version(ctx, version)
+	if err != nil {
+		return version, nil, err
+	}
+	return version, info.Java.Flags.Recommended, nil
+}
@@ -0,0 +1,45 @@
+package paper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+func TestInstalledSupport(t *testing.T) {
+	svc, dir, _ := newServiceFixture(t)
+	ctx := context.Background()
+
+	if sup, err := svc.InstalledSupport(ctx); err != nil || sup != (Support{}) {
+		t.Fatalf("InstalledSupport with nothing installed = %+v, %v", sup, err)
+	}
+	// With nothing installed, the flags are the latest version's.
+	if v, flags, err := svc.RecommendedFlags(ctx); err != nil || v != "26.1.2" || strings.Join(flags, " ") != "-XX:+UseG1GC -XX:+AlwaysPreTouch" {
+		t.Errorf("RecommendedFlags = %s %q, %v", v, flags, err)
+	}
+
+	if err := svc.Install(ctx, InstallOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	sup, err := svc.InstalledSupport(ctx)
+	if err != nil || sup.Version != "26.1.2" || sup.EndOfLife() || sup.String() != "" {
+		t.Errorf("InstalledSupport after installing 26.1.2 = %+v, %v", sup, err)
+	}
+
+	store, err := state.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(state.State{Version: "1.20.4", Build: 499, JarName: "paper-1.20.4-499.jar"}); err != nil {
+		t.Fatal(err)
+	}
+	sup, err = svc.InstalledSupport(ctx)
+	if err != nil || !sup.EndOfLife() || !strings.Contains(sup.String(), "Paper 1.20.4 is end of life") {
+		t.Errorf("InstalledSupport for 1.20.4 = %+v (%q), %v", sup, sup, err)
+	}
+	if v, flags, err := svc.RecommendedFlags(ctx); err != nil || v != "1.20.4" || flags != nil {
+		t.Errorf("RecommendedFlags for 1.20.4 = %s %q, %v; want no flags", v, flags, err)
+	}
+}
@@ -0,0 +1,53 @@
+package paper
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+var (
+	// ErrNothingInstalled means there is no recorded install to verify against.
+	ErrNothingInstalled = errors.New("paper: no build has been installed by this tool")
+	// ErrJarModified means paper.jar no longer matches the checksum recorded at install.
+	ErrJarModified = errors.New("paper: paper.jar does not match the recorded checksum")
+)
+
+// VerifyResult compares paper.jar with what state.json says was installed.
+type VerifyResult struct {
+	Expected string // SHA256 recorded in state.json
+	Actual   string // SHA256 of paper.jar on disk
+	OK       bool
+}
+
+// Verify hashes paper.jar and compares it with the checksum recorded at install. It
+// returns ErrNothingInstalled if there is no record and ErrJarModified (alongside the
+// populated result) on a mismatch, e.g. after a manual update.
+func (s *Service) Verify() (VerifyResult, error) {
+	installed, err := s.store.Load()
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	if installed.Build == 0 && installed.SHA256 == "" {
+		return VerifyResult{}, ErrNothingInstalled
+	}
+
+	actual, err := download.HashFile(s.jarPath())
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	res := VerifyResult{
+		Expected: installed.SHA256,
+		Actual:   actual,
+		OK:       strings.EqualFold(actual, installed.SHA256),
+	}
+	log := s.log.With(logging.KeyEvent, logging.EventVerify, logging.KeyVersion, installed.Version, logging.KeyBuild, installed.Build)
+	if !res.OK {
+		log.Error("verification failed", "expected", res.Expected, "actual", res.Actual)
+		return res, ErrJarModified
+	}
+	log.Info("verified")
+	return res, nil
+}
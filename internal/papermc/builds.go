@@ -3,6 +3,7 @@ package papermc
 import (
 	"context"
 	"net/url"
+	"strconv"
 )
 
 // Builds returns all builds for a version, newest first (as the API orders them).
@@ -24,3 +25,13 @@ func (c *Client) LatestBuild(ctx context.Context, version string) (Build, error)
 	}
 	return b, nil
 }
+
+// Build returns one build of a version by its ID.
+func (c *Client) Build(ctx context.Context, version string, id int) (Build, error) {
+	var b Build
+	path := "/projects/paper/versions/" + url.PathEscape(version) + "/builds/" + strconv.Itoa(id)
+	if err := c.doJSON(ctx, path, &b); err != nil {
+		return Build{}, err
+	}
+	return b, nil
+}
@@ -8,8 +8,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/metrics"
 )
 
 const (
@@ -30,6 +34,8 @@ type Client struct {
 	httpClient *http.Client
 	baseURL    string
 	userAgent  string
+	log        *slog.Logger
+	metrics    *metrics.Metrics
 }
 
 // Option configures a Client.
@@ -62,12 +68,33 @@ func WithUserAgent(ua string) Option {
 	}
 }
 
+// WithLogger sets the logger that records each API request at debug level and
+// failures at warn.
+func WithLogger(l *slog.Logger) Option {
+	return func(cl *Client) {
+		if l != nil {
+			cl.log = l
+		}
+	}
+}
+
+// WithMetrics sets where request counts and latencies are recorded.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(cl *Client) {
+		if m != nil {
+			cl.metrics = m
+		}
+	}
+}
+
 // NewClient returns a Client with sensible defaults, overridden by opts.
 func NewClient(opts ...Option) *Client {
 	c := &Client{
 		httpClient: &http.Client{Timeout: defaultTimeout},
 		baseURL:    DefaultBaseURL,
 		userAgent:  DefaultUserAgent,
+		log:        logging.Discard(),
+		metrics:    metrics.Discard(),
 	}
 	for _, opt := range opts {
 		opt(c)
@@ -84,11 +111,17 @@ func (c *Client) doJSON(ctx context.Context, path string, out any) error {
 	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Accept", "application/json")
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		c.metrics.ObserveAPI(0, time.Since(start))
+		c.log.Warn("api request failed", logging.KeyEvent, logging.EventAPI, "path", path, logging.Err(err))
 		return fmt.Errorf("papermc: request %s: %w", path, err)
 	}
 	defer resp.Body.Close()
+	c.metrics.ObserveAPI(resp.StatusCode, time.Since(start))
+	c.log.Debug("api request", logging.KeyEvent, logging.EventAPI, "path", path,
+		"status", resp.StatusCode, logging.KeyDuration, time.Since(start))
 
 	if resp.StatusCode != http.StatusOK {
 		return &StatusError{StatusCode: resp.StatusCode, URL: req.URL.String()}
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -40,7 +41,10 @@ func newTestServer(t *testing.T, gotUA *string) *httptest.Server {
 	mux.HandleFunc("/projects/paper", serve("project.json"))
 	mux.HandleFunc("/projects/paper/versions/26.2-rc-2/builds/latest", serve("build_latest_beta.json"))
 	mux.HandleFunc("/projects/paper/versions/26.1.2/builds/latest", serve("build_latest_stable.json"))
+	mux.HandleFunc("/projects/paper/versions/26.1.2/builds/70", serve("build_latest_stable.json"))
 	mux.HandleFunc("/projects/paper/versions/1.21.10/builds", serve("builds_list.json"))
+	mux.HandleFunc("/projects/paper/versions/26.1.2", serve("version.json"))
+	mux.HandleFunc("/projects/paper/versions", serve("versions.json"))
 	mux.HandleFunc("/projects/paper/versions/1.21.10/builds/latest", serve("builds_list.json"))
 
 	srv := httptest.NewServer(mux)
@@ -166,3 +170,78 @@ func TestStatusError(t *testing.T) {
 		t.Errorf("expected a 404 StatusError, got %v", err)
 	}
 }
+
+func TestBuildByID(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := newTestClient(t, srv)
+
+	b, err := c.Build(context.Background(), "26.1.2", 70)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if b.ID != 70 || b.Channel != ChannelStable {
+		t.Errorf("got build %d (%s), want 70 (STABLE)", b.ID, b.Channel)
+	}
+	if _, err := c.Build(context.Background(), "26.1.2", 69); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Errorf("unknown build err = %v, want ErrUnexpectedStatus", err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := newTestClient(t, srv)
+
+	v, err := c.Version(context.Background(), "26.1.2")
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if v.ID != "26.1.2" || v.Java.Version.Minimum != 25 {
+		t.Errorf("got %+v, want 26.1.2 needing Java 25", v)
+	}
+	if v.Support.Status != SupportSupported || v.EndOfLife() || len(v.Java.Flags.Recommended) != 2 {
+		t.Errorf("got %+v, want a supported version with recommended flags", v)
+	}
+}
+
+func TestVersionMetadata(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := newTestClient(t, srv)
+
+	vs, err := c.VersionMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("VersionMetadata: %v", err)
+	}
+	var got []string
+	for _, v := range vs {
+		got = append(got, v.ID+" "+string(v.Support.Status))
+	}
+	want := []string{"26.1.2 SUPPORTED", "1.21.10 DEPRECATED", "1.20.4 UNSUPPORTED"}
+	if !slices.Equal(got, want) {
+		t.Errorf("VersionMetadata = %q, want %q (newest first)", got, want)
+	}
+	if !vs[2].EndOfLife() || vs[1].EndOfLife() {
+		t.Error("only UNSUPPORTED should be end of life")
+	}
+	if vs[1].Support.Status.Note() != "deprecated" || vs[0].Support.Status.Note() != "" {
+		t.Errorf("notes = %q, %q", vs[1].Support.Status.Note(), vs[0].Support.Status.Note())
+	}
+}
+
+func TestResolveWithin(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := newTestClient(t, srv)
+
+	// Unconstrained, experimental resolves to the 26.2 pre-release (see
+	// TestResolveExperimental); pinned to 26.1 it must skip it.
+	rel, err := c.ResolveWithin(context.Background(), "26.1", ChannelStable, ChannelBeta, ChannelAlpha)
+	if err != nil {
+		t.Fatalf("ResolveWithin: %v", err)
+	}
+	if rel.Version != "26.1.2" {
+		t.Errorf("version = %q, want 26.1.2", rel.Version)
+	}
+
+	if _, err := c.ResolveWithin(context.Background(), "25", ChannelStable); !errors.Is(err, ErrNoStableBuild) {
+		t.Errorf("unmatched constraint err = %v, want ErrNoStableBuild", err)
+	}
+}
@@ -67,3 +67,68 @@ type Release struct {
 	Build    Build
 	Download Download
 }
+
+// VersionResponse is the body of GET /v3/projects/{project}/versions/{version}.
+type VersionResponse struct {
+	Version VersionInfo `json:"version"`
+	Builds  []int       `json:"builds"` // build IDs, newest first
+}
+
+// VersionsResponse is the body of GET /v3/projects/{project}/versions.
+type VersionsResponse struct {
+	Versions []VersionResponse `json:"versions"`
+}
+
+// VersionInfo is a version's metadata.
+type VersionInfo struct {
+	ID      string   `json:"id"`
+	Support Support  `json:"support"`
+	Java    JavaInfo `json:"java"`
+}
+
+// EndOfLife reports whether Paper no longer supports the version: it gets no more builds
+// or fixes.
+func (v VersionInfo) EndOfLife() bool { return v.Support.Status == SupportUnsupported }
+
+// SupportStatus is how Paper supports a version, as reported by Fill v3.
+type SupportStatus string
+
+const (
+	SupportSupported   SupportStatus = "SUPPORTED"
+	SupportDeprecated  SupportStatus = "DEPRECATED"  // still built, but due to be dropped
+	SupportUnsupported SupportStatus = "UNSUPPORTED" // end of life
+)
+
+// Note is a short label for lists: "deprecated", "end of life", or "" for a supported
+// version or a status the API did not give.
+func (s SupportStatus) Note() string {
+	switch s {
+	case SupportDeprecated:
+		return "deprecated"
+	case SupportUnsupported:
+		return "end of life"
+	}
+	return ""
+}
+
+// Support is a version's support status.
+type Support struct {
+	Status SupportStatus `json:"status"`
+}
+
+// JavaInfo is the Java runtime a version needs.
+type JavaInfo struct {
+	Version JavaVersion `json:"version"`
+	Flags   JavaFlags   `json:"flags"`
+}
+
+// JavaVersion bounds the Java feature release (e.g. 21) a version runs on. Minimum is 0
+// when the API does not say.
+type JavaVersion struct {
+	Minimum int `json:"minimum"`
+}
+
+// JavaFlags are JVM arguments Paper suggests for running a version.
+type JavaFlags struct {
+	Recommended []string `json:"recommended"`
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
 	"strings"
@@ -24,6 +25,29 @@ func (c *Client) Versions(ctx context.Context) (map[string][]string, error) {
 	return pr.Versions, nil
 }
 
+// Version returns a version's metadata, such as the Java release it needs.
+func (c *Client) Version(ctx context.Context, version string) (VersionInfo, error) {
+	var vr VersionResponse
+	if err := c.doJSON(ctx, "/projects/paper/versions/"+url.PathEscape(version), &vr); err != nil {
+		return VersionInfo{}, err
+	}
+	return vr.Version, nil
+}
+
+// VersionMetadata returns the metadata of every version, newest first.
+func (c *Client) VersionMetadata(ctx context.Context) ([]VersionInfo, error) {
+	var vr VersionsResponse
+	if err := c.doJSON(ctx, "/projects/paper/versions", &vr); err != nil {
+		return nil, err
+	}
+	out := make([]VersionInfo, 0, len(vr.Versions))
+	for _, v := range vr.Versions {
+		out = append(out, v.Version)
+	}
+	slices.SortStableFunc(out, func(a, b VersionInfo) int { return compareVersions(b.ID, a.ID) })
+	return out, nil
+}
+
 // Resolve finds the newest version whose latest build is in one of the allowed
 // channels and returns it together with that build and its server jar download.
 // If no channels are given it defaults to STABLE.
@@ -32,6 +56,12 @@ func (c *Client) Versions(ctx context.Context) (map[string][]string, error) {
 // only STABLE is allowed, pre-release versions (those with a "-rc"/"-pre" suffix) are
 // skipped without a request, since they are never stable.
 func (c *Client) Resolve(ctx context.Context, allowed ...Channel) (Release, error) {
+	return c.ResolveWithin(ctx, "", allowed...)
+}
+
+// ResolveWithin is Resolve restricted to versions the constraint allows. Versions
+// outside it are skipped without a request.
+func (c *Client) ResolveWithin(ctx context.Context, within Constraint, allowed ...Channel) (Release, error) {
 	if len(allowed) == 0 {
 		allowed = []Channel{ChannelStable}
 	}
@@ -44,7 +74,7 @@ func (c *Client) Resolve(ctx context.Context, allowed ...Channel) (Release, erro
 
 	probes := 0
 	for _, version := range sortedVersions(grouped) {
-		if stableOnly && isPrerelease(version) {
+		if (stableOnly && isPrerelease(version)) || !within.Allows(version) {
 			continue
 		}
 		if probes >= maxProbes {
@@ -76,6 +106,22 @@ func (c *Client) Resolve(ctx context.Context, allowed ...Channel) (Release, erro
 	return Release{}, ErrNoStableBuild
 }
 
+// Constraint restricts which versions Resolve considers. It is a version prefix matched
+// on dot boundaries: "1.21" allows 1.21, 1.21.10 and 1.21.11-pre5 but not 1.2 or
+// 1.210. A trailing ".x" or ".*" is accepted and ignored. The empty Constraint allows
+// everything.
+type Constraint string
+
+// Allows reports whether version satisfies the constraint.
+func (c Constraint) Allows(version string) bool {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(string(c), ".x"), ".*")
+	if prefix == "" {
+		return true
+	}
+	release, _, _ := strings.Cut(version, "-")
+	return release == prefix || strings.HasPrefix(release, prefix+".")
+}
+
 // isPrerelease reports whether a version string is a release candidate or pre-release
 // (e.g. "26.2-rc-2", "1.21.11-pre5"). Stable Paper versions never contain "-".
 func isPrerelease(version string) bool {
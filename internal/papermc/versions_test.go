@@ -57,3 +57,27 @@ func TestIsPrerelease(t *testing.T) {
 		}
 	}
 }
+
+func TestConstraintAllows(t *testing.T) {
+	cases := []struct {
+		c       Constraint
+		version string
+		want    bool
+	}{
+		{"", "26.1.2", true},
+		{"1.21", "1.21", true},
+		{"1.21", "1.21.10", true},
+		{"1.21.x", "1.21.11-pre5", true},
+		{"1.21.*", "1.21.4", true},
+		{"1.21", "1.210", false},
+		{"1.2", "1.21.10", false},
+		{"1.21.10", "1.21.10-rc1", true},
+		{"1.21.10", "1.21.1", false},
+		{"26", "26.1.2", true},
+	}
+	for _, tc := range cases {
+		if got := tc.c.Allows(tc.version); got != tc.want {
+			t.Errorf("Constraint(%q).Allows(%q) = %v, want %v", tc.c, tc.version, got, tc.want)
+		}
+	}
+}
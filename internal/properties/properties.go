@@ -0,0 +1,238 @@
+// Package properties reads the Java .properties files a Minecraft server keeps its
+// settings in, such as server.properties and eula.txt.
+//
+// It follows java.util.Properties: "key=value", "key: value" or "key value" lines,
+// comments starting with # or !, backslash escapes (including \uXXXX) and lines
+// continued with a trailing backslash.
+package properties
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Properties maps keys to values.
+type Properties map[string]string
+
+// Get returns the value of key, or def if it is absent.
+func (p Properties) Get(key, def string) string {
+	if v, ok := p[key]; ok {
+		return v
+	}
+	return def
+}
+
+// Load reads the properties file at path.
+func Load(path string) (Properties, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("properties: %w", err)
+	}
+	defer f.Close()
+	p, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("properties: %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Parse reads properties from r.
+func Parse(r io.Reader) (Properties, error) {
+	p := Properties{}
+	sc := bufio.NewScanner(r)
+	var logical strings.Builder
+	for sc.Scan() {
+		line := strings.TrimLeft(sc.Text(), " \t\f")
+		if logical.Len() == 0 && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		if continues(line) {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+		k, v, err := split(logical.String())
+		logical.Reset()
+		if err != nil {
+			return nil, err
+		}
+		p[k] = v
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if logical.Len() > 0 {
+		k, v, err := split(logical.String())
+		if err != nil {
+			return nil, err
+		}
+		p[k] = v
+	}
+	return p, nil
+}
+
+// continues reports whether line ends in an unescaped backslash.
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// split separates a logical line into its unescaped key and value. The key ends at the
+// first unescaped '=', ':' or whitespace; the separator and whitespace around it are
+// dropped.
+func split(line string) (key, value string, err error) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+	rest := strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	if key, err = unescape(line[:end]); err != nil {
+		return "", "", err
+	}
+	if value, err = unescape(rest); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+func unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u escape in %q", s)
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// Update returns the properties file data with the keys in values set to their new
+// values. Lines setting them are replaced in place; keys the file does not have are
+// appended, in sorted order. Comments and every other line are kept as they are.
+func Update(data []byte, values Properties) []byte {
+	var (
+		out     strings.Builder
+		pending []string // the physical lines of the logical line being read
+		done    = map[string]bool{}
+	)
+	lines := strings.Split(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	flush := func() {
+		logical := strings.TrimLeft(pending[0], " \t\f")
+		for _, l := range pending[1:] {
+			logical = strings.TrimSuffix(logical, "\\") + strings.TrimLeft(l, " \t\f")
+		}
+		if k, _, err := split(strings.TrimSuffix(logical, "\r")); err == nil {
+			if v, ok := values[k]; ok && !done[k] {
+				done[k] = true
+				out.WriteString(escape(k, true) + "=" + escape(v, false) + "\n")
+				pending = pending[:0]
+				return
+			}
+		}
+		for _, l := range pending {
+			out.WriteString(l + "\n")
+		}
+		pending = pending[:0]
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t\f")
+		if len(pending) == 0 && (strings.TrimSpace(trimmed) == "" || trimmed[0] == '#' || trimmed[0] == '!') {
+			out.WriteString(line + "\n")
+			continue
+		}
+		pending = append(pending, line)
+		if !continues(strings.TrimSuffix(line, "\r")) {
+			flush()
+		}
+	}
+	if len(pending) > 0 {
+		flush()
+	}
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		if !done[k] {
+			out.WriteString(escape(k, true) + "=" + escape(values[k], false) + "\n")
+		}
+	}
+	return []byte(out.String())
+}
+
+// escape makes s safe to write as a key or a value.
+func escape(s string, key bool) string {
+	var b strings.Builder
+	for i, c := range s {
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			if key {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(c)
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package properties
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	in := `#Minecraft server properties
+#Sat Oct 17 12:00:00 UTC 2026
+! another comment
+enable-rcon=true
+rcon.port = 25575
+rcon.password:s3cr\=t
+server-ip=
+motd=A §aPaper \
+    server
+key\ with\ spaces value
+    indented=yes
+trailing=\\
+`
+	p, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Properties{
+		"enable-rcon":     "true",
+		"rcon.port":       "25575",
+		"rcon.password":   "s3cr=t",
+		"server-ip":       "",
+		"motd":            "A §aPaper server",
+		"key with spaces": "value",
+		"indented":        "yes",
+		"trailing":        `\`,
+	}
+	if !maps.Equal(p, want) {
+		t.Errorf("Parse =\n%q\nwant\n%q", p, want)
+	}
+	if got := p.Get("missing", "def"); got != "def" {
+		t.Errorf("Get(missing) = %q", got)
+	}
+	if got := p.Get("server-ip", "def"); got != "" {
+		t.Errorf("Get(server-ip) = %q, want the empty value", got)
+	}
+
+	if _, err := Parse(strings.NewReader(`bad=\u12`)); err == nil {
+		t.Error("Parse accepted a malformed \\u escape")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "server.properties")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load(missing) = %v, want ErrNotExist", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	in := "#Minecraft server properties\nserver-port=25565\nmotd=A Paper \\\n    server\nlevel-name=world\n\nenable-rcon=true\n"
+	got := string(Update([]byte(in), Properties{
+		"server-port": "40123",
+		"motd":        " Smoke test",
+		"enable-rcon": "false",
+		"server-ip":   "127.0.0.1",
+	}))
+	want := "#Minecraft server properties\nserver-port=40123\nmotd=\\ Smoke test\nlevel-name=world\n\nenable-rcon=false\nserver-ip=127.0.0.1\n"
+	if got != want {
+		t.Errorf("Update =\n%s\nwant\n%s", got, want)
+	}
+	p, err := Parse(strings.NewReader(got))
+	if err != nil || p["motd"] != " Smoke test" || p["level-name"] != "world" {
+		t.Errorf("Update's output parses as %q, %v", p, err)
+	}
+	if got := string(Update(nil, Properties{"a key": `C:\x`})); got != "a\\ key=C:\\\\x\n" {
+		t.Errorf("Update of an empty file = %q", got)
+	}
+}
@@ -0,0 +1,285 @@
+// Package rcon is a client for the Source RCON protocol, which Minecraft servers speak
+// when enable-rcon is set. It lets the tool run console commands on a server it did not
+// start itself, e.g. one under systemd or in a screen session.
+//
+// Each packet is a little-endian int32 length, then an int32 request ID, an int32 type
+// and a NUL-terminated body, followed by one more NUL. A client authenticates with the
+// password, then sends commands and reads their responses.
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/properties"
+)
+
+// Packet types.
+const (
+	typeResponse     int32 = 0 // SERVERDATA_RESPONSE_VALUE
+	typeExecCommand  int32 = 2 // SERVERDATA_EXECCOMMAND
+	typeAuthResponse int32 = 2 // SERVERDATA_AUTH_RESPONSE
+	typeAuth         int32 = 3 // SERVERDATA_AUTH
+)
+
+const (
+	// DefaultPort is the port Minecraft listens on for RCON unless rcon.port says
+	// otherwise.
+	DefaultPort = 25575
+	// DefaultTimeout bounds each round-trip unless the context sets a deadline.
+	DefaultTimeout = 10 * time.Second
+	// MaxCommandLength is the longest command a Minecraft server accepts over RCON.
+	MaxCommandLength = 1446
+	// maxFragment is how many UTF-16 code units of a response Minecraft sends per
+	// packet; a shorter packet is the last one.
+	maxFragment = 4096
+	// maxPacketSize bounds what the client will read: a full fragment takes up to
+	// three bytes per code unit in UTF-8.
+	maxPacketSize = 3*maxFragment + 10
+	// PropertiesFile is where Minecraft keeps the RCON settings.
+	PropertiesFile = "server.properties"
+)
+
+var (
+	// ErrAuth means the server rejected the password.
+	ErrAuth = errors.New("rcon: authentication failed (check rcon.password)")
+	// ErrDisabled means server.properties does not enable RCON.
+	ErrDisabled = errors.New("rcon: RCON is disabled (set enable-rcon=true in server.properties)")
+	// ErrNoPassword means RCON is enabled without a password, which Minecraft treats as
+	// disabled.
+	ErrNoPassword = errors.New("rcon: rcon.password is empty in server.properties")
+	// ErrCommandTooLong means a command exceeds MaxCommandLength.
+	ErrCommandTooLong = fmt.Errorf("rcon: command longer than %d bytes", MaxCommandLength)
+)
+
+// Config is where and how to reach a server's RCON listener.
+type Config struct {
+	Addr     string // host:port
+	Password string
+}
+
+// FromDir reads the RCON settings from server.properties in the server directory dir.
+// A server-ip of "" or a wildcard address means the server listens everywhere, so the
+// client connects over loopback.
+func FromDir(dir string) (Config, error) {
+	p, err := properties.Load(filepath.Join(dir, PropertiesFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Config{}, fmt.Errorf("%w: there is no %s in %s", ErrDisabled, PropertiesFile, dir)
+		}
+		return Config{}, fmt.Errorf("rcon: %w", err)
+	}
+	if enabled, _ := strconv.ParseBool(p.Get("enable-rcon", "false")); !enabled {
+		return Config{}, ErrDisabled
+	}
+	password := p.Get("rcon.password", "")
+	if password == "" {
+		return Config{}, ErrNoPassword
+	}
+	port := p.Get("rcon.port", strconv.Itoa(DefaultPort))
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return Config{}, fmt.Errorf("rcon: invalid rcon.port %q in %s", port, PropertiesFile)
+	}
+	host := p.Get("server-ip", "")
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return Config{Addr: net.JoinHostPort(host, port), Password: password}, nil
+}
+
+// Client is an authenticated RCON connection. It is safe for concurrent use; commands
+// run one at a time.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	nextID int32
+}
+
+// Dial connects to cfg.Addr and authenticates. It returns ErrAuth if the password is
+// wrong.
+func Dial(ctx context.Context, cfg Config) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("rcon: %w", err)
+	}
+	c := &Client{conn: conn, nextID: 1}
+	if err := c.auth(ctx, cfg.Password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error { return c.conn.Close() }
+
+func (c *Client) auth(ctx context.Context, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.deadline(ctx)()
+	id := c.id()
+	if err := c.write(id, typeAuth, password); err != nil {
+		return err
+	}
+	// Source servers send an empty response value before the auth response; Minecraft
+	// sends only the latter.
+	for {
+		p, err := c.read()
+		if err != nil {
+			return err
+		}
+		if p.typ != typeAuthResponse {
+			continue
+		}
+		if p.id == -1 {
+			return ErrAuth
+		}
+		if p.id != id {
+			return fmt.Errorf("rcon: auth response for request %d, want %d", p.id, id)
+		}
+		return nil
+	}
+}
+
+// Exec runs a console command and returns its output, e.g. Exec(ctx, "list").
+func (c *Client) Exec(ctx context.Context, command string) (string, error) {
+	if len(command) > MaxCommandLength {
+		return "", ErrCommandTooLong
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.deadline(ctx)()
+
+	// A long response arrives split into full packets with no end marker, so a short
+	// first packet is the whole of it. After a full one, a request the server answers
+	// on its own (an empty response value, which Minecraft calls an unknown request)
+	// marks where the output ends. It is only sent once the first packet is in:
+	// Minecraft handles one packet per read from the socket and drops the rest, so a
+	// fence sent along with the command would never be answered.
+	id, fence := c.id(), int32(0)
+	if err := c.write(id, typeExecCommand, command); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for {
+		p, err := c.read()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case p.id == -1:
+			return "", ErrAuth
+		case p.id == id:
+			out.WriteString(p.body)
+			if fence != 0 {
+				continue
+			}
+			if utf16Len(p.body) < maxFragment {
+				return out.String(), nil
+			}
+			fence = c.id()
+			if err := c.write(fence, typeResponse, ""); err != nil {
+				return "", err
+			}
+		case fence != 0 && p.id == fence:
+			return out.String(), nil
+		}
+	}
+}
+
+// utf16Len is the length of s in UTF-16 code units, in which Minecraft splits responses.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
+
+// id returns a fresh request ID.
+func (c *Client) id() int32 {
+	id := c.nextID
+	c.nextID++
+	if c.nextID <= 0 {
+		c.nextID = 1
+	}
+	return id
+}
+
+// deadline applies ctx's deadline, or DefaultTimeout, to the connection and returns a
+// function that clears it.
+func (c *Client) deadline(ctx context.Context) func() {
+	d, ok := ctx.Deadline()
+	if !ok {
+		d = time.Now().Add(DefaultTimeout)
+	}
+	c.conn.SetDeadline(d)
+	stop := context.AfterFunc(ctx, func() { c.conn.SetDeadline(time.Now()) })
+	return func() {
+		stop()
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+type packet struct {
+	id   int32
+	typ  int32
+	body string
+}
+
+func (c *Client) write(id, typ int32, body string) error {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(&b, binary.LittleEndian, id)
+	binary.Write(&b, binary.LittleEndian, typ)
+	b.WriteString(body)
+	b.Write([]byte{0, 0})
+	if _, err := c.conn.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("rcon: send: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) read() (packet, error) {
+	var size int32
+	if err := binary.Read(c.conn, binary.LittleEndian, &size); err != nil {
+		return packet{}, fmt.Errorf("rcon: receive: %w", err)
+	}
+	if size < 10 || size > maxPacketSize {
+		return packet{}, fmt.Errorf("rcon: receive: invalid packet size %d", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		return packet{}, fmt.Errorf("rcon: receive: %w", err)
+	}
+	return packet{
+		id:   int32(binary.LittleEndian.Uint32(buf[0:4])),
+		typ:  int32(binary.LittleEndian.Uint32(buf[4:8])),
+		body: string(bytes.TrimRight(buf[8:], "\x00")),
+	}, nil
+}
+
+// Run connects with cfg, runs one command and disconnects.
+func Run(ctx context.Context, cfg Config, command string) (string, error) {
+	c, err := Dial(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	return c.Exec(ctx, command)
+}
@@ -0,0 +1,264 @@
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer is an RCON listener that behaves like Minecraft's: it answers auth with
+// the request ID or -1, runs commands through handle and splits long responses into
+// 4096-byte packets. Like Minecraft, it handles one packet per read from the socket;
+// it drops the connection when a read holds more, where Minecraft would lose them.
+type fakeServer struct {
+	ln       net.Listener
+	password string
+	handle   func(cmd string) string
+}
+
+func newFakeServer(t *testing.T, password string, handle func(string) string) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, password: password, handle: handle}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) config(password string) Config {
+	return Config{Addr: s.ln.Addr().String(), Password: password}
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *fakeServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	authed := false
+	read := make([]byte, 1460)
+	for {
+		// Let whatever the client sends in one go arrive before reading.
+		time.Sleep(5 * time.Millisecond)
+		n, err := conn.Read(read)
+		if err != nil || n < 14 {
+			return
+		}
+		size := int32(binary.LittleEndian.Uint32(read[0:4]))
+		if int(size)+4 != n {
+			return
+		}
+		buf := read[4:n]
+		id := int32(binary.LittleEndian.Uint32(buf[0:4]))
+		typ := int32(binary.LittleEndian.Uint32(buf[4:8]))
+		body := string(bytes.TrimRight(buf[8:], "\x00"))
+
+		switch {
+		case typ == typeAuth:
+			authed = body == s.password
+			if !authed {
+				id = -1
+			}
+			writePacket(conn, id, typeAuthResponse, "")
+		case !authed:
+			writePacket(conn, -1, typeAuthResponse, "")
+		case typ == typeExecCommand:
+			out := s.handle(body)
+			for {
+				n := min(len(out), 4096)
+				writePacket(conn, id, typeResponse, out[:n])
+				if out = out[n:]; out == "" {
+					break
+				}
+			}
+		default:
+			// Minecraft answers requests of other types like this.
+			writePacket(conn, id, typeResponse, "Unknown request 0")
+		}
+	}
+}
+
+func writePacket(w io.Writer, id, typ int32, body string) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, int32(len(body)+10))
+	binary.Write(&b, binary.LittleEndian, id)
+	binary.Write(&b, binary.LittleEndian, typ)
+	b.WriteString(body)
+	b.Write([]byte{0, 0})
+	w.Write(b.Bytes())
+}
+
+func echo(cmd string) string {
+	if cmd == "list" {
+		return "There are 2 of a max of 20 players online: alex, steve"
+	}
+	return "Unknown command: " + cmd
+}
+
+func TestExec(t *testing.T) {
+	s := newFakeServer(t, "hunter2", echo)
+	ctx := context.Background()
+	c, err := Dial(ctx, s.config("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for range 2 {
+		out, err := c.Exec(ctx, "list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out, "There are 2") {
+			t.Errorf("Exec(list) = %q", out)
+		}
+	}
+	if _, err := c.Exec(ctx, strings.Repeat("x", MaxCommandLength+1)); !errors.Is(err, ErrCommandTooLong) {
+		t.Errorf("Exec(long) = %v, want ErrCommandTooLong", err)
+	}
+}
+
+func TestExecMultiPacket(t *testing.T) {
+	long := strings.Repeat("0123456789", 1000)
+	s := newFakeServer(t, "pw", func(string) string { return long })
+	out, err := Run(context.Background(), s.config("pw"), "help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != long {
+		t.Errorf("Run returned %d bytes, want %d", len(out), len(long))
+	}
+}
+
+func TestExecOnePacketPerRead(t *testing.T) {
+	s := newFakeServer(t, "pw", echo)
+	// The fake drops a connection that sends two packets at once, as Minecraft would
+	// lose the second.
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	writePacket(&b, 1, typeAuth, "pw")
+	writePacket(&b, 2, typeExecCommand, "list")
+	conn.Write(b.Bytes())
+	if got, _ := io.ReadAll(conn); len(got) > 0 {
+		t.Errorf("the fake answered two packets sent at once: %q", got)
+	}
+	conn.Close()
+
+	// Exec's fence after a full packet arrives on its own.
+	long := strings.Repeat("x", 4096)
+	s = newFakeServer(t, "pw", func(string) string { return long })
+	out, err := Run(context.Background(), s.config("pw"), "exact")
+	if err != nil || out != long {
+		t.Errorf("Run(exact) = %d bytes, %v; want %d", len(out), err, len(long))
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	s := newFakeServer(t, "right", echo)
+	if _, err := Dial(context.Background(), s.config("wrong")); !errors.Is(err, ErrAuth) {
+		t.Errorf("Dial = %v, want ErrAuth", err)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	// A listener that accepts but never answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := Dial(ctx, Config{Addr: ln.Addr().String(), Password: "pw"})
+		errc <- err
+	}()
+	cancel()
+	if err := <-errc; err == nil {
+		t.Error("Dial succeeded against a silent server")
+	}
+}
+
+func TestFromDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		props string
+		want  Config
+		err   error
+	}{
+		{
+			name:  "defaults",
+			props: "enable-rcon=true\nrcon.password=pw\nserver-ip=\n",
+			want:  Config{Addr: "127.0.0.1:25575", Password: "pw"},
+		},
+		{
+			name:  "explicit",
+			props: "enable-rcon=true\nrcon.port=25580\nrcon.password=pw\nserver-ip=10.0.0.5\n",
+			want:  Config{Addr: "10.0.0.5:25580", Password: "pw"},
+		},
+		{
+			name:  "wildcard",
+			props: "enable-rcon=true\nrcon.password=pw\nserver-ip=0.0.0.0\n",
+			want:  Config{Addr: "127.0.0.1:25575", Password: "pw"},
+		},
+		{name: "disabled", props: "enable-rcon=false\nrcon.password=pw\n", err: ErrDisabled},
+		{name: "no password", props: "enable-rcon=true\nrcon.password=\n", err: ErrNoPassword},
+		{name: "missing file", err: ErrDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.props != "" {
+				if err := os.WriteFile(filepath.Join(dir, PropertiesFile), []byte(tt.props), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := FromDir(dir)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("FromDir = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("FromDir = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, PropertiesFile), []byte("enable-rcon=true\nrcon.password=pw\nrcon.port=http\n"), 0o644)
+	if _, err := FromDir(dir); err == nil {
+		t.Error("FromDir accepted a non-numeric rcon.port")
+	}
+}
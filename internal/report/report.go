@@ -0,0 +1,630 @@
+// Package report defines the machine-readable documents the CLI prints with
+// --output json. They are a public contract for dashboards and CI: fields are only ever
+// added, and a breaking change bumps SchemaVersion. The types here deliberately mirror,
+// rather than embed, the internal ones so refactoring those cannot change the output.
+package report
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/config"
+	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/java"
+	"github.com/mbacalan/paper-mc-tui/internal/journal"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/nagios"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/rcon"
+	"github.com/mbacalan/paper-mc-tui/internal/safeupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/selfupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
+	"github.com/mbacalan/paper-mc-tui/internal/startscript"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+	"github.com/mbacalan/paper-mc-tui/internal/systemd"
+)
+
+// SchemaVersion is the version of every document this build emits.
+const SchemaVersion = 1
+
+// Document is the single JSON object a command prints. Only the sections relevant to the
+// command are present.
+type Document struct {
+	Schema   int    `json:"schema"`
+	Command  string `json:"command"`
+	OK       bool   `json:"ok"`
+	ExitCode int    `json:"exit_code"`
+
+	Latest    *Latest    `json:"latest,omitempty"`
+	Installed *Installed `json:"installed,omitempty"`
+	Install   *Install   `json:"install,omitempty"`
+	Backup    *Backup    `json:"backup,omitempty"`
+	Rollback  *Rollback  `json:"rollback,omitempty"`
+	Verify    *Verify    `json:"verify,omitempty"`
+	Recovery  *Recovery  `json:"recovery,omitempty"`
+	History   []Record   `json:"history,omitempty"`
+	Config    []Setting  `json:"config,omitempty"`
+	Self      *Self      `json:"self,omitempty"`
+	Plan      *Plan      `json:"plan,omitempty"`
+	Nagios    *Nagios    `json:"nagios,omitempty"`
+	RCON      *RCON      `json:"rcon,omitempty"`
+	Safe      *Safe      `json:"safe_update,omitempty"`
+	Java      *Java      `json:"java,omitempty"`
+	Versions  []Version  `json:"versions,omitempty"`
+	Start     *Start     `json:"start_script,omitempty"`
+	Unit      *Unit      `json:"systemd_unit,omitempty"`
+	EULA      *EULA      `json:"eula,omitempty"`
+	Commands  []Command  `json:"commands,omitempty"`
+	Error     *Error     `json:"error,omitempty"`
+}
+
+// New returns an empty document for a command, stamped with the schema version.
+func New(command string) *Document {
+	return &Document{Schema: SchemaVersion, Command: command}
+}
+
+// Latest is paper.LatestInfo.
+type Latest struct {
+	Version  string `json:"version"`
+	Build    int    `json:"build"`
+	Channel  string `json:"channel"`
+	JarName  string `json:"jar_name"`
+	URL      string `json:"url"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	UpToDate bool   `json:"up_to_date"`
+	Behind   int    `json:"builds_behind"` // builds between the installed jar and this one
+}
+
+// FromLatest converts a paper.LatestInfo.
+func FromLatest(i paper.LatestInfo) *Latest {
+	return &Latest{
+		Version:  i.Version,
+		Build:    i.Build,
+		Channel:  string(i.Channel),
+		JarName:  i.JarName,
+		URL:      i.Download.URL,
+		Size:     i.Download.Size,
+		SHA256:   i.Download.Checksums.SHA256,
+		UpToDate: i.UpToDate,
+		Behind:   i.Behind,
+	}
+}
+
+// Installed is state.State plus facts about the directory. Build is 0 when nothing is
+// recorded.
+type Installed struct {
+	Version     string     `json:"version"`
+	Build       int        `json:"build"`
+	JarName     string     `json:"jar_name"`
+	SHA256      string     `json:"sha256"`
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+	JarPresent  bool       `json:"jar_present"`
+	LockedBy    *Holder    `json:"locked_by,omitempty"`
+	Support     string     `json:"support,omitempty"` // the version's support status, if the API said
+	EndOfLife   bool       `json:"end_of_life,omitempty"`
+	LogError    string     `json:"log_error,omitempty"` // why the activity log cannot be written, if it cannot
+}
+
+// Holder is lock.Holder.
+type Holder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	Command   string    `json:"command"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// FromState converts a state.State; the caller fills in the directory facts.
+func FromState(st state.State) *Installed {
+	in := &Installed{Version: st.Version, Build: st.Build, JarName: st.JarName, SHA256: st.SHA256}
+	if !st.InstalledAt.IsZero() {
+		t := st.InstalledAt
+		in.InstalledAt = &t
+	}
+	return in
+}
+
+// FromHolder converts a lock.Holder.
+func FromHolder(h lock.Holder) *Holder {
+	return &Holder{PID: h.PID, Host: h.Host, Command: h.Command, StartedAt: h.StartedAt}
+}
+
+// Record is a state.Record: one past install.
+type Record struct {
+	Version     string    `json:"version"`
+	Build       int       `json:"build"`
+	JarName     string    `json:"jar_name"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// FromHistory converts state.State.History, newest first.
+func FromHistory(h []state.Record) []Record {
+	out := make([]Record, 0, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		r := h[i]
+		out = append(out, Record{Version: r.Version, Build: r.Build, JarName: r.JarName, SHA256: r.SHA256, InstalledAt: r.InstalledAt})
+	}
+	return out
+}
+
+// Install is the outcome of an install command.
+type Install struct {
+	Performed bool   `json:"performed"` // false if already up to date
+	Version   string `json:"version"`
+	Build     int    `json:"build"`
+	JarName   string `json:"jar_name"`
+	SHA256    string `json:"sha256"`
+	Backup    string `json:"backup,omitempty"`
+	// SmokeTest is set when the new build was booted in a sandbox after installing.
+	SmokeTest *SmokeTest `json:"smoke_test,omitempty"`
+}
+
+// SmokeTest is smoketest.Result, and the rollback a failed test caused.
+type SmokeTest struct {
+	Passed       bool          `json:"passed"`
+	Skipped      bool          `json:"skipped"`
+	Ready        bool          `json:"ready"`
+	ReadySeconds float64       `json:"ready_seconds,omitempty"`
+	Reason       string        `json:"reason,omitempty"` // why it failed or was skipped
+	PluginErrors []PluginError `json:"plugin_errors,omitempty"`
+	Excerpt      []string      `json:"excerpt,omitempty"`
+	RolledBack   bool          `json:"rolled_back"`
+	Restored     *Rollback     `json:"restored,omitempty"` // set when rolled back
+}
+
+// PluginError is a plugin that failed to load or enable in a smoke test.
+type PluginError struct {
+	Plugin string `json:"plugin"`
+	Line   string `json:"line"`
+}
+
+// FromSmokeTest converts a smoketest.Result.
+func FromSmokeTest(r smoketest.Result) *SmokeTest {
+	out := &SmokeTest{
+		Passed:       r.Passed,
+		Skipped:      r.Skipped,
+		Ready:        r.Ready,
+		ReadySeconds: r.Startup.Seconds(),
+		Reason:       r.Reason,
+		Excerpt:      r.Excerpt,
+	}
+	for _, pe := range r.PluginErrors {
+		out.PluginErrors = append(out.PluginErrors, PluginError{Plugin: pe.Plugin, Line: pe.Line})
+	}
+	return out
+}
+
+// FromSmokeTestError converts a failed smoke test and the rollback it caused.
+func FromSmokeTestError(e *paper.SmokeTestError) *SmokeTest {
+	out := FromSmokeTest(e.Result)
+	out.RolledBack = e.RolledBack
+	if e.RolledBack {
+		out.Restored = FromRollback(e.Restored)
+	}
+	return out
+}
+
+// Backup is the outcome of backup: where the jar was moved.
+type Backup struct {
+	Path string `json:"path"`
+}
+
+// Rollback is paper.RollbackResult.
+type Rollback struct {
+	Version string `json:"version"`
+	Build   int    `json:"build"`
+	JarName string `json:"jar_name"`
+	Known   bool   `json:"known"`
+	MovedTo string `json:"moved_to,omitempty"`
+}
+
+// FromRollback converts a paper.RollbackResult.
+func FromRollback(r paper.RollbackResult) *Rollback {
+	return &Rollback{
+		Version: r.Restored.Version,
+		Build:   r.Restored.Build,
+		JarName: r.Restored.JarName,
+		Known:   r.Known,
+		MovedTo: r.MovedTo,
+	}
+}
+
+// Verify is paper.VerifyResult.
+type Verify struct {
+	OK       bool   `json:"ok"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// FromVerify converts a paper.VerifyResult.
+func FromVerify(r paper.VerifyResult) *Verify {
+	return &Verify{OK: r.OK, Expected: r.Expected, Actual: r.Actual}
+}
+
+// Recovery is paper.Recovery, present when a command first had to finish or undo an
+// interrupted operation.
+type Recovery struct {
+	Op      string `json:"op"`
+	Action  string `json:"action"`
+	JarName string `json:"jar_name"`
+}
+
+// FromRecovery converts a paper.Recovery, returning nil if nothing was recovered.
+func FromRecovery(r paper.Recovery) *Recovery {
+	if r.Action == paper.RecoveryNone {
+		return nil
+	}
+	return &Recovery{Op: r.Entry.Op, Action: string(r.Action), JarName: r.Entry.Next.JarName}
+}
+
+// Plan is paper.Plan: what a --dry-run operation would have done.
+type Plan struct {
+	Op       string     `json:"op"`
+	Download *Download  `json:"download,omitempty"`
+	Steps    []PlanStep `json:"steps"`
+	Prune    []string   `json:"prune"`
+	State    *Record    `json:"state,omitempty"` // what state.json would record as installed
+	LockedBy *Holder    `json:"locked_by,omitempty"`
+	Java     *Java      `json:"java,omitempty"`
+	Refused  bool       `json:"refused,omitempty"` // the java check would refuse the install
+	Summary  []string   `json:"summary"`           // the same plan as human-readable lines
+}
+
+// Download is a file that would be fetched and verified.
+type Download struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// PlanStep is a journal.Step: a rename, the state save or the prune.
+type PlanStep struct {
+	Kind string `json:"kind"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// FromPlan converts a paper.Plan.
+func FromPlan(p paper.Plan) *Plan {
+	out := &Plan{Op: p.Op, Steps: make([]PlanStep, 0, len(p.Steps)), Prune: p.Prune, Summary: p.Lines()}
+	if out.Prune == nil {
+		out.Prune = []string{}
+	}
+	if dl := p.Download; dl != nil {
+		out.Download = &Download{Name: dl.Name, URL: dl.URL, Size: dl.Size, SHA256: dl.Checksums.SHA256}
+	}
+	for _, st := range p.Steps {
+		out.Steps = append(out.Steps, PlanStep{Kind: string(st.Kind), From: st.From, To: st.To})
+	}
+	if st := p.State; st != nil {
+		out.State = &Record{Version: st.Version, Build: st.Build, JarName: st.JarName, SHA256: st.SHA256, InstalledAt: st.InstalledAt}
+	}
+	if p.LockedBy != nil {
+		out.LockedBy = FromHolder(*p.LockedBy)
+	}
+	if p.Java != nil {
+		out.Java, out.Refused = FromJavaCheck(*p.Java), p.Refused
+	}
+	return out
+}
+
+// Self is the outcome of self-update: the running version against the latest release.
+type Self struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+	URL     string `json:"url"`
+	Asset   string `json:"asset,omitempty"` // the binary for this platform, if published
+	Newer   bool   `json:"newer"`
+	Updated bool   `json:"updated"`
+	Path    string `json:"path,omitempty"` // the replaced executable, when Updated
+}
+
+// FromSelfUpdate converts a selfupdate.Status.
+func FromSelfUpdate(st selfupdate.Status) *Self {
+	return &Self{Current: st.Current, Latest: st.Latest.Tag, URL: st.Latest.URL, Asset: st.Asset.Name, Newer: st.Newer}
+}
+
+// Nagios is the result of check-nagios: the plugin status and its output.
+type Nagios struct {
+	Status   string   `json:"status"` // OK, WARNING, CRITICAL or UNKNOWN; the exit code matches
+	Summary  string   `json:"summary"`
+	Details  []string `json:"details,omitempty"`
+	PerfData string   `json:"perfdata,omitempty"`
+}
+
+// FromNagios converts a nagios.Result.
+func FromNagios(r nagios.Result) *Nagios {
+	return &Nagios{Status: r.Status.String(), Summary: r.Summary, Details: r.Details, PerfData: r.PerfData()}
+}
+
+// RCON is the result of the rcon command: where the command went and the server's
+// response.
+type RCON struct {
+	Addr     string `json:"addr"`
+	Command  string `json:"command"`
+	Response string `json:"response"`
+}
+
+// Safe is safeupdate.Result: the outcome of a safe-update.
+type Safe struct {
+	Version      string    `json:"version"` // the build that was to be installed
+	Build        int       `json:"build"`
+	UpToDate     bool      `json:"up_to_date"`
+	RolledBack   bool      `json:"rolled_back"`
+	Restored     *Rollback `json:"restored,omitempty"` // set when rolled back
+	ReadySeconds float64   `json:"ready_seconds,omitempty"`
+}
+
+// FromSafeUpdate converts a safeupdate.Result.
+func FromSafeUpdate(r safeupdate.Result) *Safe {
+	out := &Safe{
+		Version:      r.Latest.Version,
+		Build:        r.Latest.Build,
+		UpToDate:     r.UpToDate,
+		RolledBack:   r.RolledBack,
+		ReadySeconds: r.Ready.Seconds(),
+	}
+	if r.RolledBack {
+		out.Restored = FromRollback(r.Restored)
+	}
+	return out
+}
+
+// Java is paper.JavaCheck: whether the configured java can run a Paper version, and
+// for the java command the other runtimes found on the machine.
+type Java struct {
+	Version      string        `json:"version"`  // the Paper version
+	Required     int           `json:"required"` // minimum Java release; 0 if unknown
+	RequiredFrom string        `json:"required_from"`
+	Configured   *JavaRuntime  `json:"configured,omitempty"` // absent if it could not be run
+	CheckError   string        `json:"check_error,omitempty"`
+	TooOld       bool          `json:"too_old"`
+	Runtimes     []JavaRuntime `json:"runtimes,omitempty"`
+}
+
+// JavaRuntime is java.Runtime.
+type JavaRuntime struct {
+	Path    string `json:"path"`
+	Major   int    `json:"major"`
+	Version string `json:"version"`
+	Name    string `json:"name,omitempty"`
+	Source  string `json:"source"`
+}
+
+// FromJavaCheck converts a paper.JavaCheck.
+func FromJavaCheck(c paper.JavaCheck) *Java {
+	out := &Java{Version: c.Version, Required: c.Required, RequiredFrom: c.Source, TooOld: c.TooOld() != nil}
+	if c.Runtime != nil {
+		out.Configured = FromJavaRuntime(*c.Runtime)
+	}
+	if c.Err != nil {
+		out.CheckError = c.Err.Error()
+	}
+	return out
+}
+
+// FromJavaRuntime converts a java.Runtime.
+func FromJavaRuntime(rt java.Runtime) *JavaRuntime {
+	return &JavaRuntime{Path: rt.Path, Major: rt.Major, Version: rt.Version, Name: rt.Name, Source: rt.Source}
+}
+
+// Version is papermc.VersionInfo: one version's metadata.
+type Version struct {
+	Version     string   `json:"version"`
+	Support     string   `json:"support,omitempty"`
+	EndOfLife   bool     `json:"end_of_life"`
+	JavaMinimum int      `json:"java_minimum,omitempty"`
+	JavaFlags   []string `json:"java_flags,omitempty"` // recommended JVM arguments
+	Installed   bool     `json:"installed"`
+}
+
+// FromVersions converts version metadata, marking installed as such.
+func FromVersions(vs []papermc.VersionInfo, installed string) []Version {
+	out := make([]Version, 0, len(vs))
+	for _, v := range vs {
+		out = append(out, Version{
+			Version:     v.ID,
+			Support:     string(v.Support.Status),
+			EndOfLife:   v.EndOfLife(),
+			JavaMinimum: v.Java.Version.Minimum,
+			JavaFlags:   v.Java.Flags.Recommended,
+			Installed:   v.ID == installed,
+		})
+	}
+	return out
+}
+
+// Start is the outcome of start-script: the script's settings, and whether start.sh
+// changed and was written.
+type Start struct {
+	Path          string   `json:"path"`
+	Memory        string   `json:"memory"` // the heap, e.g. "6G"
+	TotalMemoryMB int      `json:"total_memory_mb,omitempty"`
+	Flags         []string `json:"flags"`
+	FlagsFrom     string   `json:"flags_from"`
+	Restart       bool     `json:"restart"`
+	Changed       bool     `json:"changed"`
+	Written       bool     `json:"written"`
+	Diff          string   `json:"diff,omitempty"` // unified diff against the previous start.sh
+}
+
+// FromStartScript converts a start script and the change writing it makes.
+func FromStartScript(s startscript.Script, c startscript.Change) *Start {
+	return &Start{
+		Path:          c.Path,
+		Memory:        startscript.FormatSize(s.HeapMB),
+		TotalMemoryMB: s.TotalMB,
+		Flags:         s.Flags,
+		FlagsFrom:     s.FlagsFrom,
+		Restart:       s.Restart,
+		Changed:       c.Changed(),
+		Diff:          c.Diff(),
+	}
+}
+
+// Unit is the outcome of systemd-unit: the unit's name and user, and whether its file
+// changed and was written.
+type Unit struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	User     string `json:"user"`
+	Managed  bool   `json:"managed"` // the unit already ran the server from this directory
+	Changed  bool   `json:"changed"`
+	Written  bool   `json:"written"`
+	Reloaded bool   `json:"reloaded"` // systemctl daemon-reload succeeded after writing
+	Diff     string `json:"diff,omitempty"`
+}
+
+// FromUnit converts a unit spec and the change writing it makes.
+func FromUnit(s systemd.Spec, c systemd.Change) *Unit {
+	return &Unit{
+		Name:    s.Name,
+		Path:    c.Path,
+		User:    s.User,
+		Changed: c.Changed(),
+		Diff:    c.Diff(),
+	}
+}
+
+// EULA is the outcome of the eula command: whether the Minecraft EULA is accepted and,
+// if the command accepted it, by whom and when.
+type EULA struct {
+	State      string     `json:"state"` // missing, not accepted or accepted
+	Accepted   bool       `json:"accepted"`
+	URL        string     `json:"url"`
+	AcceptedBy string     `json:"accepted_by,omitempty"`
+	Host       string     `json:"host,omitempty"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+// FromEULA converts the EULA's state.
+func FromEULA(st eula.State) *EULA {
+	return &EULA{State: st.String(), Accepted: st == eula.Accepted, URL: eula.URL}
+}
+
+// Command is one subcommand, as help lists it.
+type Command struct {
+	Name    string `json:"name"`
+	Summary string `json:"summary"`
+}
+
+// Setting is one effective config value and the layer it came from.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// FromConfig lists every setting of c in display order. Secret values are masked.
+func FromConfig(c *config.Config) []Setting {
+	out := make([]Setting, 0, len(config.Settings))
+	for _, s := range config.Settings {
+		v := c.Get(s.Key)
+		if s.Secret() && v.Value != "" {
+			v.Value = "(set)"
+		}
+		out = append(out, Setting{Key: string(s.Key), Value: v.Value, Source: v.Source})
+	}
+	return out
+}
+
+// ErrorKind is a stable, machine-matchable category for an error.
+type ErrorKind string
+
+const (
+	KindNoBuild          ErrorKind = "no_build"           // papermc.ErrNoStableBuild
+	KindNoServerDownload ErrorKind = "no_server_download" // papermc.ErrNoServerDownload
+	KindHTTPStatus       ErrorKind = "http_status"        // papermc.StatusError, selfupdate.StatusError
+	KindChecksumMismatch ErrorKind = "checksum_mismatch"  // download.ErrChecksumMismatch
+	KindSizeMismatch     ErrorKind = "size_mismatch"      // download.ErrSizeMismatch
+	KindLocked           ErrorKind = "locked"             // lock.ErrLocked
+	KindPendingRecovery  ErrorKind = "pending_recovery"   // journal.ErrPending
+	KindNotInstalled     ErrorKind = "not_installed"      // paper.ErrNothingInstalled
+	KindJarModified      ErrorKind = "jar_modified"       // paper.ErrJarModified
+	KindNoBackup         ErrorKind = "no_backup"          // paper.ErrNoBackup
+	KindNoAsset          ErrorKind = "no_asset"           // selfupdate.ErrNoAsset
+	KindNoChecksum       ErrorKind = "no_checksum"        // selfupdate.ErrNoChecksum
+	KindRCONDisabled     ErrorKind = "rcon_disabled"      // rcon.ErrDisabled, rcon.ErrNoPassword
+	KindRCONAuth         ErrorKind = "rcon_auth"          // rcon.ErrAuth
+	KindServerNotRunning ErrorKind = "server_not_running" // supervisor.ErrNotRunning
+	KindNotReady         ErrorKind = "not_ready"          // safeupdate.ErrNotReady
+	KindServerExited     ErrorKind = "server_exited"      // safeupdate.ErrExited
+	KindJavaTooOld       ErrorKind = "java_too_old"       // java.ErrTooOld
+	KindSmokeTestFailed  ErrorKind = "smoke_test_failed"  // paper.ErrSmokeTestFailed
+	KindTimeout          ErrorKind = "timeout"
+	KindCanceled         ErrorKind = "canceled"
+	KindUsage            ErrorKind = "usage"
+	KindBusy             ErrorKind = "busy" // the control API is already running an operation
+	KindUnauthorized     ErrorKind = "unauthorized"
+	KindOther            ErrorKind = "other"
+)
+
+// Error describes a failure. Status and URL are set for KindHTTPStatus, Holder for
+// KindLocked.
+type Error struct {
+	Kind    ErrorKind `json:"kind"`
+	Message string    `json:"message"`
+	Status  int       `json:"status,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	Holder  *Holder   `json:"holder,omitempty"`
+}
+
+// kinds maps sentinel errors to their kind, checked in order with errors.Is.
+var kinds = []struct {
+	err  error
+	kind ErrorKind
+}{
+	{papermc.ErrNoStableBuild, KindNoBuild},
+	{papermc.ErrNoServerDownload, KindNoServerDownload},
+	{download.ErrChecksumMismatch, KindChecksumMismatch},
+	{download.ErrSizeMismatch, KindSizeMismatch},
+	{journal.ErrPending, KindPendingRecovery},
+	{paper.ErrNothingInstalled, KindNotInstalled},
+	{paper.ErrJarModified, KindJarModified},
+	{paper.ErrNoBackup, KindNoBackup},
+	{selfupdate.ErrNoAsset, KindNoAsset},
+	{selfupdate.ErrNoChecksum, KindNoChecksum},
+	{rcon.ErrDisabled, KindRCONDisabled},
+	{rcon.ErrNoPassword, KindRCONDisabled},
+	{rcon.ErrAuth, KindRCONAuth},
+	{supervisor.ErrNotRunning, KindServerNotRunning},
+	{safeupdate.ErrNotReady, KindNotReady},
+	{safeupdate.ErrExited, KindServerExited},
+	{java.ErrTooOld, KindJavaTooOld},
+	{paper.ErrSmokeTestFailed, KindSmokeTestFailed},
+	{context.DeadlineExceeded, KindTimeout},
+	{context.Canceled, KindCanceled},
+}
+
+// FromError classifies err.
+func FromError(err error) *Error {
+	e := &Error{Kind: KindOther, Message: err.Error()}
+
+	var se *papermc.StatusError
+	var sue *selfupdate.StatusError
+	var held *lock.HeldError
+	switch {
+	case errors.As(err, &se):
+		e.Kind, e.Status, e.URL = KindHTTPStatus, se.StatusCode, se.URL
+		return e
+	case errors.As(err, &sue):
+		e.Kind, e.Status, e.URL = KindHTTPStatus, sue.StatusCode, sue.URL
+		return e
+	case errors.As(err, &held):
+		e.Kind, e.Holder = KindLocked, FromHolder(held.Holder)
+		return e
+	}
+	for _, k := range kinds {
+		if errors.Is(err, k.err) {
+			e.Kind = k.kind
+			break
+		}
+	}
+	return e
+}
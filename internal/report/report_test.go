@@ -0,0 +1,90 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/java"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/rcon"
+	"github.com/mbacalan/paper-mc-tui/internal/safeupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/selfupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+)
+
+func TestFromErrorKinds(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorKind
+	}{
+		{papermc.ErrNoStableBuild, KindNoBuild},
+		{fmt.Errorf("download: %w: got a, want b", download.ErrChecksumMismatch), KindChecksumMismatch},
+		{fmt.Errorf("wrapped: %w", paper.ErrJarModified), KindJarModified},
+		{fmt.Errorf("request: %w", context.DeadlineExceeded), KindTimeout},
+		{fmt.Errorf("%w: v1.3.0 has no checksums.txt", selfupdate.ErrNoChecksum), KindNoChecksum},
+		{fmt.Errorf("%w: server.properties not found", rcon.ErrDisabled), KindRCONDisabled},
+		{rcon.ErrAuth, KindRCONAuth},
+		{fmt.Errorf("%w (no \"Done\" line within 5m0s)", safeupdate.ErrNotReady), KindNotReady},
+		{fmt.Errorf("paper: install: %w", &java.TooOldError{Runtime: java.Runtime{Path: "java", Major: 17}, Required: 21, Version: "1.21.10"}), KindJavaTooOld},
+		{&paper.SmokeTestError{RolledBack: true}, KindSmokeTestFailed},
+		{errors.New("disk on fire"), KindOther},
+	}
+	for _, tc := range cases {
+		if got := FromError(tc.err); got.Kind != tc.want || got.Message != tc.err.Error() {
+			t.Errorf("FromError(%v) = %+v, want kind %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestFromErrorDetails(t *testing.T) {
+	se := &papermc.StatusError{StatusCode: 503, URL: "https://fill.example/v3/projects/paper"}
+	e := FromError(fmt.Errorf("resolve: %w", se))
+	if e.Kind != KindHTTPStatus || e.Status != 503 || e.URL != se.URL {
+		t.Errorf("status error = %+v", e)
+	}
+
+	sue := &selfupdate.StatusError{StatusCode: 403, URL: "https://api.github.example/releases/latest"}
+	if e := FromError(sue); e.Kind != KindHTTPStatus || e.Status != 403 {
+		t.Errorf("self-update status error = %+v", e)
+	}
+
+	held := &lock.HeldError{Path: "/srv/paper-mc.lock", Holder: lock.Holder{PID: 42, Host: "mc1"}}
+	e = FromError(held)
+	if e.Kind != KindLocked || e.Holder == nil || e.Holder.PID != 42 {
+		t.Errorf("lock error = %+v", e)
+	}
+}
+
+func TestDocumentJSON(t *testing.T) {
+	doc := New("status")
+	doc.OK = true
+	doc.Installed = FromState(state.State{Version: "26.1.2", Build: 70, InstalledAt: time.Date(2026, 5, 20, 10, 0, 0, 0, time.UTC)})
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{`"schema":1`, `"command":"status"`, `"build":70`, `"installed_at":"2026-05-20T10:00:00Z"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("document missing %s:\n%s", want, got)
+		}
+	}
+	for _, absent := range []string{`"latest"`, `"error"`, `"locked_by"`, `"support"`, `"versions"`, `"eula"`} {
+		if strings.Contains(got, absent) {
+			t.Errorf("document should omit %s:\n%s", absent, got)
+		}
+	}
+
+	if in := FromState(state.State{}); in.InstalledAt != nil {
+		t.Error("a zero install time should be omitted")
+	}
+}
@@ -0,0 +1,343 @@
+// Package safeupdate installs a new Paper build under a running server without pulling
+// the jar out from under it. It warns players with a countdown, saves the worlds, stops
+// the server, swaps in the verified jar with paper.Service.Install, starts the server
+// again and waits for Minecraft's "Done (…)! For help" line. A build that does not get
+// there within the ready timeout is rolled back to the previous jar and started again.
+package safeupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+)
+
+const (
+	// DefaultCountdown is how long players are warned before the server stops.
+	DefaultCountdown = time.Minute
+	// DefaultReadyTimeout is how long a started server has to log its "Done" line.
+	DefaultReadyTimeout = 5 * time.Minute
+	// pollInterval is how often the console is read while waiting for the server.
+	pollInterval = 250 * time.Millisecond
+)
+
+var (
+	// ErrNotReady means the server was still starting when the ready timeout ran out.
+	ErrNotReady = errors.New("safeupdate: the server did not finish starting in time")
+	// ErrExited means the server exited before it finished starting.
+	ErrExited = errors.New("safeupdate: the server exited while starting")
+)
+
+// readyRE matches the line a Minecraft server logs once it accepts players, e.g.
+// `[12:34:56 INFO]: Done (12.345s)! For help, type "help"`.
+var readyRE = regexp.MustCompile(`Done \([0-9.,]+s\)! For help`)
+
+// announceAt are the points in the countdown, as time remaining, at which players are
+// warned again. The countdown itself may start anywhere.
+var announceAt = []time.Duration{
+	30 * time.Minute, 15 * time.Minute, 10 * time.Minute, 5 * time.Minute, 2 * time.Minute, time.Minute,
+	30 * time.Second, 10 * time.Second, 5 * time.Second, 3 * time.Second, 2 * time.Second, time.Second,
+}
+
+// Installer is the part of paper.Service a safe update drives.
+type Installer interface {
+	CheckLatest(ctx context.Context) (paper.LatestInfo, error)
+	Install(ctx context.Context, opts paper.InstallOptions) error
+	Rollback(name string) (paper.RollbackResult, error)
+	Timeouts() paper.Timeouts
+	BackupName() string
+}
+
+// Server is the part of supervisor.Supervisor a safe update drives.
+type Server interface {
+	Status() (supervisor.Status, error)
+	Start() (supervisor.Status, error)
+	Stop() error
+	Send(line string) error
+	Console() *supervisor.Console
+}
+
+// Step is a stage of a safe update, reported to Run's onStep as it begins.
+type Step string
+
+const (
+	StepCheck     Step = "check"
+	StepCountdown Step = "countdown"
+	StepSave      Step = "save"
+	StepStop      Step = "stop"
+	StepInstall   Step = "install"
+	StepStart     Step = "start"
+	StepRollback  Step = "rollback"
+)
+
+// Result describes a finished safe update.
+type Result struct {
+	Latest   paper.LatestInfo // the build that was to be installed
+	UpToDate bool             // nothing to do; the server was left alone
+	// RolledBack means the new build did not start, so the previous jar is back in
+	// place; Restored describes it.
+	RolledBack bool
+	Restored   paper.RollbackResult
+	Ready      time.Duration // how long the running build took to start
+}
+
+// Updater runs safe updates. Build one with New.
+type Updater struct {
+	svc          Installer
+	server       Server
+	countdown    time.Duration
+	readyTimeout time.Duration
+	log          *slog.Logger
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures an Updater.
+type Option func(*Updater)
+
+// WithCountdown sets how long players are warned before the server stops (default
+// DefaultCountdown). Zero stops it straight away, after saving.
+func WithCountdown(d time.Duration) Option {
+	return func(u *Updater) { u.countdown = max(d, 0) }
+}
+
+// WithReadyTimeout sets how long the server has to start before the update is rolled
+// back (default DefaultReadyTimeout).
+func WithReadyTimeout(d time.Duration) Option {
+	return func(u *Updater) {
+		if d > 0 {
+			u.readyTimeout = d
+		}
+	}
+}
+
+// WithLogger sets where the updater reports.
+func WithLogger(l *slog.Logger) Option {
+	return func(u *Updater) {
+		if l != nil {
+			u.log = l
+		}
+	}
+}
+
+// New returns an Updater installing with svc under the server that server runs.
+func New(svc Installer, server Server, opts ...Option) *Updater {
+	u := &Updater{
+		svc:          svc,
+		server:       server,
+		countdown:    DefaultCountdown,
+		readyTimeout: DefaultReadyTimeout,
+		log:          logging.Discard(),
+		sleep:        sleep,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Run updates the running server to the latest build, calling onStep (if non-nil) as
+// each step begins. The server must be running; a stopped one can simply be installed
+// to.
+//
+// If the install fails, the previous build is started again and the install error
+// returned. If the new build does not start, Run rolls back, starts the previous build
+// and returns ErrNotReady or ErrExited with Result.RolledBack set. Cancelling ctx
+// during the countdown calls the update off and tells the players so.
+func (u *Updater) Run(ctx context.Context, onStep func(step Step, detail string)) (Result, error) {
+	log := u.log.With(logging.KeyEvent, logging.EventSafeUpdate)
+	step := func(s Step, detail string) {
+		log.Info(detail, "step", string(s))
+		if onStep != nil {
+			onStep(s, detail)
+		}
+	}
+
+	st, err := u.server.Status()
+	if err != nil {
+		return Result{}, err
+	}
+	if !st.Running {
+		return Result{}, fmt.Errorf("%w; install to a stopped server instead", supervisor.ErrNotRunning)
+	}
+
+	step(StepCheck, "checking for a newer build")
+	checkCtx, cancel := context.WithTimeout(ctx, u.svc.Timeouts().Check)
+	info, err := u.svc.CheckLatest(checkCtx)
+	cancel()
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Latest: info, UpToDate: info.UpToDate}
+	if info.UpToDate {
+		log.Info("already up to date", logging.KeyVersion, info.Version, logging.KeyBuild, info.Build)
+		return res, nil
+	}
+	log = log.With(logging.KeyVersion, info.Version, logging.KeyBuild, info.Build)
+	target := fmt.Sprintf("%s build %d", info.Version, info.Build)
+
+	if err := u.runCountdown(ctx, target, step); err != nil {
+		u.announce(log, "Update cancelled; the server keeps running.")
+		log.Warn("safe update cancelled during the countdown")
+		return res, err
+	}
+
+	step(StepSave, "saving the worlds")
+	if err := u.server.Send("save-all"); err != nil {
+		// Stopping saves as well; this only gets the worlds on disk sooner.
+		log.Warn("could not send save-all", logging.Err(err))
+	}
+
+	step(StepStop, "stopping the server")
+	if err := u.server.Stop(); err != nil && !errors.Is(err, supervisor.ErrNotRunning) {
+		return res, err
+	}
+
+	step(StepInstall, "installing "+target)
+	installCtx, cancel := context.WithTimeout(ctx, u.svc.Timeouts().Download)
+	err = u.svc.Install(installCtx, paper.InstallOptions{Backup: true})
+	cancel()
+	if err != nil {
+		// Install leaves the old jar in place when it fails.
+		log.Error("install failed; starting the previous build again", logging.Err(err))
+		step(StepStart, "starting the previous build again")
+		if _, startErr := u.start(ctx); startErr != nil {
+			return res, errors.Join(err, startErr)
+		}
+		return res, err
+	}
+
+	step(StepStart, "starting "+target)
+	ready, startErr := u.start(ctx)
+	if startErr == nil {
+		res.Ready = ready
+		log.Info("safe update finished", "ready_in", ready.Round(time.Millisecond).String())
+		return res, nil
+	}
+	if ctx.Err() != nil {
+		return res, startErr
+	}
+
+	log.Error("the new build did not start; rolling back", logging.Err(startErr))
+	step(StepRollback, "rolling back to the previous build")
+	if err := u.server.Stop(); err != nil && !errors.Is(err, supervisor.ErrNotRunning) {
+		return res, errors.Join(startErr, err)
+	}
+	restored, err := u.svc.Rollback(u.svc.BackupName())
+	if err != nil {
+		return res, errors.Join(startErr, err)
+	}
+	res.RolledBack, res.Restored = true, restored
+	step(StepStart, fmt.Sprintf("starting the previous build (%s build %d)", restored.Restored.Version, restored.Restored.Build))
+	if res.Ready, err = u.start(ctx); err != nil {
+		return res, errors.Join(startErr, err)
+	}
+	return res, startErr
+}
+
+// runCountdown warns the players at each point in announceAt until the countdown runs
+// out, then once more as the server goes down.
+func (u *Updater) runCountdown(ctx context.Context, target string, step func(Step, string)) error {
+	log := u.log.With(logging.KeyEvent, logging.EventSafeUpdate)
+	for left := u.countdown; left > 0; {
+		msg := fmt.Sprintf("The server restarts in %s to update to Paper %s.", humanize(left), target)
+		step(StepCountdown, "announcing: restart in "+humanize(left))
+		u.announce(log, msg)
+
+		next := time.Duration(0)
+		for _, at := range announceAt {
+			if at < left {
+				next = at
+				break
+			}
+		}
+		if err := u.sleep(ctx, left-next); err != nil {
+			return err
+		}
+		left = next
+	}
+	if u.countdown > 0 {
+		u.announce(log, "The server is restarting now.")
+	}
+	return nil
+}
+
+// announce tells the players msg, through the console or RCON. A server that can be
+// reached neither way is still updated; the failure is only logged.
+func (u *Updater) announce(log *slog.Logger, msg string) {
+	if err := u.server.Send("say " + msg); err != nil {
+		log.Warn("could not announce to players", "message", msg, logging.Err(err))
+	}
+}
+
+// start starts the server and waits for its ready line, returning how long it took.
+func (u *Updater) start(ctx context.Context) (time.Duration, error) {
+	console := u.server.Console()
+	_, seq := console.Since(^uint64(0)) // skip what is already there
+	begin := time.Now()
+	if _, err := u.server.Start(); err != nil {
+		return 0, err
+	}
+
+	timeout := time.NewTimer(u.readyTimeout)
+	defer timeout.Stop()
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	for {
+		var lines []string
+		lines, seq = console.Since(seq)
+		for _, line := range lines {
+			if readyRE.MatchString(line) {
+				return time.Since(begin), nil
+			}
+		}
+		if st, err := u.server.Status(); err == nil && !st.Running {
+			return 0, ErrExited
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-timeout.C:
+			return 0, fmt.Errorf("%w (no \"Done\" line within %s)", ErrNotReady, u.readyTimeout)
+		case <-tick.C:
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// humanize spells out a countdown for players, e.g. "1 minute 30 seconds".
+func humanize(d time.Duration) string {
+	d = d.Round(time.Second)
+	var parts []string
+	if m := int(d / time.Minute); m > 0 {
+		parts = append(parts, plural(m, "minute"))
+	}
+	if s := int(d % time.Minute / time.Second); s > 0 || len(parts) == 0 {
+		parts = append(parts, plural(s, "second"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,250 @@
+package safeupdate
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+)
+
+// fakeInstaller records what the update asks of paper.Service. The installed jar is
+// "old" or "new".
+type fakeInstaller struct {
+	latest     paper.LatestInfo
+	installErr error
+	jar        string
+	calls      []string
+}
+
+func (f *fakeInstaller) CheckLatest(context.Context) (paper.LatestInfo, error) {
+	return f.latest, nil
+}
+
+func (f *fakeInstaller) Install(_ context.Context, opts paper.InstallOptions) error {
+	f.calls = append(f.calls, "install")
+	if !opts.Backup {
+		return errors.New("installed without a backup")
+	}
+	if f.installErr != nil {
+		return f.installErr
+	}
+	f.jar = "new"
+	return nil
+}
+
+func (f *fakeInstaller) Rollback(name string) (paper.RollbackResult, error) {
+	f.calls = append(f.calls, "rollback "+name)
+	f.jar = "old"
+	return paper.RollbackResult{Restored: state.State{Version: "26.1.2", Build: 70}, Known: true}, nil
+}
+
+func (f *fakeInstaller) Timeouts() paper.Timeouts {
+	return paper.Timeouts{Check: time.Second, Download: time.Minute}
+}
+
+func (f *fakeInstaller) BackupName() string { return paper.DefaultBackupName }
+
+// fakeServer runs whichever jar svc has installed: the jars in boots start (logging
+// the ready line), exit or hang.
+type fakeServer struct {
+	mu      sync.Mutex
+	svc     *fakeInstaller
+	boots   map[string]string // jar -> "ok", "exit" or "hang"
+	running bool
+	console *supervisor.Console
+	sent    []string
+	events  []string
+}
+
+func newFakeServer(svc *fakeInstaller, boots map[string]string) *fakeServer {
+	return &fakeServer{svc: svc, boots: boots, running: true, console: supervisor.NewConsole(100)}
+}
+
+func (f *fakeServer) Status() (supervisor.Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return supervisor.Status{Running: f.running}, nil
+}
+
+func (f *fakeServer) Start() (supervisor.Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, "start "+f.svc.jar)
+	f.console.Write([]byte("[12:00:00 INFO]: Starting minecraft server version 26.1.2\n"))
+	switch f.boots[f.svc.jar] {
+	case "ok":
+		f.running = true
+		f.console.Write([]byte(`[12:00:05 INFO]: Done (4.950s)! For help, type "help"` + "\n"))
+	case "hang":
+		f.running = true
+	default:
+		f.running = false
+	}
+	return supervisor.Status{Running: f.running}, nil
+}
+
+func (f *fakeServer) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return supervisor.ErrNotRunning
+	}
+	f.events = append(f.events, "stop")
+	f.running = false
+	return nil
+}
+
+func (f *fakeServer) Send(line string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, line)
+	return nil
+}
+
+func (f *fakeServer) Console() *supervisor.Console { return f.console }
+
+func newBuild() paper.LatestInfo {
+	return paper.LatestInfo{Version: "26.1.2", Build: 71, JarName: "paper-26.1.2-71.jar"}
+}
+
+// newUpdater returns an Updater whose countdown sleeps are recorded, not slept.
+func newUpdater(svc *fakeInstaller, server *fakeServer, opts ...Option) (*Updater, *[]time.Duration) {
+	var slept []time.Duration
+	u := New(svc, server, opts...)
+	u.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return u, &slept
+}
+
+func TestRun(t *testing.T) {
+	svc := &fakeInstaller{latest: newBuild(), jar: "old"}
+	server := newFakeServer(svc, map[string]string{"old": "ok", "new": "ok"})
+	u, slept := newUpdater(svc, server, WithCountdown(90*time.Second))
+
+	var steps []Step
+	res, err := u.Run(context.Background(), func(s Step, _ string) { steps = append(steps, s) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UpToDate || res.RolledBack || res.Ready <= 0 {
+		t.Errorf("Run = %+v", res)
+	}
+	// 1m30s, then 1m, 30s, 10s, 5s, 3s, 2s and 1s before the restart.
+	if want := []time.Duration{30 * time.Second, 30 * time.Second, 20 * time.Second, 5 * time.Second,
+		2 * time.Second, time.Second, time.Second, time.Second}; !slices.Equal(*slept, want) {
+		t.Errorf("countdown slept %v, want %v", *slept, want)
+	}
+	if got := server.sent[0]; got != "say The server restarts in 1 minute 30 seconds to update to Paper 26.1.2 build 71." {
+		t.Errorf("first announcement %q", got)
+	}
+	if n := len(server.sent); server.sent[n-2] != "say The server is restarting now." || server.sent[n-1] != "save-all" {
+		t.Errorf("last lines sent %q", server.sent[n-2:])
+	}
+	if want := []string{"stop", "start new"}; !slices.Equal(server.events, want) {
+		t.Errorf("server events %q, want %q", server.events, want)
+	}
+	if want := []Step{StepCheck, StepCountdown, StepSave, StepStop, StepInstall, StepStart}; !slices.Equal(slices.Compact(steps), want) {
+		t.Errorf("steps %q, want %q", steps, want)
+	}
+}
+
+func TestRunUpToDate(t *testing.T) {
+	svc := &fakeInstaller{latest: newBuild(), jar: "old"}
+	svc.latest.UpToDate = true
+	server := newFakeServer(svc, nil)
+	u, _ := newUpdater(svc, server)
+	res, err := u.Run(context.Background(), nil)
+	if err != nil || !res.UpToDate {
+		t.Fatalf("Run = %+v, %v", res, err)
+	}
+	if len(server.sent) > 0 || len(server.events) > 0 || len(svc.calls) > 0 {
+		t.Errorf("an up-to-date server was touched: sent %q, events %q, calls %q", server.sent, server.events, svc.calls)
+	}
+}
+
+func TestRunRollsBack(t *testing.T) {
+	for _, boot := range []string{"exit", "hang"} {
+		t.Run(boot, func(t *testing.T) {
+			svc := &fakeInstaller{latest: newBuild(), jar: "old"}
+			server := newFakeServer(svc, map[string]string{"old": "ok", "new": boot})
+			u, _ := newUpdater(svc, server, WithCountdown(0), WithReadyTimeout(300*time.Millisecond))
+
+			res, err := u.Run(context.Background(), nil)
+			want := ErrExited
+			if boot == "hang" {
+				want = ErrNotReady
+			}
+			if !errors.Is(err, want) {
+				t.Fatalf("Run error = %v, want %v", err, want)
+			}
+			if !res.RolledBack || res.Restored.Restored.Build != 70 || res.Ready <= 0 {
+				t.Errorf("Run = %+v", res)
+			}
+			if svc.jar != "old" || !slices.Contains(svc.calls, "rollback "+paper.DefaultBackupName) {
+				t.Errorf("jar %q after calls %q", svc.jar, svc.calls)
+			}
+			if got := server.events[len(server.events)-1]; got != "start old" {
+				t.Errorf("last server event %q, want the old build started", got)
+			}
+		})
+	}
+}
+
+func TestRunInstallFails(t *testing.T) {
+	svc := &fakeInstaller{latest: newBuild(), jar: "old", installErr: errors.New("download: connection reset")}
+	server := newFakeServer(svc, map[string]string{"old": "ok"})
+	u, _ := newUpdater(svc, server, WithCountdown(0))
+	res, err := u.Run(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "connection reset") || res.RolledBack {
+		t.Fatalf("Run = %+v, %v", res, err)
+	}
+	if want := []string{"stop", "start old"}; !slices.Equal(server.events, want) {
+		t.Errorf("server events %q, want %q", server.events, want)
+	}
+}
+
+func TestRunRefusals(t *testing.T) {
+	svc := &fakeInstaller{latest: newBuild(), jar: "old"}
+	server := newFakeServer(svc, nil)
+	server.running = false
+	u, _ := newUpdater(svc, server)
+	if _, err := u.Run(context.Background(), nil); !errors.Is(err, supervisor.ErrNotRunning) {
+		t.Errorf("Run on a stopped server = %v, want ErrNotRunning", err)
+	}
+
+	server.running = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := u.Run(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Run = %v, want context.Canceled", err)
+	}
+	if len(svc.calls) > 0 || len(server.events) > 0 {
+		t.Errorf("a cancelled countdown went on: calls %q, events %q", svc.calls, server.events)
+	}
+	if last := server.sent[len(server.sent)-1]; !strings.Contains(last, "cancelled") {
+		t.Errorf("players were not told the update was called off: %q", last)
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		time.Second:      "1 second",
+		30 * time.Second: "30 seconds",
+		time.Minute:      "1 minute",
+		90 * time.Second: "1 minute 30 seconds",
+		10 * time.Minute: "10 minutes",
+	} {
+		if got := humanize(d); got != want {
+			t.Errorf("humanize(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
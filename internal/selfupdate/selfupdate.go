@@ -0,0 +1,331 @@
+// Package selfupdate finds newer releases of paper-mc-tui itself on GitHub and
+// replaces the running binary with one. It picks the release asset `make dist` names for
+// this platform (paper-mc-tui_<os>_<arch>[.exe]), verifies it against the release's
+// checksums.txt while downloading, and renames it over the executable only once it
+// matches, so a failed update leaves the old binary untouched.
+package selfupdate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/buildinfo"
+	"github.com/mbacalan/paper-mc-tui/internal/download"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+)
+
+const (
+	// DefaultBaseURL is the GitHub API root for the project's repository. Releases are
+	// read from DefaultBaseURL + "/releases/latest".
+	DefaultBaseURL = "https://api.github.com/repos/mbacalan/paper-mc-tui"
+	// BinaryName is the name `make dist` gives release assets.
+	BinaryName = "paper-mc-tui"
+	// ChecksumsName is the release asset listing each binary's SHA256, in sha256sum
+	// format.
+	ChecksumsName = "checksums.txt"
+	// defaultTimeout bounds the release lookup and the checksum file download. The binary
+	// itself is bounded by the caller's context.
+	defaultTimeout = 15 * time.Second
+)
+
+var (
+	// ErrNoAsset means the release has no binary for this platform.
+	ErrNoAsset = errors.New("selfupdate: release has no binary for this platform")
+	// ErrNoChecksum means the release publishes no checksum for the binary, so it is not
+	// installed.
+	ErrNoChecksum = errors.New("selfupdate: release has no checksum for the binary")
+	// ErrUnexpectedStatus is matched by StatusError via errors.Is.
+	ErrUnexpectedStatus = errors.New("selfupdate: unexpected status code")
+)
+
+// StatusError is returned when GitHub responds with a non-200 status. It matches
+// ErrUnexpectedStatus.
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("selfupdate: unexpected status %d for %s", e.StatusCode, e.URL)
+}
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrUnexpectedStatus
+}
+
+// Release is a GitHub release.
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+// AssetName is the binary `make dist` builds for goos/goarch.
+func AssetName(goos, goarch string) string {
+	name := BinaryName + "_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// asset returns the release asset called name.
+func (r Release) asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Status compares the running binary with the latest release.
+type Status struct {
+	Current string
+	Latest  Release
+	Newer   bool // the latest release is newer than the running binary
+	Asset   Asset
+}
+
+// Updater checks for and applies updates. Build one with New.
+type Updater struct {
+	httpClient *http.Client
+	downloader *download.Downloader
+	baseURL    string
+	userAgent  string
+	log        *slog.Logger
+	current    string
+
+	goos, goarch string
+	executable   func() (string, error)
+}
+
+// Option configures an Updater.
+type Option func(*Updater)
+
+// WithBaseURL overrides the repository API root (no trailing slash), e.g. to point at a
+// local stand-in.
+func WithBaseURL(u string) Option {
+	return func(up *Updater) {
+		if u != "" {
+			up.baseURL = strings.TrimSuffix(u, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client for the release lookup (useful for tests).
+func WithHTTPClient(c *http.Client) Option {
+	return func(up *Updater) {
+		if c != nil {
+			up.httpClient = c
+		}
+	}
+}
+
+// WithDownloader sets the downloader that fetches and verifies the binary.
+func WithDownloader(d *download.Downloader) Option {
+	return func(up *Updater) {
+		if d != nil {
+			up.downloader = d
+		}
+	}
+}
+
+// WithUserAgent sets the User-Agent header, which GitHub requires.
+func WithUserAgent(ua string) Option {
+	return func(up *Updater) {
+		if ua != "" {
+			up.userAgent = ua
+		}
+	}
+}
+
+// WithLogger sets where updates are recorded.
+func WithLogger(l *slog.Logger) Option {
+	return func(up *Updater) {
+		if l != nil {
+			up.log = l
+		}
+	}
+}
+
+// New returns an Updater for the running binary, with defaults overridden by opts.
+func New(opts ...Option) *Updater {
+	u := &Updater{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		downloader: download.NewDownloader(),
+		baseURL:    DefaultBaseURL,
+		userAgent:  papermc.DefaultUserAgent,
+		log:        logging.Discard(),
+		current:    buildinfo.Version,
+		goos:       runtime.GOOS,
+		goarch:     runtime.GOARCH,
+		executable: executable,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// executable is the running binary's real path, with symlinks resolved so the link is
+// kept and its target replaced.
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// Check looks up the latest release and compares it with the running binary. A
+// development build (a version that is not a release tag) is never reported as
+// outdated.
+func (u *Updater) Check(ctx context.Context) (Status, error) {
+	var rel Release
+	if err := u.getJSON(ctx, u.baseURL+"/releases/latest", &rel); err != nil {
+		return Status{}, err
+	}
+	st := Status{Current: u.current, Latest: rel, Newer: Newer(rel.Tag, u.current)}
+	if a, ok := rel.asset(AssetName(u.goos, u.goarch)); ok {
+		st.Asset = a
+	}
+	u.log.Debug("checked for a newer release", logging.KeyEvent, logging.EventSelfUpdate,
+		"current", u.current, "latest", rel.Tag, "newer", st.Newer)
+	return st, nil
+}
+
+// Apply downloads st's binary for this platform, verifies it against the release's
+// checksums and atomically replaces the running executable, whose path it returns. The
+// new binary takes effect the next time the tool starts. onProgress is as for
+// download.Downloader.Download.
+func (u *Updater) Apply(ctx context.Context, st Status, onProgress func(done, total int64)) (string, error) {
+	name := AssetName(u.goos, u.goarch)
+	if st.Asset.Name != name {
+		return "", fmt.Errorf("%w: %s has no %s", ErrNoAsset, st.Latest.Tag, name)
+	}
+	sum, err := u.checksum(ctx, st.Latest, name)
+	if err != nil {
+		return "", err
+	}
+	exe, err := u.executable()
+	if err != nil {
+		return "", fmt.Errorf("selfupdate: locate executable: %w", err)
+	}
+	log := u.log.With(logging.KeyEvent, logging.EventSelfUpdate, "from", u.current, "to", st.Latest.Tag, "path", exe)
+	log.Info("updating paper-mc-tui")
+
+	// Download next to the executable so the final rename stays on one filesystem.
+	staged := exe + ".new"
+	dl := papermc.Download{Name: name, URL: st.Asset.URL, Size: st.Asset.Size, Checksums: papermc.Checksums{SHA256: sum}}
+	if err := u.downloader.Download(ctx, dl, staged, onProgress); err != nil {
+		log.Error("update failed", "step", "download", logging.Err(err))
+		return "", err
+	}
+	if err := u.replace(exe, staged); err != nil {
+		os.Remove(staged)
+		log.Error("update failed", "step", "replace", logging.Err(err))
+		return "", err
+	}
+	log.Info("updated paper-mc-tui")
+	return exe, nil
+}
+
+// replace moves staged over exe. Windows will not overwrite a running executable but
+// does allow renaming it, so there the old binary is first moved aside to exe+".old".
+func (u *Updater) replace(exe, staged string) error {
+	if err := os.Chmod(staged, 0o755); err != nil {
+		return fmt.Errorf("selfupdate: chmod: %w", err)
+	}
+	if u.goos == "windows" {
+		old := exe + ".old"
+		os.Remove(old) // left by the previous update, if any
+		if err := os.Rename(exe, old); err != nil {
+			return fmt.Errorf("selfupdate: move old binary aside: %w", err)
+		}
+		if err := os.Rename(staged, exe); err != nil {
+			_ = os.Rename(old, exe)
+			return fmt.Errorf("selfupdate: rename into place: %w", err)
+		}
+		return nil
+	}
+	if err := os.Rename(staged, exe); err != nil {
+		return fmt.Errorf("selfupdate: rename into place: %w", err)
+	}
+	return nil
+}
+
+// checksum finds name's SHA256 in the release's checksums.txt.
+func (u *Updater) checksum(ctx context.Context, rel Release, name string) (string, error) {
+	a, ok := rel.asset(ChecksumsName)
+	if !ok {
+		return "", fmt.Errorf("%w: %s has no %s", ErrNoChecksum, rel.Tag, ChecksumsName)
+	}
+	body, err := u.get(ctx, a.URL, "application/octet-stream")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	sc := bufio.NewScanner(io.LimitReader(body, 1<<20))
+	for sc.Scan() {
+		// sha256sum format: "<hex>  <name>", with "*" before the name in binary mode.
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", fmt.Errorf("selfupdate: read %s: %w", ChecksumsName, err)
+	}
+	return "", fmt.Errorf("%w: %s does not list %s", ErrNoChecksum, ChecksumsName, name)
+}
+
+func (u *Updater) getJSON(ctx context.Context, url string, out any) error {
+	body, err := u.get(ctx, url, "application/vnd.github+json")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("selfupdate: decode %s: %w", url, err)
+	}
+	return nil
+}
+
+// get performs a GET and returns the body of a 200 response.
+func (u *Updater) get(ctx context.Context, url, accept string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("selfupdate: build request: %w", err)
+	}
+	req.Header.Set("User-Agent", u.userAgent)
+	req.Header.Set("Accept", accept)
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("selfupdate: request %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, URL: url}
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,152 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mbacalan/paper-mc-tui/internal/download"
+)
+
+// newFixture serves a GitHub-like /releases/latest for tag with a linux/amd64 binary
+// and, unless checksums is empty, a checksums.txt with that content ("%s" is replaced
+// by the binary's real SHA256). It returns an Updater whose executable is a temp file.
+func newFixture(t *testing.T, tag, checksums string) (*Updater, string, []byte) {
+	t.Helper()
+	binary := []byte("#!/bin/sh\necho new paper-mc-tui\n")
+	sum := sha256.Sum256(binary)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		assets := fmt.Sprintf(`{"name":"paper-mc-tui_linux_amd64","size":%d,"browser_download_url":"%s/bin"},
+			{"name":"paper-mc-tui_windows_amd64.exe","size":1,"browser_download_url":"%s/exe"}`, len(binary), srv.URL, srv.URL)
+		if checksums != "" {
+			assets += fmt.Sprintf(`,{"name":"checksums.txt","size":1,"browser_download_url":"%s/sums"}`, srv.URL)
+		}
+		fmt.Fprintf(w, `{"tag_name":%q,"html_url":"https://example.test/%s","assets":[%s]}`, tag, tag, assets)
+	})
+	mux.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(binary) })
+	mux.HandleFunc("/sums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, checksums, hex.EncodeToString(sum[:]))
+	})
+
+	exe := filepath.Join(t.TempDir(), "paper-mc-tui")
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	u := New(WithBaseURL(srv.URL+"/"), WithHTTPClient(srv.Client()),
+		WithDownloader(download.NewDownloader(download.WithHTTPClient(srv.Client()))))
+	u.current, u.goos, u.goarch = "v1.2.0", "linux", "amd64"
+	u.executable = func() (string, error) { return exe, nil }
+	return u, exe, binary
+}
+
+func TestCheckAndApply(t *testing.T) {
+	u, exe, binary := newFixture(t, "v1.3.0", "0000  paper-mc-tui_darwin_arm64\n%s *paper-mc-tui_linux_amd64\n")
+	ctx := context.Background()
+
+	st, err := u.Check(ctx)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !st.Newer || st.Latest.Tag != "v1.3.0" || st.Asset.Name != "paper-mc-tui_linux_amd64" {
+		t.Fatalf("status = %+v", st)
+	}
+	path, err := u.Apply(ctx, st, nil)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	if path != exe || string(got) != string(binary) {
+		t.Errorf("%s = %q, want the new binary", path, got)
+	}
+	if fi, _ := os.Stat(exe); fi.Mode().Perm()&0o100 == 0 {
+		t.Errorf("mode = %v, want executable", fi.Mode())
+	}
+	if _, err := os.Stat(exe + ".new"); !os.IsNotExist(err) {
+		t.Error("staged binary left behind")
+	}
+}
+
+func TestApplyRefusesUnverified(t *testing.T) {
+	tests := []struct {
+		name      string
+		checksums string
+		want      error
+	}{
+		{"no checksums file", "", ErrNoChecksum},
+		{"not listed", "%s  paper-mc-tui_linux_arm64\n", ErrNoChecksum},
+		{"mismatch", "00ff  paper-mc-tui_linux_amd64\n", download.ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, exe, _ := newFixture(t, "v1.3.0", tt.checksums)
+			st, err := u.Check(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := u.Apply(context.Background(), st, nil); !errors.Is(err, tt.want) {
+				t.Fatalf("Apply = %v, want %v", err, tt.want)
+			}
+			if got, _ := os.ReadFile(exe); string(got) != "old" {
+				t.Errorf("executable replaced despite the failure: %q", got)
+			}
+		})
+	}
+}
+
+func TestApplyNoAsset(t *testing.T) {
+	u, _, _ := newFixture(t, "v1.3.0", "%s  paper-mc-tui_linux_amd64\n")
+	u.goos, u.goarch = "freebsd", "amd64"
+	st, err := u.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Apply(context.Background(), st, nil); !errors.Is(err, ErrNoAsset) {
+		t.Errorf("Apply = %v, want ErrNoAsset", err)
+	}
+}
+
+func TestCheckStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	_, err := New(WithBaseURL(srv.URL)).Check(context.Background())
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound || !errors.Is(err, ErrUnexpectedStatus) {
+		t.Errorf("Check = %v, want a 404 StatusError", err)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "1.9.0", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.3.0", false},
+		{"v1.2.0", "v1.2.0-rc.1", true},
+		{"v1.2.0", "v1.2.0-4-gabc1234", false}, // built after the tag
+		{"v1.2.0", "v1.2.0-4-gabc1234-dirty", false},
+		{"v1.3.0", "v1.2.0-4-gabc1234", true},
+		{"v1.3.0-rc.1", "v1.3.0-rc.2", false},
+		{"v1.3.0", "dev", false},
+		{"nightly", "v1.2.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.latest, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.latest, tt.current, got, tt.want)
+		}
+	}
+}
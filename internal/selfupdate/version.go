@@ -0,0 +1,53 @@
+package selfupdate
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// versionRE matches a release tag like v1.2.3 or v1.2.3-rc.1, and what `git describe`
+// stamps into builds made after one: v1.2.3-4-gabcdef0, possibly with -dirty.
+var versionRE = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-(.+))?$`)
+
+// describeRE matches the `git describe` suffix: commits since the tag and the hash.
+var describeRE = regexp.MustCompile(`^\d+-g[0-9a-f]+(-dirty)?$`)
+
+type version struct {
+	core   [3]int
+	suffix string
+}
+
+func parseVersion(s string) (version, bool) {
+	m := versionRE.FindStringSubmatch(s)
+	if m == nil {
+		return version{}, false
+	}
+	var v version
+	for i := range v.core {
+		v.core[i], _ = strconv.Atoi(m[i+1])
+	}
+	v.suffix = m[4]
+	return v, true
+}
+
+// Newer reports whether release tag latest is newer than the running version current.
+// It is conservative: if either cannot be parsed (e.g. a "dev" build), or both are
+// pre-releases of the same version, it says no.
+func Newer(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range l.core {
+		if l.core[i] != c.core[i] {
+			return l.core[i] > c.core[i]
+		}
+	}
+	// Same version: a final release beats a pre-release of it, but not a build made
+	// after it.
+	return l.suffix == "" && c.suffix != "" && !describeRE.MatchString(c.suffix)
+}
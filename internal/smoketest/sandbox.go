@@ -0,0 +1,232 @@
+package smoketest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mbacalan/paper-mc-tui/internal/properties"
+)
+
+const (
+	// sandboxPrefix names the sandbox directories created in the server directory. They
+	// are created there rather than in the system temp directory, which is often too
+	// small for a world.
+	sandboxPrefix = ".paper-smoke-"
+	// worldName is the level-name of the sandbox's throwaway world.
+	worldName = "smoke-world"
+	// maxConfigSize is the largest configuration file copied into the sandbox. Bigger
+	// files are data, not settings.
+	maxConfigSize = 1 << 20
+)
+
+// configExts are the extensions of the files copied into the sandbox, at the top of
+// the server directory, under config/ and in the plugins' data folders.
+var configExts = map[string]bool{
+	".yml": true, ".yaml": true, ".json": true, ".properties": true, ".txt": true, ".toml": true, ".conf": true,
+}
+
+// cacheDirs are the caches Paper fills on its first start, mostly downloads of the
+// Mojang server and libraries. The sandbox gets copies rather than downloading them
+// again: the new build patches and adds files in them, which must not reach the live
+// server's, perhaps while it has them open. Hard links would not do, as a file
+// rewritten in place changes through every link.
+var cacheDirs = []string{"cache", "libraries", "versions"}
+
+// Sandboxes lists the sandbox directories in dir. A test removes its own when it ends,
+// so any found while no test runs were left by a crash and can be removed.
+func Sandboxes(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smoketest: %w", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), sandboxPrefix) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// prepare fills sandbox with what the server in dir needs to start jar: its
+// configuration, its plugins and a server.properties pointing at a new world on a free
+// loopback port, which it returns.
+func prepare(dir, sandbox, jar string, log *slog.Logger) (int, error) {
+	if _, err := os.Stat(jar); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrNoJar, err)
+	}
+	if err := linkOrCopy(jar, filepath.Join(sandbox, filepath.Base(jar))); err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("smoketest: %w", err)
+	}
+	for _, e := range entries {
+		if e.Type().IsRegular() && isConfig(e.Name()) {
+			if err := copyConfig(filepath.Join(dir, e.Name()), filepath.Join(sandbox, e.Name())); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := copyTree(filepath.Join(dir, "config"), filepath.Join(sandbox, "config"), false); err != nil {
+		return 0, err
+	}
+	if err := copyTree(filepath.Join(dir, "plugins"), filepath.Join(sandbox, "plugins"), true); err != nil {
+		return 0, err
+	}
+	for _, name := range cacheDirs {
+		if err := copyDir(filepath.Join(dir, name), filepath.Join(sandbox, name)); err != nil {
+			// Paper downloads what it needs instead; the test only takes longer.
+			log.Debug("could not copy a cache into the sandbox", "dir", name, "error", err.Error())
+		}
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return 0, err
+	}
+	path := filepath.Join(sandbox, "server.properties")
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("smoketest: %w", err)
+	}
+	data = properties.Update(data, properties.Properties{
+		"server-ip":    "127.0.0.1",
+		"server-port":  strconv.Itoa(port),
+		"enable-rcon":  "false",
+		"enable-query": "false",
+		"level-name":   worldName,
+	})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return 0, fmt.Errorf("smoketest: %w", err)
+	}
+	return port, nil
+}
+
+// isConfig reports whether a file of this name is copied into the sandbox.
+func isConfig(name string) bool {
+	return !strings.HasPrefix(name, ".") && configExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// copyTree copies the configuration files under src to dst. With plugins, the jars at
+// the top of src are copied too. Hidden files and directories, such as Paper's cache
+// of remapped plugins, are left out. A missing src is not an error.
+func copyTree(src, dst string, plugins bool) error {
+	if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if rel != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case !d.Type().IsRegular():
+			return nil
+		case plugins && filepath.Dir(rel) == "." && strings.EqualFold(filepath.Ext(rel), ".jar"):
+			return linkOrCopy(path, target)
+		case isConfig(d.Name()):
+			return copyConfig(path, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("smoketest: copy %s: %w", src, err)
+	}
+	return nil
+}
+
+// copyDir copies the regular files and directories under src to dst. A missing src is
+// not an error.
+func copyDir(src, dst string) error {
+	if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+		return nil
+	}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type().IsRegular():
+			return copyFile(path, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("smoketest: copy %s: %w", src, err)
+	}
+	return nil
+}
+
+// copyConfig copies a configuration file no bigger than maxConfigSize.
+func copyConfig(src, dst string) error {
+	if fi, err := os.Stat(src); err != nil || fi.Size() > maxConfigSize {
+		return nil
+	}
+	return copyFile(src, dst)
+}
+
+// linkOrCopy hard-links src to dst, copying it where links are not possible. Jars are
+// only read, so the sandbox can share them.
+func linkOrCopy(src, dst string) error {
+	if os.Link(src, dst) == nil {
+		return nil
+	}
+	return copyFile(src, dst)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("smoketest: %w", err)
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("smoketest: %w", err)
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("smoketest: copy %s: %w", src, err)
+	}
+	return nil
+}
+
+// freePort returns a TCP port on the loopback interface that nothing listens on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("smoketest: find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,355 @@
+// Package smoketest boots a freshly installed Paper jar once, away from the live server,
+// to see whether it starts. The jar runs in a disposable sandbox inside the server
+// directory: a copy of the server's configuration and plugins, a new empty world, RCON
+// and query switched off and a free port on the loopback interface. The server's log is
+// read until Minecraft's "Done (…)! For help" line, and plugins that fail to load or
+// enable are reported with the lines around the error. Then the server is stopped and
+// the sandbox removed.
+package smoketest
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+const (
+	// DefaultTimeout is how long the server has to log its ready line.
+	DefaultTimeout = 3 * time.Minute
+	// DefaultJava is the java launcher used unless WithJava says otherwise.
+	DefaultJava = "java"
+	// stopTimeout is how long a ready server has to exit after "stop" before it is
+	// killed. Nothing in the sandbox is worth waiting longer for.
+	stopTimeout = 30 * time.Second
+	// pipeWaitDelay bounds how long reaping waits for the output pipe to close.
+	pipeWaitDelay = 5 * time.Second
+	// excerptAfter is how many lines after a plugin error, usually the start of its
+	// stack trace, are kept with it.
+	excerptAfter = 5
+	// tailLines is how many of the last lines are kept when the server never gets ready.
+	tailLines = 20
+)
+
+// ErrNoJar means the jar to test does not exist.
+var ErrNoJar = errors.New("smoketest: no jar to test")
+
+var (
+	// readyRE matches the line a Minecraft server logs once it accepts players, the same
+	// line a safe update waits for.
+	readyRE = regexp.MustCompile(`Done \([0-9.,]+s\)! For help`)
+	// pluginErrorREs match the lines Paper logs when a plugin fails to load or enable;
+	// the first group names the plugin.
+	pluginErrorREs = []*regexp.Regexp{
+		regexp.MustCompile(`Error occurred while enabling (\S+)`),
+		regexp.MustCompile(`Could not load (?:plugin )?'(?:plugins[/\\])?([^']+)'`),
+	}
+	// fatalRE matches the lines of a server that has given up starting.
+	fatalRE = regexp.MustCompile(`Failed to start the minecraft server|Encountered an unexpected exception`)
+)
+
+// PluginError is a plugin that failed to load or enable.
+type PluginError struct {
+	Plugin string
+	Line   string
+}
+
+// Result describes a smoke test.
+type Result struct {
+	Passed bool
+	// Skipped means the test could not be run, e.g. because the EULA has not been
+	// accepted; Reason says why. A skipped test is not a failure.
+	Skipped      bool
+	Ready        bool          // the server logged its ready line
+	Startup      time.Duration // how long that took
+	PluginErrors []PluginError
+	Reason       string // why the test failed or was skipped
+	// Excerpt is the log around each plugin error or, if the server never got ready,
+	// its last lines.
+	Excerpt []string
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Skipped:
+		return "skipped: " + r.Reason
+	case r.Passed:
+		return fmt.Sprintf("passed: ready in %s", r.Startup.Round(100*time.Millisecond))
+	}
+	return "failed: " + r.Reason
+}
+
+// Tester runs smoke tests. Build one with New.
+type Tester struct {
+	java    string
+	jvmArgs []string
+	timeout time.Duration
+	log     *slog.Logger
+}
+
+// Option configures a Tester.
+type Option func(*Tester)
+
+// WithJava sets the java launcher, a path or a name looked up in PATH.
+func WithJava(path string) Option {
+	return func(t *Tester) {
+		if path != "" {
+			t.java = path
+		}
+	}
+}
+
+// WithJVMArgs sets the arguments given to java before -jar, normally the server's own.
+// -Xms and -XX:+AlwaysPreTouch are left out, so the test server only takes the memory
+// it uses while the live server may still be running.
+func WithJVMArgs(args ...string) Option {
+	return func(t *Tester) {
+		t.jvmArgs = nil
+		for _, a := range args {
+			if strings.HasPrefix(a, "-Xms") || a == "-XX:+AlwaysPreTouch" {
+				continue
+			}
+			t.jvmArgs = append(t.jvmArgs, a)
+		}
+	}
+}
+
+// WithTimeout sets how long the server has to log its ready line (default
+// DefaultTimeout).
+func WithTimeout(d time.Duration) Option {
+	return func(t *Tester) {
+		if d > 0 {
+			t.timeout = d
+		}
+	}
+}
+
+// WithLogger sets where the tester logs.
+func WithLogger(l *slog.Logger) Option {
+	return func(t *Tester) {
+		if l != nil {
+			t.log = l
+		}
+	}
+}
+
+// New returns a Tester.
+func New(opts ...Option) *Tester {
+	t := &Tester{java: DefaultJava, timeout: DefaultTimeout, log: logging.Discard()}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Timeout returns how long the server has to get ready.
+func (t *Tester) Timeout() time.Duration { return t.timeout }
+
+// Run boots jar against a sandboxed copy of the server in dir. A server that fails the
+// test is reported in the Result; the error is for a test that could not be set up, or
+// ctx ending. Without an accepted EULA the server would stop at once, so the test is
+// skipped: accepting it is for the server's owner, not for the tester.
+func (t *Tester) Run(ctx context.Context, dir, jar string) (Result, error) {
+	log := t.log.With(logging.KeyEvent, logging.EventSmokeTest, "jar", filepath.Base(jar))
+	if st, _ := eula.Read(dir); st != eula.Accepted {
+		log.Info("smoke test skipped: the EULA has not been accepted")
+		return Result{Skipped: true, Reason: "the Minecraft EULA has not been accepted in eula.txt"}, nil
+	}
+
+	sandbox, err := os.MkdirTemp(dir, sandboxPrefix)
+	if err != nil {
+		return Result{}, fmt.Errorf("smoketest: %w", err)
+	}
+	defer os.RemoveAll(sandbox)
+	port, err := prepare(dir, sandbox, jar, log)
+	if err != nil {
+		return Result{}, err
+	}
+
+	log.Info("smoke test started", "port", port, "timeout", t.timeout.String())
+	res, err := t.boot(ctx, sandbox, filepath.Base(jar))
+	switch {
+	case err != nil:
+		log.Warn("smoke test did not finish", logging.Err(err))
+	case res.Passed:
+		log.Info("smoke test passed", "ready_in", res.Startup.Round(time.Millisecond).String())
+	default:
+		log.Error("smoke test failed", "reason", res.Reason, "plugin_errors", len(res.PluginErrors))
+	}
+	return res, err
+}
+
+// boot runs the server in sandbox and reads its output until it is ready, gives up or
+// runs out of time.
+func (t *Tester) boot(ctx context.Context, sandbox, jarName string) (Result, error) {
+	args := append(append([]string{}, t.jvmArgs...), "-jar", jarName, "nogui")
+	cmd := exec.Command(t.java, args...)
+	cmd.Dir = sandbox
+	cmd.WaitDelay = pipeWaitDelay
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return Result{}, fmt.Errorf("smoketest: %w", err)
+	}
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	begin := time.Now()
+	if err := cmd.Start(); err != nil {
+		return Result{}, fmt.Errorf("smoketest: start %s: %w", t.java, err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		exited <- err
+	}()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(pr)
+		sc.Buffer(make([]byte, 64<<10), 1<<20)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		io.Copy(io.Discard, pr) // an overlong line must not block the server
+	}()
+
+	timeout := time.NewTimer(t.timeout)
+	defer timeout.Stop()
+	var (
+		res    Result
+		s      scan
+		runErr error
+		gone   bool // the server has exited and its output is all read
+	)
+read:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				gone = true
+				res.Reason = "the server exited before it was ready"
+				if err := <-exited; err != nil {
+					res.Reason += " (" + err.Error() + ")"
+				}
+				break read
+			}
+			switch s.line(line) {
+			case lineReady:
+				res.Ready, res.Startup = true, time.Since(begin)
+				break read
+			case lineFatal:
+				res.Reason = "the server failed to start: " + strings.TrimSpace(line)
+				break read
+			}
+		case <-timeout.C:
+			res.Reason = fmt.Sprintf("the server was not ready after %s", t.timeout)
+			break read
+		case <-ctx.Done():
+			runErr = fmt.Errorf("smoketest: %w", ctx.Err())
+			break read
+		}
+	}
+
+	if !gone {
+		grace := time.Duration(0)
+		if res.Ready {
+			// A clean stop shows the jar can also shut down. The world is thrown away, so
+			// anything else is simply killed.
+			fmt.Fprintln(stdin, "stop")
+			grace = stopTimeout
+		}
+		drain(lines, exited, cmd.Process, grace)
+	}
+	if runErr != nil {
+		return Result{}, runErr
+	}
+
+	res.PluginErrors = s.pluginErrors
+	if len(res.PluginErrors) > 0 && res.Ready {
+		res.Reason = fmt.Sprintf("%d plugin(s) failed to load or enable", len(res.PluginErrors))
+	}
+	res.Excerpt = s.excerpt
+	if !res.Ready {
+		res.Excerpt = append(res.Excerpt, s.tail...)
+	}
+	res.Passed = res.Ready && len(res.PluginErrors) == 0
+	return res, nil
+}
+
+// drain reads the rest of the server's output until it has exited, killing it once
+// grace has passed.
+func drain(lines <-chan string, exited <-chan error, p *os.Process, grace time.Duration) {
+	kill := time.NewTimer(grace)
+	defer kill.Stop()
+	for {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				lines = nil
+			}
+		case <-exited:
+			if lines != nil {
+				for range lines {
+				}
+			}
+			return
+		case <-kill.C:
+			p.Kill()
+		}
+	}
+}
+
+// lineKind is what a line of the server's output means to the test.
+type lineKind int
+
+const (
+	lineOther lineKind = iota
+	lineReady
+	lineFatal
+)
+
+// scan collects what the test reports from the server's output.
+type scan struct {
+	pluginErrors []PluginError
+	excerpt      []string
+	after        int // lines still to add to the excerpt after a plugin error
+	tail         []string
+}
+
+func (s *scan) line(line string) lineKind {
+	if len(s.tail) == tailLines {
+		s.tail = s.tail[1:]
+	}
+	s.tail = append(s.tail, line)
+
+	for _, re := range pluginErrorREs {
+		if m := re.FindStringSubmatch(line); m != nil {
+			s.pluginErrors = append(s.pluginErrors, PluginError{Plugin: m[1], Line: strings.TrimSpace(line)})
+			s.excerpt = append(s.excerpt, line)
+			s.after = excerptAfter
+			return lineOther
+		}
+	}
+	if s.after > 0 {
+		s.after--
+		s.excerpt = append(s.excerpt, line)
+	}
+	switch {
+	case readyRE.MatchString(line):
+		return lineReady
+	case fatalRE.MatchString(line):
+		return lineFatal
+	}
+	return lineOther
+}
@@ -0,0 +1,188 @@
+package smoketest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeJava writes a java launcher that records its arguments, the sandbox's files and
+// server.properties in out, then runs body as the server.
+func fakeJava(t *testing.T, out, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake java is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "java")
+	script := "#!/bin/sh\n" +
+		`echo "$@" > "` + out + `/args"` + "\n" +
+		`find . > "` + out + `/files"` + "\n" +
+		`cp server.properties "` + out + `/server.properties"` + "\n" +
+		body
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serverDir sets up a server directory with an accepted EULA, configuration, a plugin
+// with its data and a world.
+func serverDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"eula.txt":                      "#By changing the setting below to TRUE you are indicating your agreement.\neula=TRUE\n",
+		"server.properties":             "server-port=25565\nenable-rcon=true\nrcon.password=secret\nlevel-name=world\nmotd=My server\n",
+		"bukkit.yml":                    "settings:\n",
+		"paper.jar":                     "jar",
+		"config/paper-global.yml":       "_version: 30\n",
+		"plugins/Essentials.jar":        "plugin",
+		"plugins/Essentials/config.yml": "ops: true\n",
+		"plugins/Essentials/users.db":   "data",
+		"plugins/.paper-remapped/x.jar": "remapped",
+		"world/level.dat":               "level",
+		"libraries/lib.jar":             "library",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const readyLine = `echo '[12:00:05 INFO]: Done (4.321s)! For help, type "help"'` + "\n"
+
+func TestRunPasses(t *testing.T) {
+	dir, out := serverDir(t), t.TempDir()
+	// Paperclip patches the caches as it starts; that must stay in the sandbox.
+	java := fakeJava(t, out, "echo patched > libraries/lib.jar\n"+readyLine+`read cmd; echo "$cmd" > "`+out+`/stdin"`+"\n")
+	tester := New(WithJava(java), WithJVMArgs("-Xms4G", "-Xmx4G", "-XX:+AlwaysPreTouch", "-XX:+UseG1GC"))
+
+	res, err := tester.Run(context.Background(), dir, filepath.Join(dir, "paper.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Passed || !res.Ready || res.Skipped || len(res.PluginErrors) > 0 {
+		t.Fatalf("Run = %+v, want a pass", res)
+	}
+	if args := read(t, out, "args"); args != "-Xmx4G -XX:+UseG1GC -jar paper.jar nogui" {
+		t.Errorf("java args = %q", args)
+	}
+	if got := read(t, out, "stdin"); got != "stop" {
+		t.Errorf("the ready server was sent %q, want stop", got)
+	}
+
+	props := read(t, out, "server.properties") + "\n"
+	for _, want := range []string{"server-ip=127.0.0.1", "enable-rcon=false", "enable-query=false", "level-name=smoke-world", "motd=My server"} {
+		if !strings.Contains(props, want+"\n") {
+			t.Errorf("sandbox server.properties lacks %q:\n%s", want, props)
+		}
+	}
+	if strings.Contains(props, "server-port=25565\n") {
+		t.Error("the sandbox must not use the live server's port")
+	}
+
+	files := read(t, out, "files")
+	for _, want := range []string{"./paper.jar", "./eula.txt", "./bukkit.yml", "./config/paper-global.yml", "./plugins/Essentials.jar", "./plugins/Essentials/config.yml", "./libraries/lib.jar"} {
+		if !strings.Contains(files+"\n", want+"\n") {
+			t.Errorf("sandbox lacks %s:\n%s", want, files)
+		}
+	}
+	for _, unwanted := range []string{"world", "users.db", ".paper-remapped"} {
+		if strings.Contains(files, unwanted) {
+			t.Errorf("sandbox has %s:\n%s", unwanted, files)
+		}
+	}
+
+	if matches, _ := filepath.Glob(filepath.Join(dir, sandboxPrefix+"*")); len(matches) > 0 {
+		t.Errorf("sandbox left behind: %v", matches)
+	}
+	if lib, err := os.ReadFile(filepath.Join(dir, "libraries", "lib.jar")); err != nil || string(lib) != "library" {
+		t.Errorf("the sandbox wrote to the live cache: %q, %v", lib, err)
+	}
+}
+
+func TestRunPluginError(t *testing.T) {
+	dir, out := serverDir(t), t.TempDir()
+	java := fakeJava(t, out, `echo '[12:00:03 ERROR]: Error occurred while enabling Essentials v2.21.0 (Is it up to date?)'
+echo 'java.lang.NoSuchMethodError: void org.bukkit.Server.broadcast()'
+echo '	at com.earth2me.essentials.Essentials.onEnable(Essentials.java:120)'
+echo "[12:00:03 ERROR]: Could not load 'plugins/Old.jar' in folder 'plugins'"
+`+readyLine+"read cmd\n")
+
+	res, err := New(WithJava(java)).Run(context.Background(), dir, filepath.Join(dir, "paper.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Passed || !res.Ready || len(res.PluginErrors) != 2 {
+		t.Fatalf("Run = %+v, want a failure with two plugin errors", res)
+	}
+	if res.PluginErrors[0].Plugin != "Essentials" || res.PluginErrors[1].Plugin != "Old.jar" {
+		t.Errorf("plugin errors = %+v", res.PluginErrors)
+	}
+	if excerpt := strings.Join(res.Excerpt, "\n"); !strings.Contains(excerpt, "NoSuchMethodError") {
+		t.Errorf("excerpt lacks the stack trace:\n%s", excerpt)
+	}
+	if !strings.Contains(res.String(), "2 plugin(s) failed") {
+		t.Errorf("String = %q", res.String())
+	}
+}
+
+func TestRunNotReady(t *testing.T) {
+	dir, out := serverDir(t), t.TempDir()
+	slow := fakeJava(t, out, "echo '[12:00:00 INFO]: Starting minecraft server version 26.1.2'\nexec sleep 30\n")
+	begin := time.Now()
+	res, err := New(WithJava(slow), WithTimeout(300*time.Millisecond)).Run(context.Background(), dir, filepath.Join(dir, "paper.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Passed || res.Ready || !strings.Contains(res.Reason, "not ready after 300ms") || time.Since(begin) > 10*time.Second {
+		t.Errorf("Run = %+v after %s, want a timeout", res, time.Since(begin))
+	}
+	if len(res.Excerpt) == 0 || !strings.Contains(res.Excerpt[len(res.Excerpt)-1], "Starting minecraft server") {
+		t.Errorf("excerpt = %q, want the last lines", res.Excerpt)
+	}
+
+	crash := fakeJava(t, out, "echo '[12:00:01 WARN]: **** FAILED TO BIND TO PORT!'\nexit 1\n")
+	res, err = New(WithJava(crash)).Run(context.Background(), dir, filepath.Join(dir, "paper.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Passed || !strings.Contains(res.Reason, "exited before it was ready (exit status 1)") {
+		t.Errorf("Run = %+v, want an early exit", res)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := New(WithJava(slow)).Run(ctx, dir, filepath.Join(dir, "paper.jar")); err == nil {
+		t.Error("Run should fail when its context ends")
+	}
+}
+
+func TestRunSkipsWithoutEULA(t *testing.T) {
+	dir, out := serverDir(t), t.TempDir()
+	os.WriteFile(filepath.Join(dir, "eula.txt"), []byte("eula=false\n"), 0o644)
+	res, err := New(WithJava(fakeJava(t, out, readyLine))).Run(context.Background(), dir, filepath.Join(dir, "paper.jar"))
+	if err != nil || !res.Skipped || res.Passed || !strings.Contains(res.Reason, "EULA") {
+		t.Errorf("Run = %+v, %v, want skipped", res, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "args")); err == nil {
+		t.Error("java was run without an accepted EULA")
+	}
+}
+
+func read(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,297 @@
+// Package startscript writes a start.sh for running the server outside the TUI: java
+// with a heap sized to the machine's memory, Paper's recommended GC flags (or Aikar's,
+// which they descend from), and optionally a loop that restarts the server after a
+// crash. The script is generated whole; regenerating it shows a diff against the one
+// on disk, so hand edits are not lost unnoticed.
+package startscript
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/genfile"
+)
+
+const (
+	// FileName is the script written to the server directory.
+	FileName = "start.sh"
+	// MemInfoPath is where Linux reports the machine's memory.
+	MemInfoPath = "/proc/meminfo"
+	// CgroupMemoryPath is where cgroup v2 reports the memory limit of the container the
+	// tool runs in: a number of bytes, or "max" for none.
+	CgroupMemoryPath = "/sys/fs/cgroup/memory.max"
+	// DefaultRestartDelay is how long the restart loop waits after a crash.
+	DefaultRestartDelay = 10 * time.Second
+
+	// minHeapMB and maxHeapMB bound the suggested heap. More than 16 GB mostly buys
+	// longer garbage collections on a Minecraft server.
+	minHeapMB = 512
+	maxHeapMB = 16 << 10
+	// largeHeapMB is where Aikar's flags switch to their large-heap values.
+	largeHeapMB = 12 << 10
+)
+
+// ErrInvalidSize means a memory size is not a number of megabytes or gigabytes.
+var ErrInvalidSize = errors.New("startscript: invalid memory size")
+
+// Memory is what /proc/meminfo and the cgroup say, in MiB.
+type Memory struct {
+	TotalMB     int
+	AvailableMB int
+	LimitMB     int // the cgroup's memory limit; 0 if there is none
+}
+
+// UsableMB is the memory the server can have: the machine's, or the cgroup's limit if
+// that is lower.
+func (m Memory) UsableMB() int {
+	if m.LimitMB > 0 && m.LimitMB < m.TotalMB {
+		return m.LimitMB
+	}
+	return m.TotalMB
+}
+
+// ReadMemory reads a /proc/meminfo file and the limit in CgroupMemoryPath. A missing or
+// unreadable cgroup file means no limit.
+func ReadMemory(path string) (Memory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Memory{}, fmt.Errorf("startscript: read memory size: %w", err)
+	}
+	defer f.Close()
+	m, err := ParseMemInfo(f)
+	if err != nil {
+		return Memory{}, err
+	}
+	if data, err := os.ReadFile(CgroupMemoryPath); err == nil {
+		m.LimitMB = ParseCgroupLimit(data)
+	}
+	return m, nil
+}
+
+// ParseCgroupLimit reads a cgroup v2 memory.max into MiB, 0 for "max" or anything it
+// cannot read.
+func ParseCgroupLimit(data []byte) int {
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return int(n >> 20)
+}
+
+// ParseMemInfo reads the MemTotal and MemAvailable lines of /proc/meminfo, which are in
+// kB.
+func ParseMemInfo(r io.Reader) (Memory, error) {
+	var m Memory
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, rest, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		kb, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "MemTotal":
+			m.TotalMB = kb >> 10
+		case "MemAvailable":
+			m.AvailableMB = kb >> 10
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Memory{}, fmt.Errorf("startscript: read memory size: %w", err)
+	}
+	if m.TotalMB == 0 {
+		return Memory{}, errors.New("startscript: read memory size: no MemTotal in meminfo")
+	}
+	return m, nil
+}
+
+// SuggestHeap sizes the heap for totalMB of memory, Memory.UsableMB so a container's
+// limit is respected. It leaves a quarter of it, and at least 1 GB, to the system and
+// the JVM's own overhead, rounds down to 512 MB and keeps the result between 512 MB
+// and 16 GB.
+func SuggestHeap(totalMB int) int {
+	heap := totalMB - max(totalMB/4, 1<<10)
+	heap -= heap % 512
+	return min(max(heap, minHeapMB), maxHeapMB)
+}
+
+// FormatSize renders megabytes as java's -Xmx does: "6G" when whole gigabytes, "1536M"
+// otherwise.
+func FormatSize(mb int) string {
+	if mb%1024 == 0 {
+		return fmt.Sprintf("%dG", mb/1024)
+	}
+	return fmt.Sprintf("%dM", mb)
+}
+
+// ParseSize reads a size such as "6G" or "1536M" (case-insensitive) into megabytes.
+func ParseSize(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := 0
+	switch {
+	case strings.HasSuffix(s, "G"):
+		mult = 1024
+	case strings.HasSuffix(s, "M"):
+		mult = 1
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(s, "G"), "M"))
+	if mult == 0 || err != nil || n <= 0 {
+		return 0, fmt.Errorf("%w %q (want e.g. 6G or 1536M)", ErrInvalidSize, s)
+	}
+	if n*mult < minHeapMB {
+		return 0, fmt.Errorf("%w %q (Paper needs at least %s)", ErrInvalidSize, s, FormatSize(minHeapMB))
+	}
+	return n * mult, nil
+}
+
+// AikarFlags are the G1 settings from https://docs.papermc.io/paper/aikars-flags for a
+// heap of heapMB, used when the API has no recommendation.
+func AikarFlags(heapMB int) []string {
+	newSize, maxNewSize, region, reserve, ihop := "30", "40", "8M", "20", "15"
+	if heapMB > largeHeapMB {
+		newSize, maxNewSize, region, reserve, ihop = "40", "50", "16M", "15", "20"
+	}
+	return []string{
+		"-XX:+UseG1GC",
+		"-XX:+ParallelRefProcEnabled",
+		"-XX:MaxGCPauseMillis=200",
+		"-XX:+UnlockExperimentalVMOptions",
+		"-XX:+DisableExplicitGC",
+		"-XX:+AlwaysPreTouch",
+		"-XX:G1NewSizePercent=" + newSize,
+		"-XX:G1MaxNewSizePercent=" + maxNewSize,
+		"-XX:G1HeapRegionSize=" + region,
+		"-XX:G1ReservePercent=" + reserve,
+		"-XX:G1HeapWastePercent=5",
+		"-XX:G1MixedGCCountTarget=4",
+		"-XX:InitiatingHeapOccupancyPercent=" + ihop,
+		"-XX:G1MixedGCLiveThresholdPercent=90",
+		"-XX:G1RSetUpdatingPauseTimePercent=5",
+		"-XX:SurvivorRatio=32",
+		"-XX:+PerfDisableSharedMem",
+		"-XX:MaxTenuringThreshold=1",
+		"-Dusing.aikars.flags=https://mcflags.emc.gs",
+		"-Daikars.new.flags=true",
+	}
+}
+
+// ChooseFlags returns the flags the API recommends for version if it gave any, and
+// Aikar's for heapMB otherwise, with a description for Script.FlagsFrom.
+func ChooseFlags(version string, recommended []string, heapMB int) (flags []string, from string) {
+	if len(recommended) > 0 {
+		return recommended, fmt.Sprintf("Paper's recommended flags for %s", version)
+	}
+	return AikarFlags(heapMB), "Aikar's flags (https://docs.papermc.io/paper/aikars-flags)"
+}
+
+// Script is what goes into start.sh.
+type Script struct {
+	Java    string // launcher, a path or a name in PATH
+	JarName string
+	HeapMB  int
+	// TotalMB is the memory the heap was sized for, mentioned in the script's comments;
+	// 0 if unknown.
+	TotalMB int
+	// Flags go between the heap size and -jar. FlagsFrom says where they came from, for
+	// the script's comments, e.g. "Paper's recommended flags for 26.1.2".
+	Flags     []string
+	FlagsFrom string
+	// Restart wraps the server in a loop that starts it again, after RestartDelay,
+	// whenever it exits with an error. A clean "stop" exits 0 and ends the loop.
+	Restart      bool
+	RestartDelay time.Duration
+}
+
+// Render returns the script. It carries no timestamp, so rendering the same Script
+// twice gives the same bytes and a regeneration diffs only what changed.
+func (s Script) Render() []byte {
+	var b bytes.Buffer
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Starts the Paper server in this directory. Written by paper-mc-tui; run\n")
+	b.WriteString("# \"paper-mc-tui start-script\" to regenerate it and see what changed.\n#\n")
+	if s.TotalMB > 0 {
+		fmt.Fprintf(&b, "# Heap: %s, sized for %.1f GB of memory. Change MEMORY to resize it.\n", FormatSize(s.HeapMB), float64(s.TotalMB)/1024)
+	} else {
+		fmt.Fprintf(&b, "# Heap: %s. Change MEMORY to resize it.\n", FormatSize(s.HeapMB))
+	}
+	if s.FlagsFrom != "" {
+		fmt.Fprintf(&b, "# Flags: %s.\n", s.FlagsFrom)
+	}
+	b.WriteString("\ncd \"$(dirname \"$0\")\" || exit 1\n\n")
+	fmt.Fprintf(&b, "MEMORY=%s\n\n", FormatSize(s.HeapMB))
+
+	indent := ""
+	if s.Restart {
+		delay := s.RestartDelay
+		if delay <= 0 {
+			delay = DefaultRestartDelay
+		}
+		b.WriteString("# Start the server again after a crash; \"stop\" exits cleanly and ends the loop.\n")
+		b.WriteString("while :; do\n")
+		indent = "\t"
+		writeCommand(&b, indent, "", s)
+		b.WriteString("\tstatus=$?\n")
+		b.WriteString("\t[ \"$status\" -eq 0 ] && break\n")
+		secs := max(int(delay.Round(time.Second)/time.Second), 1)
+		fmt.Fprintf(&b, "\techo \"Server exited with status $status; restarting in %d seconds (Ctrl+C to give up).\"\n", secs)
+		fmt.Fprintf(&b, "\tsleep %d\n", secs)
+		b.WriteString("done\n")
+		return b.Bytes()
+	}
+	writeCommand(&b, indent, "exec ", s)
+	return b.Bytes()
+}
+
+// writeCommand writes the java command line, one flag per line so diffs stay readable.
+func writeCommand(b *bytes.Buffer, indent, prefix string, s Script) {
+	fmt.Fprintf(b, "%s%s%s -Xms$MEMORY -Xmx$MEMORY \\\n", indent, prefix, quote(s.Java))
+	for _, f := range s.Flags {
+		fmt.Fprintf(b, "%s\t%s \\\n", indent, quote(f))
+	}
+	fmt.Fprintf(b, "%s\t-jar %s nogui\n", indent, quote(s.JarName))
+}
+
+// quote makes s one word for sh, quoting it only if it needs to be.
+func quote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_=+:,./@%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Change is start.sh as it is on disk and as it would be written.
+type Change struct{ genfile.Change }
+
+// Prepare compares script with the start.sh in dir.
+func Prepare(dir string, script []byte) (Change, error) {
+	c, err := genfile.Prepare(filepath.Join(dir, FileName), script, 0o755)
+	if err != nil {
+		return Change{}, fmt.Errorf("startscript: %w", err)
+	}
+	return Change{c}, nil
+}
+
+// Write replaces start.sh atomically and makes it executable.
+func (c Change) Write() error {
+	if err := c.Change.Write(); err != nil {
+		return fmt.Errorf("startscript: %w", err)
+	}
+	return nil
+}
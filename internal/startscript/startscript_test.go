@@ -0,0 +1,172 @@
+package startscript
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseMemInfo(t *testing.T) {
+	const meminfo = "MemTotal:        8052620 kB\nMemFree:          312028 kB\nMemAvailable:    5123456 kB\nBuffers:  1 kB\n"
+	m, err := ParseMemInfo(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TotalMB != 7863 || m.AvailableMB != 5003 {
+		t.Errorf("ParseMemInfo = %+v, want 7863 MB total, 5003 available", m)
+	}
+	if _, err := ParseMemInfo(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Error("ParseMemInfo accepted meminfo without MemTotal")
+	}
+}
+
+func TestSuggestHeap(t *testing.T) {
+	for total, want := range map[int]int{
+		1024:     512,  // a tiny VPS still gets a heap Paper can start with
+		2048:     1024, // 1 GB left to the system
+		4096:     3072, // 1 GB left
+		7863:     5632, // a quarter left, rounded down to 512 MB
+		16 << 10: 12 << 10,
+		64 << 10: 16 << 10, // capped
+	} {
+		if got := SuggestHeap(total); got != want {
+			t.Errorf("SuggestHeap(%d) = %d, want %d", total, got, want)
+		}
+	}
+}
+
+func TestCgroupLimit(t *testing.T) {
+	for in, want := range map[string]int{"max\n": 0, "4294967296\n": 4096, "": 0, "junk": 0} {
+		if got := ParseCgroupLimit([]byte(in)); got != want {
+			t.Errorf("ParseCgroupLimit(%q) = %d, want %d", in, got, want)
+		}
+	}
+	m := Memory{TotalMB: 64 << 10, LimitMB: 4 << 10}
+	if got := SuggestHeap(m.UsableMB()); got != 3<<10 {
+		t.Errorf("heap in a 4 GB container on a 64 GB host = %d, want 3072", got)
+	}
+	if m := (Memory{TotalMB: 8 << 10, LimitMB: 16 << 10}); m.UsableMB() != 8<<10 {
+		t.Errorf("a limit above the machine's memory counts: %d", m.UsableMB())
+	}
+}
+
+func TestSizes(t *testing.T) {
+	for in, want := range map[string]int{"6G": 6144, "6g": 6144, "1536M": 1536, " 2G ": 2048} {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"6", "6GB", "-1G", "256M", ""} {
+		if _, err := ParseSize(in); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("ParseSize(%q) = %v, want ErrInvalidSize", in, err)
+		}
+	}
+	if FormatSize(6144) != "6G" || FormatSize(5632) != "5632M" {
+		t.Errorf("FormatSize = %s, %s", FormatSize(6144), FormatSize(5632))
+	}
+}
+
+func TestAikarFlags(t *testing.T) {
+	small, large := strings.Join(AikarFlags(6<<10), " "), strings.Join(AikarFlags(14<<10), " ")
+	if !strings.Contains(small, "-XX:G1HeapRegionSize=8M") || !strings.Contains(large, "-XX:G1HeapRegionSize=16M") {
+		t.Errorf("region sizes wrong:\n%s\n%s", small, large)
+	}
+	if flags, from := ChooseFlags("26.1.2", []string{"-XX:+UseZGC"}, 6<<10); len(flags) != 1 || !strings.Contains(from, "26.1.2") {
+		t.Errorf("ChooseFlags with a recommendation = %q, %q", flags, from)
+	}
+	if flags, from := ChooseFlags("1.20.4", nil, 6<<10); strings.Join(flags, " ") != small || !strings.HasPrefix(from, "Aikar") {
+		t.Errorf("ChooseFlags without a recommendation = %q, %q", flags, from)
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := Script{
+		Java:      "/opt/jdk 25/bin/java",
+		JarName:   "paper.jar",
+		HeapMB:    6 << 10,
+		TotalMB:   8 << 10,
+		Flags:     []string{"-XX:+UseG1GC", "-Dfile.encoding=UTF-8"},
+		FlagsFrom: "Paper's recommended flags for 26.1.2",
+	}
+	got := string(s.Render())
+	for _, want := range []string{
+		"MEMORY=6G\n",
+		"# Heap: 6G, sized for 8.0 GB of memory.",
+		"# Flags: Paper's recommended flags for 26.1.2.",
+		"exec '/opt/jdk 25/bin/java' -Xms$MEMORY -Xmx$MEMORY \\\n\t-XX:+UseG1GC \\\n\t-Dfile.encoding=UTF-8 \\\n\t-jar paper.jar nogui\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("script missing %q:\n%s", want, got)
+		}
+	}
+	if string(s.Render()) != got {
+		t.Error("rendering twice gave different scripts")
+	}
+
+	s.Restart = true
+	got = string(s.Render())
+	if !strings.Contains(got, "while :; do\n\t'/opt/jdk 25/bin/java'") || !strings.Contains(got, "sleep 10\ndone\n") || strings.Contains(got, "exec ") {
+		t.Errorf("restart loop wrong:\n%s", got)
+	}
+}
+
+// TestRestartLoop runs the script with a fake java that crashes once and then stops
+// cleanly.
+func TestRestartLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	dir := t.TempDir()
+	java := filepath.Join(dir, "java")
+	fake := "#!/bin/sh\necho \"$@\" >> runs\nif [ ! -f crashed ]; then touch crashed; exit 1; fi\nexit 0\n"
+	if err := os.WriteFile(java, []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := Script{Java: java, JarName: "paper.jar", HeapMB: 1024, Flags: []string{"-XX:+UseG1GC"}, Restart: true, RestartDelay: 1}
+	c, err := Prepare(dir, s.Render())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write(); err != nil {
+		t.Fatal(err)
+	}
+	// A RestartDelay under a second still waits a whole second.
+	if out, err := exec.Command(filepath.Join(dir, FileName)).CombinedOutput(); err != nil {
+		t.Fatalf("start.sh: %v\n%s", err, out)
+	}
+	runs, _ := os.ReadFile(filepath.Join(dir, "runs"))
+	want := "-Xms1G -Xmx1G -XX:+UseG1GC -jar paper.jar nogui\n"
+	if string(runs) != want+want {
+		t.Errorf("java ran with:\n%s\nwant twice:\n%s", runs, want)
+	}
+}
+
+func TestChange(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Prepare(dir, []byte("#!/bin/sh\necho one\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Exists() || !c.Changed() || !strings.Contains(c.Diff(), "--- /dev/null") {
+		t.Errorf("new file: exists %v, changed %v, diff:\n%s", c.Exists(), c.Changed(), c.Diff())
+	}
+	if err := c.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(c.Path); err != nil || fi.Mode().Perm()&0o100 == 0 {
+		t.Errorf("start.sh not executable: %v, %v", fi, err)
+	}
+
+	c, _ = Prepare(dir, []byte("#!/bin/sh\necho one\n"))
+	if c.Changed() || c.Diff() != "" {
+		t.Error("an identical script counts as changed")
+	}
+	c, _ = Prepare(dir, []byte("#!/bin/sh\necho two\n"))
+	if d := c.Diff(); !strings.Contains(d, "-echo one\n+echo two\n") {
+		t.Errorf("diff:\n%s", d)
+	}
+}
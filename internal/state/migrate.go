@@ -0,0 +1,284 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+)
+
+// CurrentSchema is the state.json schema this build writes.
+//
+//	1: the original, unversioned file (no "schema" field).
+//	2: adds "schema".
+//	3: adds "history", seeded with the current install.
+const CurrentSchema = 3
+
+// legacyFileName is the bare-string version file written by earlier releases of the tool.
+var legacyFileName = filepath.Join("logs", "paper-ver.txt")
+
+var (
+	// ErrSchemaTooNew means state.json was written by a newer build of the tool.
+	ErrSchemaTooNew = errors.New("state: state.json schema is newer than this build supports")
+	// ErrLegacyFormat means logs/paper-ver.txt held something we could not interpret.
+	ErrLegacyFormat = errors.New("state: unrecognized logs/paper-ver.txt contents")
+)
+
+// migrations[n] upgrades a schema-n document to schema n+1, in place. Add an entry here
+// (and bump CurrentSchema) whenever the format changes.
+var migrations = map[int]func(doc map[string]json.RawMessage) error{
+	// v1 had the same fields as v2; the version number itself is all that's new.
+	1: func(map[string]json.RawMessage) error { return nil },
+	2: func(doc map[string]json.RawMessage) error {
+		if _, ok := doc["history"]; ok {
+			return nil
+		}
+		var cur State
+		if err := remarshal(doc, &cur); err != nil {
+			return err
+		}
+		if cur.Build == 0 {
+			return nil
+		}
+		raw, err := json.Marshal([]Record{cur.Record()})
+		if err != nil {
+			return err
+		}
+		doc["history"] = raw
+		return nil
+	},
+}
+
+// remarshal decodes a raw document into out.
+func remarshal(doc map[string]json.RawMessage, out any) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Migration reports what Migrate did. The zero value means nothing needed migrating.
+type Migration struct {
+	From, To int    // schema versions; From is 0 for a legacy import
+	Source   string // the file that was read
+	Backup   string // where the original was preserved, if it was rewritten
+}
+
+// String summarizes the migration for display and the activity log.
+func (m Migration) String() string {
+	switch {
+	case m.To == 0:
+		return ""
+	case m.From == 0:
+		return fmt.Sprintf("imported %s into state.json (schema %d); the original was left in place", m.Source, m.To)
+	default:
+		return fmt.Sprintf("migrated state.json from schema %d to %d; the original was kept as %s", m.From, m.To, filepath.Base(m.Backup))
+	}
+}
+
+// Migrate brings the directory's state up to CurrentSchema. It upgrades an older
+// state.json (keeping a copy of the original as state.json.v<N>.bak, never replacing
+// an earlier one), or, if there is no state.json, imports a legacy logs/paper-ver.txt
+// (which is left untouched). What it did is recorded in the activity log. Call it once
+// at startup.
+func (s *Store) Migrate() (Migration, error) {
+	m, err := s.pendingMigration()
+	if err != nil || m.To == 0 {
+		return m, err
+	}
+
+	l, err := lock.Acquire(s.dir)
+	if err != nil {
+		return Migration{}, err
+	}
+	defer l.Release()
+
+	if m.From == 0 {
+		st, err := s.readLegacy()
+		if err != nil {
+			return Migration{}, err
+		}
+		if err := s.Save(WithInstall(State{}, st)); err != nil {
+			return Migration{}, err
+		}
+	} else {
+		data, err := os.ReadFile(s.statePath)
+		if err != nil {
+			return Migration{}, fmt.Errorf("state: read %s: %w", s.statePath, err)
+		}
+		st, _, err := upgrade(data)
+		if err != nil {
+			return Migration{}, fmt.Errorf("state: parse %s: %w", s.statePath, err)
+		}
+		if err := writeBackup(m.Backup, data); err != nil {
+			return Migration{}, fmt.Errorf("state: preserve original: %w", err)
+		}
+		if err := s.Save(st); err != nil {
+			return Migration{}, err
+		}
+	}
+
+	s.log.Info(m.String(), logging.KeyEvent, logging.EventMigrate, "from", m.From, "to", m.To, "source", m.Source)
+	return m, nil
+}
+
+// pendingMigration works out what Migrate would do without changing anything.
+func (s *Store) pendingMigration() (Migration, error) {
+	data, err := os.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		legacy := filepath.Join(s.dir, legacyFileName)
+		if _, err := os.Stat(legacy); err != nil {
+			return Migration{}, nil // fresh directory
+		}
+		return Migration{From: 0, To: CurrentSchema, Source: legacy}, nil
+	}
+	if err != nil {
+		return Migration{}, fmt.Errorf("state: read %s: %w", s.statePath, err)
+	}
+
+	_, from, err := upgrade(data)
+	if err != nil {
+		return Migration{}, fmt.Errorf("state: parse %s: %w", s.statePath, err)
+	}
+	if from == CurrentSchema {
+		return Migration{}, nil
+	}
+	backup, err := s.backupPath(from, data)
+	if err != nil {
+		return Migration{}, err
+	}
+	return Migration{
+		From:   from,
+		To:     CurrentSchema,
+		Source: s.statePath,
+		Backup: backup,
+	}, nil
+}
+
+// backupPath picks where Migrate keeps the schema-from original, data:
+// state.json.v<N>.bak, or state.json.v<N>.<i>.bak if that holds something else, say
+// from a downgrade and a second upgrade. An existing copy of data is reused rather
+// than duplicated.
+func (s *Store) backupPath(from int, data []byte) (string, error) {
+	for i := 1; ; i++ {
+		path := fmt.Sprintf("%s.v%d.bak", s.statePath, from)
+		if i > 1 {
+			path = fmt.Sprintf("%s.v%d.%d.bak", s.statePath, from, i)
+		}
+		old, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			return path, nil
+		case err != nil:
+			return "", fmt.Errorf("state: read %s: %w", path, err)
+		case bytes.Equal(old, data):
+			return path, nil
+		}
+	}
+}
+
+// writeBackup writes data to path unless path already holds it. It never replaces a
+// different file, which would lose an earlier original.
+func writeBackup(path string, data []byte) error {
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// upgrade decodes a state.json of any supported schema, running the migration chain
+// from its version up to CurrentSchema. It returns the State and the version it read.
+func upgrade(data []byte) (State, int, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return State{}, 0, err
+	}
+
+	from := 1 // files written before versioning have no "schema" field
+	if raw, ok := doc["schema"]; ok {
+		if err := json.Unmarshal(raw, &from); err != nil {
+			return State{}, 0, fmt.Errorf("schema: %w", err)
+		}
+	}
+	if from > CurrentSchema {
+		return State{}, 0, fmt.Errorf("%w (file has %d, this build supports up to %d)", ErrSchemaTooNew, from, CurrentSchema)
+	}
+
+	for v := from; v < CurrentSchema; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return State{}, 0, fmt.Errorf("state: no migration from schema %d", v)
+		}
+		if err := migrate(doc); err != nil {
+			return State{}, 0, fmt.Errorf("state: migrate schema %d: %w", v, err)
+		}
+	}
+
+	var d document
+	if err := remarshal(doc, &d); err != nil {
+		return State{}, 0, err
+	}
+	return d.State, from, nil
+}
+
+// readLegacy turns logs/paper-ver.txt into a State. The file's modification time
+// stands in for the install time, which the old format did not record.
+func (s *Store) readLegacy() (State, error) {
+	path := filepath.Join(s.dir, legacyFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, fmt.Errorf("state: read %s: %w", path, err)
+	}
+	st, err := parseLegacy(string(data))
+	if err != nil {
+		return State{}, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		st.InstalledAt = info.ModTime()
+	}
+	return st, nil
+}
+
+var (
+	legacyJarRe   = regexp.MustCompile(`^paper-(.+)-(\d+)\.jar$`)
+	legacyBuildRe = regexp.MustCompile(`^(\d+)$`)
+)
+
+// parseLegacy interprets the old paper-ver.txt contents: the last downloaded jar's file
+// name (e.g. "paper-1.21.4-130.jar"), or, in the earliest releases, just the build.
+func parseLegacy(s string) (State, error) {
+	s = strings.TrimSpace(s)
+	if m := legacyJarRe.FindStringSubmatch(s); m != nil {
+		build, _ := strconv.Atoi(m[2])
+		return State{Version: m[1], Build: build, JarName: s}, nil
+	}
+	if m := legacyBuildRe.FindStringSubmatch(s); m != nil {
+		build, _ := strconv.Atoi(m[1])
+		if build > 0 {
+			return State{Build: build}, nil
+		}
+	}
+	return State{}, fmt.Errorf("%w: %q", ErrLegacyFormat, s)
+}
@@ -0,0 +1,181 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// v1State is a state.json as written before schema versioning.
+const v1State = `{
+  "version": "1.21.10",
+  "build": 130,
+  "jar_name": "paper-1.21.10-130.jar",
+  "sha256": "aaaa",
+  "installed_at": "2025-11-02T09:00:00Z"
+}
+`
+
+func TestLoadUpgradesV1InMemory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, stateFileName)
+	if err := os.WriteFile(path, []byte(v1State), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewStore(dir)
+
+	st, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if st.Version != "1.21.10" || st.Build != 130 || st.SHA256 != "aaaa" {
+		t.Errorf("unexpected state: %+v", st)
+	}
+	if data, _ := os.ReadFile(path); string(data) != v1State {
+		t.Error("Load must not rewrite state.json")
+	}
+}
+
+func TestMigrateV1KeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, stateFileName)
+	if err := os.WriteFile(path, []byte(v1State), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewStore(dir)
+
+	m, err := s.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if m.From != 1 || m.To != CurrentSchema {
+		t.Errorf("migration = %+v, want 1 -> %d", m, CurrentSchema)
+	}
+	if orig, _ := os.ReadFile(path + ".v1.bak"); string(orig) != v1State {
+		t.Errorf("original not preserved, got:\n%s", orig)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), fmt.Sprintf(`"schema": %d`, CurrentSchema)) {
+		t.Errorf("state.json not upgraded:\n%s", data)
+	}
+	st, _ := s.Load()
+	if len(st.History) != 1 || st.History[0].Build != 130 {
+		t.Errorf("history not seeded from the current install: %+v", st.History)
+	}
+	if log, _ := os.ReadFile(filepath.Join(dir, LogFileName)); !strings.Contains(string(log), fmt.Sprintf("migrated state.json from schema 1 to %d", CurrentSchema)) {
+		t.Errorf("migration not logged:\n%s", log)
+	}
+
+	// Second run is a no-op.
+	if m, err := s.Migrate(); err != nil || m.To != 0 {
+		t.Errorf("second Migrate = %+v, %v; want nothing to do", m, err)
+	}
+}
+
+func TestMigrateKeepsEarlierBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, stateFileName)
+	os.WriteFile(path+".v1.bak", []byte("earlier original\n"), 0o644)
+	if err := os.WriteFile(path, []byte(v1State), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewStore(dir)
+
+	m, err := s.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if m.Backup != path+".v1.2.bak" {
+		t.Errorf("backup = %s, want state.json.v1.2.bak", m.Backup)
+	}
+	if orig, _ := os.ReadFile(path + ".v1.bak"); string(orig) != "earlier original\n" {
+		t.Errorf("earlier backup overwritten with:\n%s", orig)
+	}
+	if orig, _ := os.ReadFile(m.Backup); string(orig) != v1State {
+		t.Errorf("original not preserved, got:\n%s", orig)
+	}
+}
+
+func TestMigrateImportsLegacy(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "logs", "paper-ver.txt")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("paper-1.21.4-130.jar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewStore(dir)
+
+	m, err := s.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if m.From != 0 || m.To != CurrentSchema {
+		t.Errorf("migration = %+v, want a legacy import", m)
+	}
+	st, _ := s.Load()
+	if st.Version != "1.21.4" || st.Build != 130 || st.JarName != "paper-1.21.4-130.jar" {
+		t.Errorf("unexpected imported state: %+v", st)
+	}
+	if st.InstalledAt.IsZero() {
+		t.Error("expected InstalledAt from the legacy file's mtime")
+	}
+	if len(st.History) != 1 {
+		t.Errorf("imported install should start the history, got %+v", st.History)
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		t.Errorf("legacy file must be left in place: %v", err)
+	}
+}
+
+func TestMigrateFreshDirIsNoop(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewStore(dir)
+	if m, err := s.Migrate(); err != nil || m.To != 0 {
+		t.Errorf("Migrate = %+v, %v; want nothing to do", m, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateFileName)); !os.IsNotExist(err) {
+		t.Error("a fresh directory should not get a state.json")
+	}
+}
+
+func TestLoadRejectsNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, stateFileName), []byte(`{"schema": 99, "build": 1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewStore(dir)
+	if _, err := s.Load(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Load err = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	cases := []struct {
+		in      string
+		version string
+		build   int
+	}{
+		{"paper-1.21.4-130.jar", "1.21.4", 130},
+		{"paper-1.21.11-pre5-12.jar\n", "1.21.11-pre5", 12},
+		{" 455 ", "", 455},
+	}
+	for _, tc := range cases {
+		st, err := parseLegacy(tc.in)
+		if err != nil {
+			t.Errorf("parseLegacy(%q): %v", tc.in, err)
+			continue
+		}
+		if st.Version != tc.version || st.Build != tc.build {
+			t.Errorf("parseLegacy(%q) = %s/%d, want %s/%d", tc.in, st.Version, st.Build, tc.version, tc.build)
+		}
+	}
+	for _, bad := range []string{"", "latest", "0"} {
+		if _, err := parseLegacy(bad); !errors.Is(err, ErrLegacyFormat) {
+			t.Errorf("parseLegacy(%q) err = %v, want ErrLegacyFormat", bad, err)
+		}
+	}
+}
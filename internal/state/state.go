@@ -1,19 +1,25 @@
-// Package state persists what build is currently installed and keeps a human-readable
+// Package state persists what build is currently installed and owns the structured
 // activity log, both alongside the jar in the target directory. It replaces the old
-// bare-string logs/paper-ver.txt with a structured, atomically-written JSON file.
+// bare-string logs/paper-ver.txt with a structured, atomically-written JSON file that
+// carries a schema version; see migrate.go for how older formats are upgraded.
 package state
 
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
 )
 
 const (
 	stateFileName = "state.json"
-	logFileName   = "paper-mc.log"
+	// LogFileName is the activity log's name within the target directory.
+	LogFileName = "paper-mc.log"
 )
 
 // State records the build last installed by this tool.
@@ -23,29 +29,96 @@ type State struct {
 	JarName     string    `json:"jar_name"`     // e.g. "paper-26.1.2-70.jar"
 	SHA256      string    `json:"sha256"`       // verified checksum of the jar
 	InstalledAt time.Time `json:"installed_at"` // when it was downloaded
+
+	// History lists past installs, oldest first; the last entry is this install.
+	History []Record `json:"history,omitempty"`
+}
+
+// Record is one install in State.History.
+type Record struct {
+	Version     string    `json:"version"`
+	Build       int       `json:"build"`
+	JarName     string    `json:"jar_name"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// MaxHistory bounds State.History; older entries are dropped first.
+const MaxHistory = 50
+
+// Record returns the install described by st as a history entry.
+func (st State) Record() Record {
+	return Record{
+		Version:     st.Version,
+		Build:       st.Build,
+		JarName:     st.JarName,
+		SHA256:      st.SHA256,
+		InstalledAt: st.InstalledAt,
+	}
 }
 
-// Store reads and writes State and the activity log in a directory.
+// WithInstall returns next with its history set to prev's plus next itself, trimmed to
+// MaxHistory.
+func WithInstall(prev, next State) State {
+	history := append(slices.Clone(prev.History), next.Record())
+	if len(history) > MaxHistory {
+		history = history[len(history)-MaxHistory:]
+	}
+	next.History = history
+	return next
+}
+
+// document is the on-disk form of state.json: the State plus its schema version.
+type document struct {
+	Schema int `json:"schema"`
+	State
+}
+
+// Store reads and writes State in a directory and holds the activity logger.
 type Store struct {
 	dir       string
 	statePath string
-	logPath   string
+	log       *slog.Logger
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithLogger sets the activity logger. Without it, the Store logs text records to a
+// rotating paper-mc.log in its directory with the default limits.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Store) {
+		if l != nil {
+			s.log = l
+		}
+	}
 }
 
 // NewStore ensures dir exists and returns a Store rooted there.
-func NewStore(dir string) (*Store, error) {
+func NewStore(dir string, opts ...Option) (*Store, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return nil, fmt.Errorf("state: create dir %s: %w", dir, err)
 	}
-	return &Store{
+	s := &Store{
 		dir:       dir,
 		statePath: filepath.Join(dir, stateFileName),
-		logPath:   filepath.Join(dir, logFileName),
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.log == nil {
+		s.log = logging.New(logging.NewFile(filepath.Join(dir, LogFileName), 0, 0), logging.Options{})
+	}
+	return s, nil
 }
 
+// Logger returns the activity logger, for threading through the client, downloader
+// and service.
+func (s *Store) Logger() *slog.Logger { return s.log }
+
 // Load returns the saved State. A missing file is not an error: it returns the zero
-// State (Build == 0), which represents "nothing installed yet".
+// State (Build == 0), which represents "nothing installed yet". Files in an older schema
+// are upgraded in memory; Migrate persists the upgrade.
 func (s *Store) Load() (State, error) {
 	data, err := os.ReadFile(s.statePath)
 	if err != nil {
@@ -54,8 +127,8 @@ func (s *Store) Load() (State, error) {
 		}
 		return State{}, fmt.Errorf("state: read %s: %w", s.statePath, err)
 	}
-	var st State
-	if err := json.Unmarshal(data, &st); err != nil {
+	st, _, err := upgrade(data)
+	if err != nil {
 		return State{}, fmt.Errorf("state: parse %s: %w", s.statePath, err)
 	}
 	return st, nil
@@ -64,7 +137,7 @@ func (s *Store) Load() (State, error) {
 // Save writes State atomically (temp file + rename) so a crash mid-write never leaves
 // a truncated state.json.
 func (s *Store) Save(st State) error {
-	data, err := json.MarshalIndent(st, "", "  ")
+	data, err := json.MarshalIndent(document{Schema: CurrentSchema, State: st}, "", "  ")
 	if err != nil {
 		return fmt.Errorf("state: marshal: %w", err)
 	}
@@ -89,18 +162,3 @@ func (s *Store) Save(st State) error {
 	}
 	return nil
 }
-
-// Log appends a timestamped line to the activity log. It is best-effort context for a
-// human reading the log later, not the source of truth (that is state.json).
-func (s *Store) Log(format string, args ...any) error {
-	line := fmt.Sprintf("[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
-	f, err := os.OpenFile(s.logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	if err != nil {
-		return fmt.Errorf("state: open log: %w", err)
-	}
-	defer f.Close()
-	if _, err := f.WriteString(line); err != nil {
-		return fmt.Errorf("state: write log: %w", err)
-	}
-	return nil
-}
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -66,24 +67,46 @@ func TestSaveLeavesNoTempFiles(t *testing.T) {
 	}
 }
 
-func TestLogAppends(t *testing.T) {
+func TestDefaultLoggerAppends(t *testing.T) {
 	dir := t.TempDir()
 	s, _ := NewStore(dir)
-	if err := s.Log("downloaded %s", "paper-1.21.10-130.jar"); err != nil {
-		t.Fatalf("Log: %v", err)
-	}
-	if err := s.Log("second line"); err != nil {
-		t.Fatalf("Log: %v", err)
-	}
-	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	s.Logger().Info("downloaded", "jar", "paper-1.21.10-130.jar")
+	s.Logger().Info("second line")
+
+	data, err := os.ReadFile(filepath.Join(dir, LogFileName))
 	if err != nil {
 		t.Fatalf("read log: %v", err)
 	}
 	got := string(data)
-	if !strings.Contains(got, "paper-1.21.10-130.jar") || !strings.Contains(got, "second line") {
+	if !strings.Contains(got, "jar=paper-1.21.10-130.jar") || !strings.Contains(got, `msg="second line"`) {
 		t.Errorf("log missing expected content:\n%s", got)
 	}
 	if n := strings.Count(strings.TrimSpace(got), "\n"); n != 1 { // 2 lines => 1 interior newline
 		t.Errorf("expected 2 log lines, got content:\n%s", got)
 	}
 }
+
+func TestSaveWritesSchema(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := NewStore(dir)
+	if err := s.Save(State{Version: "26.1.2", Build: 70}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, stateFileName))
+	if !strings.Contains(string(data), fmt.Sprintf(`"schema": %d`, CurrentSchema)) {
+		t.Errorf("state.json missing schema version:\n%s", data)
+	}
+}
+
+func TestWithInstallAppendsAndTrims(t *testing.T) {
+	var st State
+	for b := 1; b <= MaxHistory+5; b++ {
+		st = WithInstall(st, State{Version: "26.1.2", Build: b})
+	}
+	if len(st.History) != MaxHistory {
+		t.Fatalf("history length = %d, want %d", len(st.History), MaxHistory)
+	}
+	if first, last := st.History[0].Build, st.History[MaxHistory-1].Build; first != 6 || last != MaxHistory+5 {
+		t.Errorf("history spans builds %d..%d, want 6..%d", first, last, MaxHistory+5)
+	}
+}
@@ -0,0 +1,84 @@
+package supervisor
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultConsoleLines is how many lines of server output a Console keeps.
+const DefaultConsoleLines = 5000
+
+// Console keeps the most recent lines a server wrote to stdout and stderr, for a UI to
+// poll with Since. It is an io.Writer; partial lines are held until their newline.
+type Console struct {
+	mu      sync.Mutex
+	lines   []string // ring buffer of the last cap(lines) lines
+	start   int      // index in lines of the oldest line
+	seq     uint64   // lines written since the Console was created
+	partial strings.Builder
+}
+
+// NewConsole returns a Console keeping the last n lines.
+func NewConsole(n int) *Console {
+	return &Console{lines: make([]string, 0, max(n, 1))}
+}
+
+// Write records p, split into lines.
+func (c *Console) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rest := string(p)
+	for {
+		line, after, ok := strings.Cut(rest, "\n")
+		if !ok {
+			c.partial.WriteString(rest)
+			return len(p), nil
+		}
+		c.partial.WriteString(line)
+		c.add(strings.TrimSuffix(c.partial.String(), "\r"))
+		c.partial.Reset()
+		rest = after
+	}
+}
+
+// Note records a line of the tool's own, e.g. that the server started.
+func (c *Console) Note(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+	c.add(line)
+}
+
+// flush records a pending partial line, e.g. a prompt, before a note or at exit.
+func (c *Console) flush() {
+	if c.partial.Len() > 0 {
+		c.add(c.partial.String())
+		c.partial.Reset()
+	}
+}
+
+func (c *Console) add(line string) {
+	if len(c.lines) < cap(c.lines) {
+		c.lines = append(c.lines, line)
+	} else {
+		c.lines[c.start] = line
+		c.start = (c.start + 1) % len(c.lines)
+	}
+	c.seq++
+}
+
+// Since returns the lines written after the first seq lines and the sequence number to
+// pass next time. Lines that have already rotated out of the buffer are skipped.
+func (c *Console) Since(seq uint64) (lines []string, next uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if seq >= c.seq {
+		return nil, c.seq
+	}
+	n := int(min(c.seq-seq, uint64(len(c.lines))))
+	lines = make([]string, 0, n)
+	for i := len(c.lines) - n; i < len(c.lines); i++ {
+		lines = append(lines, c.lines[(c.start+i)%len(c.lines)])
+	}
+	return lines, c.seq
+}
@@ -0,0 +1,28 @@
+package supervisor
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestConsole(t *testing.T) {
+	c := NewConsole(3)
+	fmt.Fprint(c, "[12:00:00 INFO]: Starting\r\n[12:00:01 WA")
+	if lines, next := c.Since(0); !slices.Equal(lines, []string{"[12:00:00 INFO]: Starting"}) || next != 1 {
+		t.Fatalf("Since(0) = %q, %d", lines, next)
+	}
+	fmt.Fprint(c, "RN]: Slow\n> ")
+	c.Note("note")
+	if lines, next := c.Since(1); !slices.Equal(lines, []string{"[12:00:01 WARN]: Slow", "> ", "note"}) || next != 4 {
+		t.Fatalf("Since(1) = %q, %d", lines, next)
+	}
+
+	// The first line has rotated out; a reader that fell behind gets what is left.
+	if lines, _ := c.Since(0); !slices.Equal(lines, []string{"[12:00:01 WARN]: Slow", "> ", "note"}) {
+		t.Errorf("Since(0) after rotation = %q", lines)
+	}
+	if lines, next := c.Since(4); lines != nil || next != 4 {
+		t.Errorf("Since(4) = %q, %d; want nothing new", lines, next)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// sysProcAttr starts the server in its own process group, so a Ctrl+C meant for the
+// TUI does not reach java.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// alive reports whether a process with the given PID exists. Signal 0 performs the
+// existence and permission checks without delivering anything; EPERM means the process
+// exists but belongs to another user.
+func alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// isServer reports whether pid is a live java running jar from dir. A pid file left
+// by a crash or a reboot can name a process that has nothing to do with the server,
+// which must never be signalled, so the process's command line and working directory
+// in /proc have to match. Without /proc, e.g. on macOS, existence is all there is to
+// go on.
+func isServer(pid int, dir, jar string) bool {
+	if !alive(pid) {
+		return false
+	}
+	proc := filepath.Join("/proc", strconv.Itoa(pid))
+	cmdline, err := os.ReadFile(filepath.Join(proc, "cmdline"))
+	if err != nil {
+		_, serr := os.Stat("/proc/self")
+		return serr != nil
+	}
+	if !runsJar(strings.Split(string(cmdline), "\x00"), jar) {
+		return false
+	}
+	cwd, err := os.Readlink(filepath.Join(proc, "cwd"))
+	if err != nil {
+		// Another user's process hides its working directory; the command line has to do.
+		return true
+	}
+	return sameDir(cwd, dir)
+}
+
+// terminate asks the process to exit. The JVM runs its shutdown hooks on SIGTERM, and
+// Paper saves the worlds in one.
+func terminate(pid int) error { return syscall.Kill(pid, syscall.SIGTERM) }
+
+// kill ends the process without letting it clean up.
+func kill(pid int) error { return syscall.Kill(pid, syscall.SIGKILL) }
@@ -0,0 +1,47 @@
+//go:build windows
+
+package supervisor
+
+import (
+	"os"
+	"syscall"
+)
+
+// sysProcAttr starts the server in its own process group, so a Ctrl+C meant for the
+// TUI does not reach java.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// alive reports whether a process with the given PID exists. On Windows FindProcess
+// opens a handle to the process and fails if there is none.
+func alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// isServer reports whether pid is a live process. Windows offers no cheap look at
+// another process's command line, so a live PID is taken to be the server.
+func isServer(pid int, _, _ string) bool { return alive(pid) }
+
+// terminate asks the process to exit. Windows has no signal a console-less java
+// handles gracefully, so this is the same as kill; a server this tool started is
+// stopped through its console first.
+func terminate(pid int) error { return kill(pid) }
+
+// kill ends the process without letting it clean up.
+func kill(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	defer p.Release()
+	return p.Kill()
+}
@@ -0,0 +1,595 @@
+// Package supervisor runs the Paper server itself: it launches `java ... -jar paper.jar
+// nogui` in the target directory, records the server's PID in a pid file so later runs
+// of the tool can find it, and stops it gracefully by typing "stop" on its console,
+// falling back to signals when that does not work in time.
+//
+// The server is started in its own process group and outlives the tool: quitting the
+// TUI leaves it running, and the next run sees it through the pid file. Its output goes
+// to a file in the server directory rather than through the tool, so it is kept after
+// the tool exits and any later run can follow it. Only the Supervisor that started the
+// server holds its stdin; another one sends commands over RCON when server.properties
+// enables it, and otherwise stops the server with a signal, which Paper also handles as
+// a clean shutdown.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/atomicfile"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/rcon"
+)
+
+const (
+	// PIDFileName records the running server's PID in the target directory.
+	PIDFileName = "paper.pid"
+	// ConsoleLogName receives the server's stdout and stderr in the target directory.
+	ConsoleLogName = "paper-console.log"
+	// consoleLogMax is how large the console log may grow before Start moves it aside
+	// to ConsoleLogName + ".1".
+	consoleLogMax = 16 << 20
+	// DefaultJava is the java launcher used unless WithJava says otherwise; it is looked
+	// up in PATH.
+	DefaultJava = "java"
+	// DefaultStopTimeout is how long Stop waits after "stop" (or a signal) before
+	// escalating.
+	DefaultStopTimeout = 60 * time.Second
+	// defaultKillGrace is how long Stop waits for the process to go after each
+	// escalation.
+	defaultKillGrace = 10 * time.Second
+	// pollInterval is how often Stop checks on a server it did not start.
+	pollInterval = 250 * time.Millisecond
+)
+
+var (
+	// ErrRunning means Start found the server already running.
+	ErrRunning = errors.New("supervisor: the server is already running")
+	// ErrNotRunning means Stop or Send found no running server.
+	ErrNotRunning = errors.New("supervisor: the server is not running")
+	// ErrDetached means the server is running but another process started it, so its
+	// console is not ours to type on.
+	ErrDetached = errors.New("supervisor: the server was started by another process; its console is not available")
+)
+
+// Status describes the server as the pid file and the process table see it.
+type Status struct {
+	Running  bool
+	PID      int
+	Since    time.Time // when the pid file was written
+	Attached bool      // started by this Supervisor, so its console is available
+}
+
+func (st Status) String() string {
+	if !st.Running {
+		return "stopped"
+	}
+	if st.Since.IsZero() {
+		return fmt.Sprintf("running (pid %d)", st.PID)
+	}
+	return fmt.Sprintf("running (pid %d, since %s)", st.PID, st.Since.Local().Format("2006-01-02 15:04:05"))
+}
+
+// Supervisor starts and stops the server in one directory. Build one with New.
+type Supervisor struct {
+	dir         string
+	java        string
+	jvmArgs     []string
+	jarName     string
+	stopTimeout time.Duration
+	killGrace   time.Duration
+	output      io.Writer
+	console     *Console
+	tail        *Tail
+	followOnce  sync.Once
+	log         *slog.Logger
+
+	mu   sync.Mutex
+	proc *process // the server, if this Supervisor started it
+}
+
+// process is a server this Supervisor started.
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{} // closed once the process has exited and been reaped
+}
+
+// Option configures a Supervisor.
+type Option func(*Supervisor)
+
+// WithJava sets the java launcher, a path or a name looked up in PATH.
+func WithJava(path string) Option {
+	return func(s *Supervisor) {
+		if path != "" {
+			s.java = path
+		}
+	}
+}
+
+// WithJVMArgs sets the arguments given to java before -jar, e.g. -Xmx4G.
+func WithJVMArgs(args ...string) Option {
+	return func(s *Supervisor) { s.jvmArgs = args }
+}
+
+// WithJarName sets the server jar's name in the target directory.
+func WithJarName(name string) Option {
+	return func(s *Supervisor) {
+		if name != "" {
+			s.jarName = name
+		}
+	}
+}
+
+// WithStopTimeout sets how long Stop waits for a clean shutdown before escalating.
+func WithStopTimeout(d time.Duration) Option {
+	return func(s *Supervisor) {
+		if d > 0 {
+			s.stopTimeout = d
+		}
+	}
+}
+
+// WithOutput also sends the server's stdout and stderr to w, besides the Console, as
+// they are read back from the console log.
+func WithOutput(w io.Writer) Option {
+	return func(s *Supervisor) { s.output = w }
+}
+
+// WithLogger sets where the supervisor logs starts and stops.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Supervisor) {
+		if l != nil {
+			s.log = l
+		}
+	}
+}
+
+// New returns a Supervisor for the server in dir.
+func New(dir string, opts ...Option) *Supervisor {
+	s := &Supervisor{
+		dir:         dir,
+		java:        DefaultJava,
+		jarName:     "paper.jar",
+		stopTimeout: DefaultStopTimeout,
+		killGrace:   defaultKillGrace,
+		console:     NewConsole(DefaultConsoleLines),
+		log:         logging.Discard(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	var out io.Writer = s.console
+	if s.output != nil {
+		out = io.MultiWriter(s.console, s.output)
+	}
+	s.tail = NewTail(s.consoleLogPath(), out)
+	return s
+}
+
+// Console returns the server's output as it writes it to the console log, whoever
+// started it. The first call starts following the log, beginning with its last few
+// lines; from then on the Console keeps up with it, across restarts, for as long as the
+// tool runs.
+func (s *Supervisor) Console() *Console {
+	s.follow()
+	return s.console
+}
+
+// follow starts following the console log, once.
+func (s *Supervisor) follow() {
+	s.followOnce.Do(func() {
+		s.tail.Poll(true)
+		go s.tail.Follow()
+	})
+}
+
+func (s *Supervisor) pidPath() string { return filepath.Join(s.dir, PIDFileName) }
+
+func (s *Supervisor) consoleLogPath() string { return filepath.Join(s.dir, ConsoleLogName) }
+
+// Command returns the command line Start runs.
+func (s *Supervisor) Command() []string {
+	args := append([]string{s.java}, s.jvmArgs...)
+	return append(args, "-jar", s.jarName, "nogui")
+}
+
+// Status reports whether the server is running. A pid file whose process is gone, or
+// is no longer the server, is stale: it is removed and the server reported stopped.
+func (s *Supervisor) Status() (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status()
+}
+
+func (s *Supervisor) status() (Status, error) {
+	if p := s.proc; p != nil {
+		select {
+		case <-p.done:
+			s.proc = nil
+		default:
+			st, err := s.readPID()
+			st.Running, st.PID, st.Attached = true, p.cmd.Process.Pid, true
+			return st, err
+		}
+	}
+	st, err := s.readPID()
+	if err != nil || st.PID == 0 {
+		return Status{}, err
+	}
+	if st.Running = isServer(st.PID, s.dir, s.jarName); !st.Running {
+		s.removeStalePID(st.PID)
+		return Status{}, nil
+	}
+	return st, nil
+}
+
+// removeStalePID removes the pid file if it still names pid, which is not the server.
+func (s *Supervisor) removeStalePID(pid int) {
+	if cur, err := s.readPID(); err != nil || cur.PID != pid {
+		return
+	}
+	if err := os.Remove(s.pidPath()); err == nil {
+		s.log.Info("removed stale pid file", logging.KeyEvent, logging.EventServer, "pid", pid)
+	}
+}
+
+// runsJar reports whether the command line args runs jar with -jar.
+func runsJar(args []string, jar string) bool {
+	for i, a := range args[:max(len(args)-1, 0)] {
+		if a == "-jar" && (args[i+1] == jar || filepath.Base(args[i+1]) == jar) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDir reports whether a and b name the same directory, following symlinks.
+func sameDir(a, b string) bool {
+	resolve := func(p string) string {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		return p
+	}
+	return resolve(a) == resolve(b)
+}
+
+// readPID reads the pid file. A missing file is the zero Status.
+func (s *Supervisor) readPID() (Status, error) {
+	f, err := os.Open(s.pidPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return Status{}, nil
+	}
+	if err != nil {
+		return Status{}, fmt.Errorf("supervisor: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return Status{}, fmt.Errorf("supervisor: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 64))
+	if err != nil {
+		return Status{}, fmt.Errorf("supervisor: read %s: %w", s.pidPath(), err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return Status{}, fmt.Errorf("supervisor: %s does not hold a PID: %q", s.pidPath(), data)
+	}
+	return Status{PID: pid, Since: fi.ModTime()}, nil
+}
+
+// Start launches the server and returns once it is running. It returns ErrRunning if it
+// already is, and a *lock.HeldError if an install or rollback is under way.
+func (s *Supervisor) Start() (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, err := s.status(); err != nil {
+		return Status{}, err
+	} else if st.Running {
+		return st, fmt.Errorf("%w (pid %d)", ErrRunning, st.PID)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, s.jarName)); err != nil {
+		return Status{}, fmt.Errorf("supervisor: start: %w", err)
+	}
+	// Hold the directory lock while launching, so java never starts on a jar an install
+	// or rollback is halfway through replacing.
+	l, err := lock.Acquire(s.dir)
+	if err != nil {
+		return Status{}, err
+	}
+	defer l.Release()
+
+	s.follow()
+	out, err := s.openConsoleLog()
+	if err != nil {
+		return Status{}, err
+	}
+	// The server gets the file itself, so it keeps writing its output there after the
+	// tool exits; one descriptor for both streams keeps them in order.
+	defer out.Close()
+
+	argv := s.Command()
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = s.dir
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.SysProcAttr = sysProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return Status{}, fmt.Errorf("supervisor: start: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return Status{}, fmt.Errorf("supervisor: start %s: %w", argv[0], err)
+	}
+	pid := cmd.Process.Pid
+	if err := s.writePID(pid); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return Status{}, err
+	}
+
+	p := &process{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	s.proc = p
+	go s.reap(p)
+	s.console.Note(fmt.Sprintf("[paper-mc-tui] started %s (pid %d)", strings.Join(argv, " "), pid))
+	s.log.Info("server started", logging.KeyEvent, logging.EventServer, "pid", pid, "command", strings.Join(argv, " "))
+	return s.status()
+}
+
+// openConsoleLog opens the console log for a server about to start, appending to it.
+// The previous server's last lines are copied to the Console first, and a log grown
+// past consoleLogMax is moved aside, so the Tail moves on to a fresh file.
+func (s *Supervisor) openConsoleLog() (*os.File, error) {
+	s.tail.Poll(false)
+	path := s.consoleLogPath()
+	if fi, err := os.Stat(path); err == nil && fi.Size() > consoleLogMax {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return nil, fmt.Errorf("supervisor: start: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("supervisor: start: %w", err)
+	}
+	return f, nil
+}
+
+// reap waits for a started server to exit and removes its pid file.
+func (s *Supervisor) reap(p *process) {
+	err := p.cmd.Wait()
+	pid := p.cmd.Process.Pid
+	// Copy the server's last lines before noting that it exited.
+	s.tail.Poll(false)
+	if st, rerr := s.readPID(); rerr == nil && st.PID == pid {
+		os.Remove(s.pidPath())
+	}
+	log := s.log.With(logging.KeyEvent, logging.EventServer, "pid", pid)
+	if err != nil {
+		log.Warn("server exited", "error", err)
+		s.console.Note(fmt.Sprintf("[paper-mc-tui] server exited: %v", err))
+	} else {
+		log.Info("server exited")
+		s.console.Note("[paper-mc-tui] server exited")
+	}
+	close(p.done)
+}
+
+// writePID records pid atomically, so a reader never sees a partial file.
+func (s *Supervisor) writePID(pid int) error {
+	if err := atomicfile.WriteFile(s.pidPath(), []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("supervisor: write pid file: %w", err)
+	}
+	return nil
+}
+
+// Stop shuts the server down and waits for it to exit. A server this Supervisor started
+// is sent "stop" on its console; one it found through the pid file is sent "stop" over
+// RCON if it can be, and a termination signal otherwise. If it is still running after
+// the stop timeout it is signalled (again) and finally killed. Stop returns
+// ErrNotRunning if there is nothing to stop.
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.status()
+	if err != nil {
+		return err
+	}
+	if !st.Running {
+		return ErrNotRunning
+	}
+	log := s.log.With(logging.KeyEvent, logging.EventServer, "pid", st.PID)
+
+	if p := s.proc; p != nil {
+		log.Info("stopping server", "via", "console")
+		if _, err := io.WriteString(p.stdin, "stop\n"); err != nil {
+			log.Warn("could not write to the server console", "error", err)
+		}
+		if s.wait(st.PID, s.stopTimeout) {
+			return s.stopped(log)
+		}
+		log.Warn("server did not stop in time; signalling it", "timeout", s.stopTimeout.String())
+		if err := terminate(st.PID); err != nil {
+			log.Warn("could not signal the server", "error", err)
+		}
+		if s.wait(st.PID, s.killGrace) {
+			return s.stopped(log)
+		}
+	} else {
+		if err := s.sendRCON("stop"); err == nil {
+			log.Info("stopping server", "via", "rcon")
+		} else {
+			log.Info("stopping server", "via", "signal", "rcon", err.Error())
+			if err := terminate(st.PID); err != nil {
+				return fmt.Errorf("supervisor: stop pid %d: %w", st.PID, err)
+			}
+		}
+		if s.wait(st.PID, s.stopTimeout) {
+			return s.stopped(log)
+		}
+	}
+
+	log.Warn("server did not stop in time; killing it")
+	if err := kill(st.PID); err != nil {
+		return fmt.Errorf("supervisor: kill pid %d: %w", st.PID, err)
+	}
+	if !s.wait(st.PID, s.killGrace) {
+		return fmt.Errorf("supervisor: pid %d survived being killed", st.PID)
+	}
+	return s.stopped(log)
+}
+
+// wait reports whether the server exits within d.
+func (s *Supervisor) wait(pid int, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	if p := s.proc; p != nil {
+		select {
+		case <-p.done:
+			return true
+		case <-timer.C:
+			return false
+		}
+	}
+	tick := time.NewTicker(pollInterval)
+	defer tick.Stop()
+	for {
+		if !isServer(pid, s.dir, s.jarName) {
+			return true
+		}
+		select {
+		case <-tick.C:
+		case <-timer.C:
+			return !isServer(pid, s.dir, s.jarName)
+		}
+	}
+}
+
+// stopped cleans up after the server has exited.
+func (s *Supervisor) stopped(log *slog.Logger) error {
+	if s.proc == nil {
+		// Nobody reaps a server we did not start; its pid file is ours to remove.
+		if err := os.Remove(s.pidPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("supervisor: %w", err)
+		}
+	}
+	s.proc = nil
+	log.Info("server stopped")
+	return nil
+}
+
+// Send types line on the console of the server, as if at its terminal. Only a server
+// this Supervisor started has its console here; any other is sent line over RCON, and
+// Send returns ErrDetached (or ErrNotRunning, if there is no pid file either) when
+// server.properties does not enable RCON. RCON responses are noted on the Console.
+func (s *Supervisor) Send(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line = strings.TrimRight(line, "\r\n")
+	st, err := s.status()
+	switch {
+	case err != nil:
+		return err
+	case !st.Running || !st.Attached:
+		// A server without a pid file may still be running, e.g. under systemd.
+		err := s.sendRCON(line)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, rcon.ErrAuth), errors.Is(err, rcon.ErrCommandTooLong):
+			return err
+		case !st.Running:
+			return ErrNotRunning
+		case errors.Is(err, rcon.ErrDisabled), errors.Is(err, rcon.ErrNoPassword):
+			return ErrDetached
+		}
+		return err
+	}
+	if _, err := io.WriteString(s.proc.stdin, line+"\n"); err != nil {
+		return fmt.Errorf("supervisor: send: %w", err)
+	}
+	s.console.Note("> " + line)
+	s.log.Debug("console command", logging.KeyEvent, logging.EventServer, "pid", st.PID, "command", line)
+	return nil
+}
+
+// sendRCON runs line over RCON with the settings in server.properties and notes it and
+// its response on the Console.
+func (s *Supervisor) sendRCON(line string) error {
+	cfg, err := rcon.FromDir(s.dir)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rcon.DefaultTimeout)
+	defer cancel()
+	out, err := rcon.Run(ctx, cfg, line)
+	if err != nil {
+		s.log.Warn("rcon command failed", logging.KeyEvent, logging.EventRCON, "addr", cfg.Addr, "command", line, logging.Err(err))
+		return err
+	}
+	s.console.Note("> " + line)
+	for l := range strings.Lines(out) {
+		if l = strings.TrimRight(l, "\r\n"); l != "" {
+			s.console.Note(l)
+		}
+	}
+	s.log.Debug("rcon command", logging.KeyEvent, logging.EventRCON, "addr", cfg.Addr, "command", line)
+	return nil
+}
+
+// Restart stops the server if it is running and starts it again.
+func (s *Supervisor) Restart() (Status, error) {
+	if err := s.Stop(); err != nil && !errors.Is(err, ErrNotRunning) {
+		return Status{}, err
+	}
+	return s.Start()
+}
+
+// SplitArgs splits a jvm_args setting into arguments at whitespace. Single or double
+// quotes keep an argument with spaces together, e.g. -Dname="My Server".
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,269 @@
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+)
+
+// fakeJava writes a shell script standing in for java into a fresh server directory
+// (which also gets a paper.jar) and returns the directory and the script's path.
+func fakeJava(t *testing.T, script string) (dir, java string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake java is a shell script")
+	}
+	dir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "paper.jar"), []byte("jar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	java = filepath.Join(t.TempDir(), "java")
+	if err := os.WriteFile(java, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir, java
+}
+
+// waitFile waits for the fake server to create name in dir.
+func waitFile(t *testing.T, dir, name string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return
+		}
+	}
+	t.Fatalf("the fake server never wrote %s", name)
+}
+
+// console is a server that writes its arguments to args.txt and exits on "stop".
+const console = `echo "$@" > args.txt
+while read line; do
+	if [ "$line" = stop ]; then echo stopping > stopped.txt; exit 0; fi
+done
+`
+
+func TestStartStop(t *testing.T) {
+	dir, java := fakeJava(t, console)
+	s := New(dir, WithJava(java), WithJVMArgs("-Xmx1G", "-Dx=a b"))
+
+	st, err := s.Start()
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !st.Running || !st.Attached || st.PID == 0 {
+		t.Fatalf("status after Start = %+v", st)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, PIDFileName))
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		t.Fatalf("pid file = %q, %v", data, err)
+	}
+	if _, err := s.Start(); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Start = %v, want ErrRunning", err)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stopped.txt")); err != nil {
+		t.Error("the server was not stopped through its console")
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args.txt"))
+	if got := strings.TrimSpace(string(args)); got != "-Xmx1G -Dx=a b -jar paper.jar nogui" {
+		t.Errorf("java args = %q", got)
+	}
+	if st, _ := s.Status(); st.Running {
+		t.Errorf("status after Stop = %+v", st)
+	}
+	if _, err := os.Stat(filepath.Join(dir, PIDFileName)); !os.IsNotExist(err) {
+		t.Error("pid file left behind")
+	}
+	if err := s.Stop(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("second Stop = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	dir, java := fakeJava(t, `while read line; do echo "got $line"; [ "$line" = stop ] && exit 0; done
+`)
+	s := New(dir, WithJava(java))
+	if err := s.Send("list"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Send before Start = %v, want ErrNotRunning", err)
+	}
+	if _, err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send("list\n"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := New(dir, WithJava(java)).Send("list"); !errors.Is(err, ErrDetached) {
+		t.Errorf("Send from another supervisor = %v, want ErrDetached", err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, _ := s.Console().Since(0)
+	for _, want := range []string{"> list", "got list", "got stop", "[paper-mc-tui] server exited"} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("console lacks %q:\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+func TestOutputOutlivesSupervisor(t *testing.T) {
+	dir, java := fakeJava(t, `echo "hello from pid $$"; touch ready
+while read line; do echo "got $line"; [ "$line" = stop ] && exit 0; done
+sleep 0.2; echo "still here after stdin closed"; touch done
+`)
+	first := New(dir, WithJava(java))
+	if _, err := first.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFile(t, dir, "ready")
+	// The TUI quits: the server's stdin closes, but it can still write its output.
+	first.proc.stdin.Close()
+	waitFile(t, dir, "done")
+
+	data, _ := os.ReadFile(filepath.Join(dir, ConsoleLogName))
+	if !strings.Contains(string(data), "still here after stdin closed") {
+		t.Errorf("%s = %q", ConsoleLogName, data)
+	}
+	// A later run of the tool shows what the server wrote.
+	lines, _ := New(dir, WithJava(java)).Console().Since(0)
+	if !slices.Contains(lines, "still here after stdin closed") || !strings.HasPrefix(lines[0], "hello from pid") {
+		t.Errorf("console of another supervisor:\n%s", strings.Join(lines, "\n"))
+	}
+	<-first.proc.done
+}
+
+func TestStopFallsBackToKill(t *testing.T) {
+	// Ignores "stop" and SIGTERM alike.
+	dir, java := fakeJava(t, "trap '' TERM\ntouch ready\nwhile :; do sleep 0.05; done\n")
+	s := New(dir, WithJava(java), WithStopTimeout(200*time.Millisecond))
+	s.killGrace = 200 * time.Millisecond
+	if _, err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	waitFile(t, dir, "ready")
+	start := time.Now()
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if st, _ := s.Status(); st.Running {
+		t.Errorf("still running after %v: %+v", time.Since(start), st)
+	}
+}
+
+func TestStopFromAnotherSupervisor(t *testing.T) {
+	dir, java := fakeJava(t, "trap 'echo term > stopped.txt; exit 0' TERM\ntouch ready\nwhile :; do sleep 0.05; done\n")
+	first := New(dir, WithJava(java))
+	st, err := first.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFile(t, dir, "ready")
+
+	// A later run of the tool finds the server through the pid file.
+	second := New(dir, WithJava(java), WithStopTimeout(5*time.Second))
+	got, err := second.Status()
+	if err != nil || !got.Running || got.Attached || got.PID != st.PID {
+		t.Fatalf("Status from another supervisor = %+v, %v; want running pid %d, detached", got, err, st.PID)
+	}
+	if err := second.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stopped.txt")); err != nil {
+		t.Error("the server was not sent SIGTERM")
+	}
+	if got, _ := first.Status(); got.Running {
+		t.Errorf("the starting supervisor still sees it running: %+v", got)
+	}
+}
+
+func TestStartRefusals(t *testing.T) {
+	dir, java := fakeJava(t, console)
+
+	l, err := lock.Acquire(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(dir, WithJava(java))
+	if _, err := s.Start(); !errors.Is(err, lock.ErrLocked) {
+		t.Errorf("Start while locked = %v, want ErrLocked", err)
+	}
+	l.Release()
+
+	os.Remove(filepath.Join(dir, "paper.jar"))
+	if _, err := s.Start(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Start without a jar = %v, want ErrNotExist", err)
+	}
+
+	// A pid file for a process that is gone is not a running server.
+	if err := os.WriteFile(filepath.Join(dir, PIDFileName), []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := s.Status(); err != nil || st.Running {
+		t.Errorf("stale pid file: Status = %+v, %v", st, err)
+	}
+}
+
+func TestStalePIDFileNamesAnotherProcess(t *testing.T) {
+	if _, err := os.Stat("/proc/self/cmdline"); err != nil {
+		t.Skip("needs /proc to tell processes apart")
+	}
+	dir, java := fakeJava(t, console)
+	// After a reboot the recorded PID can belong to anything, e.g. this sleep.
+	other := exec.Command("sleep", "30")
+	if err := other.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { other.Process.Kill(); other.Wait() }()
+	pid := strconv.Itoa(other.Process.Pid)
+	if err := os.WriteFile(filepath.Join(dir, PIDFileName), []byte(pid+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(dir, WithJava(java))
+	if st, err := s.Status(); err != nil || st.Running {
+		t.Errorf("Status with another process's PID = %+v, %v; want stopped", st, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, PIDFileName)); !os.IsNotExist(err) {
+		t.Error("the stale pid file was kept")
+	}
+	os.WriteFile(filepath.Join(dir, PIDFileName), []byte(pid+"\n"), 0o644)
+	if err := s.Stop(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Stop = %v, want ErrNotRunning", err)
+	}
+	if !alive(other.Process.Pid) {
+		t.Error("Stop signalled a process that is not the server")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  -Xms2G   -Xmx2G ", []string{"-Xms2G", "-Xmx2G"}},
+		{`-Dname="My Server" -Dempty='' -Da='b"c'`, []string{"-Dname=My Server", "-Dempty=", `-Da=b"c`}},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.in)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := SplitArgs(`-Dx="open`); err == nil {
+		t.Error("SplitArgs accepted an unterminated quote")
+	}
+}
@@ -0,0 +1,80 @@
+package supervisor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// tailBytes is how much of an existing log a Tail starts with.
+	tailBytes = 16 << 10
+	// tailInterval is how often Follow checks the log for new lines.
+	tailInterval = 500 * time.Millisecond
+)
+
+// Tail copies what is appended to a log file into a writer, usually a Console. When
+// the file is replaced, as Paper does with latest.log each time it starts, Tail notices
+// the new file and reads it from the beginning.
+type Tail struct {
+	path string
+	w    io.Writer
+
+	mu  sync.Mutex
+	fi  os.FileInfo // the file being followed; nil before it first exists
+	off int64
+}
+
+// NewTail returns a Tail of the file at path, writing to w.
+func NewTail(path string, w io.Writer) *Tail {
+	return &Tail{path: path, w: w}
+}
+
+// Follow polls the log forever.
+func (t *Tail) Follow() {
+	tick := time.NewTicker(tailInterval)
+	defer tick.Stop()
+	for range tick.C {
+		t.Poll(false)
+	}
+}
+
+// Poll copies what is new in the log. On the first call, with first set, it copies
+// only the complete lines among the last tailBytes, as context.
+func (t *Tail) Poll(first bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		return // not there yet, or being rotated
+	}
+	if t.fi == nil || !os.SameFile(fi, t.fi) || fi.Size() < t.off {
+		t.fi, t.off = fi, 0
+		if first && fi.Size() > tailBytes {
+			t.off = fi.Size() - tailBytes
+		}
+	}
+	if fi.Size() == t.off {
+		return
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.NewSectionReader(f, t.off, fi.Size()-t.off))
+	if err != nil {
+		return
+	}
+	if first && t.off > 0 {
+		// Start at a line boundary.
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			t.off += int64(i + 1)
+			data = data[i+1:]
+		}
+	}
+	t.off += int64(len(data))
+	t.w.Write(data)
+}
@@ -0,0 +1,44 @@
+package supervisor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTail(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs")
+	os.MkdirAll(logs, 0o755)
+	path := filepath.Join(logs, "latest.log")
+	os.WriteFile(path, []byte(strings.Repeat("old line\n", 4000)+"[12:00:00 INFO]: last line before\n"), 0o644)
+
+	c := NewConsole(100)
+	tail := NewTail(path, c)
+	tail.Poll(true)
+	lines, seq := c.Since(0)
+	if len(lines) == 0 || len(lines) > tailBytes/len("old line\n")+1 || lines[len(lines)-1] != "[12:00:00 INFO]: last line before" {
+		t.Fatalf("first poll: %d lines ending %q", len(lines), lines[len(lines)-1])
+	}
+	if lines[0] != "old line" {
+		t.Errorf("first poll should start at a line boundary, got %q", lines[0])
+	}
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("[12:00:01 INFO]: Stopping server\n")
+	f.Close()
+	tail.Poll(false)
+	if lines, seq = c.Since(seq); !slices.Equal(lines, []string{"[12:00:01 INFO]: Stopping server"}) {
+		t.Errorf("after appending: %q", lines)
+	}
+
+	// Paper moves the old log aside and starts a new one.
+	os.Rename(path, filepath.Join(logs, "2026-10-19-1.log"))
+	os.WriteFile(path, []byte("[12:01:00 INFO]: Starting minecraft server\n[12:01:09 INFO]: Done (8.5s)! For help, type \"help\"\n"), 0o644)
+	tail.Poll(false)
+	if lines, _ = c.Since(seq); len(lines) != 2 || !strings.Contains(lines[1], "Done (8.5s)!") {
+		t.Errorf("after rotation: %q", lines)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package systemd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Owner returns the user and group owning dir, whom the unit runs the server as. A
+// directory in the root group leaves group empty, for the user's own.
+func Owner(dir string) (usr, group string, err error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("systemd: %w", err)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", fmt.Errorf("systemd: cannot tell who owns %s", dir)
+	}
+	u, err := user.LookupId(strconv.FormatUint(uint64(st.Uid), 10))
+	if err != nil {
+		return "", "", fmt.Errorf("systemd: owner of %s: %w", dir, err)
+	}
+	if st.Gid != 0 {
+		if g, err := user.LookupGroupId(strconv.FormatUint(uint64(st.Gid), 10)); err == nil {
+			group = g.Name
+		}
+	}
+	return u.Username, group, nil
+}
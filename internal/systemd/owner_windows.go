@@ -0,0 +1,8 @@
+package systemd
+
+import "errors"
+
+// Owner is not available on Windows, which has no systemd.
+func Owner(dir string) (usr, group string, err error) {
+	return "", "", errors.New("systemd: not supported on Windows")
+}
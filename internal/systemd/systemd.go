@@ -0,0 +1,393 @@
+// Package systemd runs the Paper server through a systemd unit. It writes a hardened
+// unit for the server directory, tells whether a unit already manages the directory,
+// and then starts and stops the server with systemctl instead of the supervisor, so the
+// tool and systemd never disagree about who runs it. Console commands go over RCON, and
+// the console shows Paper's logs/latest.log.
+//
+// Every systemctl call goes through a Runner, so all of this can be tested without
+// systemd.
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/rcon"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+)
+
+const (
+	// UnitDir is where units written by an administrator go.
+	UnitDir = "/etc/systemd/system"
+	// LogFile is the log Paper writes in the server directory, whoever started it.
+	LogFile = "logs/latest.log"
+	// commandGrace is how much longer than the stop timeout a systemctl call may take;
+	// systemd itself escalates to SIGKILL once TimeoutStopSec has passed.
+	commandGrace = 30 * time.Second
+	// showTimeout bounds systemctl show, which only reads systemd's state.
+	showTimeout = 10 * time.Second
+)
+
+// ErrInvalidName means a unit name has characters systemd does not allow.
+var ErrInvalidName = errors.New("systemd: invalid unit name")
+
+// Runner runs a command and returns its standard output. Exec runs it for real; tests
+// substitute a fake.
+type Runner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// Exec runs a command with os/exec. The error of a failing command includes what it
+// wrote to stderr, which is where systemctl explains itself.
+func Exec(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%w: %s", err, msg)
+		}
+		return out, err
+	}
+	return out, nil
+}
+
+// nameRE matches the unit names systemd accepts: letters, digits and ":-_.\@".
+var nameRE = regexp.MustCompile(`^[A-Za-z0-9:_.\\@-]+$`)
+
+// ValidName checks a unit name, with or without its ".service" suffix.
+func ValidName(name string) error {
+	if name == "" || !nameRE.MatchString(name) {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// UnitName is the unit name used for the server in dir unless systemd_unit names one:
+// "paper-" and the directory's base name, e.g. paper-survival.service for
+// /srv/minecraft/survival.
+func UnitName(dir string) string {
+	base := "server"
+	if abs, err := filepath.Abs(dir); err == nil && filepath.Base(abs) != string(filepath.Separator) {
+		base = filepath.Base(abs)
+	}
+	base = strings.Map(func(r rune) rune {
+		if r < 0x80 && nameRE.MatchString(string(r)) && r != '\\' && r != '@' {
+			return r
+		}
+		return '-'
+	}, base)
+	return "paper-" + base + ".service"
+}
+
+// Properties is what systemctl show says about a unit.
+type Properties struct {
+	LoadState        string // "loaded", or "not-found" for a unit that does not exist
+	ActiveState      string // "active", "activating", "deactivating", "inactive" or "failed"
+	SubState         string // e.g. "running" or "dead"
+	MainPID          int
+	WorkingDirectory string
+	FragmentPath     string    // the unit file
+	Since            time.Time // when it last became active; zero if never
+}
+
+// Loaded reports whether the unit exists.
+func (p Properties) Loaded() bool { return p.LoadState == "loaded" }
+
+// Running reports whether the server is up or on its way up or down.
+func (p Properties) Running() bool {
+	switch p.ActiveState {
+	case "active", "activating", "deactivating", "reloading":
+		return true
+	}
+	return false
+}
+
+// Manages reports whether the unit exists and runs its server in dir.
+func (p Properties) Manages(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	return err == nil && p.Loaded() && p.WorkingDirectory != "" && filepath.Clean(p.WorkingDirectory) == abs
+}
+
+// timestampLayout is how systemctl show prints timestamps, e.g. "Mon 2026-10-19
+// 12:00:00 UTC".
+const timestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// ParseProperties reads the KEY=VALUE lines of systemctl show.
+func ParseProperties(out []byte) Properties {
+	var p Properties
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "LoadState":
+			p.LoadState = value
+		case "ActiveState":
+			p.ActiveState = value
+		case "SubState":
+			p.SubState = value
+		case "MainPID":
+			p.MainPID, _ = strconv.Atoi(value)
+		case "WorkingDirectory":
+			// A leading "!" or "-" is a modifier, not part of the path.
+			p.WorkingDirectory = strings.TrimLeft(value, "!-")
+		case "FragmentPath":
+			p.FragmentPath = value
+		case "ActiveEnterTimestamp":
+			p.Since, _ = time.ParseInLocation(timestampLayout, value, time.Local)
+		}
+	}
+	return p
+}
+
+// Unit runs the server in one directory through a systemd service unit. It has the
+// methods of supervisor.Supervisor that safe updates and the TUI use. Build one with
+// New.
+type Unit struct {
+	name        string
+	dir         string
+	run         Runner
+	stopTimeout time.Duration
+	log         *slog.Logger
+	console     *supervisor.Console
+
+	followOnce sync.Once
+}
+
+// Option configures a Unit.
+type Option func(*Unit)
+
+// WithRunner sets how systemctl is run (default Exec).
+func WithRunner(r Runner) Option {
+	return func(u *Unit) {
+		if r != nil {
+			u.run = r
+		}
+	}
+}
+
+// WithStopTimeout sets how long the unit takes to stop at most, as its TimeoutStopSec
+// says; systemctl stop is given a little longer (default supervisor.DefaultStopTimeout).
+func WithStopTimeout(d time.Duration) Option {
+	return func(u *Unit) {
+		if d > 0 {
+			u.stopTimeout = d
+		}
+	}
+}
+
+// WithLogger sets where the unit logs starts and stops.
+func WithLogger(l *slog.Logger) Option {
+	return func(u *Unit) {
+		if l != nil {
+			u.log = l
+		}
+	}
+}
+
+// New returns the unit called name (UnitName(dir) if empty; ".service" is added if
+// missing) for the server in dir.
+func New(name, dir string, opts ...Option) *Unit {
+	if name == "" {
+		name = UnitName(dir)
+	}
+	if !strings.Contains(name, ".") {
+		name += ".service"
+	}
+	u := &Unit{
+		name:        name,
+		dir:         dir,
+		run:         Exec,
+		stopTimeout: supervisor.DefaultStopTimeout,
+		log:         logging.Discard(),
+		console:     supervisor.NewConsole(supervisor.DefaultConsoleLines),
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Name returns the unit's name, e.g. paper-survival.service.
+func (u *Unit) Name() string { return u.name }
+
+// Show asks systemd about the unit. A unit that does not exist is not an error; its
+// LoadState says so.
+func (u *Unit) Show(ctx context.Context) (Properties, error) {
+	ctx, cancel := context.WithTimeout(ctx, showTimeout)
+	defer cancel()
+	out, err := u.systemctl(ctx, "show", u.name,
+		"--property=LoadState,ActiveState,SubState,MainPID,WorkingDirectory,FragmentPath,ActiveEnterTimestamp")
+	if err != nil {
+		return Properties{}, err
+	}
+	return ParseProperties(out), nil
+}
+
+// Managed reports whether the unit exists and runs the server in the Unit's directory.
+// Without systemd, or without systemctl in PATH, it is not.
+func (u *Unit) Managed(ctx context.Context) bool {
+	p, err := u.Show(ctx)
+	if err != nil {
+		u.log.Debug("cannot ask systemd about the unit", logging.KeyEvent, logging.EventSystemd, "unit", u.name, logging.Err(err))
+		return false
+	}
+	return p.Manages(u.dir)
+}
+
+// DaemonReload makes systemd read unit files again, as it must after one is written.
+func (u *Unit) DaemonReload(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, showTimeout)
+	defer cancel()
+	_, err := u.systemctl(ctx, "daemon-reload")
+	return err
+}
+
+// Status reports whether the unit is running the server.
+func (u *Unit) Status() (supervisor.Status, error) {
+	p, err := u.Show(context.Background())
+	if err != nil {
+		return supervisor.Status{}, err
+	}
+	if !p.Running() {
+		return supervisor.Status{}, nil
+	}
+	return supervisor.Status{Running: true, PID: p.MainPID, Since: p.Since}, nil
+}
+
+// Start starts the unit with systemctl start. It returns supervisor.ErrRunning if the
+// unit is running already, and a *lock.HeldError if an install or rollback is under
+// way.
+func (u *Unit) Start() (supervisor.Status, error) {
+	if st, err := u.Status(); err != nil {
+		return supervisor.Status{}, err
+	} else if st.Running {
+		return st, fmt.Errorf("%w (pid %d)", supervisor.ErrRunning, st.PID)
+	}
+	// As the supervisor does, hold the directory lock while java starts, so it never
+	// opens a jar an install is halfway through replacing.
+	l, err := lock.Acquire(u.dir)
+	if err != nil {
+		return supervisor.Status{}, err
+	}
+	err = u.control("start")
+	l.Release()
+	if err != nil {
+		return supervisor.Status{}, err
+	}
+	u.console.Note("[paper-mc-tui] started " + u.name)
+	return u.Status()
+}
+
+// Stop stops the unit with systemctl stop, which runs the unit's ExecStop and waits for
+// the server to exit. It returns supervisor.ErrNotRunning if there is nothing to stop.
+func (u *Unit) Stop() error {
+	if st, err := u.Status(); err != nil {
+		return err
+	} else if !st.Running {
+		return supervisor.ErrNotRunning
+	}
+	if err := u.control("stop"); err != nil {
+		return err
+	}
+	u.console.Note("[paper-mc-tui] stopped " + u.name)
+	return nil
+}
+
+// Restart restarts the unit, starting it if it is stopped.
+func (u *Unit) Restart() (supervisor.Status, error) {
+	l, err := lock.Acquire(u.dir)
+	if err != nil {
+		return supervisor.Status{}, err
+	}
+	err = u.control("restart")
+	l.Release()
+	if err != nil {
+		return supervisor.Status{}, err
+	}
+	u.console.Note("[paper-mc-tui] restarted " + u.name)
+	return u.Status()
+}
+
+// control runs systemctl verb on the unit and logs it.
+func (u *Unit) control(verb string) error {
+	log := u.log.With(logging.KeyEvent, logging.EventSystemd, "unit", u.name, "action", verb)
+	ctx, cancel := context.WithTimeout(context.Background(), u.stopTimeout+commandGrace)
+	defer cancel()
+	begin := time.Now()
+	if _, err := u.systemctl(ctx, verb, u.name); err != nil {
+		log.Error("systemctl failed", logging.Err(err))
+		return err
+	}
+	log.Info("systemctl "+verb, "duration", time.Since(begin).Round(time.Millisecond).String())
+	return nil
+}
+
+// Send runs line on the server over RCON, with the settings in server.properties, and
+// notes it and its response on the Console. It returns supervisor.ErrDetached when
+// RCON is not enabled, as there is no other way to reach a console systemd holds.
+func (u *Unit) Send(line string) error {
+	line = strings.TrimRight(line, "\r\n")
+	cfg, err := rcon.FromDir(u.dir)
+	if errors.Is(err, rcon.ErrDisabled) || errors.Is(err, rcon.ErrNoPassword) {
+		if st, serr := u.Status(); serr == nil && !st.Running {
+			return supervisor.ErrNotRunning
+		}
+		return supervisor.ErrDetached
+	}
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rcon.DefaultTimeout)
+	defer cancel()
+	out, err := rcon.Run(ctx, cfg, line)
+	if err != nil {
+		u.log.Warn("rcon command failed", logging.KeyEvent, logging.EventRCON, "addr", cfg.Addr, "command", line, logging.Err(err))
+		return err
+	}
+	u.console.Note("> " + line)
+	for l := range strings.Lines(out) {
+		if l = strings.TrimRight(l, "\r\n"); l != "" {
+			u.console.Note(l)
+		}
+	}
+	u.log.Debug("rcon command", logging.KeyEvent, logging.EventRCON, "addr", cfg.Addr, "command", line)
+	return nil
+}
+
+// Console returns the server's output as Paper writes it to logs/latest.log. The first
+// call starts following the log, beginning with its last few lines; from then on the
+// Console keeps up with it, across restarts, for as long as the tool runs.
+func (u *Unit) Console() *supervisor.Console {
+	u.followOnce.Do(func() {
+		t := supervisor.NewTail(filepath.Join(u.dir, LogFile), u.console)
+		t.Poll(true)
+		go t.Follow()
+	})
+	return u.console
+}
+
+// systemctl runs systemctl with args, never waiting for a password prompt: the TUI
+// owns the terminal, so a polkit agent could not ask anyway.
+func (u *Unit) systemctl(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := u.run(ctx, "systemctl", append([]string{"--no-ask-password"}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("systemd: systemctl %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
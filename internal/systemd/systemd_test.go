@@ -0,0 +1,152 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+)
+
+// fakeSystemctl stands in for systemctl: it records each call and answers show with
+// the unit's state, which start, stop and restart change.
+type fakeSystemctl struct {
+	dir    string
+	loaded bool
+	active bool
+	fail   map[string]error // by verb
+	calls  []string
+}
+
+func (f *fakeSystemctl) run(_ context.Context, name string, args ...string) ([]byte, error) {
+	if name != "systemctl" || len(args) < 2 || args[0] != "--no-ask-password" {
+		return nil, fmt.Errorf("unexpected command %s %q", name, args)
+	}
+	verb := args[1]
+	f.calls = append(f.calls, strings.Join(args[1:], " "))
+	if err := f.fail[verb]; err != nil {
+		return nil, err
+	}
+	switch verb {
+	case "show":
+		if !f.loaded {
+			return []byte("LoadState=not-found\nActiveState=inactive\nSubState=dead\nMainPID=0\nWorkingDirectory=\n"), nil
+		}
+		state, pid := "inactive", 0
+		if f.active {
+			state, pid = "active", 4242
+		}
+		return fmt.Appendf(nil, "LoadState=loaded\nActiveState=%s\nSubState=running\nMainPID=%d\nWorkingDirectory=%s\n"+
+			"FragmentPath=/etc/systemd/system/paper-test.service\nActiveEnterTimestamp=Mon 2026-10-19 12:00:00 UTC\n",
+			state, pid, f.dir), nil
+	case "start", "restart":
+		f.active = true
+	case "stop":
+		f.active = false
+	}
+	return nil, nil
+}
+
+func TestParseProperties(t *testing.T) {
+	out := "LoadState=loaded\nActiveState=active\nSubState=running\nMainPID=812\nWorkingDirectory=!/srv/mc\n" +
+		"FragmentPath=/etc/systemd/system/paper-mc.service\nActiveEnterTimestamp=Mon 2026-10-19 12:00:00 UTC\n"
+	p := ParseProperties([]byte(out))
+	if !p.Loaded() || !p.Running() || p.MainPID != 812 || p.WorkingDirectory != "/srv/mc" {
+		t.Errorf("ParseProperties = %+v", p)
+	}
+	if want := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC); !p.Since.Equal(want) {
+		t.Errorf("Since = %v, want %v", p.Since, want)
+	}
+	if !p.Manages("/srv/mc/") || p.Manages("/srv/other") {
+		t.Error("Manages should compare the cleaned directory")
+	}
+	if p := ParseProperties([]byte("LoadState=not-found\nActiveState=inactive\n")); p.Loaded() || p.Running() || p.Manages("/") {
+		t.Errorf("a missing unit: %+v", p)
+	}
+}
+
+func TestUnitName(t *testing.T) {
+	for dir, want := range map[string]string{
+		"/srv/minecraft/survival": "paper-survival.service",
+		"/srv/My Server (1)":      "paper-My-Server--1-.service",
+		"/":                       "paper-server.service",
+	} {
+		if got := UnitName(dir); got != want {
+			t.Errorf("UnitName(%q) = %q, want %q", dir, got, want)
+		}
+		if err := ValidName(UnitName(dir)); err != nil {
+			t.Errorf("UnitName(%q) is not valid: %v", dir, err)
+		}
+	}
+	if got := New("minecraft", "/srv").Name(); got != "minecraft.service" {
+		t.Errorf("New(minecraft) name = %q", got)
+	}
+	if err := ValidName("paper mc.service"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("ValidName(with a space) = %v", err)
+	}
+}
+
+func TestUnitControl(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeSystemctl{dir: dir, loaded: true}
+	u := New("paper-test", dir, WithRunner(fake.run))
+
+	if !u.Managed(context.Background()) {
+		t.Fatal("the unit runs the server in dir, so it manages it")
+	}
+	if err := u.Stop(); !errors.Is(err, supervisor.ErrNotRunning) {
+		t.Errorf("Stop on a stopped unit = %v, want ErrNotRunning", err)
+	}
+	st, err := u.Start()
+	if err != nil || !st.Running || st.PID != 4242 {
+		t.Fatalf("Start = %+v, %v", st, err)
+	}
+	if _, err := u.Start(); !errors.Is(err, supervisor.ErrRunning) {
+		t.Errorf("second Start = %v, want ErrRunning", err)
+	}
+	if err := u.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	var verbs []string
+	for _, c := range fake.calls {
+		if !strings.HasPrefix(c, "show ") {
+			verbs = append(verbs, c)
+		}
+	}
+	if want := []string{"start paper-test.service", "stop paper-test.service"}; !slices.Equal(verbs, want) {
+		t.Errorf("systemctl calls = %q, want %q", verbs, want)
+	}
+
+	fake.fail = map[string]error{"start": errors.New("exit status 4: Interactive authentication required.")}
+	if _, err := u.Start(); err == nil || !strings.Contains(err.Error(), "systemctl start paper-test.service") ||
+		!strings.Contains(err.Error(), "authentication required") {
+		t.Errorf("failed Start = %v", err)
+	}
+
+	// Without RCON the console of a unit cannot be reached.
+	fake.fail, fake.active = nil, true
+	if err := u.Send("list"); !errors.Is(err, supervisor.ErrDetached) {
+		t.Errorf("Send without RCON = %v, want ErrDetached", err)
+	}
+}
+
+func TestManagedElsewhere(t *testing.T) {
+	fake := &fakeSystemctl{dir: "/srv/other", loaded: true}
+	if New("paper-test", t.TempDir(), WithRunner(fake.run)).Managed(context.Background()) {
+		t.Error("a unit for another directory does not manage this one")
+	}
+	fake = &fakeSystemctl{}
+	if New("paper-test", t.TempDir(), WithRunner(fake.run)).Managed(context.Background()) {
+		t.Error("a missing unit manages nothing")
+	}
+	noSystemd := func(context.Context, string, ...string) ([]byte, error) {
+		return nil, errors.New(`exec: "systemctl": executable file not found in $PATH`)
+	}
+	if New("paper-test", t.TempDir(), WithRunner(noSystemd)).Managed(context.Background()) {
+		t.Error("without systemctl nothing is managed")
+	}
+}
@@ -0,0 +1,141 @@
+package systemd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/genfile"
+)
+
+const (
+	// DefaultRestart is the unit's restart policy: start the server again after a crash,
+	// but not after a clean stop.
+	DefaultRestart = "on-failure"
+	// DefaultRestartDelay is how long systemd waits before restarting it.
+	DefaultRestartDelay = 10 * time.Second
+)
+
+// RestartPolicies are the values of Restart= a unit may use.
+var RestartPolicies = []string{"no", "on-failure", "on-abnormal", "always"}
+
+// Spec is what the unit file says.
+type Spec struct {
+	Name      string // e.g. paper-survival.service
+	Dir       string // absolute; the server's WorkingDirectory
+	User      string
+	Group     string // "" for the user's primary group
+	ExecStart []string
+	// Tool is the paper-mc-tui binary, which ExecStop runs to send "stop" over RCON.
+	// Without it the unit is stopped with SIGTERM, which Paper also handles cleanly.
+	Tool         string
+	Restart      string        // Restart= policy; "" for DefaultRestart
+	RestartDelay time.Duration // 0 for DefaultRestartDelay
+	StopTimeout  time.Duration // how long the server has to save and exit
+}
+
+// stopScript sends "stop" over RCON with paper-mc-tui ($0), then waits for the server
+// to exit. If RCON is not enabled it gives up at once, and systemd sends SIGTERM.
+const stopScript = `"$0" rcon stop >/dev/null && while kill -0 "$MAINPID" 2>/dev/null; do sleep 1; done; exit 0`
+
+// Render writes the unit file. Like start.sh, it has no timestamp, so regenerating an
+// unchanged unit changes nothing.
+func (s Spec) Render() []byte {
+	restart, delay := s.Restart, s.RestartDelay
+	if restart == "" {
+		restart = DefaultRestart
+	}
+	if delay <= 0 {
+		delay = DefaultRestartDelay
+	}
+	dir := escapePath(s.Dir)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s: the Paper server in %s. Written by paper-mc-tui; run\n", s.Name, s.Dir)
+	b.WriteString("# \"paper-mc-tui systemd-unit\" to regenerate it and see what changed.\n\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=Paper server in %s\n", dir)
+	b.WriteString("Wants=network-online.target\nAfter=network-online.target\n\n")
+
+	b.WriteString("[Service]\nType=simple\n")
+	fmt.Fprintf(&b, "User=%s\n", s.User)
+	if s.Group != "" {
+		fmt.Fprintf(&b, "Group=%s\n", s.Group)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", dir)
+	fmt.Fprintf(&b, "ExecStart=%s\n", commandLine(s.ExecStart))
+	if s.Tool != "" {
+		b.WriteString("# Send \"stop\" over RCON and wait for the server to save and exit. Without RCON\n")
+		b.WriteString("# this gives up at once and systemd sends SIGTERM, which Paper also handles.\n")
+		fmt.Fprintf(&b, "ExecStop=%s\n", commandLine([]string{"/bin/sh", "-c", stopScript, s.Tool}))
+	}
+	if s.StopTimeout > 0 {
+		fmt.Fprintf(&b, "TimeoutStopSec=%d\n", int(s.StopTimeout.Round(time.Second)/time.Second))
+	}
+	b.WriteString("# The JVM exits with 143 when it stops on SIGTERM.\nSuccessExitStatus=143\n")
+	fmt.Fprintf(&b, "Restart=%s\nRestartSec=%d\n\n", restart, max(int(delay.Round(time.Second)/time.Second), 1))
+
+	b.WriteString("# Hardening. The server may write only to its own directory. MemoryDenyWriteExecute\n")
+	b.WriteString("# is left out on purpose: the JVM's JIT compiler needs writable, executable memory.\n")
+	b.WriteString("NoNewPrivileges=yes\n")
+	b.WriteString("ProtectSystem=strict\n")
+	b.WriteString("ProtectHome=read-only\n")
+	fmt.Fprintf(&b, "ReadWritePaths=%s\n", dir)
+	b.WriteString("PrivateTmp=yes\n")
+	b.WriteString("PrivateDevices=yes\n")
+	b.WriteString("ProtectKernelTunables=yes\n")
+	b.WriteString("ProtectKernelModules=yes\n")
+	b.WriteString("ProtectKernelLogs=yes\n")
+	b.WriteString("ProtectControlGroups=yes\n")
+	b.WriteString("ProtectClock=yes\n")
+	b.WriteString("ProtectHostname=yes\n")
+	b.WriteString("RestrictSUIDSGID=yes\n")
+	b.WriteString("RestrictRealtime=yes\n")
+	b.WriteString("RestrictNamespaces=yes\n")
+	b.WriteString("RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6\n")
+	b.WriteString("LockPersonality=yes\n")
+	b.WriteString("SystemCallArchitectures=native\n")
+	b.WriteString("CapabilityBoundingSet=\n")
+	b.WriteString("UMask=0027\n\n")
+
+	b.WriteString("[Install]\nWantedBy=multi-user.target\n")
+	return b.Bytes()
+}
+
+// commandLine joins argv for ExecStart= and ExecStop=, quoting arguments systemd
+// would otherwise split or expand.
+func commandLine(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+		if arg == "" || strings.ContainsAny(arg, " \t\"'\\;") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// escapePath escapes the specifier character in a path setting.
+func escapePath(p string) string { return strings.ReplaceAll(p, "%", "%%") }
+
+// Change is the unit file as it is on disk and as it would be written.
+type Change struct{ genfile.Change }
+
+// Prepare compares unit with the file at path.
+func Prepare(path string, unit []byte) (Change, error) {
+	c, err := genfile.Prepare(path, unit, 0o644)
+	if err != nil {
+		return Change{}, fmt.Errorf("systemd: %w", err)
+	}
+	return Change{c}, nil
+}
+
+// Write replaces the unit file atomically.
+func (c Change) Write() error {
+	if err := c.Change.Write(); err != nil {
+		return fmt.Errorf("systemd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	s := Spec{
+		Name:        "paper-survival.service",
+		Dir:         "/srv/minecraft/survival",
+		User:        "minecraft",
+		Group:       "games",
+		ExecStart:   []string{"/usr/lib/jvm/jdk-25/bin/java", "-Xms4G", "-Xmx4G", "-Dname=My Server", "-jar", "paper.jar", "nogui"},
+		Tool:        "/usr/local/bin/paper-mc-tui",
+		StopTimeout: 90 * time.Second,
+	}
+	unit := string(s.Render())
+	for _, want := range []string{
+		"User=minecraft\nGroup=games\n",
+		"WorkingDirectory=/srv/minecraft/survival\n",
+		`ExecStart=/usr/lib/jvm/jdk-25/bin/java -Xms4G -Xmx4G "-Dname=My Server" -jar paper.jar nogui` + "\n",
+		`ExecStop=/bin/sh -c "\"$$0\" rcon stop >/dev/null && while kill -0 \"$$MAINPID\" 2>/dev/null; do sleep 1; done; exit 0" /usr/local/bin/paper-mc-tui` + "\n",
+		"TimeoutStopSec=90\n",
+		"Restart=on-failure\nRestartSec=10\n",
+		"ProtectSystem=strict\n",
+		"ReadWritePaths=/srv/minecraft/survival\n",
+		"NoNewPrivileges=yes\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+	if strings.Contains(unit, "\nMemoryDenyWriteExecute=") {
+		t.Error("MemoryDenyWriteExecute breaks the JVM's JIT")
+	}
+	if string(s.Render()) != unit {
+		t.Error("Render is not deterministic")
+	}
+
+	s.Tool, s.Restart = "", "always"
+	unit = string(s.Render())
+	if strings.Contains(unit, "ExecStop=") || !strings.Contains(unit, "Restart=always\n") {
+		t.Errorf("without a tool or with Restart=always:\n%s", unit)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	got := commandLine([]string{"/opt/java/bin/java", "-Dpct=100%", "-Dhome=$HOME", `C:\x`, ""})
+	want := `/opt/java/bin/java -Dpct=100%% -Dhome=$$HOME "C:\\x" ""`
+	if got != want {
+		t.Errorf("commandLine = %s, want %s", got, want)
+	}
+}
+
+func TestChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paper-test.service")
+	s := Spec{Name: "paper-test.service", Dir: "/srv/mc", User: "mc", ExecStart: []string{"java", "-jar", "paper.jar", "nogui"}}
+
+	c, err := Prepare(path, s.Render())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Exists() || !c.Changed() || !strings.HasPrefix(c.Diff(), "--- /dev/null\n+++ paper-test.service (new)\n") {
+		t.Errorf("new unit: exists %v, changed %v, diff:\n%s", c.Exists(), c.Changed(), c.Diff())
+	}
+	if err := c.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o644 {
+		t.Errorf("unit file mode = %v, %v", fi.Mode(), err)
+	}
+
+	if c, _ = Prepare(path, s.Render()); c.Changed() || c.Diff() != "" {
+		t.Error("an unchanged unit should not be rewritten")
+	}
+	s.User = "minecraft"
+	c, _ = Prepare(path, s.Render())
+	if d := c.Diff(); !strings.Contains(d, "-User=mc\n+User=minecraft\n") {
+		t.Errorf("diff after changing the user:\n%s", d)
+	}
+}
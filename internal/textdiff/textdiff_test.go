@@ -0,0 +1,48 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := strings.Join([]string{
+		"--- old",
+		"+++ new",
+		"@@ -1,6 +1,6 @@",
+		" 1",
+		" 2",
+		"-3",
+		"+three",
+		" 4",
+		" 5",
+		" 6",
+		"@@ -10,3 +10,4 @@",
+		" 10",
+		" 11",
+		" 12",
+		"+13",
+		"",
+	}, "\n")
+	if got := Unified("old", "new", []byte(a), []byte(b)); got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUnifiedEdges(t *testing.T) {
+	if got := Unified("a", "b", []byte("same\n"), []byte("same\n")); got != "" {
+		t.Errorf("equal input gave %q", got)
+	}
+	// A new file is all additions, numbered from an empty range.
+	want := "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if got := Unified("a", "b", nil, []byte("x\ny\n")); got != want {
+		t.Errorf("Unified(new file) = %q, want %q", got, want)
+	}
+	// Changes close together share a hunk.
+	got := Unified("a", "b", []byte("1\n2\n3\n4\n5\n"), []byte("1\nx\n3\n4\ny\n"))
+	if strings.Count(got, "@@ ") != 1 {
+		t.Errorf("expected one hunk:\n%s", got)
+	}
+}
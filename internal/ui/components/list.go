@@ -53,6 +53,16 @@ func NewList(items []Item, title string) List {
 	return List{list: l}
 }
 
+// SetItems replaces the items, keeping the selection where it is.
+func (l List) SetItems(items []Item) List {
+	listItems := make([]list.Item, len(items))
+	for i, item := range items {
+		listItems[i] = item
+	}
+	l.list.SetItems(listItems)
+	return l
+}
+
 func (l List) SelectedItem() (Item, bool) {
 	selectedItem := l.list.SelectedItem()
 	if selectedItem == nil {
@@ -61,6 +71,11 @@ func (l List) SelectedItem() (Item, bool) {
 	return selectedItem.(Item), true
 }
 
+// Index returns the position of the selected item.
+func (l List) Index() int {
+	return l.list.Index()
+}
+
 func (l List) Update(msg tea.Msg) (List, tea.Cmd) {
 	var cmd tea.Cmd
 
@@ -1,8 +1,63 @@
 package views
 
-import tea "github.com/charmbracelet/bubbletea"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mbacalan/paper-mc-tui/internal/java"
+	"github.com/mbacalan/paper-mc-tui/internal/lock"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+)
 
 // backToHome is a tea.Cmd that switches back to the home menu.
 func backToHome() tea.Msg {
 	return SwitchViewMsg{ViewID: HomeViewID}
 }
+
+// describeErr renders an error for display, spelling out who holds the directory lock
+// rather than showing the raw lock error, what to do about a java that is too old, and
+// what a failed smoke test logged.
+func describeErr(err error) string {
+	var held *lock.HeldError
+	if errors.As(err, &held) {
+		h := held.Holder
+		return fmt.Sprintf("Another process is already working in this directory:\n"+
+			"  pid %d on %s, started %s\n  %s\n"+
+			"Wait for it to finish, or delete %s if you are sure it is gone.",
+			h.PID, h.Host, h.StartedAt.Format("2006-01-02 15:04:05"), h.Command, held.Path)
+	}
+	var tooOld *java.TooOldError
+	if errors.As(err, &tooOld) {
+		return fmt.Sprintf("Paper %s needs Java %d or newer, but %s is Java %d.\n"+
+			"Point the java setting at a newer runtime (the java command lists the ones\n"+
+			"installed), or set java_check = warn to install anyway.",
+			tooOld.Version, tooOld.Required, tooOld.Runtime.Path, tooOld.Runtime.Major)
+	}
+	var smoke *paper.SmokeTestError
+	if errors.As(err, &smoke) {
+		var b strings.Builder
+		fmt.Fprintf(&b, "The new build failed its smoke test: %s.\n", smoke.Result.Reason)
+		switch {
+		case smoke.RolledBack:
+			fmt.Fprintf(&b, "The previous jar is back in place: %s build %d.\n", smoke.Restored.Restored.Version, smoke.Restored.Restored.Build)
+		case smoke.RollbackErr != nil:
+			fmt.Fprintf(&b, "The previous jar could not be restored: %v\n", smoke.RollbackErr)
+		default:
+			b.WriteString("There was no previous jar to restore.\n")
+		}
+		excerpt := smoke.Result.Excerpt
+		if len(excerpt) > smokeExcerptLines {
+			excerpt = excerpt[len(excerpt)-smokeExcerptLines:]
+		}
+		for _, line := range excerpt {
+			b.WriteString("\n  " + line)
+		}
+		return b.String()
+	}
+	return err.Error()
+}
+
+// smokeExcerptLines is how much of a failed smoke test's log the TUI shows.
+const smokeExcerptLines = 12
@@ -0,0 +1,343 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mbacalan/paper-mc-tui/internal/rcon"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
+)
+
+// consolePollInterval is how often the console view checks for new server output.
+const consolePollInterval = 200 * time.Millisecond
+
+// maxHistory bounds the console's command history.
+const maxHistory = 500
+
+var (
+	// consoleWarnRE and consoleErrorRE match the level in Paper's log lines, e.g.
+	// "[12:34:56 WARN]: ...". Lines that continue an entry (stack traces) start with
+	// whitespace or "Caused by:" and take the level of the line before.
+	consoleWarnRE     = regexp.MustCompile(`^\[[^\]]* WARN\]`)
+	consoleErrorRE    = regexp.MustCompile(`^\[[^\]]* (ERROR|FATAL|SEVERE)\]`)
+	consoleContinueRE = regexp.MustCompile(`^(\s|Caused by:)`)
+)
+
+// consoleTickID distinguishes poll loops, as logTickID does for the log view.
+var consoleTickID atomic.Int64
+
+type consoleTickMsg struct {
+	id    int64
+	lines []string
+	next  uint64
+}
+
+// consoleSentMsg carries the outcome of sending a command.
+type consoleSentMsg struct {
+	err error
+}
+
+// commandHistory is the console's command history, oldest first. It outlives the
+// console view so leaving and reopening the view keeps it.
+type commandHistory struct {
+	entries []string
+}
+
+// add appends cmd unless it repeats the last entry.
+func (h *commandHistory) add(cmd string) {
+	if n := len(h.entries); n > 0 && h.entries[n-1] == cmd {
+		return
+	}
+	h.entries = append(h.entries, cmd)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+}
+
+// search returns the index of the newest entry before index before that contains
+// query, or -1.
+func (h *commandHistory) search(query string, before int) int {
+	for i := min(before, len(h.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ConsoleView streams the server's output and sends commands to it, like the terminal
+// of a `screen` session. Commands to a server started elsewhere go over RCON, and their
+// responses appear alongside its output.
+type ConsoleView struct {
+	sup     Server
+	history *commandHistory
+	tickID  int64
+	next    uint64         // console sequence number to poll from
+	lines   []string       // rendered lines, oldest first
+	style   lipgloss.Style // the level of the last line, for the lines continuing it
+	err     error
+
+	viewport viewport.Model
+	input    textinput.Model
+	follow   bool
+	pos      int    // history position while browsing with up/down; len(entries) is the input line
+	draft    string // what was typed before browsing the history
+
+	searching bool // reverse-i-search (ctrl+r)
+	query     string
+	match     int // index of the current search match, or -1
+}
+
+// NewConsoleView returns a console view for the server sup runs.
+func NewConsoleView(sup Server, history *commandHistory) *ConsoleView {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Placeholder = "server command, e.g. list"
+	ti.CharLimit = 256
+	ti.Width = 76
+	ti.Focus()
+
+	return &ConsoleView{
+		sup:      sup,
+		history:  history,
+		tickID:   consoleTickID.Add(1),
+		viewport: viewport.New(80, 20),
+		input:    ti,
+		follow:   true,
+		pos:      len(history.entries),
+		match:    -1,
+	}
+}
+
+func (v *ConsoleView) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, v.poll(0))
+}
+
+// poll reads new console lines after delay.
+func (v *ConsoleView) poll(delay time.Duration) tea.Cmd {
+	console, id, next := v.sup.Console(), v.tickID, v.next
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		lines, next := console.Since(next)
+		return consoleTickMsg{id: id, lines: lines, next: next}
+	})
+}
+
+// send types cmd on the server console, off the UI thread.
+func (v *ConsoleView) send(cmd string) tea.Cmd {
+	sup := v.sup
+	return func() tea.Msg { return consoleSentMsg{err: sup.Send(cmd)} }
+}
+
+func (v *ConsoleView) Update(msg tea.Msg) (View, tea.Cmd) {
+	switch msg := msg.(type) {
+	case consoleTickMsg:
+		if msg.id != v.tickID {
+			return v, nil
+		}
+		v.next = msg.next
+		if len(msg.lines) > 0 {
+			v.appendLines(msg.lines)
+			v.render()
+		}
+		return v, v.poll(consolePollInterval)
+
+	case consoleSentMsg:
+		v.err = msg.err
+		return v, nil
+
+	case tea.WindowSizeMsg:
+		v.viewport.Width = msg.Width - 4
+		v.viewport.Height = max(msg.Height-8, 3)
+		v.input.Width = max(msg.Width-8, 10)
+		v.render()
+		return v, nil
+
+	case tea.KeyMsg:
+		if v.searching {
+			return v.handleSearchKey(msg)
+		}
+		switch msg.String() {
+		case "ctrl+c":
+			return v, tea.Quit
+		case "esc":
+			return v, backToHome
+		case "enter":
+			cmd := strings.TrimSpace(v.input.Value())
+			if cmd == "" {
+				return v, nil
+			}
+			v.history.add(cmd)
+			v.pos, v.draft = len(v.history.entries), ""
+			v.input.SetValue("")
+			v.follow = true
+			v.viewport.GotoBottom()
+			return v, v.send(cmd)
+		case "up":
+			v.browse(-1)
+			return v, nil
+		case "down":
+			v.browse(1)
+			return v, nil
+		case "ctrl+r":
+			v.searching, v.query, v.match = true, "", -1
+			return v, nil
+		case "pgup", "pgdown", "ctrl+u", "ctrl+d":
+			var cmd tea.Cmd
+			v.viewport, cmd = v.viewport.Update(msg)
+			v.follow = v.viewport.AtBottom()
+			return v, cmd
+		case "end":
+			v.follow = true
+			v.viewport.GotoBottom()
+			return v, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	v.input, cmd = v.input.Update(msg)
+	return v, cmd
+}
+
+// browse moves through the history with up (-1) and down (+1), keeping what was typed
+// before browsing as the line below the newest entry.
+func (v *ConsoleView) browse(step int) {
+	entries := v.history.entries
+	if v.pos == len(entries) {
+		v.draft = v.input.Value()
+	}
+	v.pos = min(max(v.pos+step, 0), len(entries))
+	if v.pos == len(entries) {
+		v.input.SetValue(v.draft)
+	} else {
+		v.input.SetValue(entries[v.pos])
+	}
+	v.input.CursorEnd()
+}
+
+// handleSearchKey runs reverse-i-search: typing narrows the query to the newest
+// matching command, ctrl+r steps to older matches, enter puts the match on the input
+// line (without sending it) and esc gives up.
+func (v *ConsoleView) handleSearchKey(msg tea.KeyMsg) (View, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return v, tea.Quit
+	case tea.KeyEsc, tea.KeyCtrlG:
+		v.searching = false
+		return v, nil
+	case tea.KeyEnter:
+		v.searching = false
+		if v.match >= 0 {
+			v.input.SetValue(v.history.entries[v.match])
+			v.input.CursorEnd()
+			v.pos = len(v.history.entries)
+		}
+		return v, nil
+	case tea.KeyCtrlR:
+		if v.match >= 0 {
+			if older := v.history.search(v.query, v.match); older >= 0 {
+				v.match = older
+			}
+		}
+		return v, nil
+	case tea.KeyBackspace:
+		if v.query != "" {
+			r := []rune(v.query)
+			v.query = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		v.query += string(msg.Runes)
+	default:
+		return v, nil
+	}
+	v.match = v.history.search(v.query, len(v.history.entries))
+	return v, nil
+}
+
+// appendLines renders lines new since the last poll, colouring warnings and errors,
+// and drops the oldest beyond what the Console keeps. Lines already rendered are kept
+// as they are.
+func (v *ConsoleView) appendLines(lines []string) {
+	for _, line := range lines {
+		switch {
+		case consoleErrorRE.MatchString(line):
+			v.style = errorStyle
+		case consoleWarnRE.MatchString(line):
+			v.style = warnStyle
+		case strings.HasPrefix(line, "[paper-mc-tui]"), strings.HasPrefix(line, "> "):
+			v.style = dimStyle
+		case !consoleContinueRE.MatchString(line):
+			v.style = lipgloss.Style{}
+		}
+		v.lines = append(v.lines, v.style.Render(line))
+	}
+	if over := len(v.lines) - supervisor.DefaultConsoleLines; over > 0 {
+		v.lines = slices.Delete(v.lines, 0, over)
+	}
+}
+
+// render sets the viewport content to the rendered lines.
+func (v *ConsoleView) render() {
+	if len(v.lines) == 0 {
+		v.viewport.SetContent(dimStyle.Render("No server output yet. Start the server from the home menu."))
+	} else {
+		v.viewport.SetContent(strings.Join(v.lines, "\n"))
+	}
+	if v.follow {
+		v.viewport.GotoBottom()
+	}
+}
+
+func (v *ConsoleView) View() string {
+	status := "scrolled"
+	if v.follow {
+		status = "following"
+	}
+	header := components.Body.Render("Server console (" + status + ")")
+	if v.err != nil {
+		header += "\n" + errorStyle.Render("  "+describeConsoleErr(v.err))
+	}
+	body := lipgloss.NewStyle().Margin(0, 2).Render(v.viewport.View())
+
+	prompt := "  " + v.input.View()
+	if v.searching {
+		match := ""
+		if v.match >= 0 {
+			match = v.history.entries[v.match]
+		} else if v.query != "" {
+			match = dimStyle.Render("(no match)")
+		}
+		prompt = fmt.Sprintf("  (reverse-i-search)`%s': %s", v.query, match)
+	}
+	help := components.NewHelp(
+		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "send")),
+		key.NewBinding(key.WithKeys("up", "down"), key.WithHelp("↑/↓", "history")),
+		key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "search history")),
+		key.NewBinding(key.WithKeys("pgup", "pgdown"), key.WithHelp("pgup/pgdn", "scroll")),
+		key.NewBinding(key.WithKeys("end"), key.WithHelp("end", "follow")),
+	)
+	return header + "\n" + body + "\n\n" + prompt + help.View()
+}
+
+// describeConsoleErr explains why a command could not be sent.
+func describeConsoleErr(err error) string {
+	switch {
+	case errors.Is(err, supervisor.ErrNotRunning):
+		return "The server is not running; start it from the home menu."
+	case errors.Is(err, supervisor.ErrDetached):
+		return "This server was started outside this TUI session. Set enable-rcon=true and rcon.password in server.properties (then restart it) to send it commands over RCON."
+	case errors.Is(err, rcon.ErrAuth):
+		return "The server rejected the RCON password in server.properties; restart it after changing rcon.password."
+	}
+	return err.Error()
+}
@@ -2,9 +2,9 @@ package views
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/progress"
@@ -12,12 +12,10 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/smoketest"
 	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
 )
 
-// downloadTimeout bounds the jar transfer (separate from the short API timeout).
-const downloadTimeout = 15 * time.Minute
-
 type downloadState int
 
 const (
@@ -25,6 +23,8 @@ const (
 	stateUpToDate
 	stateBackupPrompt
 	stateBackupInput
+	statePlanning
+	stateConfirm
 	stateDownloading
 	stateDone
 	stateError
@@ -37,9 +37,19 @@ type prepareMsg struct {
 	err       error
 }
 
+// planMsg carries the dry-run plan shown for confirmation before installing.
+type planMsg struct {
+	plan paper.Plan
+	err  error
+}
+
 type progressMsg float64
 
-type doneMsg struct{ err error }
+// doneMsg ends an install; smoke is the smoke test's result, if one ran.
+type doneMsg struct {
+	err   error
+	smoke *smoketest.Result
+}
 
 type DownloadView struct {
 	svc         *paper.Service
@@ -48,15 +58,25 @@ type DownloadView struct {
 	err         error
 	backupInput textinput.Model
 	progress    progress.Model
+	percent     float64
+	smoke       *smoketest.Result
+
+	// backupName is set once the user opts to back up the existing jar; the service
+	// moves it aside as part of the journaled install.
+	backup     bool
+	backupName string
+
+	// plan is what the install will do, shown for confirmation before it starts.
+	plan paper.Plan
 
 	// progress plumbing: the download runs in a goroutine that reports on these.
 	progressCh chan float64
-	doneCh     chan error
+	doneCh     chan doneMsg
 }
 
 func NewDownloadView(svc *paper.Service) *DownloadView {
 	ti := textinput.New()
-	ti.Placeholder = "paper.backup.jar"
+	ti.Placeholder = svc.BackupName()
 	ti.Focus()
 	ti.CharLimit = 150
 	ti.Width = 30
@@ -74,7 +94,7 @@ func (v *DownloadView) Init() tea.Cmd {
 	v.err = nil
 	svc := v.svc
 	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Check)
 		defer cancel()
 		info, err := svc.CheckLatest(ctx)
 		if err != nil {
@@ -84,28 +104,49 @@ func (v *DownloadView) Init() tea.Cmd {
 	}
 }
 
+// review works out the install plan without touching disk, for the user to confirm.
+func (v *DownloadView) review() tea.Cmd {
+	v.state = statePlanning
+	svc := v.svc
+	opts := paper.InstallOptions{Backup: v.backup, BackupName: v.backupName}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Check)
+		defer cancel()
+		plan, err := svc.PlanInstall(ctx, opts)
+		return planMsg{plan: plan, err: err}
+	}
+}
+
 // startDownload launches the transfer in a goroutine and begins listening for progress.
 func (v *DownloadView) startDownload() tea.Cmd {
 	v.state = stateDownloading
+	v.percent, v.smoke = 0, nil
 	v.progressCh = make(chan float64)
-	v.doneCh = make(chan error, 1)
+	v.doneCh = make(chan doneMsg, 1)
 
 	svc := v.svc
 	progressCh := v.progressCh
 	doneCh := v.doneCh
+	backup, backupName := v.backup, v.backupName
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Download)
 		defer cancel()
-		err := svc.Install(ctx, func(done, total int64) {
-			if total <= 0 {
-				return
-			}
-			select {
-			case progressCh <- float64(done) / float64(total):
-			default: // UI busy; drop this tick
-			}
+		var smoke *smoketest.Result
+		err := svc.Install(ctx, paper.InstallOptions{
+			Backup:     backup,
+			BackupName: backupName,
+			OnProgress: func(done, total int64) {
+				if total <= 0 {
+					return
+				}
+				select {
+				case progressCh <- float64(done) / float64(total):
+				default: // UI busy; drop this tick
+				}
+			},
+			OnSmokeTest: func(r smoketest.Result) { smoke = &r },
 		})
-		doneCh <- err
+		doneCh <- doneMsg{err: err, smoke: smoke}
 	}()
 
 	return tea.Batch(v.progress.SetPercent(0), v.waitForActivity())
@@ -119,8 +160,8 @@ func (v *DownloadView) waitForActivity() tea.Cmd {
 		select {
 		case p := <-progressCh:
 			return progressMsg(p)
-		case err := <-doneCh:
-			return doneMsg{err: err}
+		case msg := <-doneCh:
+			return msg
 		}
 	}
 }
@@ -138,18 +179,33 @@ func (v *DownloadView) Update(msg tea.Msg) (View, tea.Cmd) {
 		case msg.info.UpToDate:
 			v.state = stateUpToDate
 			return v, nil
-		case msg.jarExists:
+		case msg.jarExists && v.svc.BackupPolicy() == paper.BackupAsk:
 			v.state = stateBackupPrompt
 			return v, nil
+		case msg.jarExists:
+			v.backup = v.svc.BackupPolicy() == paper.BackupAlways
+			return v, v.review()
 		default:
-			return v, v.startDownload()
+			return v, v.review()
+		}
+
+	case planMsg:
+		if msg.err != nil {
+			v.state = stateError
+			v.err = msg.err
+			return v, nil
 		}
+		v.plan = msg.plan
+		v.state = stateConfirm
+		return v, nil
 
 	case progressMsg:
+		v.percent = float64(msg)
 		cmd := v.progress.SetPercent(float64(msg))
 		return v, tea.Batch(cmd, v.waitForActivity())
 
 	case doneMsg:
+		v.smoke = msg.smoke
 		if msg.err != nil {
 			v.state = stateError
 			v.err = msg.err
@@ -180,13 +236,9 @@ func (v *DownloadView) handleKey(msg tea.KeyMsg) (View, tea.Cmd) {
 	case stateBackupInput:
 		switch msg.String() {
 		case "enter":
-			filename := strings.TrimSpace(v.backupInput.Value())
-			if _, err := v.svc.Backup(filename); err != nil {
-				v.state = stateError
-				v.err = fmt.Errorf("failed to create backup: %w", err)
-				return v, nil
-			}
-			return v, v.startDownload()
+			v.backup = true
+			v.backupName = strings.TrimSpace(v.backupInput.Value())
+			return v, v.review()
 		case "esc":
 			v.state = stateBackupPrompt
 			return v, nil
@@ -196,6 +248,16 @@ func (v *DownloadView) handleKey(msg tea.KeyMsg) (View, tea.Cmd) {
 			return v, cmd
 		}
 
+	case stateConfirm:
+		switch msg.String() {
+		case "y", "enter":
+			return v, v.startDownload()
+		case "n", "esc":
+			return v, backToHome
+		case "q":
+			return v, tea.Quit
+		}
+
 	case stateBackupPrompt:
 		switch msg.String() {
 		case "y":
@@ -216,7 +278,7 @@ func (v *DownloadView) handleKey(msg tea.KeyMsg) (View, tea.Cmd) {
 			return v, backToHome
 		}
 
-	default: // stateLoading, stateUpToDate, stateDownloading, stateDone
+	default: // stateLoading, statePlanning, stateUpToDate, stateDownloading, stateDone
 		switch msg.String() {
 		case "q":
 			return v, tea.Quit
@@ -247,22 +309,48 @@ func (v *DownloadView) View() string {
 			key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yes")),
 			key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "no")),
 		)
-		return style.Render("A paper.jar already exists. Back it up first? (y/n)") + help.View()
+		return style.Render(fmt.Sprintf("A %s already exists. Back it up first? (y/n)", v.svc.JarName())) + help.View()
 
 	case stateBackupInput:
-		text := style.Render("Enter backup filename (default: paper.backup.jar):")
+		text := style.Render(fmt.Sprintf("Enter backup filename (default: %s):", v.svc.BackupName()))
 		return text + "\n" + v.backupInput.View() + "\n\n(press Enter to confirm, Esc to go back)"
 
+	case statePlanning:
+		return style.Render("Working out what the install will do…") + components.NewHelp().View()
+
+	case stateConfirm:
+		help := components.NewHelp(
+			key.NewBinding(key.WithKeys("y", "enter"), key.WithHelp("y", "install")),
+			key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", "cancel")),
+		)
+		var b strings.Builder
+		fmt.Fprintf(&b, "Install %s build %d? This will:\n", v.info.Version, v.info.Build)
+		for _, line := range v.plan.Lines() {
+			b.WriteString("\n  " + line)
+		}
+		return style.Render(b.String()) + help.View()
+
 	case stateDownloading:
 		header := style.Render(fmt.Sprintf("Downloading %s (%s)…", v.info.JarName, humanMB(v.info.Download.Size)))
+		if v.percent >= 1 && v.svc.SmokeTest() {
+			header = style.Render(fmt.Sprintf("Downloaded %s. Booting it in a sandbox to smoke test it…", v.info.JarName))
+		}
 		return header + "\n" + lipgloss.NewStyle().Margin(0, 2).Render(v.progress.View()) + "\n"
 
 	case stateDone:
-		return style.Render(fmt.Sprintf("Downloaded and verified %s!", v.info.JarName)) + components.NewHelp().View()
+		text := fmt.Sprintf("Downloaded and verified %s!", v.info.JarName)
+		if v.smoke != nil {
+			text += "\nSmoke test " + v.smoke.String() + "."
+		}
+		return style.Render(text) + components.NewHelp().View()
 
 	case stateError:
 		help := components.NewHelp(key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "retry")))
-		return style.Render(fmt.Sprintf("Download failed:\n%v", v.err)) + help.View()
+		title := "Download failed:"
+		if errors.Is(v.err, paper.ErrSmokeTestFailed) {
+			title = "Install failed:"
+		}
+		return style.Render(title+"\n"+describeErr(v.err)) + help.View()
 
 	default:
 		return style.Render("Unexpected state.") + components.NewHelp().View()
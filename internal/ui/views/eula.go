@@ -0,0 +1,146 @@
+package views
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
+)
+
+type eulaStep int
+
+const (
+	eulaLoading eulaStep = iota
+	eulaPrompt
+	eulaWriting
+	eulaDone
+	eulaError
+)
+
+// eulaStateMsg carries whether the EULA has been accepted, read at startup, after
+// installs and server actions, and when the EULA view opens.
+type eulaStateMsg struct {
+	state eula.State
+	err   error
+}
+
+// eulaAcceptedMsg reports the acceptance written by the EULA view.
+type eulaAcceptedMsg struct {
+	acceptance paper.EULAAcceptance
+	err        error
+}
+
+// readEULA reads the EULA's state off the UI thread.
+func readEULA(svc *paper.Service) tea.Cmd {
+	return func() tea.Msg {
+		st, err := svc.EULA()
+		return eulaStateMsg{state: st, err: err}
+	}
+}
+
+// eulaStatus says why a server in state st will not start.
+func eulaStatus(st eula.State) string {
+	if st == eula.Missing {
+		return eula.FileName + " is missing"
+	}
+	return eula.FileName + " does not say eula=true"
+}
+
+// EULAView shows the link to the Minecraft EULA and asks the user to accept it before
+// writing eula.txt. Only an explicit "y" accepts.
+type EULAView struct {
+	svc        *paper.Service
+	step       eulaStep
+	state      eula.State
+	acceptance paper.EULAAcceptance
+	err        error
+}
+
+func NewEULAView(svc *paper.Service) *EULAView {
+	return &EULAView{svc: svc}
+}
+
+func (v *EULAView) Init() tea.Cmd {
+	return readEULA(v.svc)
+}
+
+func (v *EULAView) Update(msg tea.Msg) (View, tea.Cmd) {
+	switch msg := msg.(type) {
+	case eulaStateMsg:
+		if v.step != eulaLoading {
+			return v, nil
+		}
+		if msg.err != nil {
+			v.step, v.err = eulaError, msg.err
+			return v, nil
+		}
+		v.state, v.step = msg.state, eulaPrompt
+		return v, nil
+
+	case eulaAcceptedMsg:
+		if msg.err != nil {
+			v.step, v.err = eulaError, msg.err
+			return v, nil
+		}
+		v.acceptance, v.step = msg.acceptance, eulaDone
+		return v, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return v, tea.Quit
+		case "esc", "n":
+			if v.step != eulaWriting {
+				return v, backToHome
+			}
+		case "y":
+			if v.step == eulaPrompt && v.state != eula.Accepted {
+				v.step = eulaWriting
+				svc := v.svc
+				return v, func() tea.Msg {
+					a, err := svc.AcceptEULA()
+					return eulaAcceptedMsg{acceptance: a, err: err}
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+func (v *EULAView) View() string {
+	style := components.Body
+
+	switch v.step {
+	case eulaLoading:
+		return style.Render("Reading eula.txt…") + components.NewHelp().View()
+
+	case eulaPrompt:
+		if v.state == eula.Accepted {
+			return style.Render("The Minecraft EULA is already accepted in "+eula.FileName+".") + components.NewHelp().View()
+		}
+		help := components.NewHelp(
+			key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "I accept")),
+			key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", "cancel")),
+		)
+		return style.Render(fmt.Sprintf("Minecraft servers only run once their owner accepts the Minecraft End User\n"+
+			"License Agreement. Here %s,\nso the server would stop as soon as it started.\n\n"+
+			"Read the EULA at:\n\n  %s\n\n"+
+			"Do you accept the Minecraft EULA? (y/n)\n\n"+
+			"%s", eulaStatus(v.state), eula.URL, dimStyle.Render("Accepting writes eula=true to "+eula.FileName+" and records who accepted it,\nand when, in the activity log."))) + help.View()
+
+	case eulaWriting:
+		return style.Render("Writing " + eula.FileName + "…")
+
+	case eulaDone:
+		a := v.acceptance
+		return style.Render(fmt.Sprintf("%s accepted the Minecraft EULA on %s at %s.\nWrote %s; the server can start now.",
+			a.User, a.Host, a.At.Format("2006-01-02 15:04:05"), eula.FileName)) + components.NewHelp().View()
+
+	case eulaError:
+		return style.Render("Could not accept the EULA:\n"+describeErr(v.err)) + components.NewHelp().View()
+	}
+	return style.Render("Unexpected state.") + components.NewHelp().View()
+}
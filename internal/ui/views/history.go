@@ -0,0 +1,127 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/papermc"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
+)
+
+// HistoryView lists past installs, newest first, marking versions Paper has deprecated
+// or no longer supports. Selecting one opens the activity log filtered to that install.
+type HistoryView struct {
+	svc     *paper.Service
+	records []state.Record // newest first, parallel to the list items
+	support map[string]papermc.SupportStatus
+	list    components.List
+	loading bool
+	err     error
+}
+
+// versionsMsg carries the version metadata, for marking versions by support status.
+type versionsMsg struct {
+	versions []papermc.VersionInfo
+	err      error
+}
+
+func NewHistoryView(svc *paper.Service) *HistoryView {
+	return &HistoryView{svc: svc, loading: true}
+}
+
+func (v *HistoryView) Init() tea.Cmd {
+	svc := v.svc
+	return tea.Batch(
+		func() tea.Msg {
+			st, err := svc.Installed()
+			return installedMsg{state: st, err: err}
+		},
+		func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Check)
+			defer cancel()
+			vs, err := svc.Versions(ctx)
+			return versionsMsg{versions: vs, err: err}
+		},
+	)
+}
+
+// items renders the records, with the support status once it is known.
+func (v *HistoryView) items() []components.Item {
+	items := make([]components.Item, len(v.records))
+	for i, r := range v.records {
+		line := fmt.Sprintf("%s build %d  (%s)", r.Version, r.Build, r.InstalledAt.Local().Format("2006-01-02 15:04"))
+		if note := v.support[r.Version].Note(); note != "" {
+			line += "  " + note
+		}
+		items[i] = components.Item(line)
+	}
+	return items
+}
+
+func (v *HistoryView) Update(msg tea.Msg) (View, tea.Cmd) {
+	switch msg := msg.(type) {
+	case installedMsg:
+		v.loading = false
+		v.err = msg.err
+		v.records = slices.Clone(msg.state.History)
+		slices.Reverse(v.records)
+		v.list = components.NewList(v.items(), "Install history")
+		return v, nil
+
+	case versionsMsg:
+		// Without the metadata the history is simply shown unmarked.
+		if msg.err != nil {
+			return v, nil
+		}
+		v.support = make(map[string]papermc.SupportStatus, len(msg.versions))
+		for _, info := range msg.versions {
+			v.support[info.ID] = info.Support.Status
+		}
+		if !v.loading {
+			v.list = v.list.SetItems(v.items())
+		}
+		return v, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return v, tea.Quit
+		case "esc":
+			return v, backToHome
+		case "enter":
+			if i := v.list.Index(); i >= 0 && i < len(v.records) {
+				rec := v.records[i]
+				return v, func() tea.Msg { return ShowLogMsg{Record: rec} }
+			}
+			return v, nil
+		}
+	}
+
+	if !v.loading && len(v.records) > 0 {
+		var cmd tea.Cmd
+		v.list, cmd = v.list.Update(msg)
+		return v, cmd
+	}
+	return v, nil
+}
+
+func (v *HistoryView) View() string {
+	style := components.Body
+	help := components.NewHelp(key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "show log")))
+
+	switch {
+	case v.loading:
+		return style.Render("Reading install history…") + components.NewHelp().View()
+	case v.err != nil:
+		return style.Render(fmt.Sprintf("Unable to read install history:\n%v", v.err)) + components.NewHelp().View()
+	case len(v.records) == 0:
+		return style.Render("No builds have been installed by this tool yet.") + components.NewHelp().View()
+	default:
+		return "\n" + v.list.View() + help.View()
+	}
+}
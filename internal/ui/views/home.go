@@ -19,6 +19,15 @@ const (
 	CheckLatestBuild    MenuAction = "Check latest build"
 	CheckInstalledBuild MenuAction = "Check installed build"
 	DownloadLatestBuild MenuAction = "Download latest build"
+	InstallHistory      MenuAction = "Install history"
+	ActivityLog         MenuAction = "Activity log"
+	WriteStartScript    MenuAction = "Write start script (start.sh)"
+	StartServer         MenuAction = "Start server"
+	StopServer          MenuAction = "Stop server"
+	RestartServer       MenuAction = "Restart server"
+	ServerConsole       MenuAction = "Server console"
+	SafeUpdate          MenuAction = "Safe update (warn, restart, roll back on failure)"
+	AcceptEULA          MenuAction = "Accept the Minecraft EULA"
 	Quit                MenuAction = "Quit"
 )
 
@@ -28,16 +37,37 @@ const (
 	BuildViewID
 	CurrentBuildViewID
 	DownloadBuildID
+	HistoryViewID
+	LogViewID
+	ConsoleViewID
+	StartScriptViewID
+	EULAViewID
 )
 
-func NewHomeView() *HomeView {
+// NewHomeView returns the home menu. With server set it also offers to start, stop and
+// restart the Paper server and to open its console, with safeUpdate to update it
+// while it runs, and with acceptEULA to accept the Minecraft EULA.
+func NewHomeView(server, safeUpdate, acceptEULA bool) *HomeView {
 	items := []components.Item{
 		components.Item(CheckLatestVersion),
 		components.Item(CheckLatestBuild),
 		components.Item(CheckInstalledBuild),
 		components.Item(DownloadLatestBuild),
-		components.Item(Quit),
+		components.Item(InstallHistory),
+		components.Item(ActivityLog),
+		components.Item(WriteStartScript),
 	}
+	if server {
+		items = append(items, components.Item(StartServer), components.Item(StopServer), components.Item(RestartServer),
+			components.Item(ServerConsole))
+	}
+	if safeUpdate {
+		items = append(items, components.Item(SafeUpdate))
+	}
+	if acceptEULA {
+		items = append(items, components.Item(AcceptEULA))
+	}
+	items = append(items, components.Item(Quit))
 
 	list := components.NewList(items, "PaperMC Management CLI")
 
@@ -69,6 +99,30 @@ func (v *HomeView) handleMenuSelection(choice string) tea.Cmd {
 		return func() tea.Msg {
 			return SwitchViewMsg{ViewID: DownloadBuildID}
 		}
+	case string(InstallHistory):
+		return func() tea.Msg {
+			return SwitchViewMsg{ViewID: HistoryViewID}
+		}
+	case string(ActivityLog):
+		return func() tea.Msg {
+			return SwitchViewMsg{ViewID: LogViewID}
+		}
+	case string(WriteStartScript):
+		return func() tea.Msg {
+			return SwitchViewMsg{ViewID: StartScriptViewID}
+		}
+	case string(StartServer):
+		return func() tea.Msg { return ServerActionMsg{Action: ServerStart} }
+	case string(StopServer):
+		return func() tea.Msg { return ServerActionMsg{Action: ServerStop} }
+	case string(RestartServer):
+		return func() tea.Msg { return ServerActionMsg{Action: ServerRestart} }
+	case string(ServerConsole):
+		return func() tea.Msg { return SwitchViewMsg{ViewID: ConsoleViewID} }
+	case string(SafeUpdate):
+		return func() tea.Msg { return SafeUpdateMsg{} }
+	case string(AcceptEULA):
+		return func() tea.Msg { return SwitchViewMsg{ViewID: EULAViewID} }
 	case string(Quit):
 		return tea.Quit
 	}
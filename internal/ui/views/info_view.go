@@ -3,16 +3,12 @@ package views
 import (
 	"context"
 	"fmt"
-	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mbacalan/paper-mc-tui/internal/paper"
 	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
 )
 
-// checkTimeout bounds a "check latest" API round-trip.
-const checkTimeout = 30 * time.Second
-
 // latestMsg carries the result of an async CheckLatest call.
 type latestMsg struct {
 	info paper.LatestInfo
@@ -22,7 +18,7 @@ type latestMsg struct {
 // checkLatestCmd returns a command that resolves the latest release off the UI thread.
 func checkLatestCmd(svc *paper.Service) tea.Cmd {
 	return func() tea.Msg {
-		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Check)
 		defer cancel()
 		info, err := svc.CheckLatest(ctx)
 		return latestMsg{info: info, err: err}
@@ -0,0 +1,305 @@
+package views
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/state"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
+)
+
+const (
+	// logPollInterval is how often the log view checks the activity log for new records.
+	logPollInterval = time.Second
+	// maxLogRecords caps the records the log view keeps; older ones are dropped, so a
+	// view left open for days does not grow without bound.
+	maxLogRecords = 10000
+)
+
+// eventFilters is the cycle of event filters offered by the log view. "error" is not an
+// event type of its own: it selects records logged at ERROR or carrying an error field.
+var eventFilters = []string{"", logging.EventInstall, logging.EventBackup, logging.EventRollback, logging.EventDownload,
+	logging.EventCheck, logging.EventVerify, logging.EventRecover, logging.EventMigrate, logging.EventAPI, logging.EventWatch, logging.EventControl, logging.EventNotify, logging.EventSelfUpdate,
+	logging.EventServer, logging.EventRCON, logging.EventSafeUpdate, logging.EventStartScript, logging.EventSystemd, logging.EventSmokeTest, logging.EventEULA, "error"}
+
+// levelFilters is the cycle of minimum levels offered by the log view.
+var levelFilters = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+var (
+	warnStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	dimStyle   = lipgloss.NewStyle().Faint(true)
+)
+
+// logTickID distinguishes poll loops, so a tick from a log view we have left does not
+// start a second loop in a new one.
+var logTickID atomic.Int64
+
+type logTickMsg struct {
+	id      int64
+	records []logging.Record
+	err     error
+}
+
+// ShowLogMsg opens the log view focused on one install's records.
+type ShowLogMsg struct {
+	Record state.Record
+}
+
+// LogView tails the activity log in a scrollable viewport, with search and filters.
+type LogView struct {
+	follower *logging.Follower
+	tickID   int64
+	records  []logging.Record
+	err      error
+
+	viewport  viewport.Model
+	search    textinput.Model
+	searching bool
+	query     string
+	level     int // index into levelFilters
+	event     int // index into eventFilters
+	focus     *state.Record
+	follow    bool // keep the viewport pinned to the newest record
+}
+
+// NewLogView returns a log view. If focus is non-nil only that install's records are
+// shown, until the filters are cleared.
+func NewLogView(svc *paper.Service, focus *state.Record) *LogView {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = "search"
+	ti.CharLimit = 100
+	ti.Width = 40
+
+	return &LogView{
+		follower: logging.NewFollower(svc.LogPath()),
+		tickID:   logTickID.Add(1),
+		viewport: viewport.New(80, 20),
+		search:   ti,
+		level:    1, // info
+		focus:    focus,
+		follow:   focus == nil,
+	}
+}
+
+func (v *LogView) Init() tea.Cmd {
+	return v.poll(0)
+}
+
+// poll reads new records after delay, off the UI thread.
+func (v *LogView) poll(delay time.Duration) tea.Cmd {
+	follower, id := v.follower, v.tickID
+	read := func() tea.Msg {
+		recs, err := follower.Next()
+		return logTickMsg{id: id, records: recs, err: err}
+	}
+	if delay == 0 {
+		return read
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg { return read() })
+}
+
+func (v *LogView) Update(msg tea.Msg) (View, tea.Cmd) {
+	switch msg := msg.(type) {
+	case logTickMsg:
+		if msg.id != v.tickID {
+			return v, nil
+		}
+		v.err = msg.err
+		if len(msg.records) > 0 {
+			v.records = append(v.records, msg.records...)
+			if over := len(v.records) - maxLogRecords; over > 0 {
+				v.records = slices.Delete(v.records, 0, over)
+			}
+			v.render()
+		}
+		return v, v.poll(logPollInterval)
+
+	case tea.WindowSizeMsg:
+		v.viewport.Width = msg.Width - 4
+		v.viewport.Height = max(msg.Height-6, 3)
+		v.render()
+		return v, nil
+
+	case tea.KeyMsg:
+		if v.searching {
+			return v.handleSearchKey(msg)
+		}
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return v, tea.Quit
+		case "esc":
+			return v, backToHome
+		case "/":
+			v.searching = true
+			v.search.SetValue(v.query)
+			return v, v.search.Focus()
+		case "l":
+			v.level = (v.level + 1) % len(levelFilters)
+			v.render()
+			return v, nil
+		case "e":
+			v.event = (v.event + 1) % len(eventFilters)
+			v.render()
+			return v, nil
+		case "c":
+			v.query, v.level, v.event, v.focus = "", 1, 0, nil
+			v.render()
+			return v, nil
+		case "f", "G", "end":
+			v.follow = true
+			v.viewport.GotoBottom()
+			return v, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	v.viewport, cmd = v.viewport.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		v.follow = v.viewport.AtBottom()
+	}
+	return v, cmd
+}
+
+func (v *LogView) handleSearchKey(msg tea.KeyMsg) (View, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return v, tea.Quit
+	case "enter":
+		v.query = strings.TrimSpace(v.search.Value())
+		v.searching = false
+		v.search.Blur()
+		v.render()
+		return v, nil
+	case "esc":
+		v.searching = false
+		v.search.Blur()
+		return v, nil
+	}
+	var cmd tea.Cmd
+	v.search, cmd = v.search.Update(msg)
+	return v, cmd
+}
+
+// matches reports whether a record passes the current filters.
+func (v *LogView) matches(r logging.Record) bool {
+	if r.Level < levelFilters[v.level] {
+		return false
+	}
+	switch ev := eventFilters[v.event]; ev {
+	case "":
+	case "error":
+		if _, hasErr := r.Attrs[logging.KeyError]; !hasErr && r.Level < slog.LevelError {
+			return false
+		}
+	default:
+		if r.Event() != ev {
+			return false
+		}
+	}
+	if v.focus != nil && !recordOf(r, *v.focus) {
+		return false
+	}
+	if v.query != "" && !strings.Contains(strings.ToLower(r.Raw), strings.ToLower(v.query)) {
+		return false
+	}
+	return true
+}
+
+// recordOf reports whether a log record belongs to the given install.
+func recordOf(r logging.Record, h state.Record) bool {
+	if r.Attrs[logging.KeyVersion] == h.Version && r.Attrs[logging.KeyBuild] == strconv.Itoa(h.Build) {
+		return true
+	}
+	return h.JarName != "" && slices.Contains([]string{r.Attrs["jar"], r.Attrs["name"]}, h.JarName)
+}
+
+// render rebuilds the viewport content from the records that pass the filters.
+func (v *LogView) render() {
+	var b strings.Builder
+	for _, r := range v.records {
+		if !v.matches(r) {
+			continue
+		}
+		b.WriteString(formatRecord(r))
+		b.WriteByte('\n')
+	}
+	if b.Len() == 0 {
+		b.WriteString(dimStyle.Render("No matching log records."))
+	}
+	v.viewport.SetContent(strings.TrimRight(b.String(), "\n"))
+	if v.follow {
+		v.viewport.GotoBottom()
+	}
+}
+
+// formatRecord renders one record as "15:04:05 LEVEL event  message key=value …".
+func formatRecord(r logging.Record) string {
+	keys := make([]string, 0, len(r.Attrs))
+	for k := range r.Attrs {
+		if k != logging.KeyEvent {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	var fields strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&fields, " %s=%s", k, r.Attrs[k])
+	}
+
+	line := fmt.Sprintf("%s %-5s %-8s %s", r.Time.Local().Format("01-02 15:04:05"), r.Level, r.Event(), r.Msg)
+	switch {
+	case r.Level >= slog.LevelError:
+		line = errorStyle.Render(line)
+	case r.Level >= slog.LevelWarn:
+		line = warnStyle.Render(line)
+	}
+	return line + dimStyle.Render(fields.String())
+}
+
+func (v *LogView) View() string {
+	event := eventFilters[v.event]
+	if event == "" {
+		event = "all"
+	}
+	status := fmt.Sprintf("level ≥ %s · event: %s", levelFilters[v.level], event)
+	if v.focus != nil {
+		status += fmt.Sprintf(" · install: %s build %d", v.focus.Version, v.focus.Build)
+	}
+	if v.query != "" {
+		status += fmt.Sprintf(" · search: %q", v.query)
+	}
+	if v.follow {
+		status += " · following"
+	}
+	header := components.Body.Render("Activity log (" + status + ")")
+	if v.err != nil {
+		header += "\n" + errorStyle.Render("  "+v.err.Error())
+	}
+
+	body := lipgloss.NewStyle().Margin(0, 2).Render(v.viewport.View())
+	if v.searching {
+		return header + "\n" + body + "\n  " + v.search.View() + "\n\n  (Enter to search, Esc to cancel)"
+	}
+	help := components.NewHelp(
+		key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
+		key.NewBinding(key.WithKeys("l"), key.WithHelp("l", "level")),
+		key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "event")),
+		key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "clear filters")),
+		key.NewBinding(key.WithKeys("f"), key.WithHelp("f", "follow")),
+	)
+	return header + "\n" + body + help.View()
+}
@@ -1,8 +1,20 @@
 package views
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mbacalan/paper-mc-tui/internal/eula"
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
 	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/safeupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/selfupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+	"github.com/mbacalan/paper-mc-tui/internal/systemd"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
 )
 
 // View is the interface that all views must implement
@@ -19,15 +31,163 @@ type ViewID int
 type Manager struct {
 	svc         *paper.Service
 	currentView View
+
+	// notice is shown above the home menu, e.g. to report startup crash recovery.
+	notice string
+
+	// updater, if set, is asked once at startup whether a newer paper-mc-tui exists;
+	// updateNotice then says so above the home menu.
+	updater      *selfupdate.Updater
+	updateNotice string
+
+	// supportNotice warns that the installed Paper version is deprecated or end of life.
+	supportNotice string
+
+	// eula is whether the Minecraft EULA has been accepted, once known. Until it is, the
+	// home view offers to accept it and starting the server asks for it first.
+	eula      eula.State
+	eulaKnown bool
+
+	// server, if set, runs the Paper server; the home view shows its status and offers
+	// to start, stop and restart it. serverBusy describes an action in progress.
+	server       Server
+	serverStatus supervisor.Status
+	serverBusy   string
+	serverNotice string
+	history      commandHistory // the console's, kept across visits
+
+	// safe, if set, runs safe updates; safeRun is the one in progress.
+	safe    *safeupdate.Updater
+	safeRun *safeUpdate
+
+	// logFile, if set, is the activity log; the home view warns once it cannot be written.
+	logFile *logging.File
+
+	// size is the last terminal size, replayed to each new view since Bubble Tea only
+	// sends it at startup and on resize.
+	size *tea.WindowSizeMsg
+}
+
+// recoveredMsg carries the result of the startup Service.Recover call.
+type recoveredMsg struct {
+	recovery paper.Recovery
+	err      error
 }
 
-func NewManager(svc *paper.Service) *Manager {
-	return &Manager{svc: svc}
+// selfUpdateMsg carries the result of the startup self-update check.
+type selfUpdateMsg struct {
+	status selfupdate.Status
+	err    error
+}
+
+// supportMsg carries the installed version's support status, asked for at startup.
+type supportMsg struct {
+	support paper.Support
+	err     error
+}
+
+// logCheckedMsg redraws the home view once the startup check of the activity log is done.
+type logCheckedMsg struct{}
+
+// selfUpdateCheckTimeout bounds the startup self-update check, which only feeds a notice.
+const selfUpdateCheckTimeout = 10 * time.Second
+
+// ManagerOption configures a Manager.
+type ManagerOption func(*Manager)
+
+// WithSelfUpdate checks for a newer paper-mc-tui release in the background at startup
+// and mentions it on the home view.
+func WithSelfUpdate(u *selfupdate.Updater) ManagerOption {
+	return func(m *Manager) { m.updater = u }
+}
+
+// WithServer lets the TUI start, stop and restart the Paper server and open its
+// console.
+func WithServer(s Server) ManagerOption {
+	return func(m *Manager) { m.server = s }
+}
+
+// WithSafeUpdate lets the TUI update a running server with a countdown, restart and
+// automatic rollback. It needs WithServer.
+func WithSafeUpdate(u *safeupdate.Updater) ManagerOption {
+	return func(m *Manager) { m.safe = u }
+}
+
+// WithLogFile has the home view warn when the activity log cannot be written.
+func WithLogFile(f *logging.File) ManagerOption {
+	return func(m *Manager) { m.logFile = f }
+}
+
+func NewManager(svc *paper.Service, opts ...ManagerOption) *Manager {
+	m := &Manager{svc: svc}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 func (m *Manager) Init() tea.Cmd {
-	m.currentView = NewHomeView()
-	return m.currentView.Init()
+	m.currentView = m.newHomeView()
+	svc := m.svc
+	recoverCmd := func() tea.Msg {
+		r, err := svc.Recover()
+		return recoveredMsg{recovery: r, err: err}
+	}
+	cmds := []tea.Cmd{m.currentView.Init(), recoverCmd, m.checkSupport(), readEULA(svc)}
+	if u := m.updater; u != nil {
+		cmds = append(cmds, func() tea.Msg {
+			ctx, cancel := context.WithTimeout(context.Background(), selfUpdateCheckTimeout)
+			defer cancel()
+			st, err := u.Check(ctx)
+			return selfUpdateMsg{status: st, err: err}
+		})
+	}
+	if m.server != nil {
+		cmds = append(cmds, pollServer(m.server, 0))
+	}
+	if f := m.logFile; f != nil {
+		cmds = append(cmds, func() tea.Msg { f.Check(); return logCheckedMsg{} })
+	}
+	return tea.Batch(cmds...)
+}
+
+// checkSupport asks for the installed version's support status, at startup and again
+// after each install.
+func (m *Manager) checkSupport() tea.Cmd {
+	svc := m.svc
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Check)
+		defer cancel()
+		sup, err := svc.InstalledSupport(ctx)
+		return supportMsg{support: sup, err: err}
+	}
+}
+
+func (m *Manager) newHomeView() *HomeView {
+	return NewHomeView(m.server != nil, m.server != nil && m.safe != nil, m.eulaPending())
+}
+
+// eulaPending reports whether the EULA is known not to have been accepted.
+func (m *Manager) eulaPending() bool { return m.eulaKnown && m.eula != eula.Accepted }
+
+// eulaNotice says that the server will not start until the EULA is accepted.
+func (m *Manager) eulaNotice() string {
+	if !m.eulaPending() {
+		return ""
+	}
+	return fmt.Sprintf("The Minecraft EULA has not been accepted (%s), so the server will not start.\n"+
+		"Choose %q to read and accept it.", eulaStatus(m.eula), AcceptEULA)
+}
+
+// logNotice says that the activity log cannot be written.
+func (m *Manager) logNotice() string {
+	if m.logFile == nil {
+		return ""
+	}
+	if err := m.logFile.Err(); err != nil {
+		return "The activity log cannot be written, so events are being lost:\n" + describeErr(err)
+	}
+	return ""
 }
 
 func (m *Manager) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -36,6 +196,88 @@ func (m *Manager) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case SwitchViewMsg:
 		return m.switchView(msg.ViewID)
+	case ShowLogMsg:
+		rec := msg.Record
+		return m.show(NewLogView(m.svc, &rec))
+	case tea.WindowSizeMsg:
+		m.size = &msg
+	case recoveredMsg:
+		if msg.err != nil {
+			m.notice = "Could not recover an interrupted operation:\n" + describeErr(msg.err)
+		} else {
+			m.notice = msg.recovery.String()
+		}
+		return m, nil
+	case selfUpdateMsg:
+		// A failed check is not worth interrupting anyone for; self-update reports it.
+		if msg.err == nil && msg.status.Newer {
+			m.updateNotice = fmt.Sprintf("paper-mc-tui %s is available (running %s). Run \"paper-mc-tui self-update\" to upgrade.",
+				msg.status.Latest.Tag, msg.status.Current)
+		}
+		return m, nil
+	case supportMsg:
+		// Like the self-update check, this only feeds a notice; offline it says nothing.
+		if msg.err == nil {
+			m.supportNotice = msg.support.String()
+		}
+		return m, nil
+	case eulaStateMsg:
+		// Like the support check, a failed read only goes unmentioned; the view reports it.
+		if msg.err == nil {
+			pending := m.eulaPending()
+			m.eula, m.eulaKnown = msg.state, true
+			if _, home := m.currentView.(*HomeView); home && pending != m.eulaPending() {
+				m.currentView = m.newHomeView()
+				return m, nil
+			}
+		}
+	case eulaAcceptedMsg:
+		if msg.err == nil {
+			m.eula, m.eulaKnown = eula.Accepted, true
+		}
+	case ServerActionMsg:
+		if m.server == nil || m.serverBusy != "" {
+			return m, nil
+		}
+		if msg.Action != ServerStop && m.eulaPending() {
+			return m.switchView(EULAViewID)
+		}
+		m.serverBusy, m.serverNotice = serverBusyText[msg.Action], ""
+		return m, runServerAction(m.server, msg.Action)
+	case SafeUpdateMsg:
+		if m.server == nil || m.safe == nil || m.serverBusy != "" {
+			return m, nil
+		}
+		m.serverBusy, m.serverNotice = "safe update: starting…", ""
+		m.safeRun = startSafeUpdate(m.safe)
+		return m, m.safeRun.wait()
+	case safeUpdateStepMsg:
+		m.serverBusy = "safe update: " + string(msg) + "…"
+		return m, m.safeRun.wait()
+	case safeUpdateDoneMsg:
+		m.serverBusy, m.safeRun = "", nil
+		m.serverNotice = describeSafeUpdate(msg)
+		return m, tea.Batch(m.checkSupport(), readEULA(m.svc))
+	case doneMsg:
+		// The download view's install finished; it still gets the message below.
+		if msg.err == nil {
+			m.currentView, cmd = m.currentView.Update(msg)
+			return m, tea.Batch(cmd, m.checkSupport(), readEULA(m.svc))
+		}
+	case serverDoneMsg:
+		m.serverBusy = ""
+		if msg.err != nil {
+			m.serverNotice = serverFailureText[msg.action] + describeServerErr(msg.err)
+		} else {
+			m.serverStatus = msg.status
+		}
+		// A first start writes eula.txt, declining the EULA.
+		return m, readEULA(m.svc)
+	case serverStatusMsg:
+		if msg.err == nil && m.serverBusy == "" {
+			m.serverStatus = msg.status
+		}
+		return m, pollServer(m.server, serverPollInterval)
 	}
 
 	m.currentView, cmd = m.currentView.Update(msg)
@@ -43,9 +285,35 @@ func (m *Manager) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Manager) View() string {
+	if _, home := m.currentView.(*HomeView); home {
+		var notices []string
+		for _, n := range []string{m.notice, m.logNotice(), m.eulaNotice(), m.supportNotice, m.updateNotice, m.serverLine(), m.serverNotice} {
+			if n != "" {
+				notices = append(notices, n)
+			}
+		}
+		if len(notices) > 0 {
+			return components.Body.Render(strings.Join(notices, "\n\n")) + m.currentView.View()
+		}
+	}
 	return m.currentView.View()
 }
 
+// serverLine is the home view's server status, if the TUI runs the server.
+func (m *Manager) serverLine() string {
+	if m.server == nil {
+		return ""
+	}
+	label := "Server"
+	if u, ok := m.server.(*systemd.Unit); ok {
+		label = "Server (" + u.Name() + ")"
+	}
+	if m.serverBusy != "" {
+		return label + ": " + m.serverBusy
+	}
+	return label + ": " + m.serverStatus.String()
+}
+
 // SwitchViewMsg is used to switch between views
 type SwitchViewMsg struct {
 	ViewID ViewID
@@ -56,7 +324,7 @@ func (m *Manager) switchView(id ViewID) (tea.Model, tea.Cmd) {
 
 	switch id {
 	case HomeViewID:
-		view = NewHomeView()
+		view = m.newHomeView()
 	case VersionViewID:
 		view = NewVersionView(m.svc)
 	case BuildViewID:
@@ -65,10 +333,33 @@ func (m *Manager) switchView(id ViewID) (tea.Model, tea.Cmd) {
 		view = NewCurrentBuildView(m.svc)
 	case DownloadBuildID:
 		view = NewDownloadView(m.svc)
+	case HistoryViewID:
+		view = NewHistoryView(m.svc)
+	case LogViewID:
+		view = NewLogView(m.svc, nil)
+	case ConsoleViewID:
+		if m.server == nil {
+			view = m.newHomeView()
+			break
+		}
+		view = NewConsoleView(m.server, &m.history)
+	case StartScriptViewID:
+		view = NewStartScriptView(m.svc)
+	case EULAViewID:
+		view = NewEULAView(m.svc)
 	default:
-		view = NewHomeView()
+		view = m.newHomeView()
 	}
 
+	return m.show(view)
+}
+
+// show makes view current and initializes it with the known terminal size.
+func (m *Manager) show(view View) (tea.Model, tea.Cmd) {
 	m.currentView = view
-	return m, view.Init()
+	if m.size == nil {
+		return m, view.Init()
+	}
+	size := *m.size
+	return m, tea.Batch(view.Init(), func() tea.Msg { return size })
 }
@@ -0,0 +1,174 @@
+package views
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mbacalan/paper-mc-tui/internal/safeupdate"
+	"github.com/mbacalan/paper-mc-tui/internal/supervisor"
+)
+
+// serverPollInterval is how often the home view's server status is refreshed, to notice
+// a server that exits or is started by another copy of the tool.
+const serverPollInterval = 2 * time.Second
+
+// Server runs the Paper server: a supervisor.Supervisor, or a systemd.Unit when
+// systemd manages it.
+type Server interface {
+	Status() (supervisor.Status, error)
+	Start() (supervisor.Status, error)
+	Stop() error
+	Restart() (supervisor.Status, error)
+	Send(line string) error
+	Console() *supervisor.Console
+}
+
+// ServerAction is a home menu request to start, stop or restart the Paper server.
+type ServerAction int
+
+const (
+	ServerStart ServerAction = iota
+	ServerStop
+	ServerRestart
+)
+
+// ServerActionMsg asks the Manager to run a ServerAction.
+type ServerActionMsg struct {
+	Action ServerAction
+}
+
+// serverStatusMsg carries a polled server status.
+type serverStatusMsg struct {
+	status supervisor.Status
+	err    error
+}
+
+// serverDoneMsg carries the outcome of a ServerAction.
+type serverDoneMsg struct {
+	action ServerAction
+	status supervisor.Status
+	err    error
+}
+
+// pollServer reads the server status after delay.
+func pollServer(sup Server, delay time.Duration) tea.Cmd {
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		st, err := sup.Status()
+		return serverStatusMsg{status: st, err: err}
+	})
+}
+
+// runServerAction starts, stops or restarts the server in the background. Stopping can
+// take as long as stop_timeout.
+func runServerAction(sup Server, action ServerAction) tea.Cmd {
+	return func() tea.Msg {
+		var st supervisor.Status
+		var err error
+		switch action {
+		case ServerStart:
+			st, err = sup.Start()
+		case ServerStop:
+			err = sup.Stop()
+		case ServerRestart:
+			st, err = sup.Restart()
+		}
+		return serverDoneMsg{action: action, status: st, err: err}
+	}
+}
+
+// serverBusyText is shown while an action is running.
+var serverBusyText = map[ServerAction]string{
+	ServerStart:   "starting…",
+	ServerStop:    "stopping (waiting for it to save and exit)…",
+	ServerRestart: "restarting…",
+}
+
+// serverFailureText introduces an action's error.
+var serverFailureText = map[ServerAction]string{
+	ServerStart:   "Could not start the server:\n",
+	ServerStop:    "Could not stop the server:\n",
+	ServerRestart: "Could not restart the server:\n",
+}
+
+// describeServerErr explains the errors the user can act on.
+func describeServerErr(err error) string {
+	switch {
+	case errors.Is(err, supervisor.ErrRunning):
+		return "It is already running."
+	case errors.Is(err, supervisor.ErrNotRunning):
+		return "It is not running."
+	}
+	return describeErr(err)
+}
+
+// SafeUpdateMsg asks the Manager to run a safe update: warn the players, stop the
+// server, install the latest build and start it again.
+type SafeUpdateMsg struct{}
+
+// safeUpdateStepMsg reports the step a safe update has reached.
+type safeUpdateStepMsg string
+
+// safeUpdateDoneMsg carries the outcome of a safe update.
+type safeUpdateDoneMsg struct {
+	res safeupdate.Result
+	err error
+}
+
+// safeUpdate is a safe update running in the background.
+type safeUpdate struct {
+	steps chan string
+	done  chan safeUpdateDoneMsg
+}
+
+// startSafeUpdate runs u in the background. It takes minutes: the countdown, the
+// download and the server's startup.
+func startSafeUpdate(u *safeupdate.Updater) *safeUpdate {
+	s := &safeUpdate{steps: make(chan string, 32), done: make(chan safeUpdateDoneMsg, 1)}
+	go func() {
+		res, err := u.Run(context.Background(), func(_ safeupdate.Step, detail string) {
+			select {
+			case s.steps <- detail:
+			default: // UI busy; the next step replaces this one anyway
+			}
+		})
+		s.done <- safeUpdateDoneMsg{res: res, err: err}
+	}()
+	return s
+}
+
+// wait blocks (off the UI thread) for the next step or the outcome.
+func (s *safeUpdate) wait() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case detail := <-s.steps:
+			return safeUpdateStepMsg(detail)
+		case done := <-s.done:
+			return done
+		}
+	}
+}
+
+// describeSafeUpdate is the home view's notice once a safe update is over.
+func describeSafeUpdate(msg safeUpdateDoneMsg) string {
+	res := msg.res
+	switch {
+	case res.RolledBack:
+		why := "it exited while starting; see logs/latest.log"
+		if errors.Is(msg.err, safeupdate.ErrNotReady) {
+			why = `it did not log "Done" within ready_timeout`
+		}
+		restored := res.Restored.Restored
+		return fmt.Sprintf("Safe update: %s build %d did not start (%s), so %s build %d was restored and started again.",
+			res.Latest.Version, res.Latest.Build, why, restored.Version, restored.Build)
+	case msg.err != nil:
+		return "Safe update failed:\n" + describeServerErr(msg.err)
+	case res.UpToDate:
+		return fmt.Sprintf("Safe update: already up to date (%s build %d); the server was left running.",
+			res.Latest.Version, res.Latest.Build)
+	}
+	return fmt.Sprintf("Safe update: now running %s build %d (ready in %s).",
+		res.Latest.Version, res.Latest.Build, res.Ready.Round(time.Second))
+}
@@ -0,0 +1,278 @@
+package views
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+	"github.com/mbacalan/paper-mc-tui/internal/startscript"
+	"github.com/mbacalan/paper-mc-tui/internal/ui/components"
+)
+
+type startScriptStep int
+
+const (
+	scriptLoading startScriptStep = iota
+	scriptMemory
+	scriptRestart
+	scriptPlanning
+	scriptReview
+	scriptDone
+	scriptError
+)
+
+// fallbackHeapMB is offered when the machine's memory cannot be read, e.g. off Linux.
+const fallbackHeapMB = 2 << 10
+
+var addStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("70"))
+
+// startScriptMemoryMsg carries the machine's memory, which the heap is sized from.
+type startScriptMemoryMsg struct {
+	mem startscript.Memory
+	err error
+}
+
+// startScriptPlannedMsg carries the rendered script and its comparison with the
+// start.sh on disk.
+type startScriptPlannedMsg struct {
+	plan paper.StartScript
+	err  error
+}
+
+// startScriptWrittenMsg reports the write.
+type startScriptWrittenMsg struct{ err error }
+
+// StartScriptView is a wizard that writes start.sh: it asks for the heap size,
+// suggesting one from the machine's memory, and whether to restart after crashes, then
+// shows the diff against the current script before writing it.
+type StartScriptView struct {
+	svc    *paper.Service
+	step   startScriptStep
+	mem    startScriptMemoryMsg
+	memory textinput.Model
+	// memErr is why the typed size was rejected.
+	memErr error
+	heapMB int
+	plan   paper.StartScript
+	diff   viewport.Model
+	err    error
+}
+
+func NewStartScriptView(svc *paper.Service) *StartScriptView {
+	ti := textinput.New()
+	ti.CharLimit = 10
+	ti.Width = 12
+	return &StartScriptView{svc: svc, memory: ti, diff: viewport.New(80, 15)}
+}
+
+func (v *StartScriptView) Init() tea.Cmd {
+	return func() tea.Msg {
+		mem, err := startscript.ReadMemory(startscript.MemInfoPath)
+		return startScriptMemoryMsg{mem: mem, err: err}
+	}
+}
+
+// planScript renders the script and compares it with the one on disk.
+func (v *StartScriptView) planScript(restart bool) tea.Cmd {
+	svc, opts := v.svc, paper.StartScriptOptions{HeapMB: v.heapMB, Restart: restart}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), svc.Timeouts().Check)
+		defer cancel()
+		plan, err := svc.PlanStartScript(ctx, opts)
+		return startScriptPlannedMsg{plan: plan, err: err}
+	}
+}
+
+func (v *StartScriptView) Update(msg tea.Msg) (View, tea.Cmd) {
+	switch msg := msg.(type) {
+	case startScriptMemoryMsg:
+		v.mem = msg
+		heap := fallbackHeapMB
+		if msg.err == nil {
+			heap = startscript.SuggestHeap(msg.mem.UsableMB())
+		}
+		v.memory.SetValue(startscript.FormatSize(heap))
+		v.memory.CursorEnd()
+		v.step = scriptMemory
+		return v, v.memory.Focus()
+
+	case startScriptPlannedMsg:
+		if msg.err != nil {
+			v.step, v.err = scriptError, msg.err
+			return v, nil
+		}
+		v.plan, v.step = msg.plan, scriptReview
+		v.diff.SetContent(colorDiff(msg.plan.Change.Diff()))
+		v.diff.GotoTop()
+		return v, nil
+
+	case startScriptWrittenMsg:
+		if msg.err != nil {
+			v.step, v.err = scriptError, msg.err
+			return v, nil
+		}
+		v.step = scriptDone
+		return v, nil
+
+	case tea.WindowSizeMsg:
+		v.diff.Width = msg.Width - 4
+		v.diff.Height = max(msg.Height-12, 3)
+		return v, nil
+
+	case tea.KeyMsg:
+		return v.handleKey(msg)
+	}
+	return v, nil
+}
+
+func (v *StartScriptView) handleKey(msg tea.KeyMsg) (View, tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		return v, tea.Quit
+	}
+	switch v.step {
+	case scriptMemory:
+		switch msg.String() {
+		case "enter":
+			heap, err := startscript.ParseSize(v.memory.Value())
+			if err != nil {
+				v.memErr = err
+				return v, nil
+			}
+			v.memErr, v.heapMB = nil, heap
+			v.step = scriptRestart
+			return v, nil
+		case "esc":
+			return v, backToHome
+		}
+		var cmd tea.Cmd
+		v.memory, cmd = v.memory.Update(msg)
+		return v, cmd
+
+	case scriptRestart:
+		switch msg.String() {
+		case "y", "n":
+			v.step = scriptPlanning
+			return v, v.planScript(msg.String() == "y")
+		case "esc":
+			v.step = scriptMemory
+			return v, v.memory.Focus()
+		case "q":
+			return v, tea.Quit
+		}
+
+	case scriptReview:
+		switch msg.String() {
+		case "y", "enter":
+			if !v.plan.Change.Changed() {
+				return v, backToHome
+			}
+			svc, plan := v.svc, v.plan
+			return v, func() tea.Msg { return startScriptWrittenMsg{err: svc.WriteStartScript(plan)} }
+		case "n", "esc":
+			return v, backToHome
+		case "q":
+			return v, tea.Quit
+		}
+		var cmd tea.Cmd
+		v.diff, cmd = v.diff.Update(msg)
+		return v, cmd
+
+	default: // scriptLoading, scriptPlanning, scriptDone, scriptError
+		switch msg.String() {
+		case "q":
+			return v, tea.Quit
+		case "esc":
+			return v, backToHome
+		}
+	}
+	return v, nil
+}
+
+func (v *StartScriptView) View() string {
+	style := components.Body
+
+	switch v.step {
+	case scriptLoading:
+		return style.Render("Reading this machine's memory…") + components.NewHelp().View()
+
+	case scriptMemory:
+		var b strings.Builder
+		if v.mem.err == nil {
+			mem := v.mem.mem
+			fmt.Fprintf(&b, "This machine has %.1f GB of memory (%.1f GB available now).\n",
+				float64(mem.TotalMB)/1024, float64(mem.AvailableMB)/1024)
+			if mem.UsableMB() < mem.TotalMB {
+				fmt.Fprintf(&b, "Its cgroup limits the server to %.1f GB; the suggestion is sized for that.\n", float64(mem.UsableMB())/1024)
+			}
+			b.WriteString("The suggestion leaves a quarter of it, and at least 1 GB, to everything else.\n\n")
+		} else {
+			fmt.Fprintf(&b, "Could not read this machine's memory (%v).\n\n", v.mem.err)
+		}
+		b.WriteString("Heap size for the server, e.g. 6G or 1536M:")
+		text := style.Render(b.String()) + "\n  " + v.memory.View()
+		if v.memErr != nil {
+			text += "\n\n  " + errorStyle.Render(v.memErr.Error())
+		}
+		return text + "\n\n  (press Enter to continue, Esc to go back)"
+
+	case scriptRestart:
+		help := components.NewHelp(
+			key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yes")),
+			key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "no")),
+		)
+		return style.Render("Start the server again automatically whenever it crashes? (y/n)\n\n"+
+			"A clean \"stop\" still shuts it down for good.") + help.View()
+
+	case scriptPlanning:
+		return style.Render("Fetching Paper's recommended flags…") + components.NewHelp().View()
+
+	case scriptReview:
+		header := fmt.Sprintf("Heap %s; %s.", startscript.FormatSize(v.plan.HeapMB), v.plan.FlagsFrom)
+		if v.plan.FlagsErr != nil {
+			header += "\n" + warnStyle.Render("The API gave no recommended flags: "+v.plan.FlagsErr.Error())
+		}
+		if !v.plan.Change.Changed() {
+			return style.Render(header+"\n\n"+v.plan.Change.Path+" is already up to date.") + components.NewHelp().View()
+		}
+		verb := "Replace"
+		if !v.plan.Change.Exists() {
+			verb = "Create"
+		}
+		help := components.NewHelp(
+			key.NewBinding(key.WithKeys("y", "enter"), key.WithHelp("y", "write")),
+			key.NewBinding(key.WithKeys("n", "esc"), key.WithHelp("n", "cancel")),
+			key.NewBinding(key.WithKeys("pgup", "pgdown"), key.WithHelp("pgup/pgdn", "scroll")),
+		)
+		body := lipgloss.NewStyle().Margin(0, 2).Render(v.diff.View())
+		return style.Render(fmt.Sprintf("%s\n\n%s %s with these changes?", header, verb, v.plan.Change.Path)) + "\n" + body + help.View()
+
+	case scriptDone:
+		return style.Render(fmt.Sprintf("Wrote %s.", v.plan.Change.Path)) + components.NewHelp().View()
+
+	case scriptError:
+		return style.Render("Could not write the start script:\n"+describeErr(v.err)) + components.NewHelp().View()
+	}
+	return style.Render("Unexpected state.") + components.NewHelp().View()
+}
+
+// colorDiff colours a unified diff: additions green, removals red, hunk headers dim.
+func colorDiff(diff string) string {
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "@@"):
+			lines[i] = dimStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = addStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = errorStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,212 @@
+// Package watch runs the tool as a long-lived daemon: it polls for new Paper builds on
+// an interval with random jitter, logs and announces each new build once, and, when
+// auto-install is enabled, installs it inside a daily maintenance window.
+package watch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/logging"
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+)
+
+const (
+	// DefaultInterval is how often to check when no interval is configured.
+	DefaultInterval = time.Hour
+	// minInterval stops a misconfiguration from hammering the API.
+	minInterval = time.Minute
+)
+
+// Updater is the part of paper.Service the watcher drives.
+type Updater interface {
+	CheckLatest(ctx context.Context) (paper.LatestInfo, error)
+	Install(ctx context.Context, opts paper.InstallOptions) error
+	Timeouts() paper.Timeouts
+	BackupPolicy() paper.BackupPolicy
+}
+
+// Outcome is what one poll did.
+type Outcome int
+
+const (
+	OutcomeFailed    Outcome = iota // the check or the install failed; retried next poll
+	OutcomeUpToDate                 // nothing newer than what is installed
+	OutcomeAvailable                // a newer build exists; auto-install is off
+	OutcomeDeferred                 // a newer build exists; waiting for the maintenance window
+	OutcomeInstalled                // a newer build was installed
+)
+
+// Watcher polls an Updater. Build one with New.
+type Watcher struct {
+	svc         Updater
+	interval    time.Duration
+	jitter      time.Duration
+	autoInstall bool
+	window      Window
+	log         *slog.Logger
+	notifier    *notify.Notifier
+
+	now     func() time.Time
+	jitterN func(n int64) int64
+
+	// seen is the last build announced, so each one is logged at info level and sent to
+	// the notifier once.
+	seen paper.LatestInfo
+}
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithInterval sets the time between checks (default DefaultInterval, at least a
+// minute).
+func WithInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.interval = max(d, minInterval)
+		}
+	}
+}
+
+// WithJitter adds a random delay in [0, d) to every interval, so many servers started
+// together do not all hit the API at once.
+func WithJitter(d time.Duration) Option {
+	return func(w *Watcher) { w.jitter = max(d, 0) }
+}
+
+// WithAutoInstall installs new builds as they are found, but only while window is open.
+func WithAutoInstall(window Window) Option {
+	return func(w *Watcher) {
+		w.autoInstall = true
+		w.window = window
+	}
+}
+
+// WithLogger sets where the watcher reports.
+func WithLogger(l *slog.Logger) Option {
+	return func(w *Watcher) {
+		if l != nil {
+			w.log = l
+		}
+	}
+}
+
+// WithNotifier sets where new builds are announced.
+func WithNotifier(n *notify.Notifier) Option {
+	return func(w *Watcher) {
+		if n != nil {
+			w.notifier = n
+		}
+	}
+}
+
+// New returns a Watcher for svc.
+func New(svc Updater, opts ...Option) *Watcher {
+	w := &Watcher{
+		svc:      svc,
+		interval: DefaultInterval,
+		log:      logging.Discard(),
+		notifier: notify.Discard(),
+		now:      time.Now,
+		jitterN:  rand.Int64N,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Run polls immediately and then every interval until ctx is done, which is the only
+// way it returns. Cancelling ctx (e.g. on SIGTERM) also cancels an install in progress;
+// the install discards its partial download and leaves the old jar in place.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.log.Info("watching for new builds", logging.KeyEvent, logging.EventWatch,
+		"interval", w.interval, "jitter", w.jitter, "auto_install", w.autoInstall, "window", w.window.String())
+	for {
+		w.Poll(ctx)
+
+		delay := w.nextDelay()
+		w.log.Debug("next check scheduled", logging.KeyEvent, logging.EventWatch, "in", delay.Round(time.Second))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			w.log.Info("stopped watching", logging.KeyEvent, logging.EventWatch)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Watcher) nextDelay() time.Duration {
+	if w.jitter <= 0 {
+		return w.interval
+	}
+	return w.interval + time.Duration(w.jitterN(int64(w.jitter)))
+}
+
+// Poll checks once and, if allowed, installs what it finds. Failures are logged and
+// reported as OutcomeFailed rather than returned, since the next poll retries.
+func (w *Watcher) Poll(ctx context.Context) Outcome {
+	timeouts := w.svc.Timeouts()
+	checkCtx, cancel := context.WithTimeout(ctx, timeouts.Check)
+	info, err := w.svc.CheckLatest(checkCtx)
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			w.log.Warn("check failed", logging.KeyEvent, logging.EventWatch, logging.Err(err))
+		}
+		return OutcomeFailed
+	}
+	if info.UpToDate {
+		w.log.Debug("up to date", logging.KeyEvent, logging.EventWatch,
+			logging.KeyVersion, info.Version, logging.KeyBuild, info.Build)
+		return OutcomeUpToDate
+	}
+
+	level := slog.LevelDebug
+	if info.Version != w.seen.Version || info.Build != w.seen.Build {
+		level = slog.LevelInfo
+		w.seen = info
+		w.notifier.Notify(notify.Event{
+			Kind:    notify.KindNewBuild,
+			Version: info.Version,
+			Build:   info.Build,
+			Channel: string(info.Channel),
+			JarName: info.JarName,
+			Commits: notify.Commits(info.Commits),
+		})
+	}
+	w.log.Log(ctx, level, "new build available", logging.KeyEvent, logging.EventWatch,
+		logging.KeyVersion, info.Version, logging.KeyBuild, info.Build, "channel", string(info.Channel))
+
+	if !w.autoInstall {
+		return OutcomeAvailable
+	}
+	if !w.window.Contains(w.now()) {
+		w.log.Log(ctx, level, "install deferred to maintenance window", logging.KeyEvent, logging.EventWatch,
+			"window", w.window.String())
+		return OutcomeDeferred
+	}
+
+	installCtx, cancel := context.WithTimeout(ctx, timeouts.Download)
+	defer cancel()
+	err = w.svc.Install(installCtx, paper.InstallOptions{Backup: w.svc.BackupPolicy() == paper.BackupAlways})
+	switch {
+	case err == nil:
+		w.log.Info("auto-installed new build", logging.KeyEvent, logging.EventWatch,
+			logging.KeyVersion, info.Version, logging.KeyBuild, info.Build)
+		return OutcomeInstalled
+	case ctx.Err() != nil && errors.Is(err, context.Canceled):
+		w.log.Warn("install canceled", logging.KeyEvent, logging.EventWatch,
+			logging.KeyVersion, info.Version, logging.KeyBuild, info.Build)
+	default:
+		w.log.Error("auto-install failed", logging.KeyEvent, logging.EventWatch,
+			logging.KeyVersion, info.Version, logging.KeyBuild, info.Build, logging.Err(err))
+	}
+	return OutcomeFailed
+}
@@ -0,0 +1,217 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mbacalan/paper-mc-tui/internal/notify"
+	"github.com/mbacalan/paper-mc-tui/internal/paper"
+)
+
+// fakeUpdater reports latest as the newest build and records installs. If block is
+// set, Install waits for its context to be cancelled.
+type fakeUpdater struct {
+	mu       sync.Mutex
+	latest   paper.LatestInfo
+	checkErr error
+	policy   paper.BackupPolicy
+	block    bool
+	started  chan struct{}
+	installs []paper.InstallOptions
+	checks   int
+}
+
+func (f *fakeUpdater) CheckLatest(ctx context.Context) (paper.LatestInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checks++
+	return f.latest, f.checkErr
+}
+
+func (f *fakeUpdater) Install(ctx context.Context, opts paper.InstallOptions) error {
+	f.mu.Lock()
+	f.installs = append(f.installs, opts)
+	f.mu.Unlock()
+	if f.block {
+		close(f.started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	f.mu.Lock()
+	f.latest.UpToDate = true
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeUpdater) Timeouts() paper.Timeouts {
+	return paper.Timeouts{Check: time.Second, Download: time.Minute}
+}
+
+func (f *fakeUpdater) BackupPolicy() paper.BackupPolicy { return f.policy }
+
+func newBuild() paper.LatestInfo {
+	return paper.LatestInfo{Version: "26.1.2", Build: 71, JarName: "paper-26.1.2-71.jar"}
+}
+
+func TestPollOutcomes(t *testing.T) {
+	at := func(hour int) func() time.Time {
+		return func() time.Time { return time.Date(2026, 5, 20, hour, 30, 0, 0, time.Local) }
+	}
+	window, err := ParseWindow("03:00-05:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fake    *fakeUpdater
+		opts    []Option
+		now     func() time.Time
+		want    Outcome
+		install bool
+	}{
+		{"up to date", &fakeUpdater{latest: paper.LatestInfo{UpToDate: true}}, nil, at(12), OutcomeUpToDate, false},
+		{"check fails", &fakeUpdater{checkErr: errors.New("boom")}, nil, at(12), OutcomeFailed, false},
+		{"notify only", &fakeUpdater{latest: newBuild()}, nil, at(4), OutcomeAvailable, false},
+		{"outside window", &fakeUpdater{latest: newBuild()}, []Option{WithAutoInstall(window)}, at(12), OutcomeDeferred, false},
+		{"inside window", &fakeUpdater{latest: newBuild()}, []Option{WithAutoInstall(window)}, at(4), OutcomeInstalled, true},
+		{"no window", &fakeUpdater{latest: newBuild()}, []Option{WithAutoInstall(Window{})}, at(12), OutcomeInstalled, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := New(tt.fake, tt.opts...)
+			w.now = tt.now
+			if got := w.Poll(context.Background()); got != tt.want {
+				t.Errorf("Poll = %v, want %v", got, tt.want)
+			}
+			if got := len(tt.fake.installs) > 0; got != tt.install {
+				t.Errorf("installed = %v, want %v", got, tt.install)
+			}
+		})
+	}
+}
+
+func TestPollBacksUpPerPolicy(t *testing.T) {
+	for _, policy := range []paper.BackupPolicy{paper.BackupAsk, paper.BackupAlways, paper.BackupNever} {
+		f := &fakeUpdater{latest: newBuild(), policy: policy}
+		New(f, WithAutoInstall(Window{})).Poll(context.Background())
+		if got, want := f.installs[0].Backup, policy == paper.BackupAlways; got != want {
+			t.Errorf("policy %s: Backup = %v, want %v", policy, got, want)
+		}
+	}
+}
+
+func TestRunStopsAndCancelsInstall(t *testing.T) {
+	f := &fakeUpdater{latest: newBuild(), block: true, started: make(chan struct{})}
+	w := New(f, WithAutoInstall(Window{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	<-f.started
+	cancel() // as SIGTERM does
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if f.checks != 1 {
+		t.Errorf("checks = %d, want 1", f.checks)
+	}
+}
+
+func TestIntervalAndJitter(t *testing.T) {
+	w := New(&fakeUpdater{}, WithInterval(time.Second), WithJitter(10*time.Minute))
+	if w.interval != minInterval {
+		t.Errorf("interval = %v, want clamped to %v", w.interval, minInterval)
+	}
+	w.jitterN = func(n int64) int64 { return n - 1 }
+	if got, want := w.nextDelay(), minInterval+10*time.Minute-1; got != want {
+		t.Errorf("nextDelay = %v, want %v", got, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	clock := func(h, m int) time.Time { return time.Date(2026, 1, 1, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{"", clock(12, 0), true},
+		{"03:00-05:00", clock(3, 0), true},
+		{"03:00-05:00", clock(4, 59), true},
+		{"03:00-05:00", clock(5, 0), false},
+		{"03:00-05:00", clock(2, 59), false},
+		{"22:00-02:00", clock(23, 0), true},
+		{"22:00-02:00", clock(1, 0), true},
+		{"22:00-02:00", clock(12, 0), false},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", tt.spec, err)
+		}
+		if got := w.Contains(tt.at); got != tt.want {
+			t.Errorf("%q contains %s = %v, want %v", tt.spec, tt.at.Format("15:04"), got, tt.want)
+		}
+	}
+	if w, _ := ParseWindow("22:00-02:00"); w.String() != "22:00-02:00" {
+		t.Errorf("String = %q", w.String())
+	}
+	for _, bad := range []string{"03:00", "3am-5am", "03:00-03:00", "25:00-01:00"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("ParseWindow(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPollAnnouncesEachBuildOnce(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		sent = append(sent, body.Text)
+		mu.Unlock()
+	}))
+	t.Cleanup(hook.Close)
+	n, err := notify.New([]notify.Webhook{{Format: notify.FormatSlack, URL: hook.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeUpdater{latest: newBuild()}
+	f.latest.Channel = "STABLE"
+	w := New(f, WithNotifier(n))
+	w.Poll(context.Background())
+	w.Poll(context.Background())
+	f.latest.Build = 72
+	w.Poll(context.Background())
+	n.Wait(5 * time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(sent)
+	want := []string{
+		"New Paper build available: 26.1.2 build 71 (STABLE)",
+		"New Paper build available: 26.1.2 build 72 (STABLE)",
+	}
+	if !slices.Equal(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+}
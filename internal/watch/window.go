@@ -0,0 +1,73 @@
+package watch
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily maintenance window in local time, e.g. 03:00-05:00. It may wrap
+// midnight (22:00-02:00). The zero Window is always open.
+type Window struct {
+	start, end time.Duration // offsets from midnight; start == end means always
+}
+
+// ParseWindow parses "HH:MM-HH:MM". The empty string is the always-open window.
+func ParseWindow(s string) (Window, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Window{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid maintenance window %q (want HH:MM-HH:MM)", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid maintenance window %q: %w", s, err)
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("invalid maintenance window %q: start equals end", s)
+	}
+	return Window{start: start, end: end}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Always reports whether the window is always open.
+func (w Window) Always() bool { return w.start == w.end }
+
+// Contains reports whether t (in its own location) falls inside the window. The start
+// is inclusive and the end exclusive.
+func (w Window) Contains(t time.Time) bool {
+	if w.Always() {
+		return true
+	}
+	h, m, s := t.Clock()
+	at := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if w.start < w.end {
+		return at >= w.start && at < w.end
+	}
+	return at >= w.start || at < w.end // wraps midnight
+}
+
+// String returns the window as "HH:MM-HH:MM", or "any time".
+func (w Window) String() string {
+	if w.Always() {
+		return "any time"
+	}
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}